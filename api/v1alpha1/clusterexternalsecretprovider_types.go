@@ -0,0 +1,75 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterExternalSecretProviderSpec defines the desired state of ClusterExternalSecretProvider
+type ClusterExternalSecretProviderSpec struct {
+	ExternalSecretProviderSpec `json:",inline"`
+
+	// AllowedNamespaces are the namespaces whose objects can use the provider
+	AllowedNamespaces []string `json:"allowedNamespaces,omitempty"`
+	// NamespaceSelector selects the namespaces whose objects can use the provider, in addition to
+	// AllowedNamespaces. When neither is set no namespace can use the provider, an empty selector
+	// selects every namespace.
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Provider",type=string,JSONPath=`.spec.provider`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Identity",type=string,JSONPath=`.status.identity`,priority=1
+//+kubebuilder:printcolumn:name="Last Check",type=date,JSONPath=`.status.lastCheckTime`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ClusterExternalSecretProvider is a provider shared by the namespaces it allows, its credentials
+// are read from the namespace of the operator
+type ClusterExternalSecretProvider struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterExternalSecretProviderSpec `json:"spec,omitempty"`
+	Status ExternalSecretProviderStatus      `json:"status,omitempty"`
+}
+
+// ProviderSpec returns the spec of the provider shared with ExternalSecretProvider
+func (p *ClusterExternalSecretProvider) ProviderSpec() *ExternalSecretProviderSpec {
+	return &p.Spec.ExternalSecretProviderSpec
+}
+
+// ProviderStatus returns the status of the provider
+func (p *ClusterExternalSecretProvider) ProviderStatus() *ExternalSecretProviderStatus {
+	return &p.Status
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterExternalSecretProviderList contains a list of ClusterExternalSecretProvider
+type ClusterExternalSecretProviderList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterExternalSecretProvider `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterExternalSecretProvider{}, &ClusterExternalSecretProviderList{})
+}
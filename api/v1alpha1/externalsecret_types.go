@@ -36,7 +36,16 @@ type ExternalSecretSpec struct {
 	External       bool              `json:"external,omitempty"`
 	RecoveryWindow int64             `json:"recoveryWindow,omitempty"`
 	Provider       string            `json:"provider"`
-	ProviderSpec   map[string]string `json:"providerSpec,omitempty"`
+	// ProviderKind is the kind of the provider, an ExternalSecretProvider of the namespace of the
+	// secret or a ClusterExternalSecretProvider allowing the namespace
+	//+kubebuilder:validation:Enum=ExternalSecretProvider;ClusterExternalSecretProvider
+	//+kubebuilder:default=ExternalSecretProvider
+	ProviderKind string            `json:"providerKind,omitempty"`
+	ProviderSpec map[string]string `json:"providerSpec,omitempty"`
+	// Tags are set on the resources created for the secret, next to the ownership tags of the
+	// operator, by the providers supporting tags
+	//+kubebuilder:validation:XValidation:rule="self.all(k, !k.startsWith('secretsbeam.orbitops.dev/'))",message="the secretsbeam.orbitops.dev/ prefix is reserved for the ownership tags"
+	Tags map[string]string `json:"tags,omitempty"`
 }
 
 // ExternalSecretStatus defines the observed state of Secret
@@ -50,7 +59,7 @@ type ExternalSecretStatus struct {
 	IsRandom       bool               `json:"isRandom"`
 	NextRotateDate *metav1.Time       `json:"nextRotateDate,omitempty"`
 	RandomGenRegex *string            `json:"randomRe,omitempty"`
-	Provider       map[string]string  `json:"provider"`
+	Provider       map[string]string  `json:"provider,omitempty"`
 }
 
 //+kubebuilder:object:root=true
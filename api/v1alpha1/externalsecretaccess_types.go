@@ -23,23 +23,46 @@ import (
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
+const (
+	// ServiceAccountPolicyNone leaves the service accounts of the subjects to their owners
+	ServiceAccountPolicyNone = "None"
+	// ServiceAccountPolicyPatch annotates the existing service accounts of the subjects
+	ServiceAccountPolicyPatch = "Patch"
+	// ServiceAccountPolicyCreate annotates the service accounts of the subjects and creates the missing
+	// ones of the namespace of the access
+	ServiceAccountPolicyCreate = "Create"
+)
+
 // ExternalSecretAccessSpec defines the desired state of ExternalSecretAccess
 type ExternalSecretAccessSpec struct {
 	// AccessSubjects is a list of service account refs that can access this secret
 	AccessSubjects []SecretAccessSubject `json:"subjects,omitempty"`
 	// ExternalSecretName is the name of the secret access will be created for
 	SecretName string `json:"secretName"`
+	// Tags are set on the resources created for the access, next to the ownership tags of the
+	// operator, by the providers supporting tags
+	//+kubebuilder:validation:XValidation:rule="self.all(k, !k.startsWith('secretsbeam.orbitops.dev/'))",message="the secretsbeam.orbitops.dev/ prefix is reserved for the ownership tags"
+	Tags map[string]string `json:"tags,omitempty"`
+	// ServiceAccountPolicy is how the service accounts of the subjects get the annotations of their
+	// provider identity, one of None, Patch or Create
+	//+kubebuilder:validation:Enum=None;Patch;Create
+	//+kubebuilder:default=Patch
+	ServiceAccountPolicy string `json:"serviceAccountPolicy,omitempty"`
 }
 
 // ExternalSecretAccessStatus defines the observed state of ExternalSecretAccess
 type ExternalSecretAccessStatus struct {
 	// Conditions represent the latest available observations of an object's state
-	Conditions               []metav1.Condition    `json:"conditions"`
-	Created                  bool                  `json:"created"`
-	Subjects                 []SecretAccessSubject `json:"subjects"`
-	ProviderType             string                `json:"providerType"`
-	ServiceAccountAnnotation *string               `json:"serviceAccountAnnotation,omitempty"`
-	Provider                 map[string]string     `json:"provider"`
+	Conditions   []metav1.Condition    `json:"conditions"`
+	Created      bool                  `json:"created"`
+	Subjects     []SecretAccessSubject `json:"subjects,omitempty"`
+	ProviderType string                `json:"providerType"`
+	// ProviderKind is the kind of the provider named by ProviderType
+	ProviderKind string `json:"providerKind,omitempty"`
+	// ServiceAccountAnnotation is the annotation applied to the service accounts of the subjects,
+	// <key>=<value>, from the ServiceAccountAnnotation entry of the provider status
+	ServiceAccountAnnotation *string           `json:"serviceAccountAnnotation,omitempty"`
+	Provider                 map[string]string `json:"provider,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -26,36 +26,99 @@ const (
 	SecretProviderAws    = "aws"
 	SecretProviderGcp    = "gcp"
 	SecretProviderCustom = "custom"
+	SecretProviderVault  = "vault"
+	SecretProviderAzure  = "azure"
+	SecretProviderGrpc   = "grpc"
+	SecretProviderExec   = "exec"
+	SecretProviderFile   = "file"
 )
 
-type CustomProviderSpec struct {
-	Url string `json:"url"`
-}
+const (
+	// ProviderKindNamespaced references an ExternalSecretProvider of the namespace of the object
+	ProviderKindNamespaced = "ExternalSecretProvider"
+	// ProviderKindCluster references a ClusterExternalSecretProvider
+	ProviderKindCluster = "ClusterExternalSecretProvider"
+)
+
+//+kubebuilder:validation:XValidation:rule="!has(self.aws) || self.provider == 'aws'",message="aws is only valid for provider aws"
+//+kubebuilder:validation:XValidation:rule="!has(self.gcp) || self.provider == 'gcp'",message="gcp is only valid for provider gcp"
+//+kubebuilder:validation:XValidation:rule="!has(self.vault) || self.provider == 'vault'",message="vault is only valid for provider vault"
+//+kubebuilder:validation:XValidation:rule="!has(self.custom) || self.provider == 'custom'",message="custom is only valid for provider custom"
+//+kubebuilder:validation:XValidation:rule="!has(self.config) || !(has(self.aws) || has(self.gcp) || has(self.vault) || has(self.custom))",message="config cannot be combined with a typed provider config"
 
-type AwsProviderSpec struct {
-	Oidc      *AwsOIDCSpec `json:"oidc,omitempty"`
-	AccountID string       `json:"accountID"`
+// ExternalSecretProviderSpec defines the desired state of SecretProvider
+type ExternalSecretProviderSpec struct {
+	// Provider is the type of the provider, e.g. aws
+	//+kubebuilder:validation:MinLength=1
+	Provider string `json:"provider"`
+	// Config is the untyped config of the provider. It is deprecated for the types with a typed
+	// config below and remains the config of the other types.
+	Config map[string]string `json:"config,omitempty"`
+
+	Aws    *AwsProviderSpec    `json:"aws,omitempty"`
+	Gcp    *GcpProviderSpec    `json:"gcp,omitempty"`
+	Vault  *VaultProviderSpec  `json:"vault,omitempty"`
+	Custom *CustomProviderSpec `json:"custom,omitempty"`
+
+	// HealthCheckInterval is how often the credentials and connectivity of the provider are
+	// checked, defaults to 5m. 0s disables periodic checks, the provider is then only checked
+	// when its spec changes.
+	HealthCheckInterval *metav1.Duration `json:"healthCheckInterval,omitempty"`
 }
 
-type AwsOIDCSpec struct {
-	ProviderARN string `json:"providerARN"`
+// HasTypedConfig reports whether the provider type has a typed config, in which case Config is deprecated
+func (s *ExternalSecretProviderSpec) HasTypedConfig() bool {
+	switch s.Provider {
+	case SecretProviderAws, SecretProviderGcp, SecretProviderVault, SecretProviderCustom:
+		return true
+	}
+
+	return false
 }
 
-// ExternalSecretProviderSpec defines the desired state of SecretProvider
-type ExternalSecretProviderSpec struct {
-	// Foo is an example field of SecretProvider. Edit secretprovider_types.go to remove/update
-	Provider string            `json:"provider"`
-	Config   map[string]string `json:"config,omitempty"`
+// ProviderConfig returns the config passed to the provider, from its typed config when set
+func (s *ExternalSecretProviderSpec) ProviderConfig() map[string]string {
+	switch {
+	case s.Aws != nil:
+		return s.Aws.Config()
+	case s.Gcp != nil:
+		return s.Gcp.Config()
+	case s.Vault != nil:
+		return s.Vault.Config()
+	case s.Custom != nil:
+		return s.Custom.Config()
+	}
+
+	return s.Config
 }
 
 // ExternalSecretProviderStatus defines the observed state of SecretProvider
 type ExternalSecretProviderStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// Conditions hold the Ready condition, false when the provider type is unknown, its config is
+	// invalid, it failed to initialize or its health check fails, and the Degraded condition, true
+	// while the health check of an initialized provider fails
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// Identity is the principal the provider authenticates as, e.g. an IAM role ARN
+	Identity string `json:"identity,omitempty"`
+	// Account is the account, project or subscription the provider works in
+	Account string `json:"account,omitempty"`
+	Region  string `json:"region,omitempty"`
+	// LastCheckTime is when the provider was last checked
+	LastCheckTime *metav1.Time `json:"lastCheckTime,omitempty"`
+	// Message is the error of the last check, empty when the provider is healthy
+	Message string `json:"message,omitempty"`
+	// Capabilities are the features supported by the provider, e.g. RecoveryWindow. Objects asking
+	// for the others are reported as Unsupported.
+	Capabilities []string `json:"capabilities,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Provider",type=string,JSONPath=`.spec.provider`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Identity",type=string,JSONPath=`.status.identity`,priority=1
+//+kubebuilder:printcolumn:name="Last Check",type=date,JSONPath=`.status.lastCheckTime`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // ExternalSecretProvider is the Schema for the secretproviders API
 type ExternalSecretProvider struct {
@@ -66,6 +129,16 @@ type ExternalSecretProvider struct {
 	Status ExternalSecretProviderStatus `json:"status,omitempty"`
 }
 
+// ProviderSpec returns the spec of the provider shared with ClusterExternalSecretProvider
+func (p *ExternalSecretProvider) ProviderSpec() *ExternalSecretProviderSpec {
+	return &p.Spec
+}
+
+// ProviderStatus returns the status of the provider
+func (p *ExternalSecretProvider) ProviderStatus() *ExternalSecretProviderStatus {
+	return &p.Status
+}
+
 //+kubebuilder:object:root=true
 
 // SecretProviderList contains a list of SecretProvider
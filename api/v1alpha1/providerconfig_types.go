@@ -0,0 +1,317 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+//+kubebuilder:validation:XValidation:rule="!has(self.accessMode) || self.accessMode != 'podIdentity' || has(self.podIdentity)",message="podIdentity is required by the podIdentity access mode"
+//+kubebuilder:validation:XValidation:rule="!has(self.podIdentity) || self.accessMode == 'podIdentity'",message="podIdentity is only valid for the podIdentity access mode"
+
+// AwsProviderSpec is the config of the aws provider
+type AwsProviderSpec struct {
+	// Oidc is the OIDC provider of the cluster, trusted by the roles created for accesses in the irsa access mode
+	Oidc *AwsOIDCSpec `json:"oidc,omitempty"`
+	// AccessMode is how accesses are granted. irsa and podIdentity create a role per access, irsa
+	// annotates the service accounts with the role and podIdentity associates the role with them with
+	// EKS Pod Identity. resourcePolicy grants the provider identifiers of the accesses in the resource
+	// policies of the secrets, without roles.
+	//+kubebuilder:validation:Enum=irsa;podIdentity;resourcePolicy
+	//+kubebuilder:default=irsa
+	AccessMode string `json:"accessMode,omitempty"`
+	// PodIdentity is the config of the podIdentity access mode
+	PodIdentity *AwsPodIdentitySpec `json:"podIdentity,omitempty"`
+	// IAM configures the roles and policies created for accesses
+	IAM *AwsIAMSpec `json:"iam,omitempty"`
+	// KMS manages customer managed KMS keys encrypting the secrets
+	KMS *AwsKMSSpec `json:"kms,omitempty"`
+	// Backend is where secrets are stored, secretsmanager or ssm for SecureString parameters in Parameter Store
+	//+kubebuilder:validation:Enum=secretsmanager;ssm
+	//+kubebuilder:default=secretsmanager
+	Backend string `json:"backend,omitempty"`
+	// Endpoint overrides the endpoint of every AWS API, e.g. for localstack
+	//+kubebuilder:validation:Pattern=`^https?://`
+	Endpoint string `json:"endpoint,omitempty"`
+	// Region defaults to the region of the operator environment, e.g. AWS_REGION
+	//+kubebuilder:validation:Pattern=`^[a-z]{2}(-[a-z]+)+-[0-9]+$`
+	Region string `json:"region,omitempty"`
+	// Credentials default to the credentials of the operator environment
+	Credentials *AwsCredentialsSpec `json:"credentials,omitempty"`
+	// AdoptUntagged lets the provider take over the existing resources without ownership tags, e.g.
+	// created before tagging, instead of refusing to change them
+	AdoptUntagged bool `json:"adoptUntagged,omitempty"`
+}
+
+type AwsOIDCSpec struct {
+	// ProviderARN is the ARN of the IAM OIDC identity provider of the cluster
+	//+kubebuilder:validation:Pattern=`^arn:aws[a-z-]*:iam::[0-9]{12}:oidc-provider/.+$`
+	ProviderARN string `json:"providerARN"`
+}
+
+type AwsPodIdentitySpec struct {
+	// ClusterName is the EKS cluster the pod identity associations are created in
+	//+kubebuilder:validation:MinLength=1
+	ClusterName string `json:"clusterName"`
+}
+
+type AwsIAMSpec struct {
+	// RoleNameTemplate is the Go template of the names of the roles and policies, with the
+	// .Namespace, .Name, .ClusterID and .ClusterName fields. Names over 64 characters are truncated
+	// and suffixed with a hash. Defaults to secretsbeam-access-{{ .Namespace }}-{{ .Name }}
+	//+kubebuilder:validation:MinLength=1
+	RoleNameTemplate string `json:"roleNameTemplate,omitempty"`
+	// Path is the IAM path of the roles and policies, defaults to /
+	//+kubebuilder:validation:Pattern=`^/(.+/)?$`
+	Path string `json:"path,omitempty"`
+	// PermissionsBoundaryARN is the ARN of the policy set as permissions boundary of the roles
+	//+kubebuilder:validation:Pattern=`^arn:aws[a-z-]*:iam::([0-9]{12}|aws):policy/.+$`
+	PermissionsBoundaryARN string `json:"permissionsBoundaryARN,omitempty"`
+	// MaxSessionDuration is the maximum session duration of the roles, between 1h and 12h
+	MaxSessionDuration *metav1.Duration `json:"maxSessionDuration,omitempty"`
+	// ManagedPolicyARNs are attached to the roles on top of the policy reading the secret
+	ManagedPolicyARNs []string `json:"managedPolicyARNs,omitempty"`
+	// RoleScope creates a role per access, assumed by all its subjects, or a role per service
+	// account subject, shared by the accesses granting the service account with an inline policy each
+	//+kubebuilder:validation:Enum=access;serviceAccount
+	//+kubebuilder:default=access
+	RoleScope string `json:"roleScope,omitempty"`
+	// ServiceAccountRoleNameTemplate is the Go template of the names of the roles of service
+	// accounts, with the fields of RoleNameTemplate for the service account. Defaults to
+	// secretsbeam-sa-{{ .Namespace }}-{{ .Name }}
+	//+kubebuilder:validation:MinLength=1
+	ServiceAccountRoleNameTemplate string `json:"serviceAccountRoleNameTemplate,omitempty"`
+}
+
+type AwsKMSSpec struct {
+	// Scope creates a KMS key per namespace, shared by its secrets, or per secret, deleted with the
+	// secret. Secrets with a KmsKeyArn in their providerSpec keep their key.
+	//+kubebuilder:validation:Enum=namespace;secret
+	Scope string `json:"scope"`
+	// AliasPrefix prefixes the aliases of the keys, followed by the namespace and for keys of secrets
+	// by the name of the secret. Defaults to alias/secretsbeam/
+	//+kubebuilder:validation:Pattern=`^alias/[a-zA-Z0-9/_-]*/$`
+	AliasPrefix string `json:"aliasPrefix,omitempty"`
+}
+
+//+kubebuilder:validation:XValidation:rule="!(has(self.secretRef) && has(self.webIdentity))",message="secretRef and webIdentity are mutually exclusive"
+
+// AwsCredentialsSpec are the credentials of the aws provider. The role of AssumeRole is assumed
+// with the keys of SecretRef, the web identity or the credentials of the operator environment.
+type AwsCredentialsSpec struct {
+	// SecretRef references a Secret in the provider namespace holding static access keys
+	SecretRef *AwsSecretRefSpec `json:"secretRef,omitempty"`
+	// WebIdentity assumes a role with a web identity token, e.g. a projected service account token
+	WebIdentity *AwsWebIdentitySpec `json:"webIdentity,omitempty"`
+	AssumeRole  *AwsAssumeRoleSpec  `json:"assumeRole,omitempty"`
+}
+
+type AwsSecretRefSpec struct {
+	//+kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// AccessKeyIDKey defaults to access-key-id
+	AccessKeyIDKey string `json:"accessKeyIDKey,omitempty"`
+	// SecretAccessKeyKey defaults to secret-access-key
+	SecretAccessKeyKey string `json:"secretAccessKeyKey,omitempty"`
+	// SessionTokenKey is read when set, for temporary keys
+	SessionTokenKey string `json:"sessionTokenKey,omitempty"`
+}
+
+type AwsWebIdentitySpec struct {
+	//+kubebuilder:validation:Pattern=`^arn:aws[a-z-]*:iam::[0-9]{12}:role/.+$`
+	RoleARN string `json:"roleARN"`
+	//+kubebuilder:validation:MinLength=1
+	TokenFile string `json:"tokenFile"`
+}
+
+type AwsAssumeRoleSpec struct {
+	//+kubebuilder:validation:Pattern=`^arn:aws[a-z-]*:iam::[0-9]{12}:role/.+$`
+	RoleARN    string `json:"roleARN"`
+	ExternalID string `json:"externalID,omitempty"`
+	// SessionName is the session name of the assumed roles, defaults to secretsbeam-operator
+	SessionName string `json:"sessionName,omitempty"`
+}
+
+// Config returns the untyped config read by the aws provider
+func (s *AwsProviderSpec) Config() map[string]string {
+	config := map[string]string{}
+	if s.Oidc != nil {
+		config["oidcProviderArn"] = s.Oidc.ProviderARN
+	}
+	setIfNotEmpty(config, "accessMode", s.AccessMode)
+	if s.PodIdentity != nil {
+		config["clusterName"] = s.PodIdentity.ClusterName
+	}
+	if iam := s.IAM; iam != nil {
+		setIfNotEmpty(config, "roleNameTemplate", iam.RoleNameTemplate)
+		setIfNotEmpty(config, "iamPath", iam.Path)
+		setIfNotEmpty(config, "permissionsBoundaryArn", iam.PermissionsBoundaryARN)
+		if iam.MaxSessionDuration != nil {
+			config["maxSessionDuration"] = iam.MaxSessionDuration.Duration.String()
+		}
+		setIfNotEmpty(config, "managedPolicyArns", strings.Join(iam.ManagedPolicyARNs, ","))
+		setIfNotEmpty(config, "roleScope", iam.RoleScope)
+		setIfNotEmpty(config, "serviceAccountRoleNameTemplate", iam.ServiceAccountRoleNameTemplate)
+	}
+	if kms := s.KMS; kms != nil {
+		config["kmsKeyScope"] = kms.Scope
+		setIfNotEmpty(config, "kmsKeyAliasPrefix", kms.AliasPrefix)
+	}
+	setIfNotEmpty(config, "backend", s.Backend)
+	setIfNotEmpty(config, "endpoint", s.Endpoint)
+	setIfNotEmpty(config, "region", s.Region)
+	if s.AdoptUntagged {
+		config["adoptUntagged"] = strconv.FormatBool(s.AdoptUntagged)
+	}
+
+	if creds := s.Credentials; creds != nil {
+		if ref := creds.SecretRef; ref != nil {
+			config["accessKeySecretName"] = ref.Name
+			setIfNotEmpty(config, "accessKeyIDKey", ref.AccessKeyIDKey)
+			setIfNotEmpty(config, "secretAccessKeyKey", ref.SecretAccessKeyKey)
+			setIfNotEmpty(config, "sessionTokenKey", ref.SessionTokenKey)
+		}
+		if web := creds.WebIdentity; web != nil {
+			config["webIdentityRoleArn"] = web.RoleARN
+			config["webIdentityTokenFile"] = web.TokenFile
+		}
+		if role := creds.AssumeRole; role != nil {
+			config["assumeRoleArn"] = role.RoleARN
+			setIfNotEmpty(config, "externalID", role.ExternalID)
+			setIfNotEmpty(config, "roleSessionName", role.SessionName)
+		}
+	}
+
+	return config
+}
+
+// GcpProviderSpec is the config of the gcp provider
+type GcpProviderSpec struct {
+	//+kubebuilder:validation:MinLength=1
+	ProjectID string `json:"projectID"`
+	// WorkloadIdentityPool defaults to <projectID>.svc.id.goog
+	WorkloadIdentityPool string `json:"workloadIdentityPool,omitempty"`
+	// SecretManagerEndpoint overrides the Secret Manager API endpoint, e.g. for emulators
+	//+kubebuilder:validation:Pattern=`^https?://`
+	SecretManagerEndpoint string `json:"secretManagerEndpoint,omitempty"`
+	// IamEndpoint overrides the IAM API endpoint
+	//+kubebuilder:validation:Pattern=`^https?://`
+	IamEndpoint string `json:"iamEndpoint,omitempty"`
+}
+
+// Config returns the untyped config read by the gcp provider
+func (s *GcpProviderSpec) Config() map[string]string {
+	config := map[string]string{"projectID": s.ProjectID}
+	setIfNotEmpty(config, "workloadIdentityPool", s.WorkloadIdentityPool)
+	setIfNotEmpty(config, "secretManagerEndpoint", s.SecretManagerEndpoint)
+	setIfNotEmpty(config, "iamEndpoint", s.IamEndpoint)
+
+	return config
+}
+
+// VaultProviderSpec is the config of the vault provider
+type VaultProviderSpec struct {
+	//+kubebuilder:validation:Pattern=`^https?://`
+	Address string `json:"address"`
+	// Namespace is the Vault enterprise namespace
+	Namespace string `json:"namespace,omitempty"`
+	// MountPath is the path of the KV v2 engine, defaults to secret
+	MountPath string `json:"mountPath,omitempty"`
+	// AuthMountPath is the path of the kubernetes auth method, defaults to kubernetes
+	AuthMountPath string `json:"authMountPath,omitempty"`
+	// Role is the kubernetes auth role the operator logs in with. When empty VAULT_TOKEN is used.
+	Role string `json:"role,omitempty"`
+	// TokenPath is the service account token used to log in
+	TokenPath string `json:"tokenPath,omitempty"`
+	// RoleTokenTTL is the token ttl of the roles created for accesses, defaults to 1h
+	//+kubebuilder:validation:Pattern=`^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+	RoleTokenTTL       string `json:"roleTokenTTL,omitempty"`
+	CACertFile         string `json:"caCertFile,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+}
+
+// Config returns the untyped config read by the vault provider
+func (s *VaultProviderSpec) Config() map[string]string {
+	config := map[string]string{"address": s.Address}
+	setIfNotEmpty(config, "namespace", s.Namespace)
+	setIfNotEmpty(config, "mountPath", s.MountPath)
+	setIfNotEmpty(config, "authMountPath", s.AuthMountPath)
+	setIfNotEmpty(config, "role", s.Role)
+	setIfNotEmpty(config, "tokenPath", s.TokenPath)
+	setIfNotEmpty(config, "roleTokenTTL", s.RoleTokenTTL)
+	setIfNotEmpty(config, "caCertFile", s.CACertFile)
+	if s.InsecureSkipVerify {
+		config["insecureSkipVerify"] = strconv.FormatBool(s.InsecureSkipVerify)
+	}
+
+	return config
+}
+
+//+kubebuilder:validation:XValidation:rule="!has(self.tokenSecretKey) || has(self.tokenSecretName)",message="tokenSecretKey requires tokenSecretName"
+//+kubebuilder:validation:XValidation:rule="!has(self.caSecretKey) || has(self.caSecretName)",message="caSecretKey requires caSecretName"
+
+// CustomProviderSpec is the config of the custom provider
+type CustomProviderSpec struct {
+	//+kubebuilder:validation:Pattern=`^https?://`
+	Url string `json:"url"`
+	// Timeout is applied to every request, defaults to 30s
+	//+kubebuilder:validation:Pattern=`^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+	Timeout string `json:"timeout,omitempty"`
+	// TokenSecretName references a Secret in the provider namespace holding a bearer token
+	TokenSecretName string `json:"tokenSecretName,omitempty"`
+	TokenSecretKey  string `json:"tokenSecretKey,omitempty"`
+	// CASecretName references a Secret in the provider namespace holding the CA bundle of the service
+	CASecretName       string `json:"caSecretName,omitempty"`
+	CASecretKey        string `json:"caSecretKey,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+	// Capabilities are the features the service supports, the operator does not send it requests
+	// for the others
+	Capabilities []ProviderCapability `json:"capabilities,omitempty"`
+}
+
+// ProviderCapability names an optional feature of a provider
+// +kubebuilder:validation:Enum=RecoveryWindow;AccessControl
+type ProviderCapability string
+
+// Config returns the untyped config read by the custom provider
+func (s *CustomProviderSpec) Config() map[string]string {
+	config := map[string]string{"url": s.Url}
+	setIfNotEmpty(config, "timeout", s.Timeout)
+	setIfNotEmpty(config, "tokenSecretName", s.TokenSecretName)
+	setIfNotEmpty(config, "tokenSecretKey", s.TokenSecretKey)
+	setIfNotEmpty(config, "caSecretName", s.CASecretName)
+	setIfNotEmpty(config, "caSecretKey", s.CASecretKey)
+	if s.InsecureSkipVerify {
+		config["insecureSkipVerify"] = strconv.FormatBool(s.InsecureSkipVerify)
+	}
+	capabilities := make([]string, 0, len(s.Capabilities))
+	for _, capability := range s.Capabilities {
+		capabilities = append(capabilities, string(capability))
+	}
+	setIfNotEmpty(config, "capabilities", strings.Join(capabilities, ","))
+
+	return config
+}
+
+func setIfNotEmpty(config map[string]string, key, value string) {
+	if value != "" {
+		config[key] = value
+	}
+}
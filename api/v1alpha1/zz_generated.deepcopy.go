@@ -25,6 +25,91 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AwsAssumeRoleSpec) DeepCopyInto(out *AwsAssumeRoleSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AwsAssumeRoleSpec.
+func (in *AwsAssumeRoleSpec) DeepCopy() *AwsAssumeRoleSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AwsAssumeRoleSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AwsCredentialsSpec) DeepCopyInto(out *AwsCredentialsSpec) {
+	*out = *in
+	if in.SecretRef != nil {
+		in, out := &in.SecretRef, &out.SecretRef
+		*out = new(AwsSecretRefSpec)
+		**out = **in
+	}
+	if in.WebIdentity != nil {
+		in, out := &in.WebIdentity, &out.WebIdentity
+		*out = new(AwsWebIdentitySpec)
+		**out = **in
+	}
+	if in.AssumeRole != nil {
+		in, out := &in.AssumeRole, &out.AssumeRole
+		*out = new(AwsAssumeRoleSpec)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AwsCredentialsSpec.
+func (in *AwsCredentialsSpec) DeepCopy() *AwsCredentialsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AwsCredentialsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AwsIAMSpec) DeepCopyInto(out *AwsIAMSpec) {
+	*out = *in
+	if in.MaxSessionDuration != nil {
+		in, out := &in.MaxSessionDuration, &out.MaxSessionDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.ManagedPolicyARNs != nil {
+		in, out := &in.ManagedPolicyARNs, &out.ManagedPolicyARNs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AwsIAMSpec.
+func (in *AwsIAMSpec) DeepCopy() *AwsIAMSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AwsIAMSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AwsKMSSpec) DeepCopyInto(out *AwsKMSSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AwsKMSSpec.
+func (in *AwsKMSSpec) DeepCopy() *AwsKMSSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AwsKMSSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AwsOIDCSpec) DeepCopyInto(out *AwsOIDCSpec) {
 	*out = *in
@@ -40,6 +125,21 @@ func (in *AwsOIDCSpec) DeepCopy() *AwsOIDCSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AwsPodIdentitySpec) DeepCopyInto(out *AwsPodIdentitySpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AwsPodIdentitySpec.
+func (in *AwsPodIdentitySpec) DeepCopy() *AwsPodIdentitySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AwsPodIdentitySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AwsProviderSpec) DeepCopyInto(out *AwsProviderSpec) {
 	*out = *in
@@ -48,6 +148,26 @@ func (in *AwsProviderSpec) DeepCopyInto(out *AwsProviderSpec) {
 		*out = new(AwsOIDCSpec)
 		**out = **in
 	}
+	if in.PodIdentity != nil {
+		in, out := &in.PodIdentity, &out.PodIdentity
+		*out = new(AwsPodIdentitySpec)
+		**out = **in
+	}
+	if in.IAM != nil {
+		in, out := &in.IAM, &out.IAM
+		*out = new(AwsIAMSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.KMS != nil {
+		in, out := &in.KMS, &out.KMS
+		*out = new(AwsKMSSpec)
+		**out = **in
+	}
+	if in.Credentials != nil {
+		in, out := &in.Credentials, &out.Credentials
+		*out = new(AwsCredentialsSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AwsProviderSpec.
@@ -60,9 +180,129 @@ func (in *AwsProviderSpec) DeepCopy() *AwsProviderSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AwsSecretRefSpec) DeepCopyInto(out *AwsSecretRefSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AwsSecretRefSpec.
+func (in *AwsSecretRefSpec) DeepCopy() *AwsSecretRefSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AwsSecretRefSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AwsWebIdentitySpec) DeepCopyInto(out *AwsWebIdentitySpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AwsWebIdentitySpec.
+func (in *AwsWebIdentitySpec) DeepCopy() *AwsWebIdentitySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AwsWebIdentitySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterExternalSecretProvider) DeepCopyInto(out *ClusterExternalSecretProvider) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterExternalSecretProvider.
+func (in *ClusterExternalSecretProvider) DeepCopy() *ClusterExternalSecretProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterExternalSecretProvider)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterExternalSecretProvider) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterExternalSecretProviderList) DeepCopyInto(out *ClusterExternalSecretProviderList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClusterExternalSecretProvider, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterExternalSecretProviderList.
+func (in *ClusterExternalSecretProviderList) DeepCopy() *ClusterExternalSecretProviderList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterExternalSecretProviderList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterExternalSecretProviderList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterExternalSecretProviderSpec) DeepCopyInto(out *ClusterExternalSecretProviderSpec) {
+	*out = *in
+	in.ExternalSecretProviderSpec.DeepCopyInto(&out.ExternalSecretProviderSpec)
+	if in.AllowedNamespaces != nil {
+		in, out := &in.AllowedNamespaces, &out.AllowedNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterExternalSecretProviderSpec.
+func (in *ClusterExternalSecretProviderSpec) DeepCopy() *ClusterExternalSecretProviderSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterExternalSecretProviderSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CustomProviderSpec) DeepCopyInto(out *CustomProviderSpec) {
 	*out = *in
+	if in.Capabilities != nil {
+		in, out := &in.Capabilities, &out.Capabilities
+		*out = make([]ProviderCapability, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CustomProviderSpec.
@@ -171,6 +411,13 @@ func (in *ExternalSecretAccessSpec) DeepCopyInto(out *ExternalSecretAccessSpec)
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExternalSecretAccessSpec.
@@ -262,7 +509,7 @@ func (in *ExternalSecretProvider) DeepCopyInto(out *ExternalSecretProvider) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExternalSecretProvider.
@@ -325,6 +572,31 @@ func (in *ExternalSecretProviderSpec) DeepCopyInto(out *ExternalSecretProviderSp
 			(*out)[key] = val
 		}
 	}
+	if in.Aws != nil {
+		in, out := &in.Aws, &out.Aws
+		*out = new(AwsProviderSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Gcp != nil {
+		in, out := &in.Gcp, &out.Gcp
+		*out = new(GcpProviderSpec)
+		**out = **in
+	}
+	if in.Vault != nil {
+		in, out := &in.Vault, &out.Vault
+		*out = new(VaultProviderSpec)
+		**out = **in
+	}
+	if in.Custom != nil {
+		in, out := &in.Custom, &out.Custom
+		*out = new(CustomProviderSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HealthCheckInterval != nil {
+		in, out := &in.HealthCheckInterval, &out.HealthCheckInterval
+		*out = new(v1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExternalSecretProviderSpec.
@@ -340,6 +612,22 @@ func (in *ExternalSecretProviderSpec) DeepCopy() *ExternalSecretProviderSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ExternalSecretProviderStatus) DeepCopyInto(out *ExternalSecretProviderStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastCheckTime != nil {
+		in, out := &in.LastCheckTime, &out.LastCheckTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Capabilities != nil {
+		in, out := &in.Capabilities, &out.Capabilities
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExternalSecretProviderStatus.
@@ -377,6 +665,13 @@ func (in *ExternalSecretSpec) DeepCopyInto(out *ExternalSecretSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExternalSecretSpec.
@@ -431,6 +726,21 @@ func (in *ExternalSecretStatus) DeepCopy() *ExternalSecretStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GcpProviderSpec) DeepCopyInto(out *GcpProviderSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GcpProviderSpec.
+func (in *GcpProviderSpec) DeepCopy() *GcpProviderSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GcpProviderSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RandomSecretSpec) DeepCopyInto(out *RandomSecretSpec) {
 	*out = *in
@@ -505,3 +815,18 @@ func (in *SecretAccessSubjectServiceAccount) DeepCopy() *SecretAccessSubjectServ
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VaultProviderSpec) DeepCopyInto(out *VaultProviderSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VaultProviderSpec.
+func (in *VaultProviderSpec) DeepCopy() *VaultProviderSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(VaultProviderSpec)
+	in.DeepCopyInto(out)
+	return out
+}
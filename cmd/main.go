@@ -26,10 +26,13 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	"k8s.io/client-go/rest"
 
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
@@ -38,6 +41,9 @@ import (
 
 	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
 	"github.com/tiagoposse/secretsbeam-operator/internal/controller"
+	"github.com/tiagoposse/secretsbeam-operator/internal/version"
+	// Register the provider types shipped with the operator
+	_ "github.com/tiagoposse/secretsbeam-operator/internal/providers/all"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -74,6 +80,8 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var clusterProviderNamespace string
+	var clusterID string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
@@ -83,6 +91,11 @@ func main() {
 		"If set the metrics endpoint is served securely")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.StringVar(&clusterProviderNamespace, "cluster-provider-namespace", "secretsbeam-operator-system",
+		"The namespace ClusterExternalSecretProviders read their secrets from, e.g. their credentials.")
+	flag.StringVar(&clusterID, "cluster-id", "",
+		"The identifier of the cluster in the ownership tags of the resources created by the providers. "+
+			"Defaults to the UID of the kube-system namespace.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -139,12 +152,35 @@ func main() {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
+
+	ctx := ctrl.SetupSignalHandler()
+
+	// providers read their credentials with an uncached client, so that they are all loaded before
+	// the controllers start rather than by the first reconciles needing them
+	providerClient, err := client.New(mgr.GetConfig(), client.Options{Scheme: mgr.GetScheme(), Mapper: mgr.GetRESTMapper()})
 	if err != nil {
-		setupLog.Error(err, "unable initialize providers", "controller", "Providers")
+		setupLog.Error(err, "unable to create client", "controller", "Providers")
+		os.Exit(1)
+	}
+	if clusterID == "" {
+		// the kube-system namespace lives as long as the cluster, its UID is a stable identifier
+		kubeSystem := &corev1.Namespace{}
+		if err := providerClient.Get(ctx, client.ObjectKey{Name: metav1.NamespaceSystem}, kubeSystem); err != nil {
+			setupLog.Error(err, "unable to read the cluster id, set it with --cluster-id")
+			os.Exit(1)
+		}
+		clusterID = string(kubeSystem.UID)
+	}
+	setupLog.Info("cluster id", "id", clusterID, "version", version.Version)
+
+	pc := controller.NewProviderController(providerClient,
+		controller.WithClusterNamespace(clusterProviderNamespace),
+		controller.WithClusterID(clusterID),
+	)
+	if err := pc.InitProviders(ctx, mgr.GetAPIReader()); err != nil {
+		setupLog.Error(err, "unable to initialize providers", "controller", "Providers")
 		os.Exit(1)
 	}
-
-	pc := controller.NewProviderController(mgr.GetClient())
 
 	if err = (&controller.SecretReconciler{
 		ProviderController: pc,
@@ -170,6 +206,14 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "SecretProvider")
 		os.Exit(1)
 	}
+	if err = (&controller.ClusterSecretProviderReconciler{
+		ProviderController: pc,
+		Client:             mgr.GetClient(),
+		Scheme:             mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterSecretProvider")
+		os.Exit(1)
+	}
 
 	//+kubebuilder:scaffold:builder
 
@@ -183,7 +227,7 @@ func main() {
 	}
 
 	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	if err := mgr.Start(ctx); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
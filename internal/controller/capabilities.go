@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/meta"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/registry"
+)
+
+// UnsupportedError is returned when an object asks for a feature its provider does not support
+type UnsupportedError struct {
+	Provider ProviderRef
+	// Capability is the missing capability, e.g. RecoveryWindow
+	Capability string
+	// RequestedBy is what asks for the capability, e.g. spec.recoveryWindow
+	RequestedBy string
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("%s does not support %s, requested by %s", e.Provider, e.Capability, e.RequestedBy)
+}
+
+// secretCapabilities checks that the provider of an ExternalSecret supports what its spec asks for
+func secretCapabilities(ref ProviderRef, capabilities registry.Capabilities, secret *secretsv1alpha1.ExternalSecret) *UnsupportedError {
+	if secret.Spec.RecoveryWindow > 0 && !capabilities.RecoveryWindow {
+		return &UnsupportedError{Provider: ref, Capability: registry.CapabilityRecoveryWindow, RequestedBy: "spec.recoveryWindow"}
+	}
+
+	return nil
+}
+
+// accessCapabilities checks that the provider of a secret can grant access to it
+func accessCapabilities(ref ProviderRef, capabilities registry.Capabilities) *UnsupportedError {
+	if !capabilities.AccessControl {
+		return &UnsupportedError{Provider: ref, Capability: registry.CapabilityAccessControl, RequestedBy: "ExternalSecretAccess"}
+	}
+
+	return nil
+}
+
+// checkCapabilities returns the feature an object asks for that its loaded provider lacks. A
+// provider that is not loaded is reported by the reconciler when it needs it.
+func (pc *ProviderController) checkCapabilities(ctx context.Context, ref ProviderRef, check func(registry.Capabilities) *UnsupportedError) *UnsupportedError {
+	provider, err := pc.GetProvider(ctx, ref)
+	if err != nil {
+		return nil
+	}
+
+	return check(registry.CapabilitiesOf(provider))
+}
+
+// unsupportedCondition reports a feature the provider lacks. Nothing is applied to the provider
+// until the spec stops asking for it, so the object is not requeued.
+func unsupportedCondition(err *UnsupportedError) v1.Condition {
+	return v1.Condition{
+		Type:    "Unsupported",
+		Status:  v1.ConditionTrue,
+		Reason:  err.Capability + "Unsupported",
+		Message: err.Error(),
+	}
+}
+
+func (r *SecretReconciler) unsupported(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, err *UnsupportedError) (ctrl.Result, error) {
+	reqLogger.Info(err.Error())
+
+	meta.SetStatusCondition(&secret.Status.Conditions, unsupportedCondition(err))
+
+	return ctrl.Result{}, r.Status().Update(ctx, secret)
+}
+
+func (r *SecretAccessReconciler) unsupported(ctx context.Context, reqLogger logr.Logger, access *secretsv1alpha1.ExternalSecretAccess, err *UnsupportedError) (ctrl.Result, error) {
+	reqLogger.Info(err.Error())
+
+	meta.SetStatusCondition(&access.Status.Conditions, unsupportedCondition(err))
+
+	return ctrl.Result{}, r.Status().Update(ctx, access)
+}
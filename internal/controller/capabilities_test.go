@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gstruct"
+	"k8s.io/apimachinery/pkg/api/meta"
+
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/fake"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/registry"
+)
+
+// limitedType is registered with a factory building fakes that support no capability, the last one
+// built is testLimitedProvider
+const limitedType = "test-limited"
+
+var testLimitedProvider *limitedProvider
+
+type limitedProvider struct {
+	*fake.FakeProvider
+}
+
+func (p *limitedProvider) Capabilities() registry.Capabilities {
+	return registry.Capabilities{}
+}
+
+func init() {
+	registry.Register(limitedType, func(registry.Options) registry.Provider {
+		testLimitedProvider = &limitedProvider{FakeProvider: &fake.FakeProvider{}}
+		return testLimitedProvider
+	}, registry.ConfigSchema{})
+}
+
+var _ = Describe("Capabilities", func() {
+	var ctx context.Context
+
+	BeforeEach(func() {
+		ctx = context.Background()
+	})
+
+	It("reports the capabilities of providers in their status", func() {
+		provider := newExternalSecretProvider(uniqueName("backend"), testProviderType, map[string]string{"endpoint": "https://backend"})
+		Expect(k8sClient.Create(ctx, provider)).To(Succeed())
+		Expect(reconcileProvider(ctx, provider)).To(Succeed())
+		Expect(currentStatus(ctx, provider).Capabilities).To(Equal([]string{"RecoveryWindow", "AccessControl"}))
+
+		limited := newExternalSecretProvider(uniqueName("backend"), limitedType, nil)
+		Expect(k8sClient.Create(ctx, limited)).To(Succeed())
+		Expect(reconcileProvider(ctx, limited)).To(Succeed())
+		Expect(currentStatus(ctx, limited).Capabilities).To(BeEmpty())
+	})
+
+	Context("with a provider lacking capabilities", func() {
+		var providerName string
+
+		BeforeEach(func() {
+			provider := newExternalSecretProvider(uniqueName("backend"), limitedType, nil)
+			Expect(k8sClient.Create(ctx, provider)).To(Succeed())
+			Expect(reconcileProvider(ctx, provider)).To(Succeed())
+			providerName = provider.Name
+		})
+
+		It("reports unsupported recovery windows without creating the secret", func() {
+			secret := newExternalSecret(uniqueName("db"), "hunter2")
+			secret.Spec.Provider = providerName
+			secret.Spec.RecoveryWindow = 7
+			Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+			Expect(reconcileSecret(ctx, secret)).To(Succeed(), "only a change of the spec fixes it")
+
+			current := getSecret(ctx, secret)
+			Expect(current.Status.Created).To(BeFalse())
+			Expect(meta.FindStatusCondition(current.Status.Conditions, "Unsupported")).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Reason":  Equal("RecoveryWindowUnsupported"),
+				"Message": ContainSubstring("does not support RecoveryWindow, requested by spec.recoveryWindow"),
+			})))
+			Expect(testLimitedProvider.Calls(fake.MethodCreateSecret)).To(BeEmpty())
+
+			By("dropping the recovery window")
+			current.Spec.RecoveryWindow = 0
+			Expect(k8sClient.Update(ctx, current)).To(Succeed())
+			Expect(reconcileSecret(ctx, secret)).To(Succeed())
+
+			current = getSecret(ctx, secret)
+			Expect(current.Status.Created).To(BeTrue())
+			Expect(meta.FindStatusCondition(current.Status.Conditions, "Unsupported")).To(BeNil())
+		})
+
+		It("reports unsupported accesses without granting them", func() {
+			secret := newExternalSecret(uniqueName("db"), "hunter2")
+			secret.Spec.Provider = providerName
+			secret = createSecret(ctx, secret)
+
+			access := newExternalSecretAccess(uniqueName("reader"), secret.Name, serviceAccountSubject("default", "app"))
+			Expect(k8sClient.Create(ctx, access)).To(Succeed())
+			Expect(reconcileAccess(ctx, access)).To(Succeed())
+
+			current := getAccess(ctx, access)
+			Expect(current.Status.Created).To(BeFalse())
+			Expect(meta.FindStatusCondition(current.Status.Conditions, "Unsupported")).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Reason": Equal("AccessControlUnsupported"),
+			})))
+			Expect(testLimitedProvider.Calls(fake.MethodCreateAccess)).To(BeEmpty())
+		})
+	})
+})
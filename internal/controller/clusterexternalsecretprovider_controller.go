@@ -0,0 +1,78 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+)
+
+// ClusterSecretProviderReconciler reconciles a ClusterExternalSecretProvider object, the same way
+// as SecretProviderReconciler reconciles ExternalSecretProviders
+type ClusterSecretProviderReconciler struct {
+	client.Client
+	Scheme             *runtime.Scheme
+	ProviderController *ProviderController
+}
+
+//+kubebuilder:rbac:groups=orbitops.dev,resources=clusterexternalsecretproviders,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=orbitops.dev,resources=clusterexternalsecretproviders/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=orbitops.dev,resources=clusterexternalsecretproviders/finalizers,verbs=update
+
+func (r *ClusterSecretProviderReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	reqLogger := log.FromContext(ctx)
+
+	provider := &secretsv1alpha1.ClusterExternalSecretProvider{}
+	err := r.Get(ctx, req.NamespacedName, provider)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			reqLogger.Info("ClusterExternalSecretProvider resource not found, removing its provider.")
+			return r.providers().remove(ctx, ProviderRef{Kind: secretsv1alpha1.ProviderKindCluster, Name: req.Name}), nil
+		}
+
+		return ctrl.Result{}, err
+	}
+
+	return r.providers().reconcile(ctx, provider)
+}
+
+// providers returns the reconciler of ExternalSecretProviders, whose reconciliation is shared
+func (r *ClusterSecretProviderReconciler) providers() *SecretProviderReconciler {
+	return &SecretProviderReconciler{Client: r.Client, Scheme: r.Scheme, ProviderController: r.ProviderController}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterSecretProviderReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	deletingProvider := handler.EnqueueRequestsFromMapFunc(deletingProviderOf(r.Client, secretsv1alpha1.ProviderKindCluster))
+
+	return ctrl.NewControllerManagedBy(mgr).
+		// status updates do not change the provider
+		For(&secretsv1alpha1.ClusterExternalSecretProvider{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&secretsv1alpha1.ExternalSecret{}, deletingProvider, builder.WithPredicates(dependentsChanged)).
+		Watches(&secretsv1alpha1.ExternalSecretAccess{}, deletingProvider, builder.WithPredicates(dependentsChanged)).
+		Complete(r)
+}
@@ -0,0 +1,222 @@
+package controller
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gstruct"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/fake"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/registry"
+)
+
+// optionsType is registered with a factory recording the options it is called with in testOptions
+const optionsType = "test-options"
+
+var testOptions registry.Options
+
+func init() {
+	registry.Register(optionsType, func(opts registry.Options) registry.Provider {
+		testOptions = opts
+		return &fake.FakeProvider{}
+	}, registry.ConfigSchema{})
+}
+
+func newClusterExternalSecretProvider(name, providerType string, config map[string]string) *secretsv1alpha1.ClusterExternalSecretProvider {
+	return &secretsv1alpha1.ClusterExternalSecretProvider{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: secretsv1alpha1.ClusterExternalSecretProviderSpec{
+			ExternalSecretProviderSpec: secretsv1alpha1.ExternalSecretProviderSpec{
+				Provider: providerType,
+				Config:   config,
+			},
+		},
+	}
+}
+
+func reconcileClusterProvider(ctx context.Context, provider *secretsv1alpha1.ClusterExternalSecretProvider) error {
+	_, err := clusterProviderReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: provider.Name}})
+	return err
+}
+
+// newNamespace creates a namespace with labels
+func newNamespace(ctx context.Context, labels map[string]string) string {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: uniqueName("tenant"), Labels: labels}}
+	Expect(k8sClient.Create(ctx, ns)).To(Succeed())
+	return ns.Name
+}
+
+// newClusterSecret returns an ExternalSecret of a namespace using a ClusterExternalSecretProvider
+func newClusterSecret(namespace, provider string) *secretsv1alpha1.ExternalSecret {
+	secret := newExternalSecret(uniqueName("db"), "hunter2")
+	secret.Namespace = namespace
+	secret.Spec.Provider = provider
+	secret.Spec.ProviderKind = secretsv1alpha1.ProviderKindCluster
+	return secret
+}
+
+var _ = Describe("ClusterSecretProviderReconciler", func() {
+	var (
+		ctx      context.Context
+		provider *secretsv1alpha1.ClusterExternalSecretProvider
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+
+		provider = newClusterExternalSecretProvider(uniqueName("prod"), testProviderType, map[string]string{"endpoint": "https://backend"})
+	})
+
+	It("loads the provider and reports it in the status", func() {
+		Expect(k8sClient.Create(ctx, provider)).To(Succeed())
+		Expect(reconcileClusterProvider(ctx, provider)).To(Succeed())
+
+		current := &secretsv1alpha1.ClusterExternalSecretProvider{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(provider), current)).To(Succeed())
+		Expect(meta.IsStatusConditionTrue(current.Status.Conditions, "Ready")).To(BeTrue())
+
+		p, err := providerController.GetProvider(ctx, refOf(provider))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(p).To(BeIdenticalTo(testProvider))
+	})
+
+	It("reads the secrets of the provider from the cluster namespace", func() {
+		provider.Spec.Provider = optionsType
+		provider.Spec.Config = nil
+		Expect(k8sClient.Create(ctx, provider)).To(Succeed())
+		Expect(reconcileClusterProvider(ctx, provider)).To(Succeed())
+		Expect(testOptions.Namespace).To(Equal(clusterProviderNamespace))
+	})
+
+	Context("namespace restrictions", func() {
+		It("allows no namespace without restrictions", func() {
+			Expect(k8sClient.Create(ctx, provider)).To(Succeed())
+			Expect(reconcileClusterProvider(ctx, provider)).To(Succeed())
+
+			denied := newClusterSecret(newNamespace(ctx, nil), provider.Name)
+			Expect(k8sClient.Create(ctx, denied)).To(Succeed())
+			Expect(reconcileSecret(ctx, denied)).To(MatchError(ContainSubstring("does not allow namespace %s", denied.Namespace)))
+			Expect(testProvider.Calls()).NotTo(ContainElement(MatchFields(IgnoreExtras, Fields{
+				"Object": Equal(denied.Namespace + "/" + denied.Name),
+			})))
+		})
+
+		It("allows every namespace with an empty selector", func() {
+			provider.Spec.NamespaceSelector = &metav1.LabelSelector{}
+			Expect(k8sClient.Create(ctx, provider)).To(Succeed())
+			Expect(reconcileClusterProvider(ctx, provider)).To(Succeed())
+
+			secret := createSecret(ctx, newClusterSecret(newNamespace(ctx, nil), provider.Name))
+			Expect(testProvider.Calls()).To(ContainElement(MatchFields(IgnoreExtras, Fields{
+				"Method": Equal(fake.MethodCreateSecret),
+				"Object": Equal(secret.Namespace + "/" + secret.Name),
+			})))
+		})
+
+		It("allows the listed and selected namespaces", func() {
+			listed := newNamespace(ctx, nil)
+			selected := newNamespace(ctx, map[string]string{"tier": "prod"})
+			other := newNamespace(ctx, map[string]string{"tier": "dev"})
+
+			provider.Spec.AllowedNamespaces = []string{listed}
+			provider.Spec.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "prod"}}
+			Expect(k8sClient.Create(ctx, provider)).To(Succeed())
+			Expect(reconcileClusterProvider(ctx, provider)).To(Succeed())
+
+			createSecret(ctx, newClusterSecret(listed, provider.Name))
+			createSecret(ctx, newClusterSecret(selected, provider.Name))
+
+			denied := newClusterSecret(other, provider.Name)
+			Expect(k8sClient.Create(ctx, denied)).To(Succeed())
+			err := reconcileSecret(ctx, denied)
+			var notAllowed *NamespaceNotAllowedError
+			Expect(errors.As(err, &notAllowed)).To(BeTrue())
+			Expect(err).To(MatchError(ContainSubstring("ClusterExternalSecretProvider %s does not allow namespace %s", provider.Name, other)))
+			Expect(meta.FindStatusCondition(getSecret(ctx, denied).Status.Conditions, "Unavailable")).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Reason": Equal("ProviderNotAllowed"),
+			})))
+		})
+
+		It("denies accesses once their namespace is no longer allowed", func() {
+			namespace := newNamespace(ctx, nil)
+			provider.Spec.AllowedNamespaces = []string{namespace}
+			Expect(k8sClient.Create(ctx, provider)).To(Succeed())
+			Expect(reconcileClusterProvider(ctx, provider)).To(Succeed())
+
+			secret := createSecret(ctx, newClusterSecret(namespace, provider.Name))
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(provider), provider)).To(Succeed())
+			provider.Spec.AllowedNamespaces = []string{"elsewhere"}
+			Expect(k8sClient.Update(ctx, provider)).To(Succeed())
+
+			access := newExternalSecretAccess(uniqueName("reader"), secret.Name)
+			access.Namespace = namespace
+			Expect(k8sClient.Create(ctx, access)).To(Succeed())
+			Expect(reconcileAccess(ctx, access)).To(MatchError(ContainSubstring("does not allow namespace %s", namespace)))
+			Expect(meta.FindStatusCondition(getAccess(ctx, access).Status.Conditions, "Unavailable")).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Reason": Equal("ProviderNotAllowed"),
+			})))
+
+			By("deleting the secret regardless")
+			Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+			Expect(reconcileSecret(ctx, secret)).To(Succeed())
+			Expect(testProvider.Calls()).To(ContainElement(fake.Call{Method: fake.MethodDeleteSecret, Object: namespace + "/" + secret.Name}))
+		})
+
+		It("only serves ExternalSecretProviders to their own namespace", func() {
+			namespaced := newExternalSecretProvider(uniqueName("backend"), testProviderType, map[string]string{"endpoint": "https://backend"})
+			Expect(k8sClient.Create(ctx, namespaced)).To(Succeed())
+			Expect(reconcileProvider(ctx, namespaced)).To(Succeed())
+
+			secret := newExternalSecret(uniqueName("db"), "hunter2")
+			secret.Namespace = newNamespace(ctx, nil)
+			secret.Spec.Provider = namespaced.Name
+			Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+			Expect(reconcileSecret(ctx, secret)).To(MatchError(ContainSubstring(
+				"cannot find provider ExternalSecretProvider %s/%s", secret.Namespace, namespaced.Name)))
+
+			Expect(getSecret(ctx, secret).Spec.ProviderKind).To(Equal(secretsv1alpha1.ProviderKindNamespaced), "the kind defaults to ExternalSecretProvider")
+		})
+	})
+
+	It("blocks the deletion while secrets of any namespace use the provider", func() {
+		provider.Spec.NamespaceSelector = &metav1.LabelSelector{}
+		Expect(k8sClient.Create(ctx, provider)).To(Succeed())
+		Expect(reconcileClusterProvider(ctx, provider)).To(Succeed())
+
+		secret := createSecret(ctx, newClusterSecret(newNamespace(ctx, nil), provider.Name))
+
+		Expect(k8sClient.Delete(ctx, provider)).To(Succeed())
+		Expect(reconcileClusterProvider(ctx, provider)).To(Succeed())
+
+		current := &secretsv1alpha1.ClusterExternalSecretProvider{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(provider), current)).To(Succeed())
+		Expect(meta.FindStatusCondition(current.Status.Conditions, "Ready")).To(PointTo(MatchFields(IgnoreExtras, Fields{
+			"Reason":  Equal("DeletionBlocked"),
+			"Message": ContainSubstring("ExternalSecret %s/%s", secret.Namespace, secret.Name),
+		})))
+
+		Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+		Expect(reconcileSecret(ctx, secret)).To(Succeed())
+		Expect(deletingProviderOf(k8sClient, secretsv1alpha1.ProviderKindNamespaced)(ctx, secret)).To(BeEmpty())
+		Expect(deletingProviderOf(k8sClient, secretsv1alpha1.ProviderKindCluster)(ctx, secret)).To(ConsistOf(
+			reconcile.Request{NamespacedName: types.NamespacedName{Name: provider.Name}},
+		))
+
+		Expect(reconcileClusterProvider(ctx, provider)).To(Succeed())
+		Expect(kerrors.IsNotFound(k8sClient.Get(ctx, client.ObjectKeyFromObject(provider), current))).To(BeTrue())
+		_, err := providerController.GetProvider(ctx, refOf(provider))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -33,14 +33,19 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/go-logr/logr"
 	"github.com/olebedev/when"
+	"github.com/olebedev/when/rules/common"
+	"github.com/olebedev/when/rules/en"
 	"github.com/toncek345/reggenerator"
 
 	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/registry"
 	"github.com/tiagoposse/secretsbeam-operator/internal/utils"
 )
 
@@ -54,6 +59,7 @@ type SecretReconciler struct {
 //+kubebuilder:rbac:groups=orbitops.dev,resources=externalsecrets,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=orbitops.dev,resources=externalsecrets/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=orbitops.dev,resources=externalsecrets/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
 
 func (r *SecretReconciler) err(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, err error) (reconcile.Result, error) {
 	reqLogger.Error(err, "")
@@ -63,8 +69,11 @@ func (r *SecretReconciler) err(ctx context.Context, reqLogger logr.Logger, secre
 		Message: err.Error(),
 		Status:  v1.ConditionFalse,
 	}
+	var notAllowed *NamespaceNotAllowedError
 	if errors.Is(err, utils.ProviderError{}) {
 		condition.Reason = "ProviderError"
+	} else if errors.As(err, &notAllowed) {
+		condition.Reason = "ProviderNotAllowed"
 	} else {
 		condition.Reason = "ControllerError"
 	}
@@ -123,6 +132,14 @@ func (r *SecretReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		return ctrl.Result{}, nil
 	}
 
+	// features the provider lacks are reported instead of being half applied
+	ref := providerOf(secret)
+	if err := r.ProviderController.checkCapabilities(ctx, ref, func(capabilities registry.Capabilities) *UnsupportedError {
+		return secretCapabilities(ref, capabilities, secret)
+	}); err != nil {
+		return r.unsupported(ctx, reqLogger, secret, err)
+	}
+
 	var operation string
 	if !secret.Status.Created {
 		operation = "Created"
@@ -151,6 +168,7 @@ func (r *SecretReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	}
 
 	secret.Status.SecretName = *secret.Spec.ExternalName
+	meta.RemoveStatusCondition(&secret.Status.Conditions, "Unsupported")
 
 	condition := v1.Condition{
 		Type:    "Available",
@@ -168,6 +186,24 @@ func (r *SecretReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	return ctrl.Result{}, nil
 }
 
+// secretsUsingProvider maps a provider that was rebuilt or removed to the ExternalSecrets using it
+func (r *SecretReconciler) secretsUsingProvider(ctx context.Context, provider client.Object) []reconcile.Request {
+	list := &secretsv1alpha1.ExternalSecretList{}
+	if err := r.List(ctx, list); err != nil {
+		log.FromContext(ctx).Error(err, "listing objects using the provider", "provider", provider.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0)
+	for i := range list.Items {
+		if providerOf(&list.Items[i]) == refOf(provider) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&list.Items[i])})
+		}
+	}
+
+	return requests
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *SecretReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	limiter := workqueue.NewMaxOfRateLimiter(
@@ -177,6 +213,8 @@ func (r *SecretReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	)
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&secretsv1alpha1.ExternalSecret{}).
+		// a provider that is rebuilt or removed is used again or reported by the objects using it
+		WatchesRawSource(&source.Channel{Source: r.ProviderController.Changes()}, handler.EnqueueRequestsFromMapFunc(r.secretsUsingProvider)).
 		WithOptions(
 			controller.Options{
 				RateLimiter: limiter,
@@ -192,12 +230,8 @@ func generateRandomSecret(secret *secretsv1alpha1.ExternalSecret) (string, error
 	}
 
 	if secret.Spec.Random.Rotate != nil {
-		w := when.New(nil)
-		if wRes, err := w.Parse(*secret.Spec.Random.Rotate, time.Now()); err != nil {
-			return "", fmt.Errorf("parsing rotation date: %w", err)
-		} else {
-			nt := v1.NewTime(wRes.Time)
-			secret.Status.NextRotateDate = &nt
+		if err := setSecretRotation(secret); err != nil {
+			return "", err
 		}
 	} else {
 		secret.Status.NextRotateDate = nil
@@ -235,7 +269,7 @@ func (r *SecretReconciler) createSecret(ctx context.Context, reqLogger logr.Logg
 		secretValue = *secret.Spec.SecretString
 	}
 
-	if provider, err := r.ProviderController.GetProvider(ctx, secret.Spec.Provider); err != nil {
+	if provider, err := r.ProviderController.providerFor(ctx, r.Client, secret); err != nil {
 		reqLogger.Error(err, "getting provider")
 		return err
 	} else if err := provider.CreateSecret(ctx, reqLogger, secret, secretValue); err != nil {
@@ -282,7 +316,7 @@ func (r *SecretReconciler) updateSecret(ctx context.Context, reqLogger logr.Logg
 		secretValue = *secret.Spec.SecretString
 	}
 
-	provider, err := r.ProviderController.GetProvider(ctx, secret.Spec.Provider)
+	provider, err := r.ProviderController.providerFor(ctx, r.Client, secret)
 	if err != nil {
 		return fmt.Errorf("getting provider: %w", err)
 	}
@@ -297,7 +331,8 @@ func (r *SecretReconciler) updateSecret(ctx context.Context, reqLogger logr.Logg
 func (r *SecretReconciler) deleteSecret(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret) error {
 	reqLogger.Info("Successfully finalized Secret")
 
-	provider, err := r.ProviderController.GetProvider(ctx, secret.Spec.Provider)
+	// secrets are cleaned up even when their namespace is no longer allowed to use the provider
+	provider, err := r.ProviderController.GetProvider(ctx, providerOf(secret))
 	if err != nil {
 		reqLogger.Error(err, "getting provider")
 		return err
@@ -318,8 +353,13 @@ func (r *SecretReconciler) deleteSecret(ctx context.Context, reqLogger logr.Logg
 
 func setSecretRotation(secret *secretsv1alpha1.ExternalSecret) error {
 	w := when.New(nil)
+	w.Add(en.All...)
+	w.Add(common.All...)
+
 	if t, err := w.Parse(*secret.Spec.Random.Rotate, time.Now()); err != nil {
 		return fmt.Errorf("parsing rotation date: %w", err)
+	} else if t == nil {
+		return fmt.Errorf("parsing rotation date: no date found in %q", *secret.Spec.Random.Rotate)
 	} else {
 		metaTime := v1.NewTime(t.Time)
 		secret.Status.NextRotateDate = &metaTime
@@ -0,0 +1,242 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/fake"
+)
+
+var objectCounter int
+
+// uniqueName avoids collisions between specs sharing the envtest api server
+func uniqueName(prefix string) string {
+	objectCounter++
+	return fmt.Sprintf("%s-%d", prefix, objectCounter)
+}
+
+func newExternalSecret(name, value string) *secretsv1alpha1.ExternalSecret {
+	return &secretsv1alpha1.ExternalSecret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: secretsv1alpha1.ExternalSecretSpec{
+			SecretString: &value,
+			Provider:     fakeProviderName,
+		},
+	}
+}
+
+func reconcileSecret(ctx context.Context, secret *secretsv1alpha1.ExternalSecret) error {
+	_, err := secretReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}})
+	return err
+}
+
+func getSecret(ctx context.Context, secret *secretsv1alpha1.ExternalSecret) *secretsv1alpha1.ExternalSecret {
+	current := &secretsv1alpha1.ExternalSecret{}
+	Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}, current)).To(Succeed())
+	return current
+}
+
+// createSecret creates an ExternalSecret and reconciles it until it is created in the provider
+func createSecret(ctx context.Context, secret *secretsv1alpha1.ExternalSecret) *secretsv1alpha1.ExternalSecret {
+	Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+	Expect(reconcileSecret(ctx, secret)).To(Succeed())
+
+	created := getSecret(ctx, secret)
+	Expect(created.Status.Created).To(BeTrue())
+	return created
+}
+
+var _ = Describe("SecretReconciler", func() {
+	var ctx context.Context
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		fakeProvider.Reset()
+	})
+
+	It("creates the secret in the provider", func() {
+		secret := createSecret(ctx, newExternalSecret(uniqueName("db"), "hunter2"))
+
+		Expect(controllerutil.ContainsFinalizer(secret, secretsv1alpha1.SecretFinalizer)).To(BeTrue())
+		Expect(secret.Status.SecretName).To(Equal(secret.Name))
+		Expect(secret.Status.SecretVersion).To(Equal("1"))
+		Expect(secret.Status.Provider).To(HaveKeyWithValue("Version", "1"))
+		Expect(meta.IsStatusConditionTrue(secret.Status.Conditions, "Available")).To(BeTrue())
+
+		stored, ok := fakeProvider.Secret(secret.Name)
+		Expect(ok).To(BeTrue())
+		Expect(stored.Value).To(Equal("hunter2"))
+	})
+
+	It("uses the external name", func() {
+		secret := newExternalSecret(uniqueName("db"), "hunter2")
+		externalName := "team-a/" + secret.Name
+		secret.Spec.ExternalName = &externalName
+		secret = createSecret(ctx, secret)
+
+		Expect(secret.Status.SecretName).To(Equal(externalName))
+		_, ok := fakeProvider.Secret(externalName)
+		Expect(ok).To(BeTrue())
+	})
+
+	It("updates the secret when the value changes", func() {
+		secret := createSecret(ctx, newExternalSecret(uniqueName("db"), "hunter2"))
+
+		value := "hunter3"
+		secret.Spec.SecretString = &value
+		Expect(k8sClient.Update(ctx, secret)).To(Succeed())
+		Expect(reconcileSecret(ctx, secret)).To(Succeed())
+
+		secret = getSecret(ctx, secret)
+		Expect(secret.Status.SecretVersion).To(Equal("2"))
+		Expect(secret.Status.Provider).To(HaveKeyWithValue("Version", "2"))
+		Expect(meta.FindStatusCondition(secret.Status.Conditions, "Available").Reason).To(Equal("Updated"))
+
+		stored, _ := fakeProvider.Secret(secret.Name)
+		Expect(stored.Value).To(Equal("hunter3"))
+		Expect(fakeProvider.Calls(fake.MethodUpdateSecret)).To(HaveLen(1))
+	})
+
+	It("reserves the prefix of the ownership tags", func() {
+		secret := newExternalSecret(uniqueName("db"), "hunter2")
+		secret.Spec.Tags = map[string]string{"team": "a", "secretsbeam.orbitops.dev/cluster-id": "other"}
+		Expect(k8sClient.Create(ctx, secret)).To(MatchError(ContainSubstring("the secretsbeam.orbitops.dev/ prefix is reserved for the ownership tags")))
+
+		delete(secret.Spec.Tags, "secretsbeam.orbitops.dev/cluster-id")
+		Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+	})
+
+	It("does not touch external secrets after creation", func() {
+		secret := newExternalSecret(uniqueName("db"), "")
+		secret.Spec.SecretString = nil
+		secret.Spec.External = true
+		secret = createSecret(ctx, secret)
+		Expect(secret.Status.IsExternal).To(BeTrue())
+
+		stored, _ := fakeProvider.Secret(secret.Name)
+		Expect(stored.Value).To(Equal("PLACEHOLDER"))
+
+		Expect(reconcileSecret(ctx, secret)).To(Succeed())
+		Expect(fakeProvider.Calls(fake.MethodUpdateSecret)).To(BeEmpty())
+	})
+
+	Context("random secrets", func() {
+		newRandomSecret := func(rotate *string) *secretsv1alpha1.ExternalSecret {
+			secret := newExternalSecret(uniqueName("random"), "")
+			secret.Spec.SecretString = nil
+			secret.Spec.Random = &secretsv1alpha1.RandomSecretSpec{Size: 24, Regex: "[a-z0-9]", Rotate: rotate}
+			return secret
+		}
+
+		It("generates a value matching the regex", func() {
+			secret := createSecret(ctx, newRandomSecret(nil))
+			Expect(secret.Status.IsRandom).To(BeTrue())
+			Expect(secret.Status.NextRotateDate).To(BeNil())
+
+			stored, _ := fakeProvider.Secret(secret.Name)
+			Expect(stored.Value).To(MatchRegexp("^[a-z0-9]{24}$"))
+
+			// without rotation the value is kept
+			Expect(reconcileSecret(ctx, secret)).To(Succeed())
+			Expect(fakeProvider.Calls(fake.MethodUpdateSecret)).To(BeEmpty())
+		})
+
+		It("rotates the value once the rotation date has passed", func() {
+			rotate := "in 1 hour"
+			secret := createSecret(ctx, newRandomSecret(&rotate))
+			Expect(secret.Status.NextRotateDate).NotTo(BeNil())
+			Expect(secret.Status.NextRotateDate.Time).To(BeTemporally("~", time.Now().Add(time.Hour), time.Minute))
+			first, _ := fakeProvider.Secret(secret.Name)
+
+			// not due yet
+			Expect(reconcileSecret(ctx, secret)).To(Succeed())
+			Expect(fakeProvider.Calls(fake.MethodUpdateSecret)).To(BeEmpty())
+
+			past := metav1.NewTime(time.Now().Add(-time.Minute))
+			secret = getSecret(ctx, secret)
+			secret.Status.NextRotateDate = &past
+			Expect(k8sClient.Status().Update(ctx, secret)).To(Succeed())
+
+			Expect(reconcileSecret(ctx, secret)).To(Succeed())
+			calls := fakeProvider.Calls(fake.MethodUpdateSecret)
+			Expect(calls).To(HaveLen(1))
+			Expect(calls[0].Value).To(MatchRegexp("^[a-z0-9]{24}$"))
+			Expect(calls[0].Value).NotTo(Equal(first.Value))
+		})
+	})
+
+	It("reports provider errors in the status", func() {
+		fakeProvider.FailOn(fake.MethodCreateSecret, errors.New("backend unavailable"))
+
+		secret := newExternalSecret(uniqueName("db"), "hunter2")
+		Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+		Expect(reconcileSecret(ctx, secret)).To(MatchError(ContainSubstring("backend unavailable")))
+
+		secret = getSecret(ctx, secret)
+		Expect(secret.Status.Created).To(BeFalse())
+		condition := meta.FindStatusCondition(secret.Status.Conditions, "Unavailable")
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Message).To(ContainSubstring("backend unavailable"))
+
+		// the secret is created once the provider recovers
+		fakeProvider.FailOn(fake.MethodCreateSecret, nil)
+		Expect(reconcileSecret(ctx, secret)).To(Succeed())
+		Expect(getSecret(ctx, secret).Status.Created).To(BeTrue())
+	})
+
+	It("reports unknown providers", func() {
+		secret := newExternalSecret(uniqueName("db"), "hunter2")
+		secret.Spec.Provider = "missing"
+		Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+		Expect(reconcileSecret(ctx, secret)).To(MatchError(ContainSubstring("cannot find provider ExternalSecretProvider default/missing")))
+	})
+
+	Context("deletion", func() {
+		It("deletes the secret from the provider and removes the finalizer", func() {
+			secret := createSecret(ctx, newExternalSecret(uniqueName("db"), "hunter2"))
+
+			Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+			// the finalizer keeps the object until it is reconciled
+			Expect(getSecret(ctx, secret).DeletionTimestamp).NotTo(BeNil())
+
+			Expect(reconcileSecret(ctx, secret)).To(Succeed())
+			Expect(fakeProvider.Calls(fake.MethodDeleteSecret)).To(HaveLen(1))
+			_, ok := fakeProvider.Secret(secret.Name)
+			Expect(ok).To(BeFalse())
+
+			err := k8sClient.Get(ctx, types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}, &secretsv1alpha1.ExternalSecret{})
+			Expect(kerrors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("keeps the finalizer while the provider fails", func() {
+			secret := createSecret(ctx, newExternalSecret(uniqueName("db"), "hunter2"))
+			fakeProvider.FailOn(fake.MethodDeleteSecret, errors.New("backend unavailable"))
+
+			Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+			Expect(reconcileSecret(ctx, secret)).To(MatchError(ContainSubstring("backend unavailable")))
+			Expect(controllerutil.ContainsFinalizer(getSecret(ctx, secret), secretsv1alpha1.SecretFinalizer)).To(BeTrue())
+
+			fakeProvider.FailOn(fake.MethodDeleteSecret, nil)
+			Expect(reconcileSecret(ctx, secret)).To(Succeed())
+			err := k8sClient.Get(ctx, types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}, &secretsv1alpha1.ExternalSecret{})
+			Expect(kerrors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("ignores secrets that are already gone", func() {
+			Expect(reconcileSecret(ctx, newExternalSecret(uniqueName("missing"), ""))).To(Succeed())
+			Expect(fakeProvider.Calls()).To(BeEmpty())
+		})
+	})
+})
@@ -23,6 +23,8 @@ import (
 	"time"
 
 	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	kerorrs "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -30,14 +32,20 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/go-logr/logr"
 	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/registry"
 	"github.com/tiagoposse/secretsbeam-operator/internal/utils"
 )
 
@@ -57,8 +65,11 @@ func (r *SecretAccessReconciler) err(ctx context.Context, reqLogger logr.Logger,
 		Status:  v1.ConditionFalse,
 	}
 
+	var notAllowed *NamespaceNotAllowedError
 	if errors.Is(err, utils.ProviderError{}) {
 		condition.Reason = "ProviderError"
+	} else if errors.As(err, &notAllowed) {
+		condition.Reason = "ProviderNotAllowed"
 	} else {
 		condition.Reason = "ControllerError"
 	}
@@ -74,6 +85,7 @@ func (r *SecretAccessReconciler) err(ctx context.Context, reqLogger logr.Logger,
 //+kubebuilder:rbac:groups=orbitops.dev,resources=externalsecretaccesses,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=orbitops.dev,resources=externalsecretaccesses/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=orbitops.dev,resources=externalsecretaccesses/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get;list;watch;create;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -93,12 +105,17 @@ func (r *SecretAccessReconciler) Reconcile(ctx context.Context, req ctrl.Request
 
 		return r.err(ctx, reqLogger, access, err)
 	}
+	// the service accounts of the subjects removed since the last reconciliation are released
+	previous := access.Status.Subjects
 
 	// Secret is marked to be deleted, delete AWS Secret
 	if access.GetDeletionTimestamp() != nil {
 		if err := r.deleteAccess(ctx, reqLogger, access); err != nil {
 			return r.err(ctx, reqLogger, access, err)
 		}
+		if err := r.releaseServiceAccounts(ctx, reqLogger, access, serviceAccountsOf(previous, access.Spec.AccessSubjects)); err != nil {
+			return r.err(ctx, reqLogger, access, fmt.Errorf("releasing service accounts: %w", err))
+		}
 
 		// Remove secretFinalizer. Once all finalizers have been
 		// removed, the object will be deleted.
@@ -126,6 +143,14 @@ func (r *SecretAccessReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		return r.err(ctx, reqLogger, access, fmt.Errorf("target secret not successfully created"))
 	}
 
+	// accesses to providers without access control are reported rather than silently not granted
+	ref := providerOf(secret)
+	if err := r.ProviderController.checkCapabilities(ctx, ref, func(capabilities registry.Capabilities) *UnsupportedError {
+		return accessCapabilities(ref, capabilities)
+	}); err != nil {
+		return r.unsupported(ctx, reqLogger, access, err)
+	}
+
 	var operation string
 	if !access.Status.Created {
 		operation = "Created"
@@ -141,6 +166,11 @@ func (r *SecretAccessReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		}
 	}
 
+	if err := r.syncServiceAccounts(ctx, reqLogger, access, previous); err != nil {
+		return r.err(ctx, reqLogger, access, fmt.Errorf("annotating service accounts: %w", err))
+	}
+
+	meta.RemoveStatusCondition(&access.Status.Conditions, "Unsupported")
 	if err := r.Status().Update(ctx, access); err != nil {
 		return r.err(ctx, reqLogger, access, fmt.Errorf("updating status after exec: %w", err))
 	}
@@ -157,8 +187,72 @@ func (r *SecretAccessReconciler) Reconcile(ctx context.Context, req ctrl.Request
 	return ctrl.Result{}, nil
 }
 
+// accessesUsingProvider maps a provider that was rebuilt or removed to the ExternalSecretAccesses using it
+func (r *SecretAccessReconciler) accessesUsingProvider(ctx context.Context, provider client.Object) []reconcile.Request {
+	list := &secretsv1alpha1.ExternalSecretAccessList{}
+	if err := r.List(ctx, list); err != nil {
+		log.FromContext(ctx).Error(err, "listing objects using the provider", "provider", provider.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0)
+	for i := range list.Items {
+		if providerOf(&list.Items[i]) == refOf(provider) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&list.Items[i])})
+		}
+	}
+
+	return requests
+}
+
+// secretNameField indexes the ExternalSecretAccesses by the name of their secret
+const secretNameField = "spec.secretName"
+
+// accessesOfSecret maps an ExternalSecret to the ExternalSecretAccesses of its namespace granting it
+func (r *SecretAccessReconciler) accessesOfSecret(ctx context.Context, secret client.Object) []reconcile.Request {
+	list := &secretsv1alpha1.ExternalSecretAccessList{}
+	if err := r.List(ctx, list, client.InNamespace(secret.GetNamespace()), client.MatchingFields{secretNameField: secret.GetName()}); err != nil {
+		log.FromContext(ctx).Error(err, "listing objects granting the secret", "secret", client.ObjectKeyFromObject(secret))
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(list.Items))
+	for i := range list.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&list.Items[i])})
+	}
+
+	return requests
+}
+
+// secretProviderChanged passes the secrets whose provider status changed, e.g. their key or replicas,
+// which the grants of their accesses depend on
+var secretProviderChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		old, ok := e.ObjectOld.(*secretsv1alpha1.ExternalSecret)
+		if !ok {
+			return false
+		}
+		updated, ok := e.ObjectNew.(*secretsv1alpha1.ExternalSecret)
+		if !ok {
+			return false
+		}
+
+		return !equality.Semantic.DeepEqual(old.Status.Provider, updated.Status.Provider)
+	},
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *SecretAccessReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &secretsv1alpha1.ExternalSecretAccess{}, secretNameField, func(object client.Object) []string {
+		return []string{object.(*secretsv1alpha1.ExternalSecretAccess).Spec.SecretName}
+	}); err != nil {
+		return err
+	}
+
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &secretsv1alpha1.ExternalSecretAccess{}, serviceAccountField, indexServiceAccounts); err != nil {
+		return err
+	}
+
 	limiter := workqueue.NewMaxOfRateLimiter(
 		workqueue.NewItemExponentialFailureRateLimiter(1*time.Minute, 10*time.Minute),
 		// 10 qps, 100 bucket size.  This is only for retry speed and its only the overall factor (not per item)
@@ -167,6 +261,12 @@ func (r *SecretAccessReconciler) SetupWithManager(mgr ctrl.Manager) error {
 
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&secretsv1alpha1.ExternalSecretAccess{}).
+		// a provider that is rebuilt or removed is used again or reported by the objects using it
+		WatchesRawSource(&source.Channel{Source: r.ProviderController.Changes()}, handler.EnqueueRequestsFromMapFunc(r.accessesUsingProvider)).
+		// the grants follow the provider state of the secret, e.g. its key or replicas
+		Watches(&secretsv1alpha1.ExternalSecret{}, handler.EnqueueRequestsFromMapFunc(r.accessesOfSecret), builder.WithPredicates(secretProviderChanged)).
+		// service accounts created or annotated by others are annotated again
+		Watches(&corev1.ServiceAccount{}, handler.EnqueueRequestsFromMapFunc(r.accessesOfServiceAccount), builder.WithPredicates(predicate.AnnotationChangedPredicate{})).
 		WithOptions(
 			controller.Options{
 				RateLimiter: limiter,
@@ -187,7 +287,7 @@ func (r *SecretAccessReconciler) createAccess(ctx context.Context, reqLogger log
 	access.Status.Conditions = make([]v1.Condition, 0)
 	access.Status.Subjects = make([]secretsv1alpha1.SecretAccessSubject, 0)
 
-	provider, err := r.ProviderController.GetProvider(ctx, secret.Spec.Provider)
+	provider, err := r.ProviderController.providerFor(ctx, r.Client, secret)
 	if err != nil {
 		return fmt.Errorf("getting provider: %w", err)
 	}
@@ -197,12 +297,19 @@ func (r *SecretAccessReconciler) createAccess(ctx context.Context, reqLogger log
 
 	access.Status.Subjects = access.Spec.AccessSubjects
 	access.Status.ProviderType = secret.Spec.Provider
+	access.Status.ProviderKind = providerOf(secret).Kind
 
 	return nil
 }
 
 func (r *SecretAccessReconciler) deleteAccess(ctx context.Context, reqLogger logr.Logger, access *secretsv1alpha1.ExternalSecretAccess) error {
-	provider, err := r.ProviderController.GetProvider(ctx, access.Status.ProviderType)
+	// the provider is only recorded once the access is created, there is nothing to revoke before
+	if access.Status.ProviderType == "" {
+		return nil
+	}
+
+	// accesses are cleaned up even when their namespace is no longer allowed to use the provider
+	provider, err := r.ProviderController.GetProvider(ctx, providerOf(access))
 	if err != nil {
 		return fmt.Errorf("getting provider: %w", err)
 	}
@@ -211,7 +318,7 @@ func (r *SecretAccessReconciler) deleteAccess(ctx context.Context, reqLogger log
 }
 
 func (r *SecretAccessReconciler) updateAccess(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, access *secretsv1alpha1.ExternalSecretAccess) error {
-	provider, err := r.ProviderController.GetProvider(ctx, access.Status.ProviderType)
+	provider, err := r.ProviderController.providerFor(ctx, r.Client, access)
 	if err != nil {
 		return fmt.Errorf("getting provider: %w", err)
 	}
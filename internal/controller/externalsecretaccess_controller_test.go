@@ -0,0 +1,213 @@
+package controller
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/fake"
+)
+
+func serviceAccountSubject(namespace, name string) secretsv1alpha1.SecretAccessSubject {
+	return secretsv1alpha1.SecretAccessSubject{
+		ServiceAccount: &secretsv1alpha1.SecretAccessSubjectServiceAccount{Namespace: namespace, Name: name},
+	}
+}
+
+func newExternalSecretAccess(name, secretName string, subjects ...secretsv1alpha1.SecretAccessSubject) *secretsv1alpha1.ExternalSecretAccess {
+	return &secretsv1alpha1.ExternalSecretAccess{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: secretsv1alpha1.ExternalSecretAccessSpec{
+			SecretName:     secretName,
+			AccessSubjects: subjects,
+		},
+	}
+}
+
+func reconcileAccess(ctx context.Context, access *secretsv1alpha1.ExternalSecretAccess) error {
+	_, err := accessReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: access.Namespace, Name: access.Name}})
+	return err
+}
+
+func getAccess(ctx context.Context, access *secretsv1alpha1.ExternalSecretAccess) *secretsv1alpha1.ExternalSecretAccess {
+	current := &secretsv1alpha1.ExternalSecretAccess{}
+	Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: access.Namespace, Name: access.Name}, current)).To(Succeed())
+	return current
+}
+
+// startAccessManager runs a SecretAccessReconciler in a manager until the end of the spec, for the
+// specs of its watches. The manager has a provider controller of its own.
+func startAccessManager(ctx context.Context) {
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{Scheme: scheme.Scheme, Metrics: metricsserver.Options{BindAddress: "0"}})
+	Expect(err).NotTo(HaveOccurred())
+	reconciler := &SecretAccessReconciler{
+		Client:             mgr.GetClient(),
+		Scheme:             scheme.Scheme,
+		ProviderController: NewProviderController(mgr.GetClient(), WithProvider(fakeProviderRef, fakeProvider), WithClusterID(testClusterID)),
+	}
+	Expect(reconciler.SetupWithManager(mgr)).To(Succeed())
+
+	mgrCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer GinkgoRecover()
+		defer close(done)
+		Expect(mgr.Start(mgrCtx)).To(Succeed())
+	}()
+	DeferCleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+var _ = Describe("SecretAccessReconciler", func() {
+	var (
+		ctx    context.Context
+		secret *secretsv1alpha1.ExternalSecret
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		fakeProvider.Reset()
+		secret = createSecret(ctx, newExternalSecret(uniqueName("db"), "hunter2"))
+	})
+
+	It("grants the subjects access to the secret", func() {
+		access := newExternalSecretAccess(uniqueName("reader"), secret.Name, serviceAccountSubject("default", "api"))
+		Expect(k8sClient.Create(ctx, access)).To(Succeed())
+		Expect(reconcileAccess(ctx, access)).To(Succeed())
+
+		access = getAccess(ctx, access)
+		Expect(controllerutil.ContainsFinalizer(access, secretsv1alpha1.SecretFinalizer)).To(BeTrue())
+		Expect(access.Status.Created).To(BeTrue())
+		Expect(access.Status.ProviderType).To(Equal(fakeProviderName))
+		Expect(access.Status.Subjects).To(Equal(access.Spec.AccessSubjects))
+		Expect(access.Status.Provider).To(HaveKeyWithValue("Secret", secret.Name))
+
+		subjects, ok := fakeProvider.Access("default/" + access.Name)
+		Expect(ok).To(BeTrue())
+		Expect(subjects).To(Equal(access.Spec.AccessSubjects))
+	})
+
+	It("updates the subjects", func() {
+		access := newExternalSecretAccess(uniqueName("reader"), secret.Name, serviceAccountSubject("default", "api"))
+		Expect(k8sClient.Create(ctx, access)).To(Succeed())
+		Expect(reconcileAccess(ctx, access)).To(Succeed())
+
+		access = getAccess(ctx, access)
+		access.Spec.AccessSubjects = append(access.Spec.AccessSubjects, serviceAccountSubject("default", "worker"))
+		Expect(k8sClient.Update(ctx, access)).To(Succeed())
+		Expect(reconcileAccess(ctx, access)).To(Succeed())
+
+		Expect(fakeProvider.Calls(fake.MethodUpdateAccess)).To(HaveLen(1))
+		Expect(getAccess(ctx, access).Status.Subjects).To(HaveLen(2))
+		subjects, _ := fakeProvider.Access("default/" + access.Name)
+		Expect(subjects).To(HaveLen(2))
+	})
+
+	It("waits for the secret to be created", func() {
+		pending := newExternalSecret(uniqueName("pending"), "hunter2")
+		Expect(k8sClient.Create(ctx, pending)).To(Succeed())
+
+		access := newExternalSecretAccess(uniqueName("reader"), pending.Name, serviceAccountSubject("default", "api"))
+		Expect(k8sClient.Create(ctx, access)).To(Succeed())
+		Expect(reconcileAccess(ctx, access)).To(MatchError(ContainSubstring("target secret not successfully created")))
+
+		condition := meta.FindStatusCondition(getAccess(ctx, access).Status.Conditions, "Unavailable")
+		Expect(condition).NotTo(BeNil())
+		Expect(fakeProvider.Calls(fake.MethodCreateAccess)).To(BeEmpty())
+	})
+
+	It("ignores accesses to missing secrets", func() {
+		access := newExternalSecretAccess(uniqueName("reader"), "missing", serviceAccountSubject("default", "api"))
+		Expect(k8sClient.Create(ctx, access)).To(Succeed())
+		Expect(reconcileAccess(ctx, access)).To(Succeed())
+		Expect(getAccess(ctx, access).Status.Created).To(BeFalse())
+	})
+
+	It("reports provider errors in the status", func() {
+		fakeProvider.FailOn(fake.MethodCreateAccess, errors.New("permission denied"))
+
+		access := newExternalSecretAccess(uniqueName("reader"), secret.Name, serviceAccountSubject("default", "api"))
+		Expect(k8sClient.Create(ctx, access)).To(Succeed())
+		Expect(reconcileAccess(ctx, access)).To(MatchError(ContainSubstring("permission denied")))
+
+		access = getAccess(ctx, access)
+		Expect(access.Status.Created).To(BeFalse())
+		Expect(meta.FindStatusCondition(access.Status.Conditions, "Unavailable").Message).To(ContainSubstring("permission denied"))
+	})
+
+	It("revokes the access and removes the finalizer on deletion", func() {
+		access := newExternalSecretAccess(uniqueName("reader"), secret.Name, serviceAccountSubject("default", "api"))
+		Expect(k8sClient.Create(ctx, access)).To(Succeed())
+		Expect(reconcileAccess(ctx, access)).To(Succeed())
+
+		Expect(k8sClient.Delete(ctx, access)).To(Succeed())
+		Expect(reconcileAccess(ctx, access)).To(Succeed())
+
+		Expect(fakeProvider.Calls(fake.MethodDeleteAccess)).To(HaveLen(1))
+		_, ok := fakeProvider.Access("default/" + access.Name)
+		Expect(ok).To(BeFalse())
+
+		err := k8sClient.Get(ctx, types.NamespacedName{Namespace: access.Namespace, Name: access.Name}, &secretsv1alpha1.ExternalSecretAccess{})
+		Expect(kerrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("removes the finalizer of accesses that were never created", func() {
+		fakeProvider.FailOn(fake.MethodCreateAccess, errors.New("permission denied"))
+
+		access := newExternalSecretAccess(uniqueName("reader"), secret.Name, serviceAccountSubject("default", "api"))
+		Expect(k8sClient.Create(ctx, access)).To(Succeed())
+		Expect(reconcileAccess(ctx, access)).To(MatchError(ContainSubstring("permission denied")))
+		Expect(getAccess(ctx, access).Status.ProviderType).To(BeEmpty())
+
+		Expect(k8sClient.Delete(ctx, access)).To(Succeed())
+		Expect(reconcileAccess(ctx, access)).To(Succeed())
+		Expect(fakeProvider.Calls(fake.MethodDeleteAccess)).To(BeEmpty())
+
+		err := k8sClient.Get(ctx, types.NamespacedName{Namespace: access.Namespace, Name: access.Name}, &secretsv1alpha1.ExternalSecretAccess{})
+		Expect(kerrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("updates the grants of the accesses when their secret changes", func() {
+		startAccessManager(ctx)
+
+		access := newExternalSecretAccess(uniqueName("reader"), secret.Name, serviceAccountSubject("default", "api"))
+		Expect(k8sClient.Create(ctx, access)).To(Succeed())
+		Eventually(func() map[string]string {
+			return getAccess(ctx, access).Status.Provider
+		}).Should(HaveKeyWithValue("SecretVersion", "1"))
+
+		value := "hunter3"
+		secret = getSecret(ctx, secret)
+		secret.Spec.SecretString = &value
+		Expect(k8sClient.Update(ctx, secret)).To(Succeed())
+		Expect(reconcileSecret(ctx, secret)).To(Succeed())
+
+		Eventually(func() map[string]string {
+			return getAccess(ctx, access).Status.Provider
+		}).Should(HaveKeyWithValue("SecretVersion", "2"))
+	})
+
+	It("keeps the finalizer while the provider fails to revoke the access", func() {
+		access := newExternalSecretAccess(uniqueName("reader"), secret.Name, serviceAccountSubject("default", "api"))
+		Expect(k8sClient.Create(ctx, access)).To(Succeed())
+		Expect(reconcileAccess(ctx, access)).To(Succeed())
+
+		fakeProvider.FailOn(fake.MethodDeleteAccess, errors.New("permission denied"))
+		Expect(k8sClient.Delete(ctx, access)).To(Succeed())
+		Expect(reconcileAccess(ctx, access)).To(MatchError(ContainSubstring("permission denied")))
+		Expect(controllerutil.ContainsFinalizer(getAccess(ctx, access), secretsv1alpha1.SecretFinalizer)).To(BeTrue())
+	})
+})
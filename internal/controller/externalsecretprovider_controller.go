@@ -18,15 +18,33 @@ package controller
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
-	"k8s.io/apimachinery/pkg/api/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
-	"github.com/tiagoposse/secretsbeam-operator/internal/providers/aws"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/registry"
+)
+
+const (
+	defaultHealthCheckInterval = 5 * time.Minute
+	healthCheckTimeout         = 30 * time.Second
 )
 
 // SecretProviderReconciler reconciles a SecretProvider object
@@ -39,13 +57,13 @@ type SecretProviderReconciler struct {
 //+kubebuilder:rbac:groups=orbitops.dev,resources=externalsecretproviders,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=orbitops.dev,resources=externalsecretproviders/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=orbitops.dev,resources=externalsecretproviders/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
 
-// Reconcile is part of the main kubernetes reconciliation loop which aims to
-// move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the SecretProvider object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
+// Reconcile keeps the provider of an ExternalSecretProvider loaded in the ProviderController.
+// It adds the finalizer, loads the provider, which is only rebuilt when its spec changed, checks
+// its health and reports the result in the Ready condition of the status. Healthy providers are
+// requeued at their health check interval, or sooner while the controllers using them missed their
+// last change. A deleted provider is removed once no ExternalSecret or ExternalSecretAccess uses it.
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.16.3/pkg/reconcile
@@ -55,29 +73,328 @@ func (r *SecretProviderReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 	provider := &secretsv1alpha1.ExternalSecretProvider{}
 	err := r.Get(ctx, req.NamespacedName, provider)
 	if err != nil {
-		if errors.IsNotFound(err) {
-			reqLogger.Info("SecretProvider resource not found. Ignoring since object must be deleted.")
-			return ctrl.Result{}, nil
+		if kerrors.IsNotFound(err) {
+			reqLogger.Info("SecretProvider resource not found, removing its provider.")
+			return r.remove(ctx, ProviderRef{Kind: secretsv1alpha1.ProviderKindNamespaced, Namespace: req.Namespace, Name: req.Name}), nil
+		}
+
+		return ctrl.Result{}, err
+	}
+
+	return r.reconcile(ctx, provider)
+}
+
+// reconcile loads the provider of an ExternalSecretProvider or ClusterExternalSecretProvider,
+// checks its health and reports both in its status
+func (r *SecretProviderReconciler) reconcile(ctx context.Context, provider providerObject) (ctrl.Result, error) {
+	reqLogger := log.FromContext(ctx)
+	spec, status := provider.ProviderSpec(), provider.ProviderStatus()
+
+	if provider.GetDeletionTimestamp() != nil {
+		return ctrl.Result{}, r.finalize(ctx, provider)
+	}
+
+	if !controllerutil.ContainsFinalizer(provider, secretsv1alpha1.SecretFinalizer) {
+		controllerutil.AddFinalizer(provider, secretsv1alpha1.SecretFinalizer)
+		if err := r.Update(ctx, provider); err != nil {
+			return ctrl.Result{}, fmt.Errorf("adding finalizer: %w", err)
+		}
+	}
+
+	loadErr := r.ProviderController.Load(provider)
+
+	now := v1.Now()
+	status.LastCheckTime = &now
+	status.Message = ""
+	status.Identity = ""
+	status.Account = ""
+	status.Region = ""
+	status.Capabilities = nil
+
+	condition := v1.Condition{
+		Type:               "Ready",
+		Status:             v1.ConditionTrue,
+		Reason:             "Initialized",
+		Message:            "Provider initialized",
+		ObservedGeneration: provider.GetGeneration(),
+	}
+
+	var (
+		unknownType *registry.UnknownTypeError
+		invalid     *registry.ConfigError
+	)
+	if loadErr != nil {
+		reqLogger.Error(loadErr, "loading provider")
+
+		condition.Status = v1.ConditionFalse
+		condition.Message = loadErr.Error()
+		switch {
+		case errors.As(loadErr, &unknownType):
+			condition.Reason = "UnknownProviderType"
+		case errors.As(loadErr, &invalid):
+			condition.Reason = "InvalidConfig"
+		default:
+			condition.Reason = "InitFailed"
 		}
+		status.Message = loadErr.Error()
+
+		// degraded only applies to providers that are running
+		meta.RemoveStatusCondition(&status.Conditions, "Degraded")
+	} else {
+		r.checkHealth(ctx, provider, &condition)
+		if p, err := r.ProviderController.GetProvider(ctx, refOf(provider)); err == nil {
+			status.Capabilities = registry.CapabilitiesOf(p).Names()
+		}
+	}
 
+	meta.SetStatusCondition(&status.Conditions, condition)
+
+	// the untyped config keeps working for types with a typed config, until it is removed
+	if spec.HasTypedConfig() && len(spec.Config) > 0 {
+		message := fmt.Sprintf("spec.config is deprecated for provider %s, use spec.%s instead", spec.Provider, spec.Provider)
+		reqLogger.Info(message)
+		meta.SetStatusCondition(&status.Conditions, v1.Condition{
+			Type:               "Deprecated",
+			Status:             v1.ConditionTrue,
+			Reason:             "UntypedConfig",
+			Message:            message,
+			ObservedGeneration: provider.GetGeneration(),
+		})
+	} else {
+		meta.RemoveStatusCondition(&status.Conditions, "Deprecated")
+	}
+
+	if err := r.Status().Update(ctx, provider); err != nil {
 		return ctrl.Result{}, err
 	}
 
-	switch provider.Spec.Provider {
-	case "aws":
-		p := &aws.AwsProvider{}
-		if err := p.Init(provider.Spec.Config); err != nil {
-			return ctrl.Result{}, err
+	// unknown types and invalid configs are only fixed by changing the spec, other failures are retried
+	if unknownType != nil || invalid != nil {
+		return ctrl.Result{}, nil
+	} else if loadErr != nil {
+		return ctrl.Result{}, loadErr
+	}
+
+	return r.requeueUnnotified(ctx, refOf(provider), ctrl.Result{RequeueAfter: healthCheckInterval(provider)}), nil
+}
+
+// remove forgets the provider of a deleted ExternalSecretProvider or ClusterExternalSecretProvider
+func (r *SecretProviderReconciler) remove(ctx context.Context, ref ProviderRef) ctrl.Result {
+	r.ProviderController.Remove(ref)
+	return r.requeueUnnotified(ctx, ref, ctrl.Result{})
+}
+
+// requeueUnnotified requeues a provider soon while the controllers using it missed its last change,
+// otherwise it returns result
+func (r *SecretProviderReconciler) requeueUnnotified(ctx context.Context, ref ProviderRef, result ctrl.Result) ctrl.Result {
+	if !r.ProviderController.Unnotified(ref) {
+		return result
+	}
+
+	log.FromContext(ctx).Info("the change buffer of a controller is full, notifying the provider change again later", "provider", ref.String())
+	return ctrl.Result{RequeueAfter: notifyRetryInterval}
+}
+
+// finalize removes the provider once no ExternalSecret or ExternalSecretAccess uses it, until then
+// the deletion is blocked and reported in the Ready condition. The provider is reconciled again
+// when the objects using it are deleted.
+func (r *SecretProviderReconciler) finalize(ctx context.Context, provider providerObject) error {
+	reqLogger := log.FromContext(ctx)
+
+	if !controllerutil.ContainsFinalizer(provider, secretsv1alpha1.SecretFinalizer) {
+		return nil
+	}
+
+	dependents, err := r.dependents(ctx, refOf(provider))
+	if err != nil {
+		return fmt.Errorf("listing objects using the provider: %w", err)
+	}
+
+	if len(dependents) > 0 {
+		message := fmt.Sprintf("deletion is blocked by %d objects using the provider: %s", len(dependents), strings.Join(dependents, ", "))
+		reqLogger.Info(message)
+
+		status := provider.ProviderStatus()
+		meta.SetStatusCondition(&status.Conditions, v1.Condition{
+			Type:               "Ready",
+			Status:             v1.ConditionFalse,
+			Reason:             "DeletionBlocked",
+			Message:            message,
+			ObservedGeneration: provider.GetGeneration(),
+		})
+		status.Message = message
+
+		return r.Status().Update(ctx, provider)
+	}
+
+	r.ProviderController.Remove(refOf(provider))
+
+	controllerutil.RemoveFinalizer(provider, secretsv1alpha1.SecretFinalizer)
+	if err := r.Update(ctx, provider); err != nil {
+		return fmt.Errorf("removing finalizer: %w", err)
+	}
+
+	return nil
+}
+
+// dependents returns the ExternalSecrets and ExternalSecretAccesses using a provider, including
+// the ones being deleted which still need the provider to be finalized
+func (r *SecretProviderReconciler) dependents(ctx context.Context, ref ProviderRef) ([]string, error) {
+	dependents := make([]string, 0)
+
+	secrets := &secretsv1alpha1.ExternalSecretList{}
+	if err := r.List(ctx, secrets); err != nil {
+		return nil, err
+	}
+	for _, secret := range secrets.Items {
+		if providerOf(&secret) == ref {
+			dependents = append(dependents, fmt.Sprintf("ExternalSecret %s/%s", secret.Namespace, secret.Name))
+		}
+	}
+
+	accesses := &secretsv1alpha1.ExternalSecretAccessList{}
+	if err := r.List(ctx, accesses); err != nil {
+		return nil, err
+	}
+	for _, access := range accesses.Items {
+		if providerOf(&access) == ref {
+			dependents = append(dependents, fmt.Sprintf("ExternalSecretAccess %s/%s", access.Namespace, access.Name))
+		}
+	}
+
+	return dependents, nil
+}
+
+// providerOf returns the provider used by an ExternalSecret or ExternalSecretAccess, accesses
+// record the provider of their secret once created. ExternalSecretProviders are referenced from
+// their own namespace.
+func providerOf(obj client.Object) ProviderRef {
+	var ref ProviderRef
+	switch o := obj.(type) {
+	case *secretsv1alpha1.ExternalSecret:
+		ref = ProviderRef{Kind: o.Spec.ProviderKind, Name: o.Spec.Provider}
+	case *secretsv1alpha1.ExternalSecretAccess:
+		ref = ProviderRef{Kind: o.Status.ProviderKind, Name: o.Status.ProviderType}
+	default:
+		return ref
+	}
+
+	if ref.Kind != secretsv1alpha1.ProviderKindCluster {
+		ref.Kind = secretsv1alpha1.ProviderKindNamespaced
+		ref.Namespace = obj.GetNamespace()
+	}
+
+	return ref
+}
+
+// deletingProviderOf maps an object using a provider of the kind to the provider, when it is being deleted
+func deletingProviderOf(cli client.Client, kind string) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		ref := providerOf(obj)
+		if ref.Kind != kind {
+			return nil
+		}
+
+		var provider client.Object = &secretsv1alpha1.ExternalSecretProvider{}
+		if kind == secretsv1alpha1.ProviderKindCluster {
+			provider = &secretsv1alpha1.ClusterExternalSecretProvider{}
 		}
-		r.ProviderController.Add(provider.Name, p)
+		key := types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}
+		if err := cli.Get(ctx, key, provider); err != nil {
+			if !kerrors.IsNotFound(err) {
+				log.FromContext(ctx).Error(err, "getting provider", "provider", ref.String())
+			}
+			return nil
+		}
+
+		if provider.GetDeletionTimestamp() == nil {
+			return nil
+		}
+
+		return []reconcile.Request{{NamespacedName: key}}
+	}
+}
+
+// checkHealth runs the health check of the loaded provider, when it implements one, and records
+// its result in the status and the Degraded condition. A failed check also makes the provider
+// not ready, while it stays loaded so that a transient failure does not interrupt reconciles.
+func (r *SecretProviderReconciler) checkHealth(ctx context.Context, provider providerObject, ready *v1.Condition) {
+	reqLogger := log.FromContext(ctx)
+	status := provider.ProviderStatus()
+
+	p, _ := r.ProviderController.GetProvider(ctx, refOf(provider))
+	checker, ok := p.(registry.HealthChecker)
+	if !ok {
+		meta.RemoveStatusCondition(&status.Conditions, "Degraded")
+		return
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	degraded := v1.Condition{
+		Type:               "Degraded",
+		Status:             v1.ConditionFalse,
+		Reason:             "HealthCheckSucceeded",
+		Message:            "Health check succeeded",
+		ObservedGeneration: provider.GetGeneration(),
 	}
 
-	return ctrl.Result{}, nil
+	health, err := checker.CheckHealth(checkCtx)
+	if err != nil {
+		reqLogger.Error(err, "checking provider health")
+
+		message := fmt.Sprintf("health check failed: %s", err)
+		ready.Status = v1.ConditionFalse
+		ready.Reason = "HealthCheckFailed"
+		ready.Message = message
+		degraded.Status = v1.ConditionTrue
+		degraded.Reason = "HealthCheckFailed"
+		degraded.Message = message
+		status.Message = message
+	} else {
+		ready.Reason = "HealthCheckSucceeded"
+		ready.Message = "Provider initialized and healthy"
+		if health != nil {
+			status.Identity = health.Identity
+			status.Account = health.Account
+			status.Region = health.Region
+		}
+	}
+
+	meta.SetStatusCondition(&status.Conditions, degraded)
+}
+
+// healthCheckInterval returns how long to wait before checking the provider again, 0 when periodic
+// checks are disabled
+func healthCheckInterval(provider providerObject) time.Duration {
+	spec := provider.ProviderSpec()
+	if spec.HealthCheckInterval == nil {
+		return defaultHealthCheckInterval
+	}
+
+	return spec.HealthCheckInterval.Duration
+}
+
+// dependentsChanged filters the events of the objects that stop using a provider, when they are
+// deleted or move to another provider
+var dependentsChanged = predicate.Funcs{
+	CreateFunc:  func(event.CreateEvent) bool { return false },
+	DeleteFunc:  func(event.DeleteEvent) bool { return true },
+	GenericFunc: func(event.GenericEvent) bool { return false },
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		return providerOf(e.ObjectOld) != providerOf(e.ObjectNew)
+	},
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *SecretProviderReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	deletingProvider := handler.EnqueueRequestsFromMapFunc(deletingProviderOf(r.Client, secretsv1alpha1.ProviderKindNamespaced))
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&secretsv1alpha1.ExternalSecretProvider{}).
+		// status updates do not change the provider
+		For(&secretsv1alpha1.ExternalSecretProvider{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&secretsv1alpha1.ExternalSecret{}, deletingProvider, builder.WithPredicates(dependentsChanged)).
+		Watches(&secretsv1alpha1.ExternalSecretAccess{}, deletingProvider, builder.WithPredicates(dependentsChanged)).
 		Complete(r)
 }
@@ -0,0 +1,640 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gstruct"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/aws"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/fake"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/registry"
+)
+
+// testProviderType is registered with a factory building fakes, the last one built is testProvider
+const testProviderType = "test"
+
+var testProvider *fake.FakeProvider
+
+type testProviderConfig struct {
+	Endpoint string `json:"endpoint"`
+	Fail     string `json:"fail"`
+}
+
+func init() {
+	registry.Register(testProviderType, func(registry.Options) registry.Provider {
+		testProvider = &fake.FakeProvider{}
+		return testProvider
+	}, registry.SchemaOf(testProviderConfig{}, "endpoint"))
+}
+
+// healthCheckedType is registered with a factory building fakes with a health check, which
+// returns testHealth or fails with testHealthErr
+const healthCheckedType = "test-health-checked"
+
+var (
+	testHealth    = &registry.Health{Identity: "operator", Account: "team-a", Region: "eu-west-1"}
+	testHealthErr error
+)
+
+type healthCheckedProvider struct {
+	*fake.FakeProvider
+}
+
+func (p *healthCheckedProvider) CheckHealth(context.Context) (*registry.Health, error) {
+	if testHealthErr != nil {
+		return nil, testHealthErr
+	}
+
+	return testHealth, nil
+}
+
+func init() {
+	registry.Register(healthCheckedType, func(registry.Options) registry.Provider {
+		return &healthCheckedProvider{FakeProvider: &fake.FakeProvider{}}
+	}, registry.ConfigSchema{})
+}
+
+// newFakeSTS answers STS GetCallerIdentity, the health check of the aws providers built by the tests
+func newFakeSTS() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprint(w, `<GetCallerIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">`+
+			`<GetCallerIdentityResult><Arn>arn:aws:sts::111122223333:assumed-role/operator/session</Arn>`+
+			`<Account>111122223333</Account><UserId>AROAFAKE:session</UserId></GetCallerIdentityResult>`+
+			`</GetCallerIdentityResponse>`)
+	}))
+}
+
+func newExternalSecretProvider(name, providerType string, config map[string]string) *secretsv1alpha1.ExternalSecretProvider {
+	return &secretsv1alpha1.ExternalSecretProvider{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: secretsv1alpha1.ExternalSecretProviderSpec{
+			Provider: providerType,
+			Config:   config,
+		},
+	}
+}
+
+func reconcileProvider(ctx context.Context, provider *secretsv1alpha1.ExternalSecretProvider) error {
+	_, err := providerReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: provider.Namespace, Name: provider.Name}})
+	return err
+}
+
+func readyCondition(ctx context.Context, provider *secretsv1alpha1.ExternalSecretProvider) *metav1.Condition {
+	return meta.FindStatusCondition(currentStatus(ctx, provider).Conditions, "Ready")
+}
+
+func currentStatus(ctx context.Context, provider *secretsv1alpha1.ExternalSecretProvider) secretsv1alpha1.ExternalSecretProviderStatus {
+	current := &secretsv1alpha1.ExternalSecretProvider{}
+	Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: provider.Namespace, Name: provider.Name}, current)).To(Succeed())
+	return current.Status
+}
+
+var _ = Describe("SecretProviderReconciler", func() {
+	var ctx context.Context
+
+	BeforeEach(func() {
+		ctx = context.Background()
+	})
+
+	It("builds the provider of a registered type", func() {
+		provider := newExternalSecretProvider(uniqueName("backend"), testProviderType, map[string]string{"endpoint": "https://backend"})
+		Expect(k8sClient.Create(ctx, provider)).To(Succeed())
+		Expect(reconcileProvider(ctx, provider)).To(Succeed())
+
+		condition := readyCondition(ctx, provider)
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal("Initialized"))
+
+		p, err := providerController.GetProvider(ctx, refOf(provider))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(p).To(BeIdenticalTo(testProvider))
+		Expect(testProvider.Config()).To(HaveKeyWithValue("endpoint", "https://backend"))
+	})
+
+	It("reports unknown provider types", func() {
+		provider := newExternalSecretProvider(uniqueName("backend"), "dynamodb", nil)
+		Expect(k8sClient.Create(ctx, provider)).To(Succeed())
+		Expect(reconcileProvider(ctx, provider)).To(Succeed())
+
+		condition := readyCondition(ctx, provider)
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal("UnknownProviderType"))
+		Expect(condition.Message).To(ContainSubstring(`unknown provider type "dynamodb"`))
+
+		_, err := providerController.GetProvider(ctx, refOf(provider))
+		Expect(err).To(MatchError(ContainSubstring("cannot find provider")))
+	})
+
+	It("reports invalid configs", func() {
+		provider := newExternalSecretProvider(uniqueName("backend"), testProviderType, map[string]string{"endpiont": "https://backend"})
+		Expect(k8sClient.Create(ctx, provider)).To(Succeed())
+		Expect(reconcileProvider(ctx, provider)).To(Succeed())
+
+		condition := readyCondition(ctx, provider)
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal("InvalidConfig"))
+		Expect(condition.Message).To(Equal("invalid config: endpoint is required"))
+	})
+
+	It("retries providers failing to initialize", func() {
+		registry.Register("test-failing", func(registry.Options) registry.Provider {
+			p := &fake.FakeProvider{}
+			p.FailOn(fake.MethodInit, errors.New("backend unreachable"))
+			return p
+		}, registry.ConfigSchema{})
+
+		provider := newExternalSecretProvider(uniqueName("backend"), "test-failing", nil)
+		Expect(k8sClient.Create(ctx, provider)).To(Succeed())
+		Expect(reconcileProvider(ctx, provider)).To(MatchError("backend unreachable"))
+
+		condition := readyCondition(ctx, provider)
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal("InitFailed"))
+	})
+
+	Context("lifecycle", func() {
+		isGone := func(provider *secretsv1alpha1.ExternalSecretProvider) bool {
+			return kerrors.IsNotFound(k8sClient.Get(ctx, client.ObjectKeyFromObject(provider), &secretsv1alpha1.ExternalSecretProvider{}))
+		}
+
+		It("removes deleted providers", func() {
+			provider := newExternalSecretProvider(uniqueName("backend"), testProviderType, map[string]string{"endpoint": "https://backend"})
+			Expect(k8sClient.Create(ctx, provider)).To(Succeed())
+			Expect(reconcileProvider(ctx, provider)).To(Succeed())
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(provider), provider)).To(Succeed())
+			Expect(controllerutil.ContainsFinalizer(provider, secretsv1alpha1.SecretFinalizer)).To(BeTrue())
+
+			Expect(k8sClient.Delete(ctx, provider)).To(Succeed())
+			Expect(reconcileProvider(ctx, provider)).To(Succeed())
+			Expect(isGone(provider)).To(BeTrue())
+
+			_, err := providerController.GetProvider(ctx, refOf(provider))
+			Expect(err).To(MatchError(ContainSubstring("cannot find provider")))
+		})
+
+		It("blocks the deletion while secrets use the provider", func() {
+			provider := newExternalSecretProvider(uniqueName("backend"), testProviderType, map[string]string{"endpoint": "https://backend"})
+			Expect(k8sClient.Create(ctx, provider)).To(Succeed())
+			Expect(reconcileProvider(ctx, provider)).To(Succeed())
+
+			secret := newExternalSecret(uniqueName("db"), "hunter2")
+			secret.Spec.Provider = provider.Name
+			secret = createSecret(ctx, secret)
+
+			Expect(k8sClient.Delete(ctx, provider)).To(Succeed())
+			Expect(reconcileProvider(ctx, provider)).To(Succeed())
+			Expect(isGone(provider)).To(BeFalse())
+			Expect(readyCondition(ctx, provider)).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Status":  Equal(metav1.ConditionFalse),
+				"Reason":  Equal("DeletionBlocked"),
+				"Message": ContainSubstring("ExternalSecret default/" + secret.Name),
+			})))
+
+			By("deleting the secret with the provider")
+			Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+			Expect(reconcileSecret(ctx, secret)).To(Succeed())
+			Expect(testProvider.Calls()).To(ContainElement(fake.Call{Method: fake.MethodDeleteSecret, Object: "default/" + secret.Name}))
+
+			By("reconciling the provider once the secret is gone")
+			Expect(deletingProviderOf(k8sClient, secretsv1alpha1.ProviderKindNamespaced)(ctx, secret)).To(ConsistOf(reconcile.Request{NamespacedName: client.ObjectKeyFromObject(provider)}))
+			Expect(reconcileProvider(ctx, provider)).To(Succeed())
+			Expect(isGone(provider)).To(BeTrue())
+		})
+
+		It("requeues the objects using a provider when it changes", func() {
+			provider := newExternalSecretProvider(uniqueName("backend"), testProviderType, map[string]string{"endpoint": "https://backend"})
+			Expect(k8sClient.Create(ctx, provider)).To(Succeed())
+
+			pc := NewProviderController(k8sClient)
+			changes := pc.Changes()
+			reconciler := &SecretProviderReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), ProviderController: pc}
+			_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(provider)})
+			Expect(err).NotTo(HaveOccurred())
+
+			var changed event.GenericEvent
+			Expect(changes).To(Receive(&changed))
+			Expect(changed.Object.GetName()).To(Equal(provider.Name))
+
+			By("not notifying reconciles of an unchanged provider")
+			_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(provider)})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changes).NotTo(Receive())
+
+			secret := newExternalSecret(uniqueName("db"), "hunter2")
+			secret.Spec.Provider = provider.Name
+			Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+			Expect(k8sClient.Create(ctx, newExternalSecret(uniqueName("other"), "hunter2"))).To(Succeed())
+			Expect(secretReconciler.secretsUsingProvider(ctx, changed.Object)).To(ConsistOf(
+				reconcile.Request{NamespacedName: client.ObjectKeyFromObject(secret)},
+			))
+
+			access := newExternalSecretAccess(uniqueName("reader"), secret.Name)
+			Expect(k8sClient.Create(ctx, access)).To(Succeed())
+			access.Status.Conditions = []metav1.Condition{}
+			access.Status.ProviderType = provider.Name
+			Expect(k8sClient.Status().Update(ctx, access)).To(Succeed())
+			Expect(accessReconciler.accessesUsingProvider(ctx, changed.Object)).To(ConsistOf(
+				reconcile.Request{NamespacedName: client.ObjectKeyFromObject(access)},
+			))
+
+			pc.Remove(refOf(provider))
+			Expect(changes).To(Receive())
+		})
+
+		It("only keeps the injected provider of its own reference", func() {
+			injected := &fake.FakeProvider{}
+			pc := NewProviderController(k8sClient, WithProvider(ProviderRef{Kind: secretsv1alpha1.ProviderKindNamespaced, Namespace: "other", Name: "backend"}, injected))
+
+			provider := newExternalSecretProvider("backend", testProviderType, map[string]string{"endpoint": "https://backend"})
+			Expect(k8sClient.Create(ctx, provider)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, provider))).To(Succeed())
+			})
+
+			Expect(pc.Load(provider)).To(Succeed())
+			p, err := pc.GetProvider(ctx, refOf(provider))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(p).To(BeIdenticalTo(testProvider))
+
+			pc.Remove(refOf(provider))
+			_, err = pc.GetProvider(ctx, refOf(provider))
+			Expect(err).To(MatchError(ContainSubstring("cannot find provider")))
+
+			By("keeping the injected provider")
+			pc.Remove(ProviderRef{Kind: secretsv1alpha1.ProviderKindNamespaced, Namespace: "other", Name: "backend"})
+			p, err = pc.GetProvider(ctx, ProviderRef{Kind: secretsv1alpha1.ProviderKindNamespaced, Namespace: "other", Name: "backend"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(p).To(BeIdenticalTo(injected))
+		})
+
+		It("does not block on subscribers lagging behind and notifies them again", func() {
+			provider := newExternalSecretProvider(uniqueName("backend"), testProviderType, map[string]string{"endpoint": "https://backend"})
+			Expect(k8sClient.Create(ctx, provider)).To(Succeed())
+
+			pc := NewProviderController(k8sClient)
+			changes := pc.Changes()
+			for i := 0; i < changesBufferSize; i++ {
+				pc.notify(ProviderRef{Kind: secretsv1alpha1.ProviderKindNamespaced, Namespace: "default", Name: fmt.Sprintf("other-%d", i)})
+			}
+
+			reconciler := &SecretProviderReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), ProviderController: pc}
+			request := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(provider)}
+			result, err := reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(notifyRetryInterval))
+			Expect(pc.Unnotified(refOf(provider))).To(BeTrue())
+
+			By("notifying the provider again once the subscriber caught up")
+			Expect(changes).To(HaveLen(changesBufferSize))
+			for len(changes) > 0 {
+				<-changes
+			}
+			result, err = reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(5 * time.Minute))
+			Expect(pc.Unnotified(refOf(provider))).To(BeFalse())
+
+			var changed event.GenericEvent
+			Expect(changes).To(Receive(&changed))
+			Expect(changed.Object.GetName()).To(Equal(provider.Name))
+		})
+
+		It("loads every provider at startup", func() {
+			valid := newExternalSecretProvider(uniqueName("backend"), testProviderType, map[string]string{"endpoint": "https://backend"})
+			Expect(k8sClient.Create(ctx, valid)).To(Succeed())
+			Expect(k8sClient.Create(ctx, newExternalSecretProvider(uniqueName("backend"), "dynamodb", nil))).To(Succeed())
+
+			pc := NewProviderController(k8sClient)
+			_, err := pc.GetProvider(ctx, refOf(valid))
+			Expect(err).To(HaveOccurred(), "providers are not loaded lazily")
+
+			Expect(pc.InitProviders(ctx, k8sClient)).To(Succeed(), "providers failing to load are reported by their reconciler")
+			_, err = pc.GetProvider(ctx, refOf(valid))
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Context("health checks", func() {
+		BeforeEach(func() {
+			testHealthErr = nil
+		})
+
+		reconcile := func(provider *secretsv1alpha1.ExternalSecretProvider) ctrl.Result {
+			result, err := providerReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: provider.Namespace, Name: provider.Name}})
+			Expect(err).NotTo(HaveOccurred())
+			return result
+		}
+
+		It("records the identity of healthy providers and checks them again later", func() {
+			provider := newExternalSecretProvider(uniqueName("backend"), healthCheckedType, nil)
+			Expect(k8sClient.Create(ctx, provider)).To(Succeed())
+			Expect(reconcile(provider).RequeueAfter).To(Equal(5 * time.Minute))
+
+			status := currentStatus(ctx, provider)
+			Expect(status).To(MatchFields(IgnoreExtras, Fields{
+				"Identity":      Equal("operator"),
+				"Account":       Equal("team-a"),
+				"Region":        Equal("eu-west-1"),
+				"LastCheckTime": Not(BeNil()),
+				"Message":       BeEmpty(),
+			}))
+			Expect(meta.IsStatusConditionTrue(status.Conditions, "Ready")).To(BeTrue())
+			Expect(meta.IsStatusConditionFalse(status.Conditions, "Degraded")).To(BeTrue())
+		})
+
+		It("reports failing health checks and recovers", func() {
+			provider := newExternalSecretProvider(uniqueName("backend"), healthCheckedType, nil)
+			provider.Spec.HealthCheckInterval = &metav1.Duration{Duration: time.Minute}
+			Expect(k8sClient.Create(ctx, provider)).To(Succeed())
+			Expect(reconcile(provider).RequeueAfter).To(Equal(time.Minute))
+			p, err := providerController.GetProvider(ctx, refOf(provider))
+			Expect(err).NotTo(HaveOccurred())
+
+			testHealthErr = errors.New("access denied")
+			Expect(reconcile(provider).RequeueAfter).To(Equal(time.Minute))
+
+			status := currentStatus(ctx, provider)
+			Expect(status.Message).To(Equal("health check failed: access denied"))
+			Expect(status.Identity).To(BeEmpty())
+			Expect(meta.FindStatusCondition(status.Conditions, "Ready")).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Status": Equal(metav1.ConditionFalse),
+				"Reason": Equal("HealthCheckFailed"),
+			})))
+			Expect(meta.IsStatusConditionTrue(status.Conditions, "Degraded")).To(BeTrue())
+
+			By("keeping the provider loaded while it is degraded")
+			Expect(providerController.GetProvider(ctx, refOf(provider))).To(BeIdenticalTo(p))
+
+			testHealthErr = nil
+			reconcile(provider)
+			status = currentStatus(ctx, provider)
+			Expect(status.Message).To(BeEmpty())
+			Expect(meta.IsStatusConditionTrue(status.Conditions, "Ready")).To(BeTrue())
+			Expect(meta.IsStatusConditionFalse(status.Conditions, "Degraded")).To(BeTrue())
+		})
+
+		It("only rebuilds the provider when its spec changes", func() {
+			provider := newExternalSecretProvider(uniqueName("backend"), healthCheckedType, nil)
+			Expect(k8sClient.Create(ctx, provider)).To(Succeed())
+			reconcile(provider)
+			p, err := providerController.GetProvider(ctx, refOf(provider))
+			Expect(err).NotTo(HaveOccurred())
+
+			reconcile(provider)
+			Expect(providerController.GetProvider(ctx, refOf(provider))).To(BeIdenticalTo(p))
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(provider), provider)).To(Succeed())
+			provider.Spec.HealthCheckInterval = &metav1.Duration{}
+			Expect(k8sClient.Update(ctx, provider)).To(Succeed())
+			Expect(reconcile(provider).RequeueAfter).To(BeZero(), "periodic checks are disabled")
+			Expect(providerController.GetProvider(ctx, refOf(provider))).NotTo(BeIdenticalTo(p))
+		})
+
+		It("does not report providers without a health check as degraded", func() {
+			provider := newExternalSecretProvider(uniqueName("backend"), testProviderType, map[string]string{"endpoint": "https://backend"})
+			Expect(k8sClient.Create(ctx, provider)).To(Succeed())
+			reconcile(provider)
+
+			status := currentStatus(ctx, provider)
+			Expect(meta.IsStatusConditionTrue(status.Conditions, "Ready")).To(BeTrue())
+			Expect(meta.FindStatusCondition(status.Conditions, "Degraded")).To(BeNil())
+			Expect(status.Identity).To(BeEmpty())
+		})
+	})
+
+	Context("typed configs", func() {
+		var sts *httptest.Server
+
+		BeforeEach(func() {
+			sts = newFakeSTS()
+			DeferCleanup(sts.Close)
+
+			GinkgoT().Setenv("AWS_REGION", "eu-west-1")
+			GinkgoT().Setenv("AWS_ACCESS_KEY_ID", "AKIDFAKE")
+			GinkgoT().Setenv("AWS_SECRET_ACCESS_KEY", "fake")
+			GinkgoT().Setenv("AWS_CONFIG_FILE", os.DevNull)
+			GinkgoT().Setenv("AWS_SHARED_CREDENTIALS_FILE", os.DevNull)
+		})
+
+		It("passes the typed config to the provider", func() {
+			provider := newExternalSecretProvider(uniqueName("aws"), secretsv1alpha1.SecretProviderAws, nil)
+			provider.Spec.Aws = &secretsv1alpha1.AwsProviderSpec{
+				Oidc:     &secretsv1alpha1.AwsOIDCSpec{ProviderARN: "arn:aws:iam::111122223333:oidc-provider/oidc.eks.eu-west-1.amazonaws.com/id/EXAMPLE"},
+				Endpoint: sts.URL,
+			}
+			Expect(k8sClient.Create(ctx, provider)).To(Succeed())
+			Expect(provider.Spec.Aws.Backend).To(Equal("secretsmanager"), "the default is applied at admission")
+			Expect(reconcileProvider(ctx, provider)).To(Succeed())
+			Expect(readyCondition(ctx, provider).Status).To(Equal(metav1.ConditionTrue))
+
+			p, err := providerController.GetProvider(ctx, refOf(provider))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(p).To(BeAssignableToTypeOf(&aws.AwsProvider{}))
+			Expect(p.(*aws.AwsProvider).AwsProviderConfig).To(MatchFields(IgnoreExtras, Fields{
+				"OidcProviderArn": Equal(provider.Spec.Aws.Oidc.ProviderARN),
+				"Backend":         Equal(aws.BackendSecretsManager),
+				"Endpoint":        Equal(sts.URL),
+			}))
+			Expect(p.(*aws.AwsProvider).ClusterID).To(Equal(testClusterID))
+
+			status := currentStatus(ctx, provider)
+			Expect(status.Identity).To(Equal("arn:aws:sts::111122223333:assumed-role/operator/session"))
+			Expect(status.Account).To(Equal("111122223333"))
+			Expect(status.Region).To(Equal("eu-west-1"))
+		})
+
+		It("keeps accepting the untyped config, with a deprecation condition", func() {
+			provider := newExternalSecretProvider(uniqueName("aws"), secretsv1alpha1.SecretProviderAws, map[string]string{"backend": "ssm", "endpoint": sts.URL})
+			Expect(k8sClient.Create(ctx, provider)).To(Succeed())
+			Expect(reconcileProvider(ctx, provider)).To(Succeed())
+			Expect(readyCondition(ctx, provider).Status).To(Equal(metav1.ConditionTrue))
+
+			current := &secretsv1alpha1.ExternalSecretProvider{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: provider.Namespace, Name: provider.Name}, current)).To(Succeed())
+			deprecated := meta.FindStatusCondition(current.Status.Conditions, "Deprecated")
+			Expect(deprecated).NotTo(BeNil())
+			Expect(deprecated.Message).To(Equal("spec.config is deprecated for provider aws, use spec.aws instead"))
+
+			By("clearing the condition once migrated")
+			current.Spec.Config = nil
+			current.Spec.Aws = &secretsv1alpha1.AwsProviderSpec{Backend: aws.BackendSsm, Endpoint: sts.URL}
+			Expect(k8sClient.Update(ctx, current)).To(Succeed())
+			Expect(reconcileProvider(ctx, provider)).To(Succeed())
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: provider.Namespace, Name: provider.Name}, current)).To(Succeed())
+			Expect(meta.FindStatusCondition(current.Status.Conditions, "Deprecated")).To(BeNil())
+		})
+
+		It("does not deprecate the config of types without a typed config", func() {
+			provider := newExternalSecretProvider(uniqueName("backend"), testProviderType, map[string]string{"endpoint": "https://backend"})
+			Expect(k8sClient.Create(ctx, provider)).To(Succeed())
+			Expect(reconcileProvider(ctx, provider)).To(Succeed())
+
+			current := &secretsv1alpha1.ExternalSecretProvider{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: provider.Namespace, Name: provider.Name}, current)).To(Succeed())
+			Expect(meta.FindStatusCondition(current.Status.Conditions, "Deprecated")).To(BeNil())
+		})
+
+		It("converts typed configs to the keys read by the providers", func() {
+			Expect((&secretsv1alpha1.GcpProviderSpec{ProjectID: "team-a", IamEndpoint: "http://localhost:8080"}).Config()).To(Equal(map[string]string{
+				"projectID":   "team-a",
+				"iamEndpoint": "http://localhost:8080",
+			}))
+			Expect((&secretsv1alpha1.VaultProviderSpec{Address: "https://vault:8200", Role: "operator", InsecureSkipVerify: true}).Config()).To(Equal(map[string]string{
+				"address":            "https://vault:8200",
+				"role":               "operator",
+				"insecureSkipVerify": "true",
+			}))
+			Expect((&secretsv1alpha1.CustomProviderSpec{Url: "https://backend", TokenSecretName: "token"}).Config()).To(Equal(map[string]string{
+				"url":             "https://backend",
+				"tokenSecretName": "token",
+			}))
+			Expect((&secretsv1alpha1.AwsProviderSpec{
+				Region: "eu-west-1",
+				Credentials: &secretsv1alpha1.AwsCredentialsSpec{
+					SecretRef:  &secretsv1alpha1.AwsSecretRefSpec{Name: "aws-keys"},
+					AssumeRole: &secretsv1alpha1.AwsAssumeRoleSpec{RoleARN: "arn:aws:iam::111122223333:role/admin", ExternalID: "tenant-a"},
+				},
+			}).Config()).To(Equal(map[string]string{
+				"region":              "eu-west-1",
+				"accessKeySecretName": "aws-keys",
+				"assumeRoleArn":       "arn:aws:iam::111122223333:role/admin",
+				"externalID":          "tenant-a",
+			}))
+			Expect((&secretsv1alpha1.AwsProviderSpec{
+				AccessMode:  "podIdentity",
+				PodIdentity: &secretsv1alpha1.AwsPodIdentitySpec{ClusterName: "prod"},
+			}).Config()).To(Equal(map[string]string{
+				"accessMode":  "podIdentity",
+				"clusterName": "prod",
+			}))
+			Expect((&secretsv1alpha1.AwsProviderSpec{
+				IAM: &secretsv1alpha1.AwsIAMSpec{
+					RoleNameTemplate:       "{{ .ClusterName }}-{{ .Name }}",
+					Path:                   "/secretsbeam/",
+					PermissionsBoundaryARN: "arn:aws:iam::111122223333:policy/boundary",
+					MaxSessionDuration:     &metav1.Duration{Duration: 4 * time.Hour},
+					ManagedPolicyARNs:      []string{"arn:aws:iam::aws:policy/ReadOnlyAccess", "arn:aws:iam::111122223333:policy/audit"},
+				},
+			}).Config()).To(Equal(map[string]string{
+				"roleNameTemplate":       "{{ .ClusterName }}-{{ .Name }}",
+				"iamPath":                "/secretsbeam/",
+				"permissionsBoundaryArn": "arn:aws:iam::111122223333:policy/boundary",
+				"maxSessionDuration":     "4h0m0s",
+				"managedPolicyArns":      "arn:aws:iam::aws:policy/ReadOnlyAccess,arn:aws:iam::111122223333:policy/audit",
+			}))
+			Expect((&secretsv1alpha1.AwsProviderSpec{
+				KMS: &secretsv1alpha1.AwsKMSSpec{Scope: "namespace", AliasPrefix: "alias/prod/"},
+			}).Config()).To(Equal(map[string]string{
+				"kmsKeyScope":       "namespace",
+				"kmsKeyAliasPrefix": "alias/prod/",
+			}))
+			Expect((&secretsv1alpha1.AwsProviderSpec{
+				IAM: &secretsv1alpha1.AwsIAMSpec{RoleScope: "serviceAccount", ServiceAccountRoleNameTemplate: "{{ .ClusterName }}-sa-{{ .Name }}"},
+			}).Config()).To(Equal(map[string]string{
+				"roleScope":                      "serviceAccount",
+				"serviceAccountRoleNameTemplate": "{{ .ClusterName }}-sa-{{ .Name }}",
+			}))
+		})
+
+		Context("admission", func() {
+			createUnstructured := func(spec map[string]interface{}) error {
+				provider := &unstructured.Unstructured{Object: map[string]interface{}{
+					"apiVersion": secretsv1alpha1.GroupVersion.String(),
+					"kind":       "ExternalSecretProvider",
+					"metadata":   map[string]interface{}{"name": uniqueName("invalid"), "namespace": "default"},
+					"spec":       spec,
+				}}
+				return k8sClient.Create(ctx, provider, &client.CreateOptions{Raw: &metav1.CreateOptions{FieldValidation: "Strict"}})
+			}
+
+			It("rejects unknown fields", func() {
+				err := createUnstructured(map[string]interface{}{
+					"provider": "aws",
+					"aws":      map[string]interface{}{"oidcProviderArn": "arn:aws:iam::111122223333:oidc-provider/example"},
+				})
+				Expect(err).To(MatchError(ContainSubstring(`unknown field "spec.aws.oidcProviderArn"`)))
+			})
+
+			It("validates the fields", func() {
+				err := createUnstructured(map[string]interface{}{
+					"provider": "aws",
+					"aws": map[string]interface{}{
+						"oidc":    map[string]interface{}{"providerARN": "oidc.eks.eu-west-1.amazonaws.com/id/EXAMPLE"},
+						"backend": "dynamodb",
+					},
+				})
+				Expect(kerrors.IsInvalid(err)).To(BeTrue())
+				Expect(err).To(MatchError(ContainSubstring("spec.aws.oidc.providerARN")))
+				Expect(err).To(MatchError(ContainSubstring("spec.aws.backend")))
+
+				err = createUnstructured(map[string]interface{}{
+					"provider": "custom",
+					"custom":   map[string]interface{}{"url": "https://backend", "tokenSecretKey": "token"},
+				})
+				Expect(err).To(MatchError(ContainSubstring("tokenSecretKey requires tokenSecretName")))
+
+				err = createUnstructured(map[string]interface{}{
+					"provider": "aws",
+					"aws": map[string]interface{}{
+						"credentials": map[string]interface{}{
+							"secretRef":   map[string]interface{}{"name": "aws-keys"},
+							"webIdentity": map[string]interface{}{"roleARN": "arn:aws:iam::111122223333:role/operator", "tokenFile": "/var/run/token"},
+							"assumeRole":  map[string]interface{}{"roleARN": "admin"},
+						},
+					},
+				})
+				Expect(err).To(MatchError(ContainSubstring("secretRef and webIdentity are mutually exclusive")))
+				Expect(err).To(MatchError(ContainSubstring("spec.aws.credentials.assumeRole.roleARN")))
+
+				err = createUnstructured(map[string]interface{}{
+					"provider": "aws",
+					"aws":      map[string]interface{}{"accessMode": "podIdentity"},
+				})
+				Expect(err).To(MatchError(ContainSubstring("podIdentity is required by the podIdentity access mode")))
+
+				err = createUnstructured(map[string]interface{}{
+					"provider": "aws",
+					"aws":      map[string]interface{}{"podIdentity": map[string]interface{}{"clusterName": "prod"}},
+				})
+				Expect(err).To(MatchError(ContainSubstring("podIdentity is only valid for the podIdentity access mode")))
+			})
+
+			It("rejects typed configs of another type", func() {
+				err := createUnstructured(map[string]interface{}{
+					"provider": "gcp",
+					"aws":      map[string]interface{}{"backend": "ssm"},
+				})
+				Expect(err).To(MatchError(ContainSubstring("aws is only valid for provider aws")))
+			})
+
+			It("rejects typed configs combined with the untyped config", func() {
+				err := createUnstructured(map[string]interface{}{
+					"provider": "vault",
+					"config":   map[string]interface{}{"address": "https://vault:8200"},
+					"vault":    map[string]interface{}{"address": "https://vault:8200"},
+				})
+				Expect(err).To(MatchError(ContainSubstring("config cannot be combined with a typed provider config")))
+			})
+		})
+	})
+})
@@ -3,69 +3,354 @@ package controller
 import (
 	"context"
 	"fmt"
+	"io"
 	"time"
 
-	"github.com/go-logr/logr"
 	sync "github.com/tiagoposse/go-sync-types"
-	"github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
 	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
-	"github.com/tiagoposse/secretsbeam-operator/internal/providers/aws"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/registry"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
-type Provider interface {
-	Init(config map[string]string) error
-	DeleteSecret(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret) error
-	CreateSecret(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, value string) error
-	UpdateSecret(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, value string) error
-	CreateAccess(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, access *secretsv1alpha1.ExternalSecretAccess) error
-	UpdateAccess(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, access *secretsv1alpha1.ExternalSecretAccess) error
-	DeleteAccess(ctx context.Context, reqLogger logr.Logger, access *secretsv1alpha1.ExternalSecretAccess) error
-	GetSecretLastChangedDate(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret) (*time.Time, error)
+// Provider is implemented by every secret backend, provider types are registered in package registry
+type Provider = registry.Provider
+
+// ProviderRef identifies a provider: an ExternalSecretProvider of a namespace or a
+// ClusterExternalSecretProvider, whose namespace is empty
+type ProviderRef struct {
+	Kind      string
+	Namespace string
+	Name      string
 }
 
-func NewProviderController(cli client.Client) *ProviderController {
-	return &ProviderController{
-		providers: sync.NewMap[string, Provider](),
-		cli:       cli,
+func (r ProviderRef) String() string {
+	if r.Namespace == "" {
+		return fmt.Sprintf("%s %s", r.Kind, r.Name)
 	}
+
+	return fmt.Sprintf("%s %s/%s", r.Kind, r.Namespace, r.Name)
 }
 
-type ProviderController struct {
-	providers *sync.Map[string, Provider]
-	cli       client.Client
+// providerObject is implemented by ExternalSecretProvider and ClusterExternalSecretProvider
+type providerObject interface {
+	client.Object
+	ProviderSpec() *secretsv1alpha1.ExternalSecretProviderSpec
+	ProviderStatus() *secretsv1alpha1.ExternalSecretProviderStatus
+}
+
+// refOf returns the reference of an ExternalSecretProvider or ClusterExternalSecretProvider
+func refOf(provider client.Object) ProviderRef {
+	if _, ok := provider.(*secretsv1alpha1.ClusterExternalSecretProvider); ok {
+		return ProviderRef{Kind: secretsv1alpha1.ProviderKindCluster, Name: provider.GetName()}
+	}
+
+	return ProviderRef{Kind: secretsv1alpha1.ProviderKindNamespaced, Namespace: provider.GetNamespace(), Name: provider.GetName()}
+}
+
+// NamespaceNotAllowedError is returned when an object uses a ClusterExternalSecretProvider that
+// does not allow its namespace
+type NamespaceNotAllowedError struct {
+	Provider  ProviderRef
+	Namespace string
+}
+
+func (e *NamespaceNotAllowedError) Error() string {
+	return fmt.Sprintf("%s does not allow namespace %s", e.Provider, e.Namespace)
+}
+
+// ProviderControllerOption configures a ProviderController
+type ProviderControllerOption func(*ProviderController)
+
+// WithProvider injects the provider of a reference, e.g. a fake in tests. Injected providers are not
+// replaced when the providers are loaded from the ExternalSecretProviders and
+// ClusterExternalSecretProviders of the cluster.
+func WithProvider(ref ProviderRef, provider Provider) ProviderControllerOption {
+	return func(pc *ProviderController) {
+		pc.providers.Put(ref, provider)
+		pc.injected[ref] = true
+	}
 }
 
-func (pc *ProviderController) GetProvider(ctx context.Context, provider string) (Provider, error) {
-	if pc.providers.Length() == 0 {
-		pc.InitProviders(ctx, pc.cli)
+// WithClusterNamespace sets the namespace ClusterExternalSecretProviders read their secrets from,
+// e.g. the secret holding their credentials
+func WithClusterNamespace(namespace string) ProviderControllerOption {
+	return func(pc *ProviderController) {
+		pc.clusterNamespace = namespace
 	}
+}
 
-	if val, ok := pc.providers.Get(provider); ok {
+// WithClusterID sets the identifier of the cluster passed to the providers
+func WithClusterID(id string) ProviderControllerOption {
+	return func(pc *ProviderController) {
+		pc.clusterID = id
+	}
+}
+
+const (
+	// changesBufferSize is the number of provider changes a subscriber can lag behind
+	changesBufferSize = 128
+	// notifyRetryInterval is the delay before notifying the subscribers that missed a change again
+	notifyRetryInterval = 5 * time.Second
+)
+
+// NewProviderController returns a controller building providers with cli, which is also passed to
+// the providers reading objects of the cluster. It is meant to be an uncached client, so that the
+// providers can be loaded before the manager starts.
+func NewProviderController(cli client.Client, opts ...ProviderControllerOption) *ProviderController {
+	pc := &ProviderController{
+		providers:  sync.NewMap[ProviderRef, Provider](),
+		loaded:     sync.NewMap[ProviderRef, loadedSpec](),
+		unnotified: sync.NewMap[ProviderRef, bool](),
+		injected:   make(map[ProviderRef]bool),
+		cli:        cli,
+	}
+
+	for _, opt := range opts {
+		opt(pc)
+	}
+
+	return pc
+}
+
+// loadedSpec identifies the spec a provider was built from
+type loadedSpec struct {
+	uid        types.UID
+	generation int64
+}
+
+type ProviderController struct {
+	providers *sync.Map[ProviderRef, Provider]
+	// loaded holds the spec each provider was built from, so that periodic reconciles of an
+	// unchanged ExternalSecretProvider reuse its provider
+	loaded *sync.Map[ProviderRef, loadedSpec]
+	// injected holds the references of the providers passed with WithProvider
+	injected map[ProviderRef]bool
+	cli      client.Client
+	// clusterNamespace is the namespace of the secrets read by ClusterExternalSecretProviders
+	clusterNamespace string
+	// clusterID identifies the cluster in the resources created by the providers
+	clusterID string
+	// subscribers receive the providers that are rebuilt or removed
+	subscribers []chan event.GenericEvent
+	// unnotified holds the providers whose change some subscriber missed because its buffer was full
+	unnotified *sync.Map[ProviderRef, bool]
+}
+
+// GetProvider returns the provider loaded for a reference. Providers are loaded by InitProviders at
+// startup and then by the ExternalSecretProvider and ClusterExternalSecretProvider reconcilers.
+func (pc *ProviderController) GetProvider(_ context.Context, ref ProviderRef) (Provider, error) {
+	if val, ok := pc.providers.Get(ref); ok {
 		return val, nil
 	}
 
-	return nil, fmt.Errorf("cannot find provider %s", provider)
+	return nil, fmt.Errorf("cannot find provider %s", ref)
 }
 
-func (pc *ProviderController) Add(providerName string, providerSpec Provider) {
-	pc.providers.Put(providerName, providerSpec)
+// providerFor returns the provider used by an ExternalSecret or ExternalSecretAccess, once the
+// namespace of the object is allowed to use it
+func (pc *ProviderController) providerFor(ctx context.Context, cli client.Reader, obj client.Object) (Provider, error) {
+	ref := providerOf(obj)
+	if err := allowNamespace(ctx, cli, ref, obj.GetNamespace()); err != nil {
+		return nil, err
+	}
+
+	return pc.GetProvider(ctx, ref)
 }
 
-func (pc *ProviderController) InitProviders(ctx context.Context, cli client.Client) error {
-	providerList := &v1alpha1.ExternalSecretProviderList{}
-	if err := cli.List(ctx, providerList); err != nil {
-		return err
+// allowNamespace checks that objects of a namespace can use a provider. ExternalSecretProviders
+// are only referenced from their own namespace, ClusterExternalSecretProviders only allow the
+// namespaces listed or selected in their spec.
+func allowNamespace(ctx context.Context, cli client.Reader, ref ProviderRef, namespace string) error {
+	if ref.Kind != secretsv1alpha1.ProviderKindCluster {
+		if ref.Namespace != namespace {
+			return &NamespaceNotAllowedError{Provider: ref, Namespace: namespace}
+		}
+		return nil
+	}
+
+	provider := &secretsv1alpha1.ClusterExternalSecretProvider{}
+	if err := cli.Get(ctx, types.NamespacedName{Name: ref.Name}, provider); err != nil {
+		return fmt.Errorf("getting %s: %w", ref, err)
 	}
 
-	for _, provider := range providerList.Items {
-		switch provider.Spec.Provider {
-		case secretsv1alpha1.SecretProviderAws:
-			p := &aws.AwsProvider{}
-			if err := p.Init(provider.Spec.Config); err != nil {
-				return err
+	spec := provider.Spec
+	for _, allowed := range spec.AllowedNamespaces {
+		if allowed == namespace {
+			return nil
+		}
+	}
+
+	if spec.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(spec.NamespaceSelector)
+		if err != nil {
+			return fmt.Errorf("parsing namespace selector of %s: %w", ref, err)
+		}
+
+		ns := &corev1.Namespace{}
+		if err := cli.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+			return fmt.Errorf("getting namespace %s: %w", namespace, err)
+		}
+		if selector.Matches(labels.Set(ns.Labels)) {
+			return nil
+		}
+	}
+
+	return &NamespaceNotAllowedError{Provider: ref, Namespace: namespace}
+}
+
+func (pc *ProviderController) Add(ref ProviderRef, providerSpec Provider) {
+	if pc.injected[ref] {
+		return
+	}
+
+	// release the connections held by the provider being replaced, e.g. a plugin connection
+	if previous, ok := pc.providers.Get(ref); ok && previous != providerSpec {
+		closeProvider(previous)
+	}
+
+	pc.providers.Put(ref, providerSpec)
+}
+
+// Remove closes and forgets the provider loaded for a reference, injected providers are kept
+func (pc *ProviderController) Remove(ref ProviderRef) {
+	if pc.injected[ref] {
+		return
+	}
+
+	previous, ok := pc.providers.Get(ref)
+	if !ok {
+		// a removal is only notified once the provider is gone, retry the subscribers that missed it
+		if pc.Unnotified(ref) {
+			pc.notify(ref)
+		}
+		return
+	}
+
+	closeProvider(previous)
+	pc.providers.Remove(ref)
+	pc.loaded.Remove(ref)
+	pc.notify(ref)
+}
+
+func closeProvider(provider Provider) {
+	if closer, ok := provider.(io.Closer); ok {
+		_ = closer.Close()
+	}
+}
+
+// Changes returns a channel receiving an event with the ExternalSecretProvider or
+// ClusterExternalSecretProvider of a provider every time it is rebuilt or removed, for the
+// controllers requeuing the objects using it. Every call subscribes a new channel, so it is meant
+// to be called while setting up the controllers.
+func (pc *ProviderController) Changes() <-chan event.GenericEvent {
+	ch := make(chan event.GenericEvent, changesBufferSize)
+	pc.subscribers = append(pc.subscribers, ch)
+
+	return ch
+}
+
+// notify sends a provider to the subscribers without blocking the reconciler when a subscriber lags
+// behind. The provider is then recorded as unnotified until a later Load or Remove delivers it.
+func (pc *ProviderController) notify(ref ProviderRef) {
+	delivered := true
+	for _, ch := range pc.subscribers {
+		var obj client.Object = &secretsv1alpha1.ExternalSecretProvider{
+			ObjectMeta: metav1.ObjectMeta{Namespace: ref.Namespace, Name: ref.Name},
+		}
+		if ref.Kind == secretsv1alpha1.ProviderKindCluster {
+			obj = &secretsv1alpha1.ClusterExternalSecretProvider{ObjectMeta: metav1.ObjectMeta{Name: ref.Name}}
+		}
+
+		select {
+		case ch <- event.GenericEvent{Object: obj}:
+		default:
+			delivered = false
+		}
+	}
+
+	if delivered {
+		pc.unnotified.Remove(ref)
+	} else {
+		pc.unnotified.Put(ref, true)
+	}
+}
+
+// Unnotified reports whether some subscriber missed the last change of a provider, its reconciler
+// is then requeued so that the change is sent again
+func (pc *ProviderController) Unnotified(ref ProviderRef) bool {
+	_, ok := pc.unnotified.Get(ref)
+	return ok
+}
+
+// Load builds the provider of an ExternalSecretProvider or ClusterExternalSecretProvider from the
+// registered factory of its type. The provider is only rebuilt when the spec changed since it was
+// last loaded.
+func (pc *ProviderController) Load(provider providerObject) error {
+	ref := refOf(provider)
+	if pc.injected[ref] {
+		return nil
+	}
+
+	spec := loadedSpec{uid: provider.GetUID(), generation: provider.GetGeneration()}
+	if loaded, ok := pc.loaded.Get(ref); ok && loaded == spec {
+		if _, ok := pc.providers.Get(ref); ok {
+			if pc.Unnotified(ref) {
+				pc.notify(ref)
 			}
-			pc.providers.Put(provider.Name, p)
+			return nil
+		}
+	}
+
+	namespace := provider.GetNamespace()
+	if ref.Kind == secretsv1alpha1.ProviderKindCluster {
+		namespace = pc.clusterNamespace
+	}
+
+	providerSpec := provider.ProviderSpec()
+	p, err := registry.New(providerSpec.Provider, registry.Options{Client: pc.cli, Namespace: namespace, ClusterID: pc.clusterID}, providerSpec.ProviderConfig())
+	if err != nil {
+		return err
+	}
+	pc.Add(ref, p)
+	pc.loaded.Put(ref, spec)
+	pc.notify(ref)
+
+	return nil
+}
+
+// InitProviders loads the providers of every ExternalSecretProvider and
+// ClusterExternalSecretProvider, it is called once at startup before the controllers run.
+// Providers failing to load are skipped and logged, their reconciler reports them in their status
+// and retries them, so only failing to list them is an error.
+func (pc *ProviderController) InitProviders(ctx context.Context, cli client.Reader) error {
+	reqLogger := log.FromContext(ctx)
+
+	providerList := &secretsv1alpha1.ExternalSecretProviderList{}
+	if err := cli.List(ctx, providerList); err != nil {
+		return fmt.Errorf("listing providers: %w", err)
+	}
+	clusterProviderList := &secretsv1alpha1.ClusterExternalSecretProviderList{}
+	if err := cli.List(ctx, clusterProviderList); err != nil {
+		return fmt.Errorf("listing cluster providers: %w", err)
+	}
+
+	providers := make([]providerObject, 0, len(providerList.Items)+len(clusterProviderList.Items))
+	for i := range providerList.Items {
+		providers = append(providers, &providerList.Items[i])
+	}
+	for i := range clusterProviderList.Items {
+		providers = append(providers, &clusterProviderList.Items[i])
+	}
+
+	for _, provider := range providers {
+		if err := pc.Load(provider); err != nil {
+			reqLogger.Error(err, "loading provider", "provider", refOf(provider).String())
 		}
 	}
 
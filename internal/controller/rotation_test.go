@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+)
+
+var _ = Describe("setSecretRotation", func() {
+	rotating := func(rotate string) *secretsv1alpha1.ExternalSecret {
+		return &secretsv1alpha1.ExternalSecret{
+			Spec: secretsv1alpha1.ExternalSecretSpec{
+				Random: &secretsv1alpha1.RandomSecretSpec{Size: 24, Regex: "[a-z0-9]", Rotate: &rotate},
+			},
+		}
+	}
+
+	It("sets the next rotation date from an English expression", func() {
+		secret := rotating("in 1 hour")
+		Expect(setSecretRotation(secret)).To(Succeed())
+		Expect(secret.Status.NextRotateDate).NotTo(BeNil())
+		Expect(secret.Status.NextRotateDate.Time).To(BeTemporally("~", time.Now().Add(time.Hour), time.Minute))
+
+		secret = rotating("in 3 days")
+		Expect(setSecretRotation(secret)).To(Succeed())
+		Expect(secret.Status.NextRotateDate.Time).To(BeTemporally("~", time.Now().AddDate(0, 0, 3), time.Minute))
+	})
+
+	It("rejects expressions without a date", func() {
+		secret := rotating("whenever")
+		Expect(setSecretRotation(secret)).To(MatchError(`parsing rotation date: no date found in "whenever"`))
+		Expect(secret.Status.NextRotateDate).To(BeNil())
+	})
+})
@@ -0,0 +1,324 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	kerorrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+)
+
+// serviceAccountAnnotationEntry is the entry of the provider status of an access holding the annotation
+// of the service accounts of its subjects, as <key>=<value>. Entries suffixed with .<namespace>/<name>
+// hold the annotation of a single service account and take precedence.
+const serviceAccountAnnotationEntry = "ServiceAccountAnnotation"
+
+// conditionServiceAccountsAnnotated reports whether the service accounts of the subjects carry the
+// annotations of the access
+const conditionServiceAccountsAnnotated = "ServiceAccountsAnnotated"
+
+// fieldManagerPrefix prefixes the field managers of the accesses
+const fieldManagerPrefix = "secretsbeam/"
+
+// maxFieldManagerLength is the length of field managers allowed by the API server
+const maxFieldManagerLength = 128
+
+var errServiceAccountNotFound = errors.New("service account not found")
+
+// fieldManagerOf returns the field manager applying the annotations of an access, a manager per access so
+// that accesses annotating the same service account do not take each other's annotations away
+func fieldManagerOf(access *secretsv1alpha1.ExternalSecretAccess) string {
+	manager := fmt.Sprintf("%s%s/%s", fieldManagerPrefix, access.Namespace, access.Name)
+	if len(manager) <= maxFieldManagerLength {
+		return manager
+	}
+
+	sum := sha256.Sum256([]byte(manager))
+	return manager[:maxFieldManagerLength-17] + "-" + hex.EncodeToString(sum[:])[:16]
+}
+
+// serviceAccountsOf returns the service accounts of the subjects, without duplicates
+func serviceAccountsOf(subjects ...[]secretsv1alpha1.SecretAccessSubject) []types.NamespacedName {
+	seen := make(map[types.NamespacedName]bool)
+	res := make([]types.NamespacedName, 0)
+	for _, list := range subjects {
+		for _, subject := range list {
+			if subject.ServiceAccount == nil {
+				continue
+			}
+
+			key := types.NamespacedName{Namespace: subject.ServiceAccount.Namespace, Name: subject.ServiceAccount.Name}
+			if !seen[key] {
+				seen[key] = true
+				res = append(res, key)
+			}
+		}
+	}
+
+	return res
+}
+
+// serviceAccountAnnotations returns the annotations of the service accounts of the subjects of an
+// access, from the provider status. Service accounts without an annotation are left out.
+func serviceAccountAnnotations(access *secretsv1alpha1.ExternalSecretAccess) map[types.NamespacedName]map[string]string {
+	annotations := make(map[types.NamespacedName]map[string]string)
+	if access.Spec.ServiceAccountPolicy == secretsv1alpha1.ServiceAccountPolicyNone {
+		return annotations
+	}
+
+	for _, key := range serviceAccountsOf(access.Spec.AccessSubjects) {
+		annotation, ok := access.Status.Provider[serviceAccountAnnotationEntry+"."+key.String()]
+		if !ok {
+			annotation = access.Status.Provider[serviceAccountAnnotationEntry]
+		}
+
+		if name, value, ok := strings.Cut(annotation, "="); ok && name != "" {
+			annotations[key] = map[string]string{name: value}
+		}
+	}
+
+	return annotations
+}
+
+// syncServiceAccounts applies the annotations of an access to the service accounts of its subjects,
+// and releases the service accounts it no longer annotates, those of the previous subjects included.
+// Missing service accounts and annotations owned by other managers are reported in the
+// ServiceAccountsAnnotated condition rather than failing the access.
+func (r *SecretAccessReconciler) syncServiceAccounts(ctx context.Context, reqLogger logr.Logger, access *secretsv1alpha1.ExternalSecretAccess, previous []secretsv1alpha1.SecretAccessSubject) error {
+	if annotation, ok := access.Status.Provider[serviceAccountAnnotationEntry]; ok {
+		access.Status.ServiceAccountAnnotation = &annotation
+	} else {
+		access.Status.ServiceAccountAnnotation = nil
+	}
+
+	desired := serviceAccountAnnotations(access)
+	released := make([]types.NamespacedName, 0)
+	for _, key := range serviceAccountsOf(previous, access.Spec.AccessSubjects) {
+		if _, ok := desired[key]; !ok {
+			released = append(released, key)
+		}
+	}
+	if err := r.releaseServiceAccounts(ctx, reqLogger, access, released); err != nil {
+		return err
+	}
+
+	if len(desired) == 0 {
+		meta.RemoveStatusCondition(&access.Status.Conditions, conditionServiceAccountsAnnotated)
+		return nil
+	}
+
+	keys := make([]types.NamespacedName, 0, len(desired))
+	for key := range desired {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+
+	missing := make([]string, 0)
+	conflicts := make([]string, 0)
+	for _, key := range keys {
+		err := r.applyServiceAccount(ctx, access, key, desired[key])
+		switch {
+		case err == nil:
+		case errors.Is(err, errServiceAccountNotFound):
+			missing = append(missing, key.String())
+		case isFieldManagerConflict(err):
+			reqLogger.Info(fmt.Sprintf("Annotation of service account %s is owned by another manager", key), "error", err.Error())
+			conflicts = append(conflicts, fmt.Sprintf("%s: %s", key, err))
+		default:
+			return fmt.Errorf("annotating service account %s: %w", key, err)
+		}
+	}
+
+	condition := v1.Condition{
+		Type:    conditionServiceAccountsAnnotated,
+		Status:  v1.ConditionTrue,
+		Reason:  "Annotated",
+		Message: fmt.Sprintf("%d service accounts annotated", len(keys)),
+	}
+	if len(conflicts) > 0 {
+		condition.Status = v1.ConditionFalse
+		condition.Reason = "Conflict"
+		condition.Message = strings.Join(conflicts, "; ")
+	} else if len(missing) > 0 {
+		condition.Status = v1.ConditionFalse
+		condition.Reason = "ServiceAccountNotFound"
+		condition.Message = fmt.Sprintf("service accounts %s not found", strings.Join(missing, ", "))
+	}
+	meta.SetStatusCondition(&access.Status.Conditions, condition)
+
+	return nil
+}
+
+// applyServiceAccount applies the annotations to a service account with the field manager of the
+// access. Missing service accounts are created with the Create policy in the namespace of the access,
+// owned by the access, as owners cannot be in another namespace.
+func (r *SecretAccessReconciler) applyServiceAccount(ctx context.Context, access *secretsv1alpha1.ExternalSecretAccess, key types.NamespacedName, annotations map[string]string) error {
+	current := &corev1.ServiceAccount{}
+	if err := r.Get(ctx, key, current); err != nil {
+		if !kerorrs.IsNotFound(err) {
+			return err
+		}
+		if access.Spec.ServiceAccountPolicy != secretsv1alpha1.ServiceAccountPolicyCreate || key.Namespace != access.Namespace {
+			return errServiceAccountNotFound
+		}
+		current = nil
+	}
+
+	serviceAccount := &corev1.ServiceAccount{
+		TypeMeta: v1.TypeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+		ObjectMeta: v1.ObjectMeta{
+			Namespace:   key.Namespace,
+			Name:        key.Name,
+			Annotations: annotations,
+		},
+	}
+	// the owner reference is kept on the service accounts created for the access
+	if current == nil || hasOwnerReference(current, access) {
+		if err := controllerutil.SetOwnerReference(access, serviceAccount, r.Scheme); err != nil {
+			return err
+		}
+	}
+
+	return r.Patch(ctx, serviceAccount, client.Apply, client.FieldOwner(fieldManagerOf(access)))
+}
+
+// releaseServiceAccounts removes the annotations of an access from service accounts. The service
+// accounts created for the access and annotated by no other access are deleted, the others keep the
+// fields of their other managers.
+func (r *SecretAccessReconciler) releaseServiceAccounts(ctx context.Context, reqLogger logr.Logger, access *secretsv1alpha1.ExternalSecretAccess, keys []types.NamespacedName) error {
+	manager := fieldManagerOf(access)
+	for _, key := range keys {
+		current := &corev1.ServiceAccount{}
+		if err := r.Get(ctx, key, current); err != nil {
+			if kerorrs.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("getting service account %s: %w", key, err)
+		}
+		if !appliedBy(current, manager) {
+			continue
+		}
+
+		// service accounts still annotated by other accesses are kept for them
+		if len(current.OwnerReferences) == 1 && current.OwnerReferences[0].UID == access.UID && !appliedByOthers(current, manager) {
+			if err := r.Delete(ctx, current, client.Preconditions{UID: &current.UID}); client.IgnoreNotFound(err) != nil {
+				return fmt.Errorf("deleting service account %s: %w", key, err)
+			}
+			reqLogger.Info(fmt.Sprintf("Deleted service account %s", key))
+			continue
+		}
+
+		// applying no fields releases those of the manager, the resource version keeps a service
+		// account deleted in the meantime from being created again
+		serviceAccount := &corev1.ServiceAccount{
+			TypeMeta: v1.TypeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+			ObjectMeta: v1.ObjectMeta{
+				Namespace:       key.Namespace,
+				Name:            key.Name,
+				ResourceVersion: current.ResourceVersion,
+			},
+		}
+		if err := r.Patch(ctx, serviceAccount, client.Apply, client.FieldOwner(manager)); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("releasing service account %s: %w", key, err)
+		}
+		reqLogger.Info(fmt.Sprintf("Removed the annotations of service account %s", key))
+	}
+
+	return nil
+}
+
+// appliedBy returns whether a field manager applied fields of an object
+func appliedBy(object client.Object, manager string) bool {
+	for _, entry := range object.GetManagedFields() {
+		if entry.Manager == manager && entry.Operation == v1.ManagedFieldsOperationApply {
+			return true
+		}
+	}
+
+	return false
+}
+
+// appliedByOthers returns whether the field managers of other accesses applied fields of an object
+func appliedByOthers(object client.Object, manager string) bool {
+	for _, entry := range object.GetManagedFields() {
+		if entry.Manager != manager && strings.HasPrefix(entry.Manager, fieldManagerPrefix) && entry.Operation == v1.ManagedFieldsOperationApply {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasOwnerReference(object client.Object, owner client.Object) bool {
+	for _, ref := range object.GetOwnerReferences() {
+		if ref.UID == owner.GetUID() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isFieldManagerConflict returns whether an apply failed on fields owned by other managers
+func isFieldManagerConflict(err error) bool {
+	var status kerorrs.APIStatus
+	if !kerorrs.IsConflict(err) || !errors.As(err, &status) || status.Status().Details == nil {
+		return false
+	}
+
+	for _, cause := range status.Status().Details.Causes {
+		if cause.Type == v1.CauseTypeFieldManagerConflict {
+			return true
+		}
+	}
+
+	return false
+}
+
+// serviceAccountField indexes the ExternalSecretAccesses by the namespace/name of the service accounts
+// of their subjects
+const serviceAccountField = "subjects.serviceAccount"
+
+// indexServiceAccounts returns the keys of the service accounts of an access in the serviceAccountField
+// index, those of the previous subjects included so that they are released
+func indexServiceAccounts(object client.Object) []string {
+	access := object.(*secretsv1alpha1.ExternalSecretAccess)
+	keys := make([]string, 0)
+	for _, key := range serviceAccountsOf(access.Spec.AccessSubjects, access.Status.Subjects) {
+		keys = append(keys, key.String())
+	}
+
+	return keys
+}
+
+// accessesOfServiceAccount maps a service account to the ExternalSecretAccesses of its subjects, so that
+// service accounts created, or whose annotations are changed by others, are annotated again
+func (r *SecretAccessReconciler) accessesOfServiceAccount(ctx context.Context, serviceAccount client.Object) []reconcile.Request {
+	list := &secretsv1alpha1.ExternalSecretAccessList{}
+	if err := r.List(ctx, list, client.MatchingFields{serviceAccountField: client.ObjectKeyFromObject(serviceAccount).String()}); err != nil {
+		log.FromContext(ctx).Error(err, "listing objects using the service account", "serviceAccount", client.ObjectKeyFromObject(serviceAccount))
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(list.Items))
+	for i := range list.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&list.Items[i])})
+	}
+
+	return requests
+}
@@ -0,0 +1,208 @@
+package controller
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+)
+
+var _ = Describe("SecretAccessReconciler service accounts", func() {
+	var (
+		ctx    context.Context
+		secret *secretsv1alpha1.ExternalSecret
+	)
+
+	const annotation = "fake.orbitops.dev/identity"
+
+	createServiceAccount := func(name string, annotations map[string]string) *corev1.ServiceAccount {
+		serviceAccount := &corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, Annotations: annotations},
+		}
+		Expect(k8sClient.Create(ctx, serviceAccount)).To(Succeed())
+		return serviceAccount
+	}
+
+	getServiceAccount := func(name string) *corev1.ServiceAccount {
+		serviceAccount := &corev1.ServiceAccount{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, serviceAccount)).To(Succeed())
+		return serviceAccount
+	}
+
+	createAccess := func(policy string, subjects ...secretsv1alpha1.SecretAccessSubject) *secretsv1alpha1.ExternalSecretAccess {
+		access := newExternalSecretAccess(uniqueName("reader"), secret.Name, subjects...)
+		access.Spec.ServiceAccountPolicy = policy
+		Expect(k8sClient.Create(ctx, access)).To(Succeed())
+		Expect(reconcileAccess(ctx, access)).To(Succeed())
+		return getAccess(ctx, access)
+	}
+
+	deleteAccess := func(access *secretsv1alpha1.ExternalSecretAccess) {
+		Expect(k8sClient.Delete(ctx, access)).To(Succeed())
+		Expect(reconcileAccess(ctx, access)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		fakeProvider.Reset()
+		fakeProvider.SetServiceAccountAnnotation("", annotation+"=reader")
+		secret = createSecret(ctx, newExternalSecret(uniqueName("db"), "hunter2"))
+	})
+
+	It("annotates the service accounts of the subjects until the access is deleted", func() {
+		api := createServiceAccount(uniqueName("api"), map[string]string{"team": "a"})
+		access := createAccess(secretsv1alpha1.ServiceAccountPolicyPatch, serviceAccountSubject("default", api.Name))
+
+		Expect(getServiceAccount(api.Name).Annotations).To(Equal(map[string]string{"team": "a", annotation: "reader"}))
+		Expect(access.Status.ServiceAccountAnnotation).To(HaveValue(Equal(annotation + "=reader")))
+		condition := meta.FindStatusCondition(access.Status.Conditions, conditionServiceAccountsAnnotated)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal("Annotated"))
+
+		deleteAccess(access)
+		Expect(getServiceAccount(api.Name).Annotations).To(Equal(map[string]string{"team": "a"}))
+	})
+
+	It("reports missing service accounts without creating them", func() {
+		name := uniqueName("api")
+		access := createAccess(secretsv1alpha1.ServiceAccountPolicyPatch, serviceAccountSubject("default", name))
+
+		condition := meta.FindStatusCondition(access.Status.Conditions, conditionServiceAccountsAnnotated)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal("ServiceAccountNotFound"))
+		Expect(condition.Message).To(ContainSubstring("default/" + name))
+		err := k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, &corev1.ServiceAccount{})
+		Expect(kerrors.IsNotFound(err)).To(BeTrue())
+
+		createServiceAccount(name, nil)
+		Expect(reconcileAccess(ctx, access)).To(Succeed())
+		Expect(getServiceAccount(name).Annotations).To(HaveKeyWithValue(annotation, "reader"))
+	})
+
+	It("creates the missing service accounts with the Create policy and deletes them with the access", func() {
+		name := uniqueName("api")
+		access := createAccess(secretsv1alpha1.ServiceAccountPolicyCreate, serviceAccountSubject("default", name))
+
+		serviceAccount := getServiceAccount(name)
+		Expect(serviceAccount.Annotations).To(HaveKeyWithValue(annotation, "reader"))
+		Expect(serviceAccount.OwnerReferences).To(HaveLen(1))
+		Expect(serviceAccount.OwnerReferences[0].UID).To(Equal(access.UID))
+
+		Expect(reconcileAccess(ctx, access)).To(Succeed())
+		Expect(getServiceAccount(name).OwnerReferences).To(HaveLen(1), "the owner is kept on updates")
+
+		deleteAccess(access)
+		err := k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, &corev1.ServiceAccount{})
+		Expect(kerrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("keeps the service accounts created by others with the Create policy", func() {
+		api := createServiceAccount(uniqueName("api"), nil)
+		access := createAccess(secretsv1alpha1.ServiceAccountPolicyCreate, serviceAccountSubject("default", api.Name))
+		Expect(getServiceAccount(api.Name).OwnerReferences).To(BeEmpty())
+
+		deleteAccess(access)
+		Expect(getServiceAccount(api.Name).Annotations).NotTo(HaveKey(annotation))
+	})
+
+	It("keeps the service accounts created by an access while other accesses annotate them", func() {
+		name := uniqueName("api")
+		first := createAccess(secretsv1alpha1.ServiceAccountPolicyCreate, serviceAccountSubject("default", name))
+		second := createAccess(secretsv1alpha1.ServiceAccountPolicyCreate, serviceAccountSubject("default", name))
+		Expect(getServiceAccount(name).OwnerReferences).To(ConsistOf(HaveField("UID", first.UID)))
+
+		deleteAccess(first)
+		serviceAccount := getServiceAccount(name)
+		Expect(serviceAccount.Annotations).To(HaveKeyWithValue(annotation, "reader"))
+		Expect(serviceAccount.OwnerReferences).To(BeEmpty())
+
+		deleteAccess(second)
+		Expect(getServiceAccount(name).Annotations).NotTo(HaveKey(annotation))
+	})
+
+	It("reports annotations owned by another manager", func() {
+		api := createServiceAccount(uniqueName("api"), map[string]string{annotation: "someone-else"})
+		access := createAccess(secretsv1alpha1.ServiceAccountPolicyPatch, serviceAccountSubject("default", api.Name))
+
+		condition := meta.FindStatusCondition(access.Status.Conditions, conditionServiceAccountsAnnotated)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal("Conflict"))
+		Expect(condition.Message).To(ContainSubstring("default/" + api.Name))
+		Expect(getServiceAccount(api.Name).Annotations).To(HaveKeyWithValue(annotation, "someone-else"))
+	})
+
+	It("shares the annotation between the accesses setting the same value", func() {
+		api := createServiceAccount(uniqueName("api"), nil)
+		first := createAccess(secretsv1alpha1.ServiceAccountPolicyPatch, serviceAccountSubject("default", api.Name))
+		second := createAccess(secretsv1alpha1.ServiceAccountPolicyPatch, serviceAccountSubject("default", api.Name))
+		Expect(meta.IsStatusConditionTrue(second.Status.Conditions, conditionServiceAccountsAnnotated)).To(BeTrue())
+
+		deleteAccess(first)
+		Expect(getServiceAccount(api.Name).Annotations).To(HaveKeyWithValue(annotation, "reader"))
+		deleteAccess(second)
+		Expect(getServiceAccount(api.Name).Annotations).NotTo(HaveKey(annotation))
+	})
+
+	It("applies the annotations of single service accounts and releases the removed subjects", func() {
+		api := createServiceAccount(uniqueName("api"), nil)
+		worker := createServiceAccount(uniqueName("worker"), nil)
+		fakeProvider.SetServiceAccountAnnotation("default/"+worker.Name, annotation+"=worker")
+
+		access := createAccess(secretsv1alpha1.ServiceAccountPolicyPatch, serviceAccountSubject("default", api.Name), serviceAccountSubject("default", worker.Name))
+		Expect(getServiceAccount(api.Name).Annotations).To(HaveKeyWithValue(annotation, "reader"))
+		Expect(getServiceAccount(worker.Name).Annotations).To(HaveKeyWithValue(annotation, "worker"))
+
+		access.Spec.AccessSubjects = []secretsv1alpha1.SecretAccessSubject{serviceAccountSubject("default", worker.Name)}
+		Expect(k8sClient.Update(ctx, access)).To(Succeed())
+		Expect(reconcileAccess(ctx, access)).To(Succeed())
+		Expect(getServiceAccount(api.Name).Annotations).NotTo(HaveKey(annotation))
+		Expect(getServiceAccount(worker.Name).Annotations).To(HaveKeyWithValue(annotation, "worker"))
+	})
+
+	It("leaves the service accounts alone with the None policy", func() {
+		api := createServiceAccount(uniqueName("api"), nil)
+		access := createAccess(secretsv1alpha1.ServiceAccountPolicyPatch, serviceAccountSubject("default", api.Name))
+		Expect(getServiceAccount(api.Name).Annotations).To(HaveKey(annotation))
+
+		access.Spec.ServiceAccountPolicy = secretsv1alpha1.ServiceAccountPolicyNone
+		Expect(k8sClient.Update(ctx, access)).To(Succeed())
+		Expect(reconcileAccess(ctx, access)).To(Succeed())
+		Expect(getServiceAccount(api.Name).Annotations).NotTo(HaveKey(annotation))
+		Expect(meta.FindStatusCondition(getAccess(ctx, access).Status.Conditions, conditionServiceAccountsAnnotated)).To(BeNil())
+	})
+
+	It("annotates the service accounts once they are created", func() {
+		startAccessManager(ctx)
+
+		name := uniqueName("api")
+		access := newExternalSecretAccess(uniqueName("reader"), secret.Name, serviceAccountSubject("default", name))
+		Expect(k8sClient.Create(ctx, access)).To(Succeed())
+		Eventually(func() *metav1.Condition {
+			return meta.FindStatusCondition(getAccess(ctx, access).Status.Conditions, conditionServiceAccountsAnnotated)
+		}).Should(HaveField("Reason", "ServiceAccountNotFound"))
+
+		createServiceAccount(name, nil)
+		Eventually(func() map[string]string {
+			return getServiceAccount(name).Annotations
+		}).Should(HaveKeyWithValue(annotation, "reader"))
+	})
+
+	It("limits the field managers to 128 characters", func() {
+		access := newExternalSecretAccess(strings.Repeat("a", 200), secret.Name)
+		manager := fieldManagerOf(access)
+		Expect(manager).To(HaveLen(maxFieldManagerLength))
+		Expect(manager).To(HavePrefix("secretsbeam/default/aaa"))
+		Expect(manager).NotTo(Equal(fieldManagerOf(newExternalSecretAccess(strings.Repeat("a", 201), secret.Name))))
+	})
+})
@@ -33,6 +33,8 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	_ "github.com/tiagoposse/secretsbeam-operator/internal/providers/all"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/fake"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -43,6 +45,25 @@ var cfg *rest.Config
 var k8sClient client.Client
 var testEnv *envtest.Environment
 
+// fakeProviderName is the provider of the objects created by the reconciler tests, served by fakeProvider
+const fakeProviderName = "fake"
+
+// fakeProviderRef is the ExternalSecretProvider of the default namespace injected with fakeProvider
+var fakeProviderRef = ProviderRef{Kind: secretsv1alpha1.ProviderKindNamespaced, Namespace: "default", Name: fakeProviderName}
+
+// clusterProviderNamespace is the namespace ClusterExternalSecretProviders read their secrets from
+const clusterProviderNamespace = "kube-system"
+
+// testClusterID is the cluster id passed to the providers
+const testClusterID = "test-cluster"
+
+var fakeProvider *fake.FakeProvider
+var providerController *ProviderController
+var secretReconciler *SecretReconciler
+var accessReconciler *SecretAccessReconciler
+var providerReconciler *SecretProviderReconciler
+var clusterProviderReconciler *ClusterSecretProviderReconciler
+
 func TestControllers(t *testing.T) {
 	RegisterFailHandler(Fail)
 
@@ -81,6 +102,14 @@ var _ = BeforeSuite(func() {
 	Expect(err).NotTo(HaveOccurred())
 	Expect(k8sClient).NotTo(BeNil())
 
+	// the reconcilers are called directly by the tests rather than by a manager, so that every
+	// reconciliation and its result can be asserted on
+	fakeProvider = &fake.FakeProvider{}
+	providerController = NewProviderController(k8sClient, WithProvider(fakeProviderRef, fakeProvider), WithClusterNamespace(clusterProviderNamespace), WithClusterID(testClusterID))
+	secretReconciler = &SecretReconciler{Client: k8sClient, Scheme: scheme.Scheme, ProviderController: providerController}
+	accessReconciler = &SecretAccessReconciler{Client: k8sClient, Scheme: scheme.Scheme, ProviderController: providerController}
+	providerReconciler = &SecretProviderReconciler{Client: k8sClient, Scheme: scheme.Scheme, ProviderController: providerController}
+	clusterProviderReconciler = &ClusterSecretProviderReconciler{Client: k8sClient, Scheme: scheme.Scheme, ProviderController: providerController}
 })
 
 var _ = AfterSuite(func() {
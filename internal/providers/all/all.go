@@ -0,0 +1,13 @@
+// Package all registers every provider type shipped with the operator
+package all
+
+import (
+	_ "github.com/tiagoposse/secretsbeam-operator/internal/providers/aws"
+	_ "github.com/tiagoposse/secretsbeam-operator/internal/providers/azure"
+	_ "github.com/tiagoposse/secretsbeam-operator/internal/providers/custom"
+	_ "github.com/tiagoposse/secretsbeam-operator/internal/providers/execplugin"
+	_ "github.com/tiagoposse/secretsbeam-operator/internal/providers/file"
+	_ "github.com/tiagoposse/secretsbeam-operator/internal/providers/gcp"
+	_ "github.com/tiagoposse/secretsbeam-operator/internal/providers/grpcplugin"
+	_ "github.com/tiagoposse/secretsbeam-operator/internal/providers/vault"
+)
@@ -0,0 +1,106 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	defaultAccessKeyIDKey     = "access-key-id"
+	defaultSecretAccessKeyKey = "secret-access-key"
+	defaultRoleSessionName    = "secretsbeam-operator"
+)
+
+// loadConfig loads the SDK config of the provider: the region and static access keys of the
+// provider config take precedence over the environment of the operator
+func (p *AwsProvider) loadConfig(ctx context.Context, config AwsProviderConfig) (aws.Config, error) {
+	opts := make([]func(*awsconfig.LoadOptions) error, 0)
+	if config.Region != "" {
+		opts = append(opts, awsconfig.WithRegion(config.Region))
+	}
+
+	if config.AccessKeySecretName != "" {
+		staticCredentials, err := p.readAccessKeys(ctx, config)
+		if err != nil {
+			return aws.Config{}, err
+		}
+		opts = append(opts, awsconfig.WithCredentialsProvider(staticCredentials))
+	}
+
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("unable to load SDK config, %v", err)
+	}
+
+	return cfg, nil
+}
+
+// readAccessKeys reads static access keys from the secret referenced by the provider config
+func (p *AwsProvider) readAccessKeys(ctx context.Context, config AwsProviderConfig) (credentials.StaticCredentialsProvider, error) {
+	none := credentials.StaticCredentialsProvider{}
+	if p.Client == nil {
+		return none, fmt.Errorf("no kubernetes client configured to read secret %s", config.AccessKeySecretName)
+	}
+
+	secret := &corev1.Secret{}
+	if err := p.Client.Get(ctx, types.NamespacedName{Namespace: p.Namespace, Name: config.AccessKeySecretName}, secret); err != nil {
+		return none, fmt.Errorf("getting secret %s/%s: %w", p.Namespace, config.AccessKeySecretName, err)
+	}
+
+	read := func(key string, required bool) (string, error) {
+		val, ok := secret.Data[key]
+		if !ok && required {
+			return "", fmt.Errorf("key %s not found in secret %s/%s", key, p.Namespace, config.AccessKeySecretName)
+		}
+
+		return strings.TrimSpace(string(val)), nil
+	}
+
+	accessKeyID, err := read(config.AccessKeyIDKey, true)
+	if err != nil {
+		return none, err
+	}
+	secretAccessKey, err := read(config.SecretAccessKeyKey, true)
+	if err != nil {
+		return none, err
+	}
+	sessionToken, err := read(config.SessionTokenKey, config.SessionTokenKey != "")
+	if err != nil {
+		return none, err
+	}
+
+	return credentials.NewStaticCredentialsProvider(accessKeyID, secretAccessKey, sessionToken), nil
+}
+
+// withRoles replaces the credentials of cfg with those of the web identity role and then of the
+// assumed role of the provider config, when set. The roles are assumed through STS with the
+// endpoint of cfg and their credentials are refreshed before they expire.
+func withRoles(cfg aws.Config, config AwsProviderConfig) aws.Config {
+	if config.WebIdentityRoleArn != "" {
+		provider := stscreds.NewWebIdentityRoleProvider(sts.NewFromConfig(cfg), config.WebIdentityRoleArn, stscreds.IdentityTokenFile(config.WebIdentityTokenFile), func(o *stscreds.WebIdentityRoleOptions) {
+			o.RoleSessionName = config.RoleSessionName
+		})
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+
+	if config.AssumeRoleArn != "" {
+		provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), config.AssumeRoleArn, func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = config.RoleSessionName
+			if config.ExternalID != "" {
+				o.ExternalID = aws.String(config.ExternalID)
+			}
+		})
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+
+	return cfg
+}
@@ -0,0 +1,168 @@
+package aws
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const fakeRoleArn = "arn:aws:iam::444455556666:role/secrets-admin"
+
+var _ = Describe("AwsProvider credentials", func() {
+	var (
+		ctx  context.Context
+		fake *fakeAWS
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		fake = newFakeAWS()
+		DeferCleanup(fake.Close)
+	})
+
+	Context("static access keys", func() {
+		var provider *AwsProvider
+
+		BeforeEach(func() {
+			// the keys of the environment must not leak into the provider
+			GinkgoT().Setenv("AWS_REGION", "")
+			GinkgoT().Setenv("AWS_ACCESS_KEY_ID", "AKIDENVIRONMENT")
+			GinkgoT().Setenv("AWS_SECRET_ACCESS_KEY", "environment")
+			GinkgoT().Setenv("AWS_CONFIG_FILE", os.DevNull)
+			GinkgoT().Setenv("AWS_SHARED_CREDENTIALS_FILE", os.DevNull)
+
+			cli := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "aws-keys", Namespace: "secretsbeam"},
+				Data: map[string][]byte{
+					"access-key-id":     []byte("AKIDSTATIC\n"),
+					"secret-access-key": []byte("static"),
+					"id":                []byte("AKIDCUSTOM"),
+					"secret":            []byte("custom"),
+				},
+			}).Build()
+			provider = &AwsProvider{Client: cli, Namespace: "secretsbeam"}
+		})
+
+		It("reads the keys from a secret", func() {
+			Expect(provider.Init(map[string]string{
+				"endpoint":            fake.server.URL,
+				"region":              "us-east-2",
+				"accessKeySecretName": "aws-keys",
+			})).To(Succeed())
+
+			health, err := provider.CheckHealth(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(health.Region).To(Equal("us-east-2"))
+			Expect(fake.callers("GetCallerIdentity")).To(Equal([]string{"AKIDSTATIC"}))
+		})
+
+		It("reads the keys under custom names", func() {
+			Expect(provider.Init(map[string]string{
+				"endpoint":            fake.server.URL,
+				"region":              "us-east-2",
+				"accessKeySecretName": "aws-keys",
+				"accessKeyIDKey":      "id",
+				"secretAccessKeyKey":  "secret",
+			})).To(Succeed())
+
+			_, err := provider.CheckHealth(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fake.callers("GetCallerIdentity")).To(Equal([]string{"AKIDCUSTOM"}))
+		})
+
+		It("reports missing secrets and keys", func() {
+			config := map[string]string{"region": "us-east-2", "accessKeySecretName": "missing"}
+			Expect(provider.Init(config)).To(MatchError(ContainSubstring("getting secret secretsbeam/missing")))
+			Expect((&AwsProvider{}).Init(config)).To(MatchError(ContainSubstring("no kubernetes client configured to read secret missing")))
+
+			config["accessKeySecretName"] = "aws-keys"
+			config["sessionTokenKey"] = "token"
+			Expect(provider.Init(config)).To(MatchError(ContainSubstring("key token not found in secret secretsbeam/aws-keys")))
+		})
+
+		It("requires a region", func() {
+			Expect(provider.Init(map[string]string{"accessKeySecretName": "aws-keys"})).
+				To(MatchError(ContainSubstring("region is required")))
+		})
+	})
+
+	Context("assumed roles", func() {
+		It("assumes the role with the external id", func() {
+			provider := &AwsProvider{}
+			Expect(provider.init(AwsProviderConfig{
+				Endpoint:      fake.server.URL,
+				AssumeRoleArn: fakeRoleArn,
+				ExternalID:    "tenant-a",
+			}, fake.config())).To(Succeed())
+
+			health, err := provider.CheckHealth(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(health.Identity).To(Equal("arn:aws:sts::111122223333:assumed-role/secrets-admin/secretsbeam-operator"))
+
+			// the session is cached until it expires
+			_, err = provider.CheckHealth(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fake.callers("AssumeRole")).To(Equal([]string{"AKIDFAKE"}))
+			Expect(fake.assumed).To(HaveLen(1))
+			Expect(fake.assumed[0].Get("RoleArn")).To(Equal(fakeRoleArn))
+			Expect(fake.assumed[0].Get("ExternalId")).To(Equal("tenant-a"))
+			Expect(fake.assumed[0].Get("RoleSessionName")).To(Equal("secretsbeam-operator"))
+		})
+
+		It("assumes the role with a web identity token", func() {
+			tokenFile := filepath.Join(GinkgoT().TempDir(), "token")
+			Expect(os.WriteFile(tokenFile, []byte("projected-token"), 0o600)).To(Succeed())
+
+			provider := &AwsProvider{}
+			Expect(provider.init(AwsProviderConfig{
+				Endpoint:             fake.server.URL,
+				WebIdentityRoleArn:   fakeRoleArn,
+				WebIdentityTokenFile: tokenFile,
+				RoleSessionName:      "cluster-a",
+			}, fake.config())).To(Succeed())
+
+			health, err := provider.CheckHealth(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(health.Identity).To(Equal("arn:aws:sts::111122223333:assumed-role/secrets-admin/cluster-a"))
+			Expect(fake.callers("AssumeRoleWithWebIdentity")).To(Equal([]string{""}))
+			Expect(fake.assumed[0].Get("WebIdentityToken")).To(Equal("projected-token"))
+		})
+
+		It("chains the web identity and the assumed role", func() {
+			tokenFile := filepath.Join(GinkgoT().TempDir(), "token")
+			Expect(os.WriteFile(tokenFile, []byte("projected-token"), 0o600)).To(Succeed())
+
+			provider := &AwsProvider{}
+			Expect(provider.init(AwsProviderConfig{
+				Endpoint:             fake.server.URL,
+				WebIdentityRoleArn:   "arn:aws:iam::111122223333:role/operator",
+				WebIdentityTokenFile: tokenFile,
+				AssumeRoleArn:        fakeRoleArn,
+			}, fake.config())).To(Succeed())
+
+			_, err := provider.CheckHealth(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fake.operations()).To(Equal([]string{"AssumeRoleWithWebIdentity", "AssumeRole", "GetCallerIdentity"}))
+			Expect(fake.callers("AssumeRole")[0]).To(HavePrefix("ASIA"))
+		})
+
+		It("validates the config", func() {
+			Expect((&AwsProvider{}).init(AwsProviderConfig{ExternalID: "tenant-a"}, fake.config())).
+				To(MatchError(ContainSubstring("externalID requires assumeRoleArn")))
+			Expect((&AwsProvider{}).init(AwsProviderConfig{WebIdentityRoleArn: fakeRoleArn}, fake.config())).
+				To(MatchError(ContainSubstring("must be set together")))
+			Expect((&AwsProvider{}).init(AwsProviderConfig{
+				AccessKeySecretName:  "aws-keys",
+				WebIdentityRoleArn:   fakeRoleArn,
+				WebIdentityTokenFile: "/var/run/token",
+			}, fake.config())).To(MatchError(ContainSubstring("mutually exclusive")))
+		})
+	})
+})
@@ -0,0 +1,1499 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+const (
+	fakeAccountID = "111122223333"
+	fakeRegion    = "eu-west-1"
+	// fakeCallerArn is the identity returned by STS GetCallerIdentity for the credentials of config
+	fakeCallerArn = "arn:aws:sts::111122223333:assumed-role/secretsbeam-operator/session"
+	// fakeSessionDuration is the lifetime of the credentials of assumed roles
+	fakeSessionDuration = time.Hour
+
+	// iamPolicyVersionLimit is the number of versions IAM keeps per managed policy
+	iamPolicyVersionLimit = 5
+)
+
+type fakeSecret struct {
+	name         string
+	arn          string
+	value        string
+	kmsKeyID     string
+	lastChanged  time.Time
+	deletionDate *time.Time
+	tags         map[string]string
+	// replicas holds the replicas of the secret by region
+	replicas map[string]*fakeReplica
+	// policy is the resource policy of the secret
+	policy string
+}
+
+type fakeReplica struct {
+	kmsKeyID string
+	status   string
+	message  string
+}
+
+type fakeParameter struct {
+	name         string
+	arn          string
+	value        string
+	keyID        string
+	tier         string
+	version      int64
+	lastModified time.Time
+	tags         map[string]string
+}
+
+type fakePolicyVersion struct {
+	id        string
+	document  string
+	isDefault bool
+	created   time.Time
+}
+
+type fakePolicy struct {
+	name        string
+	arn         string
+	created     time.Time
+	versions    []*fakePolicyVersion
+	nextVersion int
+	tags        map[string]string
+}
+
+// defaultVersion returns the version applied by IAM
+func (p *fakePolicy) defaultVersion() *fakePolicyVersion {
+	for _, version := range p.versions {
+		if version.isDefault {
+			return version
+		}
+	}
+
+	return nil
+}
+
+type fakeRole struct {
+	name             string
+	arn              string
+	created          time.Time
+	trustPolicy      string
+	attachedPolicies map[string]bool
+	tags             map[string]string
+	path             string
+	boundary         string
+	// maxSessionDuration is in seconds
+	maxSessionDuration int
+	// inlinePolicies holds the documents of the inline policies by name
+	inlinePolicies map[string]string
+}
+
+type fakeKey struct {
+	id           string
+	arn          string
+	description  string
+	policy       string
+	state        string
+	rotation     bool
+	deletionDays int32
+	tags         map[string]string
+}
+
+type fakeAssociation struct {
+	id             string
+	arn            string
+	cluster        string
+	namespace      string
+	serviceAccount string
+	roleArn        string
+	created        time.Time
+	tags           map[string]string
+}
+
+type awsError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *awsError) Error() string {
+	return fmt.Sprintf("%s: %s", e.code, e.message)
+}
+
+// fakeAWS is an in-memory stand-in for the Secrets Manager, Parameter Store, KMS, IAM, STS and EKS
+// APIs used by the provider. Secrets Manager, Parameter Store and KMS speak JSON with the operation
+// in the X-Amz-Target header, IAM and STS speak the query protocol with form encoded requests and XML
+// responses, and EKS speaks JSON with the operation in the method and path.
+type fakeAWS struct {
+	mu         sync.Mutex
+	clock      time.Time
+	ids        int
+	calls      []string
+	failures   map[string]*awsError
+	secrets    map[string]*fakeSecret
+	parameters map[string]*fakeParameter
+	policies   map[string]*fakePolicy
+	roles      map[string]*fakeRole
+	// associations holds the pod identity associations by id
+	associations map[string]*fakeAssociation
+	// replicaFailures holds the messages of the regions failing the replication of secrets
+	replicaFailures map[string]string
+	// keys holds the KMS keys by id, aliases maps the aliases to the ids of their keys
+	keys    map[string]*fakeKey
+	aliases map[string]string
+	// accessKeys holds the access key signing each call, in the order of calls
+	accessKeys []string
+	// sessions maps the access keys issued by STS to the assumed role sessions
+	sessions map[string]stsAssumedRoleUser
+	// assumed holds the parameters of the AssumeRole and AssumeRoleWithWebIdentity calls
+	assumed []url.Values
+	server  *httptest.Server
+}
+
+func newFakeAWS() *fakeAWS {
+	f := &fakeAWS{
+		clock:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		failures:   make(map[string]*awsError),
+		secrets:    make(map[string]*fakeSecret),
+		parameters: make(map[string]*fakeParameter),
+		policies:   make(map[string]*fakePolicy),
+		roles:      make(map[string]*fakeRole),
+		sessions:   make(map[string]stsAssumedRoleUser),
+
+		associations: make(map[string]*fakeAssociation),
+		keys:         make(map[string]*fakeKey),
+
+		replicaFailures: make(map[string]string),
+		aliases:         make(map[string]string),
+	}
+	f.server = httptest.NewServer(http.HandlerFunc(f.handle))
+
+	return f
+}
+
+func (f *fakeAWS) Close() {
+	f.server.Close()
+}
+
+// config returns an SDK config with static credentials and without retries, the endpoint
+// of the fake is set through the provider config
+func (f *fakeAWS) config() aws.Config {
+	return aws.Config{
+		Region: fakeRegion,
+		Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "AKIDFAKE", SecretAccessKey: "fake"}, nil
+		}),
+		HTTPClient: f.server.Client(),
+		Retryer: func() aws.Retryer {
+			return aws.NopRetryer{}
+		},
+	}
+}
+
+// failOn makes the next calls to the operation fail with the error code, an empty code clears the failure
+func (f *fakeAWS) failOn(operation, code string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if code == "" {
+		delete(f.failures, operation)
+		return
+	}
+	f.failures[operation] = &awsError{status: http.StatusInternalServerError, code: code, message: "injected failure"}
+}
+
+// operations returns the operations called so far, in order
+func (f *fakeAWS) operations() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string{}, f.calls...)
+}
+
+// now advances the clock by a second on every call, so creation dates are strictly ordered
+func (f *fakeAWS) now() time.Time {
+	f.clock = f.clock.Add(time.Second)
+	return f.clock
+}
+
+func (f *fakeAWS) newID(prefix string) string {
+	f.ids++
+	return fmt.Sprintf("%s%016d", prefix, f.ids)
+}
+
+// callers returns the access keys signing the calls to the operation, in order, an empty key
+// for unsigned calls
+func (f *fakeAWS) callers(operation string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	callers := []string{}
+	for i, call := range f.calls {
+		if call == operation {
+			callers = append(callers, f.accessKeys[i])
+		}
+	}
+
+	return callers
+}
+
+// accessKey extracts the access key from the SigV4 Authorization header of the request
+func accessKey(r *http.Request) string {
+	_, credential, found := strings.Cut(r.Header.Get("Authorization"), "Credential=")
+	if !found {
+		return ""
+	}
+	key, _, _ := strings.Cut(credential, "/")
+
+	return key
+}
+
+func (f *fakeAWS) handle(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.accessKeys = append(f.accessKeys, accessKey(r))
+
+	if strings.HasPrefix(r.URL.Path, "/clusters/") {
+		f.handleEks(w, r)
+		return
+	}
+
+	if target := r.Header.Get("X-Amz-Target"); target != "" {
+		service, operation, _ := strings.Cut(target, ".")
+		f.calls = append(f.calls, operation)
+
+		var (
+			result interface{}
+			err    *awsError
+		)
+		if err = f.failures[operation]; err == nil {
+			switch service {
+			case "secretsmanager":
+				result, err = f.secretsManager(operation, r)
+			case "AmazonSSM":
+				result, err = f.parameterStore(operation, r)
+			case "TrentService":
+				result, err = f.kms(operation, r)
+			default:
+				err = &awsError{status: http.StatusBadRequest, code: "UnknownOperationException", message: target}
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		if err != nil {
+			w.WriteHeader(err.status)
+			_ = json.NewEncoder(w).Encode(map[string]string{"__type": err.code, "Message": err.message})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(result)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	operation := r.PostForm.Get("Action")
+	f.calls = append(f.calls, operation)
+
+	var (
+		result    interface{}
+		err       *awsError
+		namespace = "https://iam.amazonaws.com/doc/2010-05-08/"
+	)
+	if err = f.failures[operation]; err == nil {
+		switch operation {
+		case "GetCallerIdentity", "AssumeRole", "AssumeRoleWithWebIdentity":
+			namespace = "https://sts.amazonaws.com/doc/2011-06-15/"
+			result, err = f.sts(operation, r.PostForm, f.accessKeys[len(f.accessKeys)-1])
+		default:
+			result, err = f.iam(operation, r.PostForm)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/xml")
+	if err != nil {
+		w.WriteHeader(err.status)
+		_ = xml.NewEncoder(w).Encode(struct {
+			XMLName xml.Name `xml:"ErrorResponse"`
+			Type    string   `xml:"Error>Type"`
+			Code    string   `xml:"Error>Code"`
+			Message string   `xml:"Error>Message"`
+		}{Type: "Sender", Code: err.code, Message: err.message})
+		return
+	}
+
+	encoder := xml.NewEncoder(w)
+	response := xml.StartElement{Name: xml.Name{Space: namespace, Local: operation + "Response"}}
+	_ = encoder.EncodeToken(response)
+	if result != nil {
+		_ = encoder.EncodeElement(result, xml.StartElement{Name: xml.Name{Local: operation + "Result"}})
+	}
+	_ = encoder.EncodeToken(response.End())
+	_ = encoder.Flush()
+}
+
+func decodeRequest(r *http.Request, input interface{}) *awsError {
+	if err := json.NewDecoder(r.Body).Decode(input); err != nil {
+		return &awsError{status: http.StatusBadRequest, code: "SerializationException", message: err.Error()}
+	}
+
+	return nil
+}
+
+func epochSeconds(t time.Time) float64 {
+	return float64(t.UnixMilli()) / 1000
+}
+
+type jsonTag struct {
+	Key   string
+	Value string
+}
+
+// tagJSON adds the tags of a JSON request to the tags of a resource
+func tagJSON(tags map[string]string, list []jsonTag) map[string]string {
+	if tags == nil {
+		tags = make(map[string]string)
+	}
+	for _, tag := range list {
+		tags[tag.Key] = tag.Value
+	}
+
+	return tags
+}
+
+func jsonTags(tags map[string]string) []jsonTag {
+	list := make([]jsonTag, 0, len(tags))
+	for key, value := range tags {
+		list = append(list, jsonTag{key, value})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+
+	return list
+}
+
+// tagForm adds the tags of a query request, Tags.member.N.Key and Tags.member.N.Value, to the tags
+// of a resource
+func tagForm(tags map[string]string, form url.Values) map[string]string {
+	if tags == nil {
+		tags = make(map[string]string)
+	}
+	for i := 1; form.Has(fmt.Sprintf("Tags.member.%d.Key", i)); i++ {
+		tags[form.Get(fmt.Sprintf("Tags.member.%d.Key", i))] = form.Get(fmt.Sprintf("Tags.member.%d.Value", i))
+	}
+
+	return tags
+}
+
+// untagForm removes the tags of a query request, TagKeys.member.N, from the tags of a resource
+func untagForm(tags map[string]string, form url.Values) {
+	for i := 1; form.Has(fmt.Sprintf("TagKeys.member.%d", i)); i++ {
+		delete(tags, form.Get(fmt.Sprintf("TagKeys.member.%d", i)))
+	}
+}
+
+type iamTag struct {
+	Key   string
+	Value string
+}
+
+func iamTagList(tags map[string]string) []iamTag {
+	list := make([]iamTag, 0, len(tags))
+	for _, tag := range jsonTags(tags) {
+		list = append(list, iamTag(tag))
+	}
+
+	return list
+}
+
+// tagsOf returns the tags of the resource with the name or ARN, nil when it does not exist
+func (f *fakeAWS) tagsOf(id string) map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if secret := f.secret(id); secret != nil {
+		return secret.tags
+	}
+	if parameter, ok := f.parameters[id]; ok {
+		return parameter.tags
+	}
+	if policy, ok := f.policies[id]; ok {
+		return policy.tags
+	}
+	if role, ok := f.roles[id]; ok {
+		return role.tags
+	}
+	if key := f.key(id); key != nil {
+		return key.tags
+	}
+
+	return nil
+}
+
+// secret finds a secret by name or ARN
+func (f *fakeAWS) secret(id string) *fakeSecret {
+	if secret, ok := f.secrets[id]; ok {
+		return secret
+	}
+
+	for _, secret := range f.secrets {
+		if secret.arn == id {
+			return secret
+		}
+	}
+
+	return nil
+}
+
+func (f *fakeAWS) secretsManager(operation string, r *http.Request) (interface{}, *awsError) {
+	input := struct {
+		Name                       string
+		SecretId                   string
+		SecretString               string
+		KmsKeyId                   string
+		ForceDeleteWithoutRecovery bool
+		RecoveryWindowInDays       *int64
+		Tags                       []jsonTag
+		TagKeys                    []string
+		AddReplicaRegions          []struct {
+			Region   string
+			KmsKeyId string
+		}
+		RemoveReplicaRegions []string
+		ResourcePolicy       string
+	}{}
+	if err := decodeRequest(r, &input); err != nil {
+		return nil, err
+	}
+
+	if operation == "CreateSecret" {
+		if existing := f.secret(input.Name); existing != nil {
+			if existing.deletionDate != nil {
+				return nil, &awsError{status: http.StatusBadRequest, code: "InvalidRequestException", message: "a secret with this name is already scheduled for deletion"}
+			}
+			return nil, &awsError{status: http.StatusBadRequest, code: "ResourceExistsException", message: fmt.Sprintf("the secret %s already exists", input.Name)}
+		}
+
+		secret := &fakeSecret{
+			name:        input.Name,
+			arn:         fmt.Sprintf("arn:aws:secretsmanager:%s:%s:secret:%s-%s", fakeRegion, fakeAccountID, input.Name, f.newID("")[10:]),
+			value:       input.SecretString,
+			kmsKeyID:    input.KmsKeyId,
+			lastChanged: f.now(),
+			tags:        tagJSON(nil, input.Tags),
+			replicas:    make(map[string]*fakeReplica),
+		}
+		f.secrets[secret.name] = secret
+		for _, replica := range input.AddReplicaRegions {
+			f.replicate(secret, replica.Region, replica.KmsKeyId)
+		}
+
+		return map[string]interface{}{"ARN": secret.arn, "Name": secret.name, "VersionId": f.newID("version-"), "ReplicationStatus": secret.replicationStatus()}, nil
+	}
+
+	secret := f.secret(input.SecretId)
+	if secret == nil {
+		return nil, &awsError{status: http.StatusBadRequest, code: "ResourceNotFoundException", message: "Secrets Manager can't find the specified secret"}
+	}
+
+	switch operation {
+	case "DescribeSecret":
+		result := map[string]interface{}{
+			"ARN":             secret.arn,
+			"Name":            secret.name,
+			"LastChangedDate": epochSeconds(secret.lastChanged),
+		}
+		if secret.kmsKeyID != "" {
+			result["KmsKeyId"] = secret.kmsKeyID
+		}
+		if secret.deletionDate != nil {
+			result["DeletedDate"] = epochSeconds(*secret.deletionDate)
+		}
+		if len(secret.tags) > 0 {
+			result["Tags"] = jsonTags(secret.tags)
+		}
+		if len(secret.replicas) > 0 {
+			result["ReplicationStatus"] = secret.replicationStatus()
+		}
+		return result, nil
+
+	case "ReplicateSecretToRegions":
+		for _, replica := range input.AddReplicaRegions {
+			if _, ok := secret.replicas[replica.Region]; ok {
+				return nil, &awsError{status: http.StatusBadRequest, code: "InvalidParameterException", message: fmt.Sprintf("the secret is already replicated to %s", replica.Region)}
+			}
+			f.replicate(secret, replica.Region, replica.KmsKeyId)
+		}
+		return map[string]interface{}{"ARN": secret.arn, "ReplicationStatus": secret.replicationStatus()}, nil
+
+	case "GetResourcePolicy":
+		result := map[string]string{"ARN": secret.arn, "Name": secret.name}
+		if secret.policy != "" {
+			result["ResourcePolicy"] = secret.policy
+		}
+		return result, nil
+
+	case "PutResourcePolicy":
+		if !json.Valid([]byte(input.ResourcePolicy)) {
+			return nil, &awsError{status: http.StatusBadRequest, code: "MalformedPolicyDocumentException", message: "the policy is not valid JSON"}
+		}
+		secret.policy = input.ResourcePolicy
+		return map[string]string{"ARN": secret.arn, "Name": secret.name}, nil
+
+	case "DeleteResourcePolicy":
+		secret.policy = ""
+		return map[string]string{"ARN": secret.arn, "Name": secret.name}, nil
+
+	case "RemoveRegionsFromReplication":
+		for _, region := range input.RemoveReplicaRegions {
+			if _, ok := secret.replicas[region]; !ok {
+				return nil, &awsError{status: http.StatusBadRequest, code: "InvalidParameterException", message: fmt.Sprintf("the secret is not replicated to %s", region)}
+			}
+			delete(secret.replicas, region)
+		}
+		return map[string]interface{}{"ARN": secret.arn, "ReplicationStatus": secret.replicationStatus()}, nil
+
+	case "TagResource":
+		secret.tags = tagJSON(secret.tags, input.Tags)
+		return map[string]string{}, nil
+
+	case "UntagResource":
+		for _, key := range input.TagKeys {
+			delete(secret.tags, key)
+		}
+		return map[string]string{}, nil
+
+	case "UpdateSecret":
+		if secret.deletionDate != nil {
+			return nil, &awsError{status: http.StatusBadRequest, code: "InvalidRequestException", message: "the secret is scheduled for deletion"}
+		}
+		secret.value = input.SecretString
+		if input.KmsKeyId != "" {
+			secret.kmsKeyID = input.KmsKeyId
+		}
+		secret.lastChanged = f.now()
+		return map[string]string{"ARN": secret.arn, "Name": secret.name, "VersionId": f.newID("version-")}, nil
+
+	case "DeleteSecret":
+		if len(secret.replicas) > 0 {
+			return nil, &awsError{status: http.StatusBadRequest, code: "InvalidRequestException", message: "You can't delete secret that has replica regions."}
+		}
+		if input.ForceDeleteWithoutRecovery && input.RecoveryWindowInDays != nil {
+			return nil, &awsError{status: http.StatusBadRequest, code: "InvalidParameterException", message: "ForceDeleteWithoutRecovery and RecoveryWindowInDays are mutually exclusive"}
+		}
+
+		deletionDate := f.now()
+		if input.ForceDeleteWithoutRecovery {
+			delete(f.secrets, secret.name)
+		} else if secret.deletionDate == nil {
+			days := int64(30)
+			if input.RecoveryWindowInDays != nil {
+				days = *input.RecoveryWindowInDays
+			}
+			deletionDate = deletionDate.AddDate(0, 0, int(days))
+			secret.deletionDate = &deletionDate
+		} else {
+			deletionDate = *secret.deletionDate
+		}
+		return map[string]interface{}{"ARN": secret.arn, "Name": secret.name, "DeletionDate": epochSeconds(deletionDate)}, nil
+	}
+
+	return nil, &awsError{status: http.StatusBadRequest, code: "UnknownOperationException", message: operation}
+}
+
+// failReplication fails the replications of secrets to the region with the message, or stops failing
+// them when the message is empty
+func (f *fakeAWS) failReplication(region, message string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if message == "" {
+		delete(f.replicaFailures, region)
+		return
+	}
+	f.replicaFailures[region] = message
+}
+
+// resourcePolicyOf returns the statements of the resource policy of a secret, nil without a policy
+// holding a list of statements
+func (f *fakeAWS) resourcePolicyOf(name string) []map[string]interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	secret, ok := f.secrets[name]
+	if !ok || secret.policy == "" {
+		return nil
+	}
+	policy := struct {
+		Statement []map[string]interface{}
+	}{}
+	if err := json.Unmarshal([]byte(secret.policy), &policy); err != nil {
+		return nil
+	}
+
+	return policy.Statement
+}
+
+// replicasOf returns the keys of the replicas of a secret by region, nil when the secret does not exist
+func (f *fakeAWS) replicasOf(name string) map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	secret, ok := f.secrets[name]
+	if !ok {
+		return nil
+	}
+
+	return secret.replicaKeys()
+}
+
+// replicate adds a replica to a secret, failed when its region fails the replications
+func (f *fakeAWS) replicate(secret *fakeSecret, region, kmsKeyID string) {
+	replica := &fakeReplica{kmsKeyID: kmsKeyID, status: "InSync"}
+	if message, ok := f.replicaFailures[region]; ok {
+		replica.status = "Failed"
+		replica.message = message
+	}
+	secret.replicas[region] = replica
+}
+
+func (s *fakeSecret) replicationStatus() []map[string]string {
+	status := make([]map[string]string, 0, len(s.replicas))
+	for _, region := range sortedKeys(s.replicaKeys()) {
+		replica := s.replicas[region]
+		entry := map[string]string{"Region": region, "Status": replica.status}
+		if replica.kmsKeyID != "" {
+			entry["KmsKeyId"] = replica.kmsKeyID
+		}
+		if replica.message != "" {
+			entry["StatusMessage"] = replica.message
+		}
+		status = append(status, entry)
+	}
+
+	return status
+}
+
+// replicaKeys returns the keys of the replicas by region
+func (s *fakeSecret) replicaKeys() map[string]string {
+	keys := make(map[string]string, len(s.replicas))
+	for region, replica := range s.replicas {
+		keys[region] = replica.kmsKeyID
+	}
+
+	return keys
+}
+
+func (f *fakeAWS) parameterStore(operation string, r *http.Request) (interface{}, *awsError) {
+	input := struct {
+		Name      string
+		Value     string
+		KeyId     string
+		Tier      string
+		Overwrite bool
+		Tags      []jsonTag
+		TagKeys   []string
+		// ResourceId names the parameter of the tagging operations
+		ResourceId string
+	}{}
+	if err := decodeRequest(r, &input); err != nil {
+		return nil, err
+	}
+
+	if input.ResourceId != "" {
+		input.Name = input.ResourceId
+	}
+	parameter, exists := f.parameters[input.Name]
+	notFound := &awsError{status: http.StatusBadRequest, code: "ParameterNotFound", message: input.Name}
+
+	switch operation {
+	case "PutParameter":
+		if exists && !input.Overwrite {
+			return nil, &awsError{status: http.StatusBadRequest, code: "ParameterAlreadyExists", message: input.Name}
+		}
+		if input.Overwrite && len(input.Tags) > 0 {
+			return nil, &awsError{status: http.StatusBadRequest, code: "ValidationException", message: "tags and overwrite can't be used together"}
+		}
+		if !exists {
+			parameter = &fakeParameter{
+				name: input.Name,
+				arn:  fmt.Sprintf("arn:aws:ssm:%s:%s:parameter/%s", fakeRegion, fakeAccountID, strings.TrimPrefix(input.Name, "/")),
+			}
+			f.parameters[input.Name] = parameter
+		}
+		if len(input.Tags) > 0 {
+			parameter.tags = tagJSON(parameter.tags, input.Tags)
+		}
+		parameter.value = input.Value
+		parameter.keyID = input.KeyId
+		parameter.tier = input.Tier
+		parameter.version++
+		parameter.lastModified = f.now()
+		return map[string]interface{}{"Version": parameter.version, "Tier": parameter.tier}, nil
+
+	case "GetParameter":
+		if !exists {
+			return nil, notFound
+		}
+		return map[string]interface{}{
+			"Parameter": map[string]interface{}{
+				"Name":             parameter.name,
+				"ARN":              parameter.arn,
+				"Type":             "SecureString",
+				"Value":            parameter.value,
+				"Version":          parameter.version,
+				"LastModifiedDate": epochSeconds(parameter.lastModified),
+			},
+		}, nil
+
+	case "ListTagsForResource", "AddTagsToResource", "RemoveTagsFromResource":
+		if !exists {
+			return nil, &awsError{status: http.StatusBadRequest, code: "InvalidResourceId", message: input.Name}
+		}
+		if operation == "AddTagsToResource" {
+			parameter.tags = tagJSON(parameter.tags, input.Tags)
+			return map[string]string{}, nil
+		}
+		if operation == "RemoveTagsFromResource" {
+			for _, key := range input.TagKeys {
+				delete(parameter.tags, key)
+			}
+			return map[string]string{}, nil
+		}
+		return map[string]interface{}{"TagList": jsonTags(parameter.tags)}, nil
+
+	case "DeleteParameter":
+		if !exists {
+			return nil, notFound
+		}
+		delete(f.parameters, input.Name)
+		return map[string]string{}, nil
+	}
+
+	return nil, &awsError{status: http.StatusBadRequest, code: "UnknownOperationException", message: operation}
+}
+
+type kmsTag struct {
+	TagKey   string
+	TagValue string
+}
+
+// key finds a KMS key by id, ARN or alias
+func (f *fakeAWS) key(id string) *fakeKey {
+	if keyID, ok := f.aliases[id]; ok {
+		id = keyID
+	}
+	if key, ok := f.keys[id]; ok {
+		return key
+	}
+
+	for _, key := range f.keys {
+		if key.arn == id {
+			return key
+		}
+	}
+
+	return nil
+}
+
+func (f *fakeAWS) kms(operation string, r *http.Request) (interface{}, *awsError) {
+	input := struct {
+		KeyId               string
+		AliasName           string
+		TargetKeyId         string
+		Description         string
+		Policy              string
+		PendingWindowInDays int32
+		Tags                []kmsTag
+	}{}
+	if err := decodeRequest(r, &input); err != nil {
+		return nil, err
+	}
+
+	if operation == "CreateKey" {
+		key := &fakeKey{
+			id:          f.newID("key-"),
+			description: input.Description,
+			policy:      input.Policy,
+			state:       "Enabled",
+			tags:        make(map[string]string),
+		}
+		key.arn = fmt.Sprintf("arn:aws:kms:%s:%s:key/%s", fakeRegion, fakeAccountID, key.id)
+		for _, tag := range input.Tags {
+			key.tags[tag.TagKey] = tag.TagValue
+		}
+		f.keys[key.id] = key
+		return map[string]interface{}{"KeyMetadata": key.metadata()}, nil
+	}
+
+	if operation == "CreateAlias" {
+		input.KeyId = input.TargetKeyId
+		if _, ok := f.aliases[input.AliasName]; ok {
+			return nil, &awsError{status: http.StatusBadRequest, code: "AlreadyExistsException", message: input.AliasName}
+		}
+	}
+
+	key := f.key(input.KeyId)
+	if key == nil {
+		return nil, &awsError{status: http.StatusBadRequest, code: "NotFoundException", message: fmt.Sprintf("Key '%s' does not exist", input.KeyId)}
+	}
+	invalidState := &awsError{status: http.StatusBadRequest, code: "KMSInvalidStateException", message: fmt.Sprintf("%s is %s", key.arn, key.state)}
+
+	switch operation {
+	case "CreateAlias":
+		f.aliases[input.AliasName] = key.id
+		return map[string]string{}, nil
+
+	case "DescribeKey":
+		return map[string]interface{}{"KeyMetadata": key.metadata()}, nil
+
+	case "EnableKeyRotation":
+		key.rotation = true
+		return map[string]string{}, nil
+
+	case "ListResourceTags":
+		tags := make([]kmsTag, 0, len(key.tags))
+		for _, tag := range jsonTags(key.tags) {
+			tags = append(tags, kmsTag{tag.Key, tag.Value})
+		}
+		return map[string]interface{}{"Tags": tags, "Truncated": false}, nil
+
+	case "TagResource":
+		if key.state == "PendingDeletion" {
+			return nil, invalidState
+		}
+		for _, tag := range input.Tags {
+			key.tags[tag.TagKey] = tag.TagValue
+		}
+		return map[string]string{}, nil
+
+	case "ScheduleKeyDeletion":
+		if key.state == "PendingDeletion" {
+			return nil, invalidState
+		}
+		key.state = "PendingDeletion"
+		key.deletionDays = input.PendingWindowInDays
+		return map[string]interface{}{"KeyId": key.arn, "KeyState": key.state, "PendingWindowInDays": key.deletionDays}, nil
+
+	case "CancelKeyDeletion":
+		if key.state != "PendingDeletion" {
+			return nil, invalidState
+		}
+		key.state = "Disabled"
+		key.deletionDays = 0
+		return map[string]string{"KeyId": key.arn}, nil
+
+	case "EnableKey":
+		if key.state == "PendingDeletion" {
+			return nil, invalidState
+		}
+		key.state = "Enabled"
+		return map[string]string{}, nil
+	}
+
+	return nil, &awsError{status: http.StatusBadRequest, code: "UnsupportedOperationException", message: operation}
+}
+
+func (k *fakeKey) metadata() map[string]interface{} {
+	return map[string]interface{}{
+		"KeyId":        k.id,
+		"Arn":          k.arn,
+		"AWSAccountId": fakeAccountID,
+		"Description":  k.description,
+		"KeyState":     k.state,
+		"Enabled":      k.state == "Enabled",
+		"KeyManager":   "CUSTOMER",
+	}
+}
+
+// keyOf returns a copy of the key of an alias, nil when the alias doesn't exist
+func (f *fakeAWS) keyOf(alias string) *fakeKey {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	keyID, ok := f.aliases[alias]
+	if !ok {
+		return nil
+	}
+	copied := *f.keys[keyID]
+
+	return &copied
+}
+
+type stsCallerIdentity struct {
+	Arn     string
+	Account string
+	UserId  string
+}
+
+type stsCredentials struct {
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+}
+
+type stsAssumedRoleUser struct {
+	Arn           string
+	AssumedRoleId string
+}
+
+type stsAssumedRole struct {
+	Credentials     stsCredentials
+	AssumedRoleUser stsAssumedRoleUser
+}
+
+// sts answers the STS calls, the identity of the caller depends on the access key signing the call
+func (f *fakeAWS) sts(operation string, form url.Values, accessKey string) (interface{}, *awsError) {
+	if operation == "GetCallerIdentity" {
+		if session, ok := f.sessions[accessKey]; ok {
+			return stsCallerIdentity{Arn: session.Arn, Account: fakeAccountID, UserId: session.AssumedRoleId}, nil
+		}
+
+		return stsCallerIdentity{Arn: fakeCallerArn, Account: fakeAccountID, UserId: "AROAFAKE:session"}, nil
+	}
+
+	if operation == "AssumeRoleWithWebIdentity" && form.Get("WebIdentityToken") == "" {
+		return nil, &awsError{status: http.StatusBadRequest, code: "InvalidIdentityToken", message: "missing web identity token"}
+	}
+
+	roleArn, sessionName := form.Get("RoleArn"), form.Get("RoleSessionName")
+	_, roleName, found := strings.Cut(roleArn, ":role/")
+	if !found {
+		return nil, &awsError{status: http.StatusBadRequest, code: "ValidationError", message: fmt.Sprintf("invalid role arn %s", roleArn)}
+	}
+	f.assumed = append(f.assumed, form)
+
+	credentials := stsCredentials{
+		AccessKeyId:     f.newID("ASIA"),
+		SecretAccessKey: "session-secret",
+		SessionToken:    f.newID("token"),
+		Expiration:      time.Now().Add(fakeSessionDuration),
+	}
+	user := stsAssumedRoleUser{
+		Arn:           fmt.Sprintf("arn:aws:sts::%s:assumed-role/%s/%s", fakeAccountID, roleName, sessionName),
+		AssumedRoleId: fmt.Sprintf("AROA%s:%s", strings.ToUpper(roleName), sessionName),
+	}
+	f.sessions[credentials.AccessKeyId] = user
+
+	return stsAssumedRole{Credentials: credentials, AssumedRoleUser: user}, nil
+}
+
+type iamPolicy struct {
+	PolicyName       string
+	PolicyId         string
+	Arn              string
+	Path             string
+	DefaultVersionId string
+	AttachmentCount  int
+	CreateDate       time.Time
+}
+
+type iamPolicyVersion struct {
+	VersionId        string
+	IsDefaultVersion bool
+	CreateDate       time.Time
+}
+
+type iamAttachedPolicy struct {
+	PolicyName string
+	PolicyArn  string
+}
+
+type iamRole struct {
+	RoleName                 string
+	RoleId                   string
+	Arn                      string
+	Path                     string
+	AssumeRolePolicyDocument string
+	CreateDate               time.Time
+}
+
+func (f *fakeAWS) iamPolicy(policy *fakePolicy) iamPolicy {
+	attachments := 0
+	for _, role := range f.roles {
+		if role.attachedPolicies[policy.arn] {
+			attachments++
+		}
+	}
+
+	return iamPolicy{
+		PolicyName:       policy.name,
+		PolicyId:         strings.ToUpper(policy.name),
+		Arn:              policy.arn,
+		Path:             "/",
+		DefaultVersionId: policy.defaultVersion().id,
+		AttachmentCount:  attachments,
+		CreateDate:       policy.created,
+	}
+}
+
+// iamPath returns the path of the request, / by default
+func iamPath(form url.Values) string {
+	if path := form.Get("Path"); path != "" {
+		return path
+	}
+
+	return "/"
+}
+
+// roleByArn finds a role by ARN
+func (f *fakeAWS) roleByArn(roleArn string) *fakeRole {
+	for _, role := range f.roles {
+		if role.arn == roleArn {
+			return role
+		}
+	}
+
+	return nil
+}
+
+func iamNotFound(kind, name string) *awsError {
+	return &awsError{status: http.StatusNotFound, code: "NoSuchEntity", message: fmt.Sprintf("The %s with name %s cannot be found.", kind, name)}
+}
+
+func iamConflict(message string) *awsError {
+	return &awsError{status: http.StatusConflict, code: "DeleteConflict", message: message}
+}
+
+func (f *fakeAWS) iam(operation string, form url.Values) (interface{}, *awsError) {
+	policy, policyExists := f.policies[form.Get("PolicyArn")]
+	role, roleExists := f.roles[form.Get("RoleName")]
+
+	// inline policies are named by the role, attached policies are listed by the role
+	inline := map[string]bool{"PutRolePolicy": true, "DeleteRolePolicy": true, "ListRolePolicies": true, "ListAttachedRolePolicies": true}
+	if strings.Contains(operation, "Policy") && operation != "CreatePolicy" && operation != "UpdateAssumeRolePolicy" && !inline[operation] && !policyExists {
+		return nil, iamNotFound("policy", form.Get("PolicyArn"))
+	}
+	if strings.Contains(operation, "Role") && operation != "CreateRole" && !roleExists {
+		return nil, iamNotFound("role", form.Get("RoleName"))
+	}
+
+	switch operation {
+	case "CreatePolicy":
+		arn := fmt.Sprintf("arn:aws:iam::%s:policy%s%s", fakeAccountID, iamPath(form), form.Get("PolicyName"))
+		if _, ok := f.policies[arn]; ok {
+			return nil, &awsError{status: http.StatusConflict, code: "EntityAlreadyExists", message: fmt.Sprintf("A policy called %s already exists.", form.Get("PolicyName"))}
+		}
+
+		created := f.now()
+		policy = &fakePolicy{
+			name:        form.Get("PolicyName"),
+			arn:         arn,
+			created:     created,
+			versions:    []*fakePolicyVersion{{id: "v1", document: form.Get("PolicyDocument"), isDefault: true, created: created}},
+			nextVersion: 2,
+			tags:        tagForm(nil, form),
+		}
+		f.policies[arn] = policy
+		return struct{ Policy iamPolicy }{f.iamPolicy(policy)}, nil
+
+	case "CreatePolicyVersion":
+		if len(policy.versions) >= iamPolicyVersionLimit {
+			return nil, &awsError{status: http.StatusConflict, code: "LimitExceeded", message: fmt.Sprintf("A managed policy can have up to %d versions.", iamPolicyVersionLimit)}
+		}
+
+		version := &fakePolicyVersion{
+			id:        fmt.Sprintf("v%d", policy.nextVersion),
+			document:  form.Get("PolicyDocument"),
+			isDefault: form.Get("SetAsDefault") == "true",
+			created:   f.now(),
+		}
+		policy.nextVersion++
+		if version.isDefault {
+			policy.defaultVersion().isDefault = false
+		}
+		policy.versions = append(policy.versions, version)
+		return struct{ PolicyVersion iamPolicyVersion }{iamPolicyVersion{version.id, version.isDefault, version.created}}, nil
+
+	case "ListPolicyVersions":
+		versions := make([]iamPolicyVersion, 0, len(policy.versions))
+		for _, version := range policy.versions {
+			versions = append(versions, iamPolicyVersion{version.id, version.isDefault, version.created})
+		}
+		return struct {
+			Versions    []iamPolicyVersion `xml:"Versions>member"`
+			IsTruncated bool
+		}{Versions: versions}, nil
+
+	case "DeletePolicyVersion":
+		for i, version := range policy.versions {
+			if version.id != form.Get("VersionId") {
+				continue
+			}
+			if version.isDefault {
+				return nil, iamConflict("Cannot delete the default version of a policy.")
+			}
+			policy.versions = append(policy.versions[:i], policy.versions[i+1:]...)
+			return nil, nil
+		}
+		return nil, iamNotFound("policy version", form.Get("VersionId"))
+
+	case "DeletePolicy":
+		if f.iamPolicy(policy).AttachmentCount > 0 {
+			return nil, iamConflict("Cannot delete a policy attached to entities.")
+		}
+		if len(policy.versions) > 1 {
+			return nil, iamConflict("This policy has more than one version. Before you delete a policy, you must delete the policy's versions.")
+		}
+		delete(f.policies, policy.arn)
+		return nil, nil
+
+	case "CreateRole":
+		if roleExists {
+			return nil, &awsError{status: http.StatusConflict, code: "EntityAlreadyExists", message: fmt.Sprintf("Role with name %s already exists.", form.Get("RoleName"))}
+		}
+
+		if len(form.Get("RoleName")) > 64 {
+			return nil, &awsError{status: http.StatusBadRequest, code: "ValidationError", message: "roleName must have length less than or equal to 64"}
+		}
+
+		role = &fakeRole{
+			name:               form.Get("RoleName"),
+			arn:                fmt.Sprintf("arn:aws:iam::%s:role%s%s", fakeAccountID, iamPath(form), form.Get("RoleName")),
+			created:            f.now(),
+			trustPolicy:        form.Get("AssumeRolePolicyDocument"),
+			attachedPolicies:   make(map[string]bool),
+			tags:               tagForm(nil, form),
+			path:               iamPath(form),
+			boundary:           form.Get("PermissionsBoundary"),
+			maxSessionDuration: 3600,
+			inlinePolicies:     make(map[string]string),
+		}
+		if form.Has("MaxSessionDuration") {
+			role.maxSessionDuration, _ = strconv.Atoi(form.Get("MaxSessionDuration"))
+		}
+		f.roles[role.name] = role
+		return struct{ Role iamRole }{iamRole{
+			RoleName:                 role.name,
+			RoleId:                   f.newID("AROA"),
+			Arn:                      role.arn,
+			Path:                     role.path,
+			AssumeRolePolicyDocument: url.QueryEscape(role.trustPolicy),
+			CreateDate:               role.created,
+		}}, nil
+
+	case "PutRolePermissionsBoundary":
+		role.boundary = form.Get("PermissionsBoundary")
+		return nil, nil
+
+	case "UpdateRole":
+		if form.Has("MaxSessionDuration") {
+			role.maxSessionDuration, _ = strconv.Atoi(form.Get("MaxSessionDuration"))
+		}
+		return struct{}{}, nil
+
+	case "GetRole":
+		return struct{ Role iamRole }{iamRole{
+			RoleName:                 role.name,
+			RoleId:                   f.newID("AROA"),
+			Arn:                      role.arn,
+			Path:                     role.path,
+			AssumeRolePolicyDocument: url.QueryEscape(role.trustPolicy),
+			CreateDate:               role.created,
+		}}, nil
+
+	case "ListPolicyTags", "ListRoleTags":
+		var tags map[string]string
+		if operation == "ListRoleTags" {
+			tags = role.tags
+		} else {
+			tags = policy.tags
+		}
+		return struct {
+			Tags        []iamTag `xml:"Tags>member"`
+			IsTruncated bool
+		}{Tags: iamTagList(tags)}, nil
+
+	case "TagPolicy":
+		policy.tags = tagForm(policy.tags, form)
+		return nil, nil
+
+	case "TagRole":
+		role.tags = tagForm(role.tags, form)
+		return nil, nil
+
+	case "UntagPolicy":
+		untagForm(policy.tags, form)
+		return nil, nil
+
+	case "UntagRole":
+		untagForm(role.tags, form)
+		return nil, nil
+
+	case "UpdateAssumeRolePolicy":
+		role.trustPolicy = form.Get("PolicyDocument")
+		return nil, nil
+
+	case "AttachRolePolicy":
+		role.attachedPolicies[policy.arn] = true
+		return nil, nil
+
+	case "DetachRolePolicy":
+		if !role.attachedPolicies[policy.arn] {
+			return nil, iamNotFound("policy attachment", policy.arn)
+		}
+		delete(role.attachedPolicies, policy.arn)
+		return nil, nil
+
+	case "PutRolePolicy":
+		role.inlinePolicies[form.Get("PolicyName")] = form.Get("PolicyDocument")
+		return nil, nil
+
+	case "DeleteRolePolicy":
+		if _, ok := role.inlinePolicies[form.Get("PolicyName")]; !ok {
+			return nil, iamNotFound("role policy", form.Get("PolicyName"))
+		}
+		delete(role.inlinePolicies, form.Get("PolicyName"))
+		return nil, nil
+
+	case "ListRolePolicies":
+		return struct {
+			PolicyNames []string `xml:"PolicyNames>member"`
+			IsTruncated bool
+		}{PolicyNames: sortedKeys(role.inlinePolicies)}, nil
+
+	case "ListAttachedRolePolicies":
+		attached := make([]iamAttachedPolicy, 0, len(role.attachedPolicies))
+		for _, policyArn := range role.policies() {
+			attached = append(attached, iamAttachedPolicy{PolicyName: policyArn[strings.LastIndex(policyArn, "/")+1:], PolicyArn: policyArn})
+		}
+		return struct {
+			AttachedPolicies []iamAttachedPolicy `xml:"AttachedPolicies>member"`
+			IsTruncated      bool
+		}{AttachedPolicies: attached}, nil
+
+	case "DeleteRole":
+		if len(role.attachedPolicies) > 0 || len(role.inlinePolicies) > 0 {
+			return nil, iamConflict("Cannot delete entity, must detach all policies first.")
+		}
+		delete(f.roles, role.name)
+		return nil, nil
+	}
+
+	return nil, &awsError{status: http.StatusBadRequest, code: "InvalidAction", message: operation}
+}
+
+// policyVersions returns the ids of the versions of a policy, oldest first
+func (f *fakeAWS) policyVersions(policyArn string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ids := make([]string, 0)
+	if policy, ok := f.policies[policyArn]; ok {
+		for _, version := range policy.versions {
+			ids = append(ids, version.id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, _ := strconv.Atoi(ids[i][1:])
+		b, _ := strconv.Atoi(ids[j][1:])
+		return a < b
+	})
+
+	return ids
+}
+
+// addPolicy creates a policy managed outside of the provider, such as the AWS managed policies
+func (f *fakeAWS) addPolicy(policyArn string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.policies[policyArn] = &fakePolicy{
+		name:     policyArn[strings.LastIndex(policyArn, "/")+1:],
+		arn:      policyArn,
+		created:  f.now(),
+		versions: []*fakePolicyVersion{{id: "v1", document: "{}", isDefault: true, created: f.now()}},
+	}
+}
+
+// role returns a copy of a role, nil when it doesn't exist
+func (f *fakeAWS) role(name string) *fakeRole {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	role, ok := f.roles[name]
+	if !ok {
+		return nil
+	}
+	copied := *role
+	copied.attachedPolicies = make(map[string]bool, len(role.attachedPolicies))
+	for policyArn := range role.attachedPolicies {
+		copied.attachedPolicies[policyArn] = true
+	}
+	copied.inlinePolicies = make(map[string]string, len(role.inlinePolicies))
+	for name, document := range role.inlinePolicies {
+		copied.inlinePolicies[name] = document
+	}
+
+	return &copied
+}
+
+// policies returns the sorted ARNs of the policies attached to a role
+func (r *fakeRole) policies() []string {
+	policies := make([]string, 0, len(r.attachedPolicies))
+	for policyArn := range r.attachedPolicies {
+		policies = append(policies, policyArn)
+	}
+	sort.Strings(policies)
+
+	return policies
+}
+
+// handleEks answers the EKS calls on /clusters/<cluster>/pod-identity-associations[/<id>]
+func (f *fakeAWS) handleEks(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/clusters/"), "/")
+	cluster, id := parts[0], ""
+	if len(parts) > 2 {
+		id = parts[2]
+	}
+
+	var operation string
+	switch {
+	case r.Method == http.MethodPost && id == "":
+		operation = "CreatePodIdentityAssociation"
+	case r.Method == http.MethodGet && id == "":
+		operation = "ListPodIdentityAssociations"
+	case r.Method == http.MethodGet:
+		operation = "DescribePodIdentityAssociation"
+	case r.Method == http.MethodDelete:
+		operation = "DeletePodIdentityAssociation"
+	default:
+		operation = r.Method + " " + r.URL.Path
+	}
+	f.calls = append(f.calls, operation)
+
+	var (
+		result interface{}
+		err    *awsError
+	)
+	if err = f.failures[operation]; err == nil {
+		result, err = f.eks(operation, cluster, id, r)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.Header().Set("X-Amzn-ErrorType", err.code)
+		w.WriteHeader(err.status)
+		_ = json.NewEncoder(w).Encode(map[string]string{"message": err.message})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+func (a *fakeAssociation) toJSON() map[string]interface{} {
+	return map[string]interface{}{
+		"associationId":  a.id,
+		"associationArn": a.arn,
+		"clusterName":    a.cluster,
+		"namespace":      a.namespace,
+		"serviceAccount": a.serviceAccount,
+		"roleArn":        a.roleArn,
+		"createdAt":      epochSeconds(a.created),
+	}
+}
+
+func eksNotFound(message string) *awsError {
+	return &awsError{status: http.StatusNotFound, code: "ResourceNotFoundException", message: message}
+}
+
+func (f *fakeAWS) eks(operation, cluster, id string, r *http.Request) (interface{}, *awsError) {
+	switch operation {
+	case "CreatePodIdentityAssociation":
+		input := struct {
+			Namespace      string
+			ServiceAccount string
+			RoleArn        string
+			Tags           map[string]string
+		}{}
+		if err := decodeRequest(r, &input); err != nil {
+			return nil, err
+		}
+
+		if f.roleByArn(input.RoleArn) == nil {
+			return nil, &awsError{status: http.StatusBadRequest, code: "InvalidParameterException", message: fmt.Sprintf("Role %s does not exist", input.RoleArn)}
+		}
+		for _, association := range f.associations {
+			if association.cluster == cluster && association.namespace == input.Namespace && association.serviceAccount == input.ServiceAccount {
+				return nil, &awsError{status: http.StatusConflict, code: "ResourceInUseException", message: "Association already exists"}
+			}
+		}
+
+		association := &fakeAssociation{
+			id:             strings.ToLower(f.newID("a-")),
+			cluster:        cluster,
+			namespace:      input.Namespace,
+			serviceAccount: input.ServiceAccount,
+			roleArn:        input.RoleArn,
+			created:        f.now(),
+			tags:           input.Tags,
+		}
+		association.arn = fmt.Sprintf("arn:aws:eks:%s:%s:podidentityassociation/%s/%s", fakeRegion, fakeAccountID, cluster, association.id)
+		f.associations[association.id] = association
+		return map[string]interface{}{"association": association.toJSON()}, nil
+
+	case "ListPodIdentityAssociations":
+		query := r.URL.Query()
+		summaries := make([]map[string]interface{}, 0)
+		for _, association := range f.associations {
+			if association.cluster != cluster ||
+				(query.Has("namespace") && query.Get("namespace") != association.namespace) ||
+				(query.Has("serviceAccount") && query.Get("serviceAccount") != association.serviceAccount) {
+				continue
+			}
+			summary := association.toJSON()
+			delete(summary, "roleArn")
+			delete(summary, "createdAt")
+			summaries = append(summaries, summary)
+		}
+		return map[string]interface{}{"associations": summaries}, nil
+	}
+
+	association, ok := f.associations[id]
+	if !ok || association.cluster != cluster {
+		return nil, eksNotFound(fmt.Sprintf("Association %s not found", id))
+	}
+
+	switch operation {
+	case "DescribePodIdentityAssociation":
+		return map[string]interface{}{"association": association.toJSON()}, nil
+
+	case "DeletePodIdentityAssociation":
+		delete(f.associations, id)
+		return map[string]interface{}{"association": association.toJSON()}, nil
+	}
+
+	return nil, &awsError{status: http.StatusBadRequest, code: "InvalidRequestException", message: operation}
+}
+
+// podIdentityAssociations returns the associations of the cluster as namespace/serviceaccount=role
+func (f *fakeAWS) podIdentityAssociations(cluster string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	associations := make([]string, 0)
+	for _, association := range f.associations {
+		if association.cluster == cluster {
+			associations = append(associations, fmt.Sprintf("%s/%s=%s", association.namespace, association.serviceAccount, association.roleArn))
+		}
+	}
+	sort.Strings(associations)
+
+	return associations
+}
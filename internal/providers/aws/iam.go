@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
 	"github.com/aws/aws-sdk-go-v2/service/iam"
 	"github.com/aws/aws-sdk-go-v2/service/iam/types"
 	"github.com/go-logr/logr"
@@ -16,17 +17,42 @@ import (
 )
 
 func (p *AwsProvider) CreateAccess(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, access *secretsv1alpha1.ExternalSecretAccess) error {
+	if p.RoleScope == RoleScopeServiceAccount {
+		return p.grantServiceAccountRoles(ctx, reqLogger, secret, access)
+	}
+	// the roles of service accounts left by the serviceAccount role scope
+	if err := p.revokeServiceAccountRoles(ctx, reqLogger, access); err != nil {
+		return err
+	}
+	if p.AccessMode == AccessModeResourcePolicy {
+		return p.grantResourcePolicy(ctx, reqLogger, secret, access)
+	}
+
+	return p.createRole(ctx, reqLogger, secret, access)
+}
+
+// createRole creates the policy and the role of an access assumable by its subjects, existing ones
+// are updated
+func (p *AwsProvider) createRole(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, access *secretsv1alpha1.ExternalSecretAccess) error {
 	// Create the IAM policy document
-	policyDocumentJSON, err := getSecretAccessPolicy(secret.Status.Provider["SecretArn"])
+	policyDocumentJSON, err := getSecretAccessPolicy(secret)
+	if err != nil {
+		return fmt.Errorf("failed to build policy document: %w", err)
+	}
+	tags := p.tagsOf(access, access.Spec.Tags)
+	removed := removedTags(access.Status.Provider, access.Spec.Tags)
+	name, err := p.resourceName(access)
 	if err != nil {
-		return fmt.Errorf("failed to marshal policy document: %w", err)
+		return err
 	}
 
 	if val, ok := access.Status.Provider["PolicyArn"]; !ok {
 		// Create the IAM policy
 		if createPolicyOutput, err := p.iamClient.CreatePolicy(ctx, &iam.CreatePolicyInput{
-			PolicyName:     aws.String(fmt.Sprintf("secretsbeam-%s-%s", access.Namespace, access.Name)),
+			PolicyName:     aws.String(name),
+			Path:           aws.String(p.IamPath),
 			PolicyDocument: aws.String(policyDocumentJSON),
+			Tags:           iamTags(tags, sortedKeys(tags)),
 		}); err != nil {
 			return fmt.Errorf("failed to create policy: %w", err)
 		} else {
@@ -34,16 +60,10 @@ func (p *AwsProvider) CreateAccess(ctx context.Context, reqLogger logr.Logger, s
 			access.Status.Provider["PolicyArn"] = *createPolicyOutput.Policy.Arn
 		}
 
-	} else {
-		// Update access policy
-		if policyDocumentJSON, err := getSecretAccessPolicy(secret.Status.Provider["SecretArn"]); err != nil {
-			return fmt.Errorf("failed to marshal policy document: %w", err)
-		} else if _, err = p.iamClient.CreatePolicyVersion(ctx, &iam.CreatePolicyVersionInput{
-			PolicyArn:      aws.String(val),
-			PolicyDocument: aws.String(policyDocumentJSON),
-		}); err != nil {
-			return fmt.Errorf("updating policy: %w", err)
-		}
+	} else if err := p.claimPolicy(ctx, val, access, tags, removed); err != nil {
+		return err
+	} else if err := p.updatePolicy(ctx, val, policyDocumentJSON); err != nil {
+		return fmt.Errorf("updating policy: %w", err)
 	}
 
 	if val, ok := access.Status.Provider["RoleName"]; !ok {
@@ -54,28 +74,30 @@ func (p *AwsProvider) CreateAccess(ctx context.Context, reqLogger logr.Logger, s
 		}
 
 		// Create the IAM role
-		createRoleOutput, err := p.iamClient.CreateRole(ctx, &iam.CreateRoleInput{
-			RoleName:                 aws.String(fmt.Sprintf("secretsbeam-%s-%s", access.Namespace, access.Name)),
-			AssumeRolePolicyDocument: aws.String(assumeRolePolicyDocumentJSON),
-		})
+		createRoleOutput, err := p.iamClient.CreateRole(ctx, p.createRoleInput(name, assumeRolePolicyDocumentJSON, tags))
 		if err != nil {
 			return fmt.Errorf("failed to create role: %w", err)
 		}
-		reqLogger.Info(fmt.Sprintf("Created Role: %s\n", *createRoleOutput.Role.Arn))
+		reqLogger.Info(fmt.Sprintf("Created Role: %s", *createRoleOutput.Role.Arn))
 		access.Status.Provider["RoleName"] = *createRoleOutput.Role.RoleName
-		access.Status.Provider["ServiceAccountAnnotation"] = fmt.Sprintf("eks.amazonaws.com/role-arn=%s", *createRoleOutput.Role.Arn)
+		access.Status.Provider["RoleArn"] = *createRoleOutput.Role.Arn
 	} else {
 		// Update trust policy
 		assumeRolePolicyDocumentJSON, err := p.getAssumePolicyDocument(access)
 		if err != nil {
 			return fmt.Errorf("marshalling assume role policy document: %w", err)
+		} else if err := p.claimRole(ctx, val, access, tags, removed); err != nil {
+			return err
 		} else if _, err = p.iamClient.UpdateAssumeRolePolicy(ctx, &iam.UpdateAssumeRolePolicyInput{
 			RoleName:       aws.String(val),
 			PolicyDocument: aws.String(assumeRolePolicyDocumentJSON),
 		}); err != nil {
 			return fmt.Errorf("failed to create role: %w", err)
+		} else if err := p.configureRole(ctx, val); err != nil {
+			return err
 		}
 	}
+	recordTags(access.Status.Provider, access.Spec.Tags)
 
 	// Attach the policy to the role
 	_, err = p.iamClient.AttachRolePolicy(ctx, &iam.AttachRolePolicyInput{
@@ -85,7 +107,18 @@ func (p *AwsProvider) CreateAccess(ctx context.Context, reqLogger logr.Logger, s
 	if err != nil {
 		return fmt.Errorf("failed to attach policy to role: %w", err)
 	}
-	reqLogger.Info(fmt.Sprintf("Attached Policy %s to Role %s\n", access.Status.Provider["PolicyArn"], access.Status.Provider["RoleName"]))
+	reqLogger.Info(fmt.Sprintf("Attached Policy %s to Role %s", access.Status.Provider["PolicyArn"], access.Status.Provider["RoleName"]))
+
+	if err := p.syncManagedPolicies(ctx, reqLogger, access, p.managedPolicyArns); err != nil {
+		return err
+	}
+
+	if err := p.bindServiceAccounts(ctx, reqLogger, access); err != nil {
+		return err
+	}
+	if err := p.revokeResourcePolicy(ctx, reqLogger, access); err != nil {
+		return err
+	}
 
 	access.Status.Subjects = access.Spec.AccessSubjects
 
@@ -93,82 +126,156 @@ func (p *AwsProvider) CreateAccess(ctx context.Context, reqLogger logr.Logger, s
 }
 
 func (p *AwsProvider) DeleteAccess(ctx context.Context, reqLogger logr.Logger, access *secretsv1alpha1.ExternalSecretAccess) error {
-	if _, err := p.iamClient.DetachRolePolicy(ctx, &iam.DetachRolePolicyInput{
-		PolicyArn: aws.String(access.Status.Provider["PolicyArn"]),
-		RoleName:  aws.String(access.Status.Provider["RoleName"]),
-	}); err != nil {
-		var notFoundErr *types.NoSuchEntityException
-		// Ignore the error if the policy is not found
-		if ok := errors.As(err, &notFoundErr); !ok {
-			return fmt.Errorf("failed to detach policy %s from role %s: %w", access.Status.Provider["PolicyArn"], access.Status.Provider["RoleName"], err)
+	if err := p.revokeResourcePolicy(ctx, reqLogger, access); err != nil {
+		return err
+	}
+	if err := p.revokeServiceAccountRoles(ctx, reqLogger, access); err != nil {
+		return err
+	}
+
+	return p.deleteRole(ctx, reqLogger, access)
+}
+
+// deleteRole deletes the role and the policy of an access and removes its pod identity associations,
+// they are removed from the status so that the access can switch to the resourcePolicy mode or the
+// serviceAccount role scope
+func (p *AwsProvider) deleteRole(ctx context.Context, reqLogger logr.Logger, access *secretsv1alpha1.ExternalSecretAccess) error {
+	policyArn := access.Status.Provider["PolicyArn"]
+	roleName := access.Status.Provider["RoleName"]
+
+	if policyArn != "" {
+		if err := p.claimPolicy(ctx, policyArn, access, nil, nil); err != nil {
+			return err
+		}
+	}
+	if roleName != "" {
+		if err := p.claimRole(ctx, roleName, access, nil, nil); err != nil {
+			return err
 		}
 	}
-	fmt.Printf("Detached policy %s from role %s\n", access.Status.Provider["PolicyArn"], access.Status.Provider["RoleName"])
 
-	if err := p.deleteNOldestPolicyVersions(ctx, access.Status.Provider["PolicyArn"], 0); err != nil {
-		var notFoundErr *types.NoSuchEntityException
-		// Ignore the error if the policy is not found
-		if ok := errors.As(err, &notFoundErr); !ok {
-			return fmt.Errorf("failed to delete policy versions %s: %w", access.Status.Provider["PolicyArn"], err)
+	if err := p.syncPodIdentityAssociations(ctx, reqLogger, access, nil); err != nil {
+		return err
+	}
+
+	if roleName != "" {
+		if err := p.syncManagedPolicies(ctx, reqLogger, access, nil); err != nil {
+			return err
 		}
 	}
-	fmt.Printf("Deleted policy versions from %s\n", access.Status.Provider["PolicyArn"])
 
-	if _, err := p.iamClient.DeletePolicy(ctx, &iam.DeletePolicyInput{
-		PolicyArn: aws.String(access.Status.Provider["PolicyArn"]),
-	}); err != nil {
-		var notFoundErr *types.NoSuchEntityException
-		if ok := errors.As(err, &notFoundErr); !ok {
-			return fmt.Errorf("failed to delete policy %s: %w", access.Status.Provider["PolicyArn"], err)
-			// Ignore the error if the policy is not found
+	if policyArn != "" && roleName != "" {
+		if _, err := p.iamClient.DetachRolePolicy(ctx, &iam.DetachRolePolicyInput{
+			PolicyArn: aws.String(policyArn),
+			RoleName:  aws.String(roleName),
+		}); err != nil && !isNoSuchEntity(err) {
+			return fmt.Errorf("failed to detach policy %s from role %s: %w", policyArn, roleName, err)
 		}
-		fmt.Printf("Policy %s not found, ignoring error\n", access.Status.Provider["PolicyArn"])
+		reqLogger.Info(fmt.Sprintf("Detached policy %s from role %s", policyArn, roleName))
 	}
-	fmt.Printf("Deleted policy %s\n", access.Status.Provider["PolicyArn"])
 
-	if _, err := p.iamClient.DeleteRole(ctx, &iam.DeleteRoleInput{
-		RoleName: aws.String(access.Status.Provider["RoleName"]),
-	}); err != nil {
-		var notFoundErr *types.NoSuchEntityException
-		if ok := errors.As(err, &notFoundErr); !ok {
-			return fmt.Errorf("failed to delete role %s: %w", access.Status.Provider["RoleName"], err)
-			// Ignore the error if the role is not found
+	if policyArn != "" {
+		// policies can only be deleted once all their non default versions are gone
+		if err := p.deleteNOldestPolicyVersions(ctx, policyArn, 0); err != nil && !isNoSuchEntity(err) {
+			return fmt.Errorf("failed to delete policy versions %s: %w", policyArn, err)
 		}
 
-		fmt.Printf("Policy %s not found, ignoring error\n", access.Status.Provider["RoleName"])
+		if _, err := p.iamClient.DeletePolicy(ctx, &iam.DeletePolicyInput{
+			PolicyArn: aws.String(policyArn),
+		}); err != nil {
+			if !isNoSuchEntity(err) {
+				return fmt.Errorf("failed to delete policy %s: %w", policyArn, err)
+			}
+			reqLogger.Info(fmt.Sprintf("Policy %s not found, ignoring", policyArn))
+		} else {
+			reqLogger.Info(fmt.Sprintf("Deleted policy %s", policyArn))
+		}
+	}
+
+	if roleName != "" {
+		if _, err := p.iamClient.DeleteRole(ctx, &iam.DeleteRoleInput{
+			RoleName: aws.String(roleName),
+		}); err != nil {
+			if !isNoSuchEntity(err) {
+				return fmt.Errorf("failed to delete role %s: %w", roleName, err)
+			}
+			reqLogger.Info(fmt.Sprintf("Role %s not found, ignoring", roleName))
+		} else {
+			reqLogger.Info(fmt.Sprintf("Deleted role %s", roleName))
+		}
+	}
+
+	for _, entry := range []string{"PolicyArn", "RoleName", "RoleArn", "ServiceAccountAnnotation", "Tags"} {
+		delete(access.Status.Provider, entry)
 	}
-	fmt.Printf("Deleted role %s\n", access.Status.Provider["RoleName"])
 
 	return nil
 }
 
 func (p *AwsProvider) UpdateAccess(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, access *secretsv1alpha1.ExternalSecretAccess) error {
-	policyArn := access.Status.Provider["PolicyArn"]
-
-	if err := p.deleteNOldestPolicyVersions(ctx, policyArn, 1); err != nil {
-		return fmt.Errorf("deleting oldest policy: %w", err)
+	if p.RoleScope == RoleScopeServiceAccount {
+		return p.grantServiceAccountRoles(ctx, reqLogger, secret, access)
+	}
+	if err := p.revokeServiceAccountRoles(ctx, reqLogger, access); err != nil {
+		return err
+	}
+	if p.AccessMode == AccessModeResourcePolicy {
+		return p.grantResourcePolicy(ctx, reqLogger, secret, access)
 	}
+	// accesses switching from the resourcePolicy mode or the serviceAccount role scope may have no role yet
+	if access.Status.Provider["PolicyArn"] == "" {
+		return p.createRole(ctx, reqLogger, secret, access)
+	}
+
 	// Update access policy
-	if policyDocumentJSON, err := getSecretAccessPolicy(secret.Status.Provider["SecretArn"]); err != nil {
-		reqLogger.Error(err, "failed to marshal policy document")
-	} else if _, err = p.iamClient.CreatePolicyVersion(ctx, &iam.CreatePolicyVersionInput{
-		PolicyArn:      aws.String(policyArn),
-		PolicyDocument: aws.String(policyDocumentJSON),
-		SetAsDefault:   true,
-	}); err != nil {
+	policyDocumentJSON, err := getSecretAccessPolicy(secret)
+	if err != nil {
+		return fmt.Errorf("failed to build policy document: %w", err)
+	}
+
+	roleName := access.Status.Provider["RoleName"]
+	if roleName == "" {
+		if roleName, err = p.resourceName(access); err != nil {
+			return err
+		}
+	}
+
+	tags := p.tagsOf(access, access.Spec.Tags)
+	removed := removedTags(access.Status.Provider, access.Spec.Tags)
+	if err := p.claimPolicy(ctx, access.Status.Provider["PolicyArn"], access, tags, removed); err != nil {
+		return err
+	}
+	if err := p.claimRole(ctx, roleName, access, tags, removed); err != nil {
+		return err
+	}
+	recordTags(access.Status.Provider, access.Spec.Tags)
+
+	if err := p.updatePolicy(ctx, access.Status.Provider["PolicyArn"], policyDocumentJSON); err != nil {
 		return fmt.Errorf("failed to update policy: %w", err)
 	}
 
 	// Update trust policy
 	assumeRolePolicyDocumentJSON, err := p.getAssumePolicyDocument(access)
 	if err != nil {
-		reqLogger.Error(err, "failed to marshal assume role policy document")
-		return err
+		return fmt.Errorf("marshalling assume role policy document: %w", err)
 	} else if _, err = p.iamClient.UpdateAssumeRolePolicy(ctx, &iam.UpdateAssumeRolePolicyInput{
-		RoleName:       aws.String(fmt.Sprintf("secretsbeam-%s-%s", access.Namespace, access.Name)),
+		RoleName:       aws.String(roleName),
 		PolicyDocument: aws.String(assumeRolePolicyDocumentJSON),
 	}); err != nil {
-		reqLogger.Error(err, "failed to create role")
+		return fmt.Errorf("failed to update role %s: %w", roleName, err)
+	}
+
+	if err := p.configureRole(ctx, roleName); err != nil {
+		return err
+	}
+	if err := p.syncManagedPolicies(ctx, reqLogger, access, p.managedPolicyArns); err != nil {
+		return err
+	}
+
+	if err := p.bindServiceAccounts(ctx, reqLogger, access); err != nil {
+		return err
+	}
+	if err := p.revokeResourcePolicy(ctx, reqLogger, access); err != nil {
 		return err
 	}
 
@@ -177,6 +284,143 @@ func (p *AwsProvider) UpdateAccess(ctx context.Context, reqLogger logr.Logger, s
 	return nil
 }
 
+// bindServiceAccounts lets the service accounts of the subjects assume the role of the access. In the
+// irsa mode they are annotated with the role, from the ServiceAccountAnnotation of the status, in the
+// podIdentity mode the role is associated with them. Associations left by the podIdentity mode are
+// removed in the irsa mode.
+func (p *AwsProvider) bindServiceAccounts(ctx context.Context, reqLogger logr.Logger, access *secretsv1alpha1.ExternalSecretAccess) error {
+	if p.AccessMode == AccessModePodIdentity {
+		delete(access.Status.Provider, "ServiceAccountAnnotation")
+		return p.syncPodIdentityAssociations(ctx, reqLogger, access, access.Spec.AccessSubjects)
+	}
+
+	if _, ok := access.Status.Provider["ServiceAccountAnnotation"]; !ok {
+		roleArn, err := p.roleArn(ctx, access)
+		if err != nil {
+			return err
+		}
+		access.Status.Provider["ServiceAccountAnnotation"] = fmt.Sprintf("eks.amazonaws.com/role-arn=%s", roleArn)
+	}
+
+	return p.syncPodIdentityAssociations(ctx, reqLogger, access, nil)
+}
+
+// createRoleInput returns the input creating a role with the path, the permissions boundary and the
+// maximum session duration of the provider
+func (p *AwsProvider) createRoleInput(roleName, assumeRolePolicyDocumentJSON string, tags map[string]string) *iam.CreateRoleInput {
+	input := &iam.CreateRoleInput{
+		RoleName:                 aws.String(roleName),
+		Path:                     aws.String(p.IamPath),
+		AssumeRolePolicyDocument: aws.String(assumeRolePolicyDocumentJSON),
+		Tags:                     iamTags(tags, sortedKeys(tags)),
+	}
+	if p.PermissionsBoundaryArn != "" {
+		input.PermissionsBoundary = aws.String(p.PermissionsBoundaryArn)
+	}
+	if p.maxSessionDuration > 0 {
+		input.MaxSessionDuration = aws.Int32(int32(p.maxSessionDuration.Seconds()))
+	}
+
+	return input
+}
+
+// configureRole applies the permissions boundary and the maximum session duration of the provider to
+// an existing role. Settings missing from the config are left as they are on the role.
+func (p *AwsProvider) configureRole(ctx context.Context, roleName string) error {
+	if p.PermissionsBoundaryArn != "" {
+		if _, err := p.iamClient.PutRolePermissionsBoundary(ctx, &iam.PutRolePermissionsBoundaryInput{
+			RoleName:            aws.String(roleName),
+			PermissionsBoundary: aws.String(p.PermissionsBoundaryArn),
+		}); err != nil {
+			return fmt.Errorf("setting the permissions boundary of role %s: %w", roleName, err)
+		}
+	}
+
+	if p.maxSessionDuration > 0 {
+		if _, err := p.iamClient.UpdateRole(ctx, &iam.UpdateRoleInput{
+			RoleName:           aws.String(roleName),
+			MaxSessionDuration: aws.Int32(int32(p.maxSessionDuration.Seconds())),
+		}); err != nil {
+			return fmt.Errorf("setting the max session duration of role %s: %w", roleName, err)
+		}
+	}
+
+	return nil
+}
+
+// syncManagedPolicies attaches the managed policies to the role of the access and detaches the ones
+// it had before, recorded in the ManagedPolicyArns entry of the status
+func (p *AwsProvider) syncManagedPolicies(ctx context.Context, reqLogger logr.Logger, access *secretsv1alpha1.ExternalSecretAccess, policyArns []string) error {
+	roleName := access.Status.Provider["RoleName"]
+	attached := make(map[string]bool)
+	if val := access.Status.Provider["ManagedPolicyArns"]; val != "" {
+		for _, policyArn := range strings.Split(val, ",") {
+			attached[policyArn] = true
+		}
+	}
+	defer func() {
+		if len(attached) == 0 {
+			delete(access.Status.Provider, "ManagedPolicyArns")
+			return
+		}
+		arns := make([]string, 0, len(attached))
+		for policyArn := range attached {
+			arns = append(arns, policyArn)
+		}
+		sort.Strings(arns)
+		access.Status.Provider["ManagedPolicyArns"] = strings.Join(arns, ",")
+	}()
+
+	desired := make(map[string]bool, len(policyArns))
+	for _, policyArn := range policyArns {
+		desired[policyArn] = true
+
+		if _, err := p.iamClient.AttachRolePolicy(ctx, &iam.AttachRolePolicyInput{
+			RoleName:  aws.String(roleName),
+			PolicyArn: aws.String(policyArn),
+		}); err != nil {
+			return fmt.Errorf("attaching managed policy %s to role %s: %w", policyArn, roleName, err)
+		}
+		if !attached[policyArn] {
+			reqLogger.Info(fmt.Sprintf("Attached managed policy %s to role %s", policyArn, roleName))
+		}
+		attached[policyArn] = true
+	}
+
+	for policyArn := range attached {
+		if desired[policyArn] {
+			continue
+		}
+
+		if _, err := p.iamClient.DetachRolePolicy(ctx, &iam.DetachRolePolicyInput{
+			RoleName:  aws.String(roleName),
+			PolicyArn: aws.String(policyArn),
+		}); err != nil && !isNoSuchEntity(err) {
+			return fmt.Errorf("detaching managed policy %s from role %s: %w", policyArn, roleName, err)
+		}
+		reqLogger.Info(fmt.Sprintf("Detached managed policy %s from role %s", policyArn, roleName))
+		delete(attached, policyArn)
+	}
+
+	return nil
+}
+
+// updatePolicy makes the document the default version of the policy. The oldest non default version
+// is deleted first so the policy stays under the limit of five versions.
+func (p *AwsProvider) updatePolicy(ctx context.Context, policyArn, policyDocumentJSON string) error {
+	if err := p.deleteNOldestPolicyVersions(ctx, policyArn, 1); err != nil {
+		return fmt.Errorf("deleting oldest policy version: %w", err)
+	}
+
+	_, err := p.iamClient.CreatePolicyVersion(ctx, &iam.CreatePolicyVersionInput{
+		PolicyArn:      aws.String(policyArn),
+		PolicyDocument: aws.String(policyDocumentJSON),
+		SetAsDefault:   true,
+	})
+
+	return err
+}
+
 func (p *AwsProvider) getAssumePolicyDocument(access *secretsv1alpha1.ExternalSecretAccess) (string, error) {
 	// Create the assume role policy document
 	sas := make([]string, 0)
@@ -191,16 +435,18 @@ func (p *AwsProvider) getAssumePolicyDocument(access *secretsv1alpha1.ExternalSe
 		if subject.ServiceAccount != nil {
 			sas = append(sas, fmt.Sprintf("system:serviceaccount:%s:%s", subject.ServiceAccount.Namespace, subject.ServiceAccount.Name))
 		} else if subject.ProviderIdentifier != nil {
-			accountID := strings.Split(subject.ProviderIdentifier.Identifier, ":")[4]
-			if _, ok := arns[accountID]; !ok {
-				arns[accountID] = make([]string, 0)
+			identifier, err := arn.Parse(subject.ProviderIdentifier.Identifier)
+			if err != nil {
+				return "", fmt.Errorf("invalid provider identifier %s: %w", subject.ProviderIdentifier.Identifier, err)
 			}
 
-			arns[accountID] = append(arns[accountID], subject.ProviderIdentifier.Identifier)
+			arns[identifier.AccountID] = append(arns[identifier.AccountID], subject.ProviderIdentifier.Identifier)
 		}
 	}
 
-	if len(sas) > 0 {
+	if len(sas) > 0 && p.AccessMode == AccessModePodIdentity {
+		assumeRolePolicyDocument["Statement"] = append(assumeRolePolicyDocument["Statement"].([]interface{}), p.podIdentityStatement())
+	} else if len(sas) > 0 {
 		assumeRolePolicyDocument["Statement"] = append(assumeRolePolicyDocument["Statement"].([]interface{}), map[string]interface{}{
 			"Effect": "Allow",
 			"Principal": map[string]string{
@@ -215,12 +461,20 @@ func (p *AwsProvider) getAssumePolicyDocument(access *secretsv1alpha1.ExternalSe
 		})
 	}
 
+	// one statement per account, sorted so the document only changes with the subjects
+	accounts := make([]string, 0, len(arns))
+	for acc := range arns {
+		accounts = append(accounts, acc)
+	}
+	sort.Strings(accounts)
+
 	if len(arns) > 0 {
-		for acc, subjects := range arns {
-			assumeRolePolicyDocument["Statement"] = append(assumeRolePolicyDocument["Statement"].([]map[string]interface{}), map[string]interface{}{
+		for _, acc := range accounts {
+			subjects := arns[acc]
+			assumeRolePolicyDocument["Statement"] = append(assumeRolePolicyDocument["Statement"].([]interface{}), map[string]interface{}{
 				"Effect": "Allow",
 				"Principal": map[string]string{
-					"Aws": fmt.Sprintf("arn:aws:iam::%s:root", acc),
+					"AWS": fmt.Sprintf("arn:aws:iam::%s:root", acc),
 				},
 				"Action": "sts:AssumeRole",
 				"Condition": map[string]map[string][]string{
@@ -236,18 +490,63 @@ func (p *AwsProvider) getAssumePolicyDocument(access *secretsv1alpha1.ExternalSe
 	return string(assumeRolePolicyDocumentJSON), err
 }
 
-func getSecretAccessPolicy(secretArn string) (string, error) {
-	policyDocument := map[string]interface{}{
-		"Version": "2012-10-17",
-		"Statement": []map[string]interface{}{
-			{
-				"Effect": "Allow",
-				"Action": []string{
-					"secretsmanager:GetSecretValue",
-				},
-				"Resource": strings.TrimSuffix(secretArn, secretArn[len(secretArn)-6:]) + "??????",
+// getSecretAccessPolicy returns the policy granting read access to the secret on the backend it is stored in
+func getSecretAccessPolicy(secret *secretsv1alpha1.ExternalSecret) (string, error) {
+	statements := make([]map[string]interface{}, 0)
+
+	if parameterArn, ok := secret.Status.Provider["ParameterArn"]; ok {
+		statements = append(statements, map[string]interface{}{
+			"Effect": "Allow",
+			"Action": []string{
+				"ssm:GetParameter",
+			},
+			"Resource": parameterArn,
+		})
+	} else {
+		// the suffix of the ARN is random, replicas share it in their region
+		secretArn := secret.Status.Provider["SecretArn"]
+		if len(secretArn) < 6 {
+			return "", fmt.Errorf("the ARN of secret %s is not known yet", secret.Name)
+		}
+		resources := []string{secretArn}
+		resources = append(resources, replicaArns(secret, secretArn)...)
+		for i, resource := range resources {
+			resources[i] = resource[:len(resource)-6] + "??????"
+		}
+		statements = append(statements, map[string]interface{}{
+			"Effect": "Allow",
+			"Action": []string{
+				"secretsmanager:GetSecretValue",
 			},
-		},
+			"Resource": policyResource(resources),
+		})
+	}
+
+	// secrets encrypted with a customer managed key can only be read with access to the key, which can
+	// only be granted by ARN. The AWS managed keys allow any caller of the account.
+	keys := make([]string, 0)
+	if kmsKeyArn, ok := secret.Status.Provider["KmsKeyArn"]; ok && isKeyArn(kmsKeyArn) {
+		keys = append(keys, kmsKeyArn)
+	}
+	replicas := recordedReplicas(secret.Status.Provider)
+	for _, region := range sortedKeys(replicas) {
+		if keyID := replicas[region]; isKeyArn(keyID) {
+			keys = append(keys, keyID)
+		}
+	}
+	if len(keys) > 0 {
+		statements = append(statements, map[string]interface{}{
+			"Effect": "Allow",
+			"Action": []string{
+				"kms:Decrypt",
+			},
+			"Resource": policyResource(keys),
+		})
+	}
+
+	policyDocument := map[string]interface{}{
+		"Version":   "2012-10-17",
+		"Statement": statements,
 	}
 
 	policyDocumentJSON, err := json.Marshal(policyDocument)
@@ -280,10 +579,31 @@ func (p *AwsProvider) deleteNOldestPolicyVersions(ctx context.Context, policyArn
 				VersionId: version.VersionId,
 			})
 			if err != nil {
-				return fmt.Errorf("failed to delete policy version, %v", err)
+				return fmt.Errorf("failed to delete policy version, %w", err)
 			}
+			n++
 		}
 	}
 
 	return nil
 }
+
+func isNoSuchEntity(err error) bool {
+	var notFoundErr *types.NoSuchEntityException
+	return errors.As(err, &notFoundErr)
+}
+
+// isKeyArn reports whether the key is given by ARN rather than by id or alias
+func isKeyArn(keyID string) bool {
+	return strings.HasPrefix(keyID, "arn:") && strings.Contains(keyID, ":key/")
+}
+
+// policyResource returns the resource of a policy statement, a single resource is kept a string so
+// that the policies of secrets without replicas do not change
+func policyResource(resources []string) interface{} {
+	if len(resources) == 1 {
+		return resources[0]
+	}
+
+	return resources
+}
@@ -0,0 +1,233 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+)
+
+const (
+	// KmsKeyScopeNamespace manages a KMS key per namespace, shared by the secrets of the namespace
+	KmsKeyScopeNamespace = "namespace"
+	// KmsKeyScopeSecret manages a KMS key per secret, deleted with the secret
+	KmsKeyScopeSecret = "secret"
+
+	defaultKmsKeyAliasPrefix = "alias/secretsbeam/"
+	// KMS deletes keys after a waiting period of 7 to 30 days
+	minKeyDeletionWindow = 7
+	maxKeyDeletionWindow = 30
+)
+
+// secretKey is the KMS key encrypting a secret
+type secretKey struct {
+	// id is the KmsKeyArn of the provider spec of the secret or the ARN of the managed key, empty for
+	// the AWS managed key of the backend
+	id string
+	// scope and alias are set for the keys managed by the provider
+	scope string
+	alias string
+}
+
+// record sets the key in the KmsKeyArn, KmsKeyScope and KmsKeyAlias entries of the status
+func (k secretKey) record(status map[string]string) {
+	for entry, value := range map[string]string{"KmsKeyArn": k.id, "KmsKeyScope": k.scope, "KmsKeyAlias": k.alias} {
+		if value == "" {
+			delete(status, entry)
+		} else {
+			status[entry] = value
+		}
+	}
+}
+
+// keyOf returns the KMS key of a secret: the KmsKeyArn of its provider spec, the key managed for its
+// namespace or itself, created when missing, or none for the AWS managed key of the backend
+func (p *AwsProvider) keyOf(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret) (secretKey, error) {
+	if val, ok := secret.Spec.ProviderSpec["KmsKeyArn"]; ok {
+		return secretKey{id: val}, nil
+	}
+	if p.KmsKeyScope == "" {
+		return secretKey{}, nil
+	}
+
+	// keys of namespaces are owned by the namespace, tagged with an empty name
+	var (
+		owner       metav1.Object = secret
+		alias                     = p.KmsKeyAliasPrefix + secret.Namespace + "/" + strings.ReplaceAll(secret.Name, ".", "_")
+		description               = fmt.Sprintf("Encrypts the secret %s/%s", secret.Namespace, secret.Name)
+	)
+	if p.KmsKeyScope == KmsKeyScopeNamespace {
+		owner = &metav1.ObjectMeta{Namespace: secret.Namespace}
+		alias = p.KmsKeyAliasPrefix + secret.Namespace
+		description = fmt.Sprintf("Encrypts the secrets of namespace %s", secret.Namespace)
+	}
+
+	keyArn, err := p.ensureKey(ctx, reqLogger, alias, description, owner)
+	if err != nil {
+		return secretKey{}, err
+	}
+
+	return secretKey{id: keyArn, scope: p.KmsKeyScope, alias: alias}, nil
+}
+
+// ensureKey returns the ARN of the key of the alias, created with the key policy and the ownership
+// tags of the owner when missing. A key scheduled for deletion, the key of a secret created again,
+// is restored.
+func (p *AwsProvider) ensureKey(ctx context.Context, reqLogger logr.Logger, alias, description string, owner metav1.Object) (string, error) {
+	tags := p.tagsOf(owner, nil)
+
+	result, err := p.kmsClient.DescribeKey(ctx, &kms.DescribeKeyInput{
+		KeyId: aws.String(alias),
+	})
+	if err == nil {
+		keyArn := aws.ToString(result.KeyMetadata.Arn)
+		if result.KeyMetadata.KeyState == kmstypes.KeyStatePendingDeletion {
+			if err := p.claimKey(ctx, keyArn, owner, nil); err != nil {
+				return "", err
+			}
+			if err := p.restoreKey(ctx, keyArn); err != nil {
+				return "", err
+			}
+			reqLogger.Info(fmt.Sprintf("Restored KMS key %s scheduled for deletion", alias))
+		}
+
+		if err := p.claimKey(ctx, keyArn, owner, tags); err != nil {
+			return "", err
+		}
+
+		return keyArn, nil
+	}
+
+	var notFoundErr *kmstypes.NotFoundException
+	if !errors.As(err, &notFoundErr) {
+		return "", fmt.Errorf("describing KMS key %s: %w", alias, err)
+	}
+
+	policy, err := p.keyPolicy(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	created, err := p.kmsClient.CreateKey(ctx, &kms.CreateKeyInput{
+		Description: aws.String(description),
+		Policy:      aws.String(policy),
+		Tags:        kmsTags(tags, sortedKeys(tags)),
+	})
+	if err != nil {
+		return "", fmt.Errorf("creating KMS key %s: %w", alias, err)
+	}
+	keyArn := aws.ToString(created.KeyMetadata.Arn)
+
+	if _, err := p.kmsClient.EnableKeyRotation(ctx, &kms.EnableKeyRotationInput{
+		KeyId: aws.String(keyArn),
+	}); err != nil {
+		return "", fmt.Errorf("enabling the rotation of KMS key %s: %w", alias, err)
+	}
+
+	if _, err := p.kmsClient.CreateAlias(ctx, &kms.CreateAliasInput{
+		AliasName:   aws.String(alias),
+		TargetKeyId: aws.String(keyArn),
+	}); err != nil {
+		return "", fmt.Errorf("creating KMS alias %s: %w", alias, err)
+	}
+	reqLogger.Info(fmt.Sprintf("Created KMS key %s", alias))
+
+	return keyArn, nil
+}
+
+// restoreKey cancels the deletion of a key, which leaves it disabled
+func (p *AwsProvider) restoreKey(ctx context.Context, keyArn string) error {
+	if _, err := p.kmsClient.CancelKeyDeletion(ctx, &kms.CancelKeyDeletionInput{
+		KeyId: aws.String(keyArn),
+	}); err != nil {
+		return fmt.Errorf("cancelling the deletion of KMS key %s: %w", keyArn, err)
+	}
+
+	if _, err := p.kmsClient.EnableKey(ctx, &kms.EnableKeyInput{
+		KeyId: aws.String(keyArn),
+	}); err != nil {
+		return fmt.Errorf("enabling KMS key %s: %w", keyArn, err)
+	}
+
+	return nil
+}
+
+// keyPolicy returns the policy of the managed keys, the default key policy of KMS which delegates the
+// access to the key to the IAM policies of the account, such as the policies of the accesses
+func (p *AwsProvider) keyPolicy(ctx context.Context) (string, error) {
+	identity, err := p.stsClient.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", fmt.Errorf("getting caller identity: %w", err)
+	}
+	caller, err := arn.Parse(aws.ToString(identity.Arn))
+	if err != nil {
+		return "", fmt.Errorf("parsing caller identity: %w", err)
+	}
+
+	policy, err := json.Marshal(map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{
+			{
+				"Sid":    "EnableIAMPolicies",
+				"Effect": "Allow",
+				"Principal": map[string]string{
+					"AWS": fmt.Sprintf("arn:%s:iam::%s:root", caller.Partition, aws.ToString(identity.Account)),
+				},
+				"Action":   "kms:*",
+				"Resource": "*",
+			},
+		},
+	})
+
+	return string(policy), err
+}
+
+// deleteSecretKey schedules the deletion of the key managed for a secret, with the recovery window of
+// the secret within the waiting period allowed by KMS. Keys of namespaces are shared and kept.
+func (p *AwsProvider) deleteSecretKey(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret) error {
+	if secret.Status.Provider["KmsKeyScope"] != KmsKeyScopeSecret {
+		return nil
+	}
+
+	keyArn := secret.Status.Provider["KmsKeyArn"]
+	if err := p.claimKey(ctx, keyArn, secret, nil); err != nil {
+		return err
+	}
+
+	window := secret.Spec.RecoveryWindow
+	if window < minKeyDeletionWindow {
+		window = minKeyDeletionWindow
+	} else if window > maxKeyDeletionWindow {
+		window = maxKeyDeletionWindow
+	}
+
+	if _, err := p.kmsClient.ScheduleKeyDeletion(ctx, &kms.ScheduleKeyDeletionInput{
+		KeyId:               aws.String(keyArn),
+		PendingWindowInDays: aws.Int32(int32(window)),
+	}); err != nil {
+		var (
+			notFoundErr     *kmstypes.NotFoundException
+			invalidStateErr *kmstypes.KMSInvalidStateException
+		)
+		if !errors.As(err, &notFoundErr) && !errors.As(err, &invalidStateErr) {
+			return fmt.Errorf("scheduling the deletion of KMS key %s: %w", keyArn, err)
+		}
+
+		reqLogger.Info(fmt.Sprintf("KMS key %s already deleted, ignoring", keyArn))
+		return nil
+	}
+	reqLogger.Info(fmt.Sprintf("Scheduled the deletion of KMS key %s in %d days", keyArn, window))
+
+	return nil
+}
@@ -0,0 +1,267 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/providertest"
+	"github.com/tiagoposse/secretsbeam-operator/internal/version"
+)
+
+var _ = Describe("AwsProvider KMS keys", func() {
+	var (
+		ctx  context.Context
+		fake *fakeAWS
+	)
+
+	newProvider := func(config AwsProviderConfig) *AwsProvider {
+		config.Endpoint = fake.server.URL
+		config.OidcProviderArn = fakeOidcProviderArn
+		provider := &AwsProvider{ClusterID: "cluster-a"}
+		Expect(provider.init(config, fake.config())).To(Succeed())
+
+		return provider
+	}
+
+	newKeyedSecret := func(namespace, name string) *secretsv1alpha1.ExternalSecret {
+		secret := providertest.NewSecret(namespace + "-" + name)
+		secret.ObjectMeta = metav1.ObjectMeta{Namespace: namespace, Name: name}
+		secret.Status.SecretName = namespace + "-" + name
+		return secret
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		fake = newFakeAWS()
+		DeferCleanup(fake.Close)
+	})
+
+	It("validates the config", func() {
+		Expect((&AwsProvider{}).init(AwsProviderConfig{KmsKeyScope: "cluster"}, fake.config())).
+			To(MatchError(ContainSubstring("unsupported kmsKeyScope cluster")))
+		for _, prefix := range []string{"secretsbeam/", "alias/aws/secretsbeam/", "alias/secretsbeam"} {
+			Expect((&AwsProvider{}).init(AwsProviderConfig{KmsKeyAliasPrefix: prefix}, fake.config())).
+				To(MatchError(ContainSubstring("invalid kmsKeyAliasPrefix " + prefix)))
+		}
+	})
+
+	Context("per namespace", func() {
+		var provider *AwsProvider
+
+		BeforeEach(func() {
+			provider = newProvider(AwsProviderConfig{KmsKeyScope: KmsKeyScopeNamespace})
+		})
+
+		It("encrypts the secrets of a namespace with its key", func() {
+			db := newKeyedSecret("team-a", "db")
+			Expect(provider.CreateSecret(ctx, logr.Discard(), db, "hunter2")).To(Succeed())
+			api := newKeyedSecret("team-a", "api")
+			Expect(provider.CreateSecret(ctx, logr.Discard(), api, "hunter2")).To(Succeed())
+
+			key := fake.keyOf("alias/secretsbeam/team-a")
+			Expect(key).NotTo(BeNil())
+			Expect(fake.keys).To(HaveLen(1))
+			Expect(key.rotation).To(BeTrue())
+			Expect(key.description).To(Equal("Encrypts the secrets of namespace team-a"))
+			Expect(key.tags).To(Equal(map[string]string{
+				TagClusterID:       "cluster-a",
+				TagNamespace:       "team-a",
+				TagName:            "",
+				TagOperatorVersion: version.Version,
+			}))
+
+			policy := struct {
+				Statement []map[string]interface{}
+			}{}
+			Expect(json.Unmarshal([]byte(key.policy), &policy)).To(Succeed())
+			Expect(policy.Statement).To(ConsistOf(map[string]interface{}{
+				"Sid":       "EnableIAMPolicies",
+				"Effect":    "Allow",
+				"Principal": map[string]interface{}{"AWS": "arn:aws:iam::111122223333:root"},
+				"Action":    "kms:*",
+				"Resource":  "*",
+			}))
+
+			for _, secret := range []*secretsv1alpha1.ExternalSecret{db, api} {
+				Expect(fake.secrets[secret.Status.SecretName].kmsKeyID).To(Equal(key.arn))
+				Expect(secret.Status.Provider).To(HaveKeyWithValue("KmsKeyArn", key.arn))
+				Expect(secret.Status.Provider).To(HaveKeyWithValue("KmsKeyScope", KmsKeyScopeNamespace))
+				Expect(secret.Status.Provider).To(HaveKeyWithValue("KmsKeyAlias", "alias/secretsbeam/team-a"))
+			}
+
+			By("using another key for another namespace")
+			other := newKeyedSecret("team-b", "db")
+			Expect(provider.CreateSecret(ctx, logr.Discard(), other, "hunter2")).To(Succeed())
+			Expect(other.Status.Provider["KmsKeyArn"]).NotTo(Equal(key.arn))
+			Expect(fake.keys).To(HaveLen(2))
+		})
+
+		It("grants the accesses the decryption with the key", func() {
+			secret := newKeyedSecret("team-a", "db")
+			Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+
+			Expect(policyStatements(secret)).To(ContainElement(map[string]interface{}{
+				"Effect":   "Allow",
+				"Action":   []interface{}{"kms:Decrypt"},
+				"Resource": secret.Status.Provider["KmsKeyArn"],
+			}))
+		})
+
+		It("keeps the key when the secrets are deleted", func() {
+			secret := newKeyedSecret("team-a", "db")
+			Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+			Expect(provider.DeleteSecret(ctx, logr.Discard(), secret)).To(Succeed())
+
+			Expect(fake.keyOf("alias/secretsbeam/team-a").state).To(Equal("Enabled"))
+		})
+
+		It("leaves the keys of other clusters alone", func() {
+			other := &AwsProvider{ClusterID: "cluster-b"}
+			Expect(other.init(AwsProviderConfig{KmsKeyScope: KmsKeyScopeNamespace, Endpoint: fake.server.URL}, fake.config())).To(Succeed())
+			Expect(other.CreateSecret(ctx, logr.Discard(), newKeyedSecret("team-a", "db"), "hunter2")).To(Succeed())
+
+			err := provider.CreateSecret(ctx, logr.Discard(), newKeyedSecret("team-a", "api"), "hunter2")
+			Expect(err).To(BeAssignableToTypeOf(&OwnershipError{}))
+			Expect(fake.secrets).NotTo(HaveKey("team-a-api"))
+		})
+	})
+
+	Context("per secret", func() {
+		var provider *AwsProvider
+
+		BeforeEach(func() {
+			provider = newProvider(AwsProviderConfig{KmsKeyScope: KmsKeyScopeSecret, KmsKeyAliasPrefix: "alias/prod/"})
+		})
+
+		It("encrypts each secret with its own key", func() {
+			secret := newKeyedSecret("team-a", "db.primary")
+			secret.UID = "uid-db"
+			Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+
+			key := fake.keyOf("alias/prod/team-a/db_primary")
+			Expect(key).NotTo(BeNil())
+			Expect(key.description).To(Equal("Encrypts the secret team-a/db.primary"))
+			Expect(key.tags).To(HaveKeyWithValue(TagName, "db.primary"))
+			Expect(key.tags).To(HaveKeyWithValue(TagUID, "uid-db"))
+			Expect(secret.Status.Provider).To(HaveKeyWithValue("KmsKeyArn", key.arn))
+			Expect(secret.Status.Provider).To(HaveKeyWithValue("KmsKeyScope", KmsKeyScopeSecret))
+		})
+
+		It("schedules the deletion of the key with the secret", func() {
+			secret := newKeyedSecret("team-a", "db")
+			Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+			Expect(provider.DeleteSecret(ctx, logr.Discard(), secret)).To(Succeed())
+
+			key := fake.keyOf("alias/prod/team-a/db")
+			Expect(key.state).To(Equal("PendingDeletion"))
+			Expect(key.deletionDays).To(BeEquivalentTo(minKeyDeletionWindow))
+
+			By("ignoring keys already scheduled for deletion")
+			Expect(provider.deleteSecretKey(ctx, logr.Discard(), secret)).To(Succeed())
+		})
+
+		It("waits the recovery window of the secret", func() {
+			secret := newKeyedSecret("team-a", "db")
+			secret.Spec.RecoveryWindow = 10
+			Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+			Expect(provider.DeleteSecret(ctx, logr.Discard(), secret)).To(MatchError(ContainSubstring("secret scheduled for deletion")))
+
+			Expect(fake.keyOf("alias/prod/team-a/db").deletionDays).To(BeEquivalentTo(10))
+		})
+
+		It("restores the key of a secret created again", func() {
+			secret := newKeyedSecret("team-a", "db")
+			Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+			keyArn := secret.Status.Provider["KmsKeyArn"]
+			Expect(provider.DeleteSecret(ctx, logr.Discard(), secret)).To(Succeed())
+
+			secret = newKeyedSecret("team-a", "db")
+			Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+			Expect(secret.Status.Provider).To(HaveKeyWithValue("KmsKeyArn", keyArn))
+			Expect(fake.keyOf("alias/prod/team-a/db").state).To(Equal("Enabled"))
+		})
+
+		It("does not restore the keys of other secrets", func() {
+			secret := newKeyedSecret("team-a", "db")
+			Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+			Expect(provider.DeleteSecret(ctx, logr.Discard(), secret)).To(Succeed())
+
+			other := &AwsProvider{ClusterID: "cluster-b"}
+			Expect(other.init(AwsProviderConfig{KmsKeyScope: KmsKeyScopeSecret, KmsKeyAliasPrefix: "alias/prod/", Endpoint: fake.server.URL}, fake.config())).To(Succeed())
+			Expect(other.CreateSecret(ctx, logr.Discard(), newKeyedSecret("team-a", "db"), "hunter2")).To(BeAssignableToTypeOf(&OwnershipError{}))
+			Expect(fake.keyOf("alias/prod/team-a/db").state).To(Equal("PendingDeletion"))
+		})
+	})
+
+	It("prefers the key of the provider spec", func() {
+		provider := newProvider(AwsProviderConfig{KmsKeyScope: KmsKeyScopeNamespace})
+		secret := newKeyedSecret("team-a", "db")
+		secret.Spec.ProviderSpec["KmsKeyArn"] = "alias/team-a"
+		Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+
+		Expect(fake.keys).To(BeEmpty())
+		Expect(fake.secrets["team-a-db"].kmsKeyID).To(Equal("alias/team-a"))
+		Expect(secret.Status.Provider).To(HaveKeyWithValue("KmsKeyArn", "alias/team-a"))
+		Expect(secret.Status.Provider).NotTo(HaveKey("KmsKeyScope"))
+	})
+
+	Context("on updates", func() {
+		It("moves secrets to the key of the config", func() {
+			secret := newKeyedSecret("team-a", "db")
+			Expect(newProvider(AwsProviderConfig{}).CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+			Expect(fake.secrets["team-a-db"].kmsKeyID).To(BeEmpty())
+
+			By("managing a key for the namespace")
+			provider := newProvider(AwsProviderConfig{KmsKeyScope: KmsKeyScopeNamespace})
+			Expect(provider.UpdateSecret(ctx, logr.Discard(), secret, "hunter3")).To(Succeed())
+			keyArn := fake.keyOf("alias/secretsbeam/team-a").arn
+			Expect(fake.secrets["team-a-db"].kmsKeyID).To(Equal(keyArn))
+			Expect(secret.Status.Provider).To(HaveKeyWithValue("KmsKeyArn", keyArn))
+
+			By("setting the key of the provider spec")
+			secret.Spec.ProviderSpec["KmsKeyArn"] = "arn:aws:kms:eu-west-1:111122223333:key/team-a"
+			Expect(provider.UpdateSecret(ctx, logr.Discard(), secret, "hunter4")).To(Succeed())
+			Expect(fake.secrets["team-a-db"].kmsKeyID).To(Equal("arn:aws:kms:eu-west-1:111122223333:key/team-a"))
+			Expect(secret.Status.Provider).NotTo(HaveKey("KmsKeyAlias"))
+
+			By("going back to the AWS managed key")
+			delete(secret.Spec.ProviderSpec, "KmsKeyArn")
+			Expect(newProvider(AwsProviderConfig{}).UpdateSecret(ctx, logr.Discard(), secret, "hunter5")).To(Succeed())
+			Expect(fake.secrets["team-a-db"].kmsKeyID).To(Equal(defaultSecretsManagerKey))
+			Expect(secret.Status.Provider).NotTo(HaveKey("KmsKeyArn"))
+			Expect(fake.keyOf("alias/secretsbeam/team-a").state).To(Equal("Enabled"), "keys may still encrypt previous versions")
+		})
+
+		It("only changes the key of secrets when it changed", func() {
+			provider := newProvider(AwsProviderConfig{KmsKeyScope: KmsKeyScopeNamespace})
+			secret := newKeyedSecret("team-a", "db")
+			Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+
+			fake.secrets["team-a-db"].kmsKeyID = "changed-out-of-band"
+			Expect(provider.UpdateSecret(ctx, logr.Discard(), secret, "hunter3")).To(Succeed())
+			Expect(fake.secrets["team-a-db"].kmsKeyID).To(Equal("changed-out-of-band"))
+		})
+
+		It("moves parameters to the key of the config", func() {
+			secret := newKeyedSecret("team-a", "db")
+			Expect(newProvider(AwsProviderConfig{Backend: BackendSsm}).CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+			Expect(fake.parameters["team-a-db"].keyID).To(BeEmpty())
+
+			provider := newProvider(AwsProviderConfig{Backend: BackendSsm, KmsKeyScope: KmsKeyScopeSecret})
+			Expect(provider.UpdateSecret(ctx, logr.Discard(), secret, "hunter3")).To(Succeed())
+			keyArn := fake.keyOf("alias/secretsbeam/team-a/db").arn
+			Expect(fake.parameters["team-a-db"].keyID).To(Equal(keyArn))
+			Expect(policyStatements(secret)).To(ContainElement(HaveKeyWithValue("Resource", keyArn)))
+
+			By("deleting the key with the parameter")
+			Expect(provider.DeleteSecret(ctx, logr.Discard(), secret)).To(Succeed())
+			Expect(fake.keyOf("alias/secretsbeam/team-a/db").state).To(Equal("PendingDeletion"))
+		})
+	})
+})
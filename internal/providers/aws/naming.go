@@ -0,0 +1,88 @@
+package aws
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"text/template"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+)
+
+const (
+	// the default templates have distinct prefixes, so that the role of an access never takes the
+	// name of the role of a service account, e.g. for access sa/team-a-api and service account team-a/api
+	defaultRoleNameTemplate               = "secretsbeam-access-{{ .Namespace }}-{{ .Name }}"
+	defaultServiceAccountRoleNameTemplate = "secretsbeam-sa-{{ .Namespace }}-{{ .Name }}"
+	// iamRoleNameLimit is the maximum length of the names of IAM roles, policy names allow 128
+	// characters but share the name of the role
+	iamRoleNameLimit = 64
+	// nameHashLength is the length of the hash suffixing truncated names
+	nameHashLength = 8
+)
+
+// roleNameData are the fields of the role name template
+type roleNameData struct {
+	Namespace   string
+	Name        string
+	ClusterID   string
+	ClusterName string
+}
+
+// parseRoleNameTemplate parses the template of the config field and renders it once, so that invalid
+// fields are reported with the config rather than by the first access
+func parseRoleNameTemplate(field, text string) (*template.Template, error) {
+	tmpl, err := template.New(field).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", field, err)
+	}
+	if err := tmpl.Execute(&strings.Builder{}, roleNameData{}); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", field, err)
+	}
+
+	return tmpl, nil
+}
+
+// resourceName returns the name of the policy and the role of an access, rendered from the role
+// name template of the provider
+func (p *AwsProvider) resourceName(access *secretsv1alpha1.ExternalSecretAccess) (string, error) {
+	var name strings.Builder
+	if err := p.roleNameTemplate.Execute(&name, roleNameData{
+		Namespace:   access.Namespace,
+		Name:        access.Name,
+		ClusterID:   p.ClusterID,
+		ClusterName: p.ClusterName,
+	}); err != nil {
+		return "", fmt.Errorf("rendering the role name of %s/%s: %w", access.Namespace, access.Name, err)
+	}
+
+	return truncateName(name.String(), iamRoleNameLimit), nil
+}
+
+// serviceAccountRoleName returns the name of the role of a service account, rendered from the service
+// account role name template of the provider
+func (p *AwsProvider) serviceAccountRoleName(serviceAccount *secretsv1alpha1.SecretAccessSubjectServiceAccount) (string, error) {
+	var name strings.Builder
+	if err := p.serviceAccountRoleNameTemplate.Execute(&name, roleNameData{
+		Namespace:   serviceAccount.Namespace,
+		Name:        serviceAccount.Name,
+		ClusterID:   p.ClusterID,
+		ClusterName: p.ClusterName,
+	}); err != nil {
+		return "", fmt.Errorf("rendering the role name of service account %s/%s: %w", serviceAccount.Namespace, serviceAccount.Name, err)
+	}
+
+	return truncateName(name.String(), iamRoleNameLimit), nil
+}
+
+// truncateName shortens names over the limit and suffixes them with a hash of the full name, so
+// that names sharing a prefix stay unique
+func truncateName(name string, limit int) string {
+	if len(name) <= limit {
+		return name
+	}
+
+	sum := sha256.Sum256([]byte(name))
+	return name[:limit-nameHashLength-1] + "-" + hex.EncodeToString(sum[:])[:nameHashLength]
+}
@@ -0,0 +1,194 @@
+package aws
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/providertest"
+)
+
+var _ = Describe("AwsProvider IAM roles", func() {
+	var (
+		ctx    context.Context
+		fake   *fakeAWS
+		secret *secretsv1alpha1.ExternalSecret
+	)
+
+	const (
+		boundaryArn = "arn:aws:iam::111122223333:policy/boundaries/secretsbeam"
+		readOnlyArn = "arn:aws:iam::aws:policy/ReadOnlyAccess"
+		auditArn    = "arn:aws:iam::111122223333:policy/audit"
+	)
+
+	newProvider := func(config AwsProviderConfig) *AwsProvider {
+		config.Endpoint = fake.server.URL
+		config.OidcProviderArn = fakeOidcProviderArn
+		provider := &AwsProvider{ClusterID: "cluster-a"}
+		Expect(provider.init(config, fake.config())).To(Succeed())
+
+		return provider
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		fake = newFakeAWS()
+		DeferCleanup(fake.Close)
+		fake.addPolicy(readOnlyArn)
+		fake.addPolicy(auditArn)
+
+		secret = providertest.NewSecret("db")
+		secret.Status.SecretName = "db"
+	})
+
+	It("validates the config", func() {
+		for _, invalid := range []struct {
+			config  AwsProviderConfig
+			message string
+		}{
+			{AwsProviderConfig{RoleNameTemplate: "{{ .Namespace"}, "invalid roleNameTemplate"},
+			{AwsProviderConfig{RoleNameTemplate: "{{ .Team }}"}, "invalid roleNameTemplate"},
+			{AwsProviderConfig{IamPath: "secretsbeam"}, "invalid iamPath secretsbeam"},
+			{AwsProviderConfig{IamPath: "/secretsbeam"}, "invalid iamPath /secretsbeam"},
+			{AwsProviderConfig{PermissionsBoundaryArn: "arn:aws:iam::111122223333:role/x"}, "invalid permissionsBoundaryArn"},
+			{AwsProviderConfig{MaxSessionDuration: "forever"}, "invalid maxSessionDuration forever"},
+			{AwsProviderConfig{MaxSessionDuration: "30m"}, "invalid maxSessionDuration 30m"},
+			{AwsProviderConfig{MaxSessionDuration: "13h"}, "invalid maxSessionDuration 13h"},
+		} {
+			Expect((&AwsProvider{}).init(invalid.config, fake.config())).To(MatchError(ContainSubstring(invalid.message)), invalid.message)
+		}
+	})
+
+	It("renders the names of the roles from the template", func() {
+		provider := newProvider(AwsProviderConfig{
+			RoleNameTemplate: "{{ .ClusterName }}-{{ .ClusterID }}-{{ .Namespace }}-{{ .Name }}",
+			ClusterName:      "prod",
+		})
+
+		name, err := provider.resourceName(providertest.NewAccess("reader"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(name).To(Equal("prod-cluster-a-team-a-reader"))
+	})
+
+	It("truncates long names and keeps them unique", func() {
+		provider := newProvider(AwsProviderConfig{})
+
+		first, err := provider.resourceName(providertest.NewAccess(strings.Repeat("a", 60) + "-first"))
+		Expect(err).NotTo(HaveOccurred())
+		second, err := provider.resourceName(providertest.NewAccess(strings.Repeat("a", 60) + "-second"))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(first).To(HaveLen(iamRoleNameLimit))
+		Expect(second).To(HaveLen(iamRoleNameLimit))
+		Expect(first).To(HavePrefix("secretsbeam-access-team-a-aaaa"))
+		Expect(first).NotTo(Equal(second))
+
+		short, err := provider.resourceName(providertest.NewAccess("reader"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(short).To(Equal("secretsbeam-access-team-a-reader"), "short names are kept as they are")
+	})
+
+	It("keeps the roles of accesses apart from the roles of service accounts", func() {
+		provider := newProvider(AwsProviderConfig{})
+
+		access := providertest.NewAccess("team-a-api")
+		access.Namespace = "sa"
+		accessRole, err := provider.resourceName(access)
+		Expect(err).NotTo(HaveOccurred())
+		serviceAccountRole, err := provider.serviceAccountRoleName(&secretsv1alpha1.SecretAccessSubjectServiceAccount{Namespace: "team-a", Name: "api"})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(accessRole).To(Equal("secretsbeam-access-sa-team-a-api"))
+		Expect(serviceAccountRole).To(Equal("secretsbeam-sa-team-a-api"))
+	})
+
+	It("creates the roles under the path with the boundary, the session duration and the managed policies", func() {
+		provider := newProvider(AwsProviderConfig{
+			IamPath:                "/secretsbeam/",
+			PermissionsBoundaryArn: boundaryArn,
+			MaxSessionDuration:     "4h",
+			ManagedPolicyArns:      readOnlyArn + ", " + auditArn,
+		})
+		Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+
+		access := providertest.NewAccess("reader", providertest.ServiceAccountSubject("team-a", "api"))
+		Expect(provider.CreateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+
+		policyArn := "arn:aws:iam::111122223333:policy/secretsbeam/secretsbeam-access-team-a-reader"
+		Expect(access.Status.Provider).To(HaveKeyWithValue("PolicyArn", policyArn))
+		Expect(access.Status.Provider).To(HaveKeyWithValue("RoleArn", "arn:aws:iam::111122223333:role/secretsbeam/secretsbeam-access-team-a-reader"))
+		Expect(access.Status.Provider).To(HaveKeyWithValue("ManagedPolicyArns", auditArn+","+readOnlyArn))
+
+		role := fake.role("secretsbeam-access-team-a-reader")
+		Expect(role).NotTo(BeNil())
+		Expect(role.path).To(Equal("/secretsbeam/"))
+		Expect(role.boundary).To(Equal(boundaryArn))
+		Expect(role.maxSessionDuration).To(Equal(4 * 3600))
+		Expect(role.policies()).To(ConsistOf(policyArn, auditArn, readOnlyArn))
+	})
+
+	It("applies the settings to existing roles", func() {
+		provider := newProvider(AwsProviderConfig{})
+		Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+		access := providertest.NewAccess("reader", providertest.ServiceAccountSubject("team-a", "api"))
+		Expect(provider.CreateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+
+		role := fake.role("secretsbeam-access-team-a-reader")
+		Expect(role.boundary).To(BeEmpty())
+		Expect(role.maxSessionDuration).To(Equal(3600))
+
+		provider = newProvider(AwsProviderConfig{
+			PermissionsBoundaryArn: boundaryArn,
+			MaxSessionDuration:     "2h",
+			ManagedPolicyArns:      readOnlyArn,
+		})
+		Expect(provider.UpdateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+
+		role = fake.role("secretsbeam-access-team-a-reader")
+		Expect(role.boundary).To(Equal(boundaryArn))
+		Expect(role.maxSessionDuration).To(Equal(2 * 3600))
+		Expect(role.policies()).To(ContainElement(readOnlyArn))
+	})
+
+	It("detaches the managed policies removed from the config", func() {
+		provider := newProvider(AwsProviderConfig{ManagedPolicyArns: readOnlyArn + "," + auditArn})
+		Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+		access := providertest.NewAccess("reader", providertest.ServiceAccountSubject("team-a", "api"))
+		Expect(provider.CreateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+
+		provider = newProvider(AwsProviderConfig{ManagedPolicyArns: auditArn})
+		Expect(provider.UpdateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+
+		Expect(access.Status.Provider).To(HaveKeyWithValue("ManagedPolicyArns", auditArn))
+		Expect(fake.role("secretsbeam-access-team-a-reader").policies()).To(ConsistOf(access.Status.Provider["PolicyArn"], auditArn))
+	})
+
+	It("detaches the managed policies before deleting the role", func() {
+		provider := newProvider(AwsProviderConfig{ManagedPolicyArns: readOnlyArn})
+		Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+		access := providertest.NewAccess("reader", providertest.ServiceAccountSubject("team-a", "api"))
+		Expect(provider.CreateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+
+		Expect(provider.DeleteAccess(ctx, logr.Discard(), access)).To(Succeed())
+		Expect(fake.role("secretsbeam-access-team-a-reader")).To(BeNil())
+		Expect(fake.policies).To(HaveKey(readOnlyArn), "managed policies are never deleted")
+		Expect(access.Status.Provider).NotTo(HaveKey("ManagedPolicyArns"))
+	})
+
+	It("keeps the names of existing roles when the template changes", func() {
+		provider := newProvider(AwsProviderConfig{})
+		Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+		access := providertest.NewAccess("reader", providertest.ServiceAccountSubject("team-a", "api"))
+		Expect(provider.CreateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+
+		provider = newProvider(AwsProviderConfig{RoleNameTemplate: "renamed-{{ .Name }}"})
+		Expect(provider.UpdateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+
+		Expect(access.Status.Provider).To(HaveKeyWithValue("RoleName", "secretsbeam-access-team-a-reader"))
+		Expect(fake.role("renamed-reader")).To(BeNil())
+	})
+})
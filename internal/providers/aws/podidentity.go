@@ -0,0 +1,215 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	ekstypes "github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/go-logr/logr"
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+)
+
+// podIdentityPrincipal is the service assuming the roles associated with service accounts by EKS Pod Identity
+const podIdentityPrincipal = "pods.eks.amazonaws.com"
+
+// podIdentityStatement lets EKS Pod Identity assume the role for the pods of the cluster, the service
+// accounts allowed are the ones associated with the role
+func (p *AwsProvider) podIdentityStatement() map[string]interface{} {
+	return map[string]interface{}{
+		"Effect": "Allow",
+		"Principal": map[string]string{
+			"Service": podIdentityPrincipal,
+		},
+		"Action": []string{"sts:AssumeRole", "sts:TagSession"},
+		"Condition": map[string]map[string][]string{
+			"StringEquals": {
+				"aws:RequestTag/eks-cluster-name": {p.ClusterName},
+			},
+		},
+	}
+}
+
+// podIdentityAssociations returns the associations recorded in the status of the access, by
+// namespace/name of their service account
+func podIdentityAssociations(access *secretsv1alpha1.ExternalSecretAccess) map[string]string {
+	associations := make(map[string]string)
+	for _, entry := range strings.Split(access.Status.Provider["PodIdentityAssociations"], ",") {
+		if serviceAccount, id, ok := strings.Cut(entry, "="); ok {
+			associations[serviceAccount] = id
+		}
+	}
+
+	return associations
+}
+
+// setPodIdentityAssociations records the associations in the status of the access, sorted so the
+// status only changes with the associations
+func setPodIdentityAssociations(access *secretsv1alpha1.ExternalSecretAccess, cluster string, associations map[string]string) {
+	if len(associations) == 0 {
+		delete(access.Status.Provider, "PodIdentityAssociations")
+		delete(access.Status.Provider, "ClusterName")
+		return
+	}
+
+	entries := make([]string, 0, len(associations))
+	for serviceAccount, id := range associations {
+		entries = append(entries, serviceAccount+"="+id)
+	}
+	sort.Strings(entries)
+
+	access.Status.Provider["PodIdentityAssociations"] = strings.Join(entries, ",")
+	access.Status.Provider["ClusterName"] = cluster
+}
+
+// syncPodIdentityAssociations associates the role of the access with the service accounts of the
+// subjects and removes the associations of former subjects. The associations created or removed
+// are recorded in the status even when a later one fails.
+func (p *AwsProvider) syncPodIdentityAssociations(ctx context.Context, reqLogger logr.Logger, access *secretsv1alpha1.ExternalSecretAccess, subjects []secretsv1alpha1.SecretAccessSubject) error {
+	associations := podIdentityAssociations(access)
+	// associations are removed from the cluster they were created in, which outlives a change of the provider config
+	cluster := access.Status.Provider["ClusterName"]
+	if cluster == "" {
+		cluster = p.ClusterName
+	}
+	defer func() {
+		setPodIdentityAssociations(access, cluster, associations)
+	}()
+
+	desired := make(map[string]*secretsv1alpha1.SecretAccessSubjectServiceAccount)
+	for _, subject := range subjects {
+		if subject.ServiceAccount != nil {
+			desired[subject.ServiceAccount.Namespace+"/"+subject.ServiceAccount.Name] = subject.ServiceAccount
+		}
+	}
+
+	for serviceAccount, id := range associations {
+		if desired[serviceAccount] != nil && cluster == p.ClusterName {
+			continue
+		}
+
+		if _, err := p.eksClient.DeletePodIdentityAssociation(ctx, &eks.DeletePodIdentityAssociationInput{
+			ClusterName:   aws.String(cluster),
+			AssociationId: aws.String(id),
+		}); err != nil {
+			if !isResourceNotFound(err) {
+				return fmt.Errorf("deleting pod identity association %s of %s: %w", id, serviceAccount, err)
+			}
+			reqLogger.Info(fmt.Sprintf("Pod identity association %s not found, ignoring", id))
+		} else {
+			reqLogger.Info(fmt.Sprintf("Deleted pod identity association %s of %s", id, serviceAccount))
+		}
+		delete(associations, serviceAccount)
+	}
+	if len(desired) == 0 {
+		return nil
+	}
+	cluster = p.ClusterName
+
+	roleArn, err := p.roleArn(ctx, access)
+	if err != nil {
+		return err
+	}
+
+	serviceAccounts := make([]string, 0, len(desired))
+	for serviceAccount := range desired {
+		serviceAccounts = append(serviceAccounts, serviceAccount)
+	}
+	sort.Strings(serviceAccounts)
+
+	for _, serviceAccount := range serviceAccounts {
+		if _, ok := associations[serviceAccount]; ok {
+			continue
+		}
+
+		id, err := p.associate(ctx, desired[serviceAccount], roleArn, p.tagsOf(access, access.Spec.Tags))
+		if err != nil {
+			return fmt.Errorf("associating %s with role %s: %w", serviceAccount, roleArn, err)
+		}
+		reqLogger.Info(fmt.Sprintf("Associated %s with role %s: %s", serviceAccount, roleArn, id))
+		associations[serviceAccount] = id
+	}
+
+	return nil
+}
+
+// associate creates the pod identity association of the service account with the role. A service
+// account has a single association, an existing one is adopted when it is already for the role.
+func (p *AwsProvider) associate(ctx context.Context, serviceAccount *secretsv1alpha1.SecretAccessSubjectServiceAccount, roleArn string, tags map[string]string) (string, error) {
+	created, err := p.eksClient.CreatePodIdentityAssociation(ctx, &eks.CreatePodIdentityAssociationInput{
+		ClusterName:    aws.String(p.ClusterName),
+		Namespace:      aws.String(serviceAccount.Namespace),
+		ServiceAccount: aws.String(serviceAccount.Name),
+		RoleArn:        aws.String(roleArn),
+		Tags:           tags,
+	})
+	if err == nil {
+		return aws.ToString(created.Association.AssociationId), nil
+	}
+
+	var inUse *ekstypes.ResourceInUseException
+	if !errors.As(err, &inUse) {
+		return "", err
+	}
+
+	existing, listErr := p.eksClient.ListPodIdentityAssociations(ctx, &eks.ListPodIdentityAssociationsInput{
+		ClusterName:    aws.String(p.ClusterName),
+		Namespace:      aws.String(serviceAccount.Namespace),
+		ServiceAccount: aws.String(serviceAccount.Name),
+	})
+	if listErr != nil {
+		return "", fmt.Errorf("listing existing associations: %w", listErr)
+	}
+	for _, summary := range existing.Associations {
+		association, err := p.eksClient.DescribePodIdentityAssociation(ctx, &eks.DescribePodIdentityAssociationInput{
+			ClusterName:   aws.String(p.ClusterName),
+			AssociationId: summary.AssociationId,
+		})
+		if err != nil {
+			return "", fmt.Errorf("describing association %s: %w", aws.ToString(summary.AssociationId), err)
+		}
+		if aws.ToString(association.Association.RoleArn) != roleArn {
+			return "", fmt.Errorf("the service account is already associated with role %s", aws.ToString(association.Association.RoleArn))
+		}
+
+		return aws.ToString(summary.AssociationId), nil
+	}
+
+	return "", err
+}
+
+// roleArn returns the ARN of the role of the access, accesses created before the ARN was recorded
+// in the status look it up by name
+func (p *AwsProvider) roleArn(ctx context.Context, access *secretsv1alpha1.ExternalSecretAccess) (string, error) {
+	if roleArn := access.Status.Provider["RoleArn"]; roleArn != "" {
+		return roleArn, nil
+	}
+
+	roleName := access.Status.Provider["RoleName"]
+	if roleName == "" {
+		var err error
+		if roleName, err = p.resourceName(access); err != nil {
+			return "", err
+		}
+	}
+
+	role, err := p.iamClient.GetRole(ctx, &iam.GetRoleInput{
+		RoleName: aws.String(roleName),
+	})
+	if err != nil {
+		return "", fmt.Errorf("getting role %s: %w", roleName, err)
+	}
+	access.Status.Provider["RoleArn"] = aws.ToString(role.Role.Arn)
+
+	return access.Status.Provider["RoleArn"], nil
+}
+
+func isResourceNotFound(err error) bool {
+	var notFoundErr *ekstypes.ResourceNotFoundException
+	return errors.As(err, &notFoundErr)
+}
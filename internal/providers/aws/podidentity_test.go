@@ -0,0 +1,153 @@
+package aws
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/providertest"
+)
+
+const fakeClusterName = "prod"
+
+var _ = Describe("AwsProvider pod identity", func() {
+	var (
+		ctx      context.Context
+		fake     *fakeAWS
+		provider *AwsProvider
+		secret   *secretsv1alpha1.ExternalSecret
+	)
+
+	const roleArn = "arn:aws:iam::111122223333:role/secretsbeam-access-team-a-reader"
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		fake = newFakeAWS()
+		DeferCleanup(fake.Close)
+
+		provider = &AwsProvider{}
+		Expect(provider.init(AwsProviderConfig{
+			AccessMode:  AccessModePodIdentity,
+			ClusterName: fakeClusterName,
+			Endpoint:    fake.server.URL,
+		}, fake.config())).To(Succeed())
+
+		secret = providertest.NewSecret("db")
+		secret.Status.SecretName = "db"
+		Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+	})
+
+	It("validates the config", func() {
+		Expect((&AwsProvider{}).init(AwsProviderConfig{AccessMode: AccessModePodIdentity}, fake.config())).
+			To(MatchError(ContainSubstring("clusterName is required by the podIdentity access mode")))
+		Expect((&AwsProvider{}).init(AwsProviderConfig{AccessMode: "kiam"}, fake.config())).
+			To(MatchError(ContainSubstring("unsupported access mode kiam")))
+	})
+
+	It("creates a role trusted by pod identity and associates it with the service accounts", func() {
+		access := providertest.NewAccess("reader",
+			providertest.ServiceAccountSubject("team-a", "api"),
+			providertest.ServiceAccountSubject("team-b", "worker"),
+			principalSubject("arn:aws:iam::444455556666:role/ci"),
+		)
+		Expect(provider.CreateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+
+		Expect(access.Status.Provider).To(HaveKeyWithValue("RoleArn", roleArn))
+		Expect(access.Status.Provider).To(HaveKeyWithValue("ClusterName", fakeClusterName))
+		Expect(access.Status.Provider).NotTo(HaveKey("ServiceAccountAnnotation"))
+		Expect(strings.Split(access.Status.Provider["PodIdentityAssociations"], ",")).To(ConsistOf(
+			HavePrefix("team-a/api=a-"),
+			HavePrefix("team-b/worker=a-"),
+		))
+		Expect(fake.podIdentityAssociations(fakeClusterName)).To(Equal([]string{
+			"team-a/api=" + roleArn,
+			"team-b/worker=" + roleArn,
+		}))
+
+		statements := trustStatements(fake, "secretsbeam-access-team-a-reader")
+		Expect(statements).To(HaveLen(2))
+		Expect(statements[0]).To(Equal(map[string]interface{}{
+			"Effect":    "Allow",
+			"Principal": map[string]interface{}{"Service": "pods.eks.amazonaws.com"},
+			"Action":    []interface{}{"sts:AssumeRole", "sts:TagSession"},
+			"Condition": map[string]interface{}{
+				"StringEquals": map[string]interface{}{
+					"aws:RequestTag/eks-cluster-name": []interface{}{fakeClusterName},
+				},
+			},
+		}))
+		Expect(statements[1]).To(HaveKeyWithValue("Principal", map[string]interface{}{"AWS": "arn:aws:iam::444455556666:root"}))
+	})
+
+	It("follows the subjects of the access", func() {
+		access := providertest.NewAccess("reader", providertest.ServiceAccountSubject("team-a", "api"), providertest.ServiceAccountSubject("team-b", "worker"))
+		Expect(provider.CreateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+		api := podIdentityAssociations(access)["team-a/api"]
+
+		access.Spec.AccessSubjects = []secretsv1alpha1.SecretAccessSubject{
+			providertest.ServiceAccountSubject("team-a", "api"),
+			providertest.ServiceAccountSubject("team-c", "batch"),
+		}
+		Expect(provider.UpdateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+
+		Expect(fake.podIdentityAssociations(fakeClusterName)).To(Equal([]string{
+			"team-a/api=" + roleArn,
+			"team-c/batch=" + roleArn,
+		}))
+		Expect(podIdentityAssociations(access)).To(HaveKeyWithValue("team-a/api", api), "kept associations are not recreated")
+		Expect(podIdentityAssociations(access)).To(HaveLen(2))
+	})
+
+	It("adopts associations of the service account with the role", func() {
+		access := providertest.NewAccess("reader", providertest.ServiceAccountSubject("team-a", "api"))
+		Expect(provider.CreateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+		id := podIdentityAssociations(access)["team-a/api"]
+
+		By("losing the status of the access")
+		delete(access.Status.Provider, "PodIdentityAssociations")
+		Expect(provider.UpdateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+		Expect(podIdentityAssociations(access)).To(Equal(map[string]string{"team-a/api": id}))
+	})
+
+	It("reports service accounts associated with other roles", func() {
+		other := providertest.NewAccess("writer", providertest.ServiceAccountSubject("team-b", "worker"))
+		Expect(provider.CreateAccess(ctx, logr.Discard(), secret, other)).To(Succeed())
+
+		access := providertest.NewAccess("reader", providertest.ServiceAccountSubject("team-a", "api"), providertest.ServiceAccountSubject("team-b", "worker"))
+		Expect(provider.CreateAccess(ctx, logr.Discard(), secret, access)).To(MatchError(ContainSubstring(
+			"associating team-b/worker with role " + roleArn + ": the service account is already associated with role arn:aws:iam::111122223333:role/secretsbeam-access-team-a-writer")))
+		Expect(podIdentityAssociations(access)).To(HaveKey("team-a/api"), "created associations are recorded")
+	})
+
+	It("removes the associations with the access", func() {
+		access := providertest.NewAccess("reader", providertest.ServiceAccountSubject("team-a", "api"), providertest.ServiceAccountSubject("team-b", "worker"))
+		Expect(provider.CreateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+
+		By("ignoring associations that are already gone")
+		delete(fake.associations, podIdentityAssociations(access)["team-b/worker"])
+
+		Expect(provider.DeleteAccess(ctx, logr.Discard(), access)).To(Succeed())
+		Expect(fake.podIdentityAssociations(fakeClusterName)).To(BeEmpty())
+		Expect(fake.roles).To(BeEmpty())
+		Expect(access.Status.Provider).NotTo(HaveKey("PodIdentityAssociations"))
+	})
+
+	It("removes the associations when switching to irsa", func() {
+		access := providertest.NewAccess("reader", providertest.ServiceAccountSubject("team-a", "api"))
+		Expect(provider.CreateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+
+		irsa := &AwsProvider{}
+		Expect(irsa.init(AwsProviderConfig{OidcProviderArn: fakeOidcProviderArn, Endpoint: fake.server.URL}, fake.config())).To(Succeed())
+		Expect(irsa.UpdateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+
+		Expect(fake.podIdentityAssociations(fakeClusterName)).To(BeEmpty())
+		Expect(access.Status.Provider).NotTo(HaveKey("PodIdentityAssociations"))
+		Expect(access.Status.Provider).NotTo(HaveKey("ClusterName"))
+		Expect(access.Status.Provider).To(HaveKeyWithValue("ServiceAccountAnnotation", "eks.amazonaws.com/role-arn="+roleArn))
+		Expect(trustStatements(fake, "secretsbeam-access-team-a-reader")[0]).To(HaveKeyWithValue("Principal", map[string]interface{}{"Federated": fakeOidcProviderArn}))
+	})
+})
@@ -3,25 +3,146 @@ package aws
 import (
 	"context"
 	"fmt"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync"
+	"text/template"
+	"time"
 
-	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
 	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/mitchellh/mapstructure"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/registry"
+)
+
+const (
+	BackendSecretsManager = "secretsmanager"
+	BackendSsm            = "ssm"
+
+	// AccessModeIrsa grants accesses with roles trusted by the OIDC provider of the cluster, assumed
+	// by service accounts annotated with the role
+	AccessModeIrsa = "irsa"
+	// AccessModePodIdentity grants accesses with roles trusted by EKS Pod Identity, associated with
+	// the service accounts of the cluster
+	AccessModePodIdentity = "podIdentity"
+	// AccessModeResourcePolicy grants accesses with statements of the resource policies of the secrets,
+	// for the principals of the provider identifier subjects. No role is created.
+	AccessModeResourcePolicy = "resourcePolicy"
+
+	// RoleScopeAccess creates a role per access, assumed by all its subjects
+	RoleScopeAccess = "access"
+	// RoleScopeServiceAccount creates a role per service account shared by the accesses granting it,
+	// which each add an inline policy to the role
+	RoleScopeServiceAccount = "serviceAccount"
 )
 
 // AwsProvider
 type AwsProvider struct {
 	AwsProviderConfig
 
+	// Client and Namespace are used to read the secret holding the access keys of the provider
+	Client    client.Client
+	Namespace string
+	// ClusterID is the cluster of the ownership tags of the resources created by the provider
+	ClusterID string
+
+	eksClient     *eks.Client
 	iamClient     *iam.Client
+	kmsClient     *kms.Client
 	secretsClient *secretsmanager.Client
+	ssmClient     *ssm.Client
+	stsClient     *sts.Client
+	region        string
+
+	// resourcePolicyMu serializes the edits of the resource policies of secrets, shared by their accesses
+	resourcePolicyMu sync.Mutex
+	// serviceAccountRolesMu serializes the edits of the roles of service accounts, shared by their accesses
+	serviceAccountRolesMu sync.Mutex
 }
 
 type AwsProviderConfig struct {
 	OidcProviderArn string `json:"oidcProviderArn"`
-	oidcProviderID  string
+	// AccessMode is how accesses are granted: irsa (default) or podIdentity, how service accounts
+	// assume the roles of accesses, or resourcePolicy for the resource policies of the secrets
+	AccessMode string `json:"accessMode"`
+	// ClusterName is the EKS cluster holding the pod identity associations of the podIdentity mode
+	ClusterName string `json:"clusterName"`
+	// Backend is where secrets are stored, either secretsmanager (default) or ssm for
+	// SecureString parameters in Parameter Store
+	Backend string `json:"backend"`
+	// Endpoint overrides the endpoint of every AWS API called by the provider, for
+	// testing against a local stand-in such as localstack
+	Endpoint string `json:"endpoint"`
+	// Region defaults to the region of the environment of the operator, e.g. AWS_REGION
+	Region string `json:"region"`
+
+	// AccessKeySecretName references a Secret in the provider namespace holding static access keys,
+	// under AccessKeyIDKey, SecretAccessKeyKey and optionally SessionTokenKey
+	AccessKeySecretName string `json:"accessKeySecretName"`
+	AccessKeyIDKey      string `json:"accessKeyIDKey"`
+	SecretAccessKeyKey  string `json:"secretAccessKeyKey"`
+	SessionTokenKey     string `json:"sessionTokenKey"`
+	// WebIdentityRoleArn is assumed with the token of WebIdentityTokenFile, e.g. a projected
+	// service account token
+	WebIdentityRoleArn   string `json:"webIdentityRoleArn"`
+	WebIdentityTokenFile string `json:"webIdentityTokenFile"`
+	// AssumeRoleArn is assumed with the credentials above, e.g. a role of another account
+	AssumeRoleArn string `json:"assumeRoleArn"`
+	ExternalID    string `json:"externalID"`
+	// RoleSessionName is the session name of the assumed roles, defaults to secretsbeam-operator
+	RoleSessionName string `json:"roleSessionName"`
+
+	// RoleNameTemplate is the template of the names of the roles and policies created for accesses,
+	// with the fields .Namespace, .Name, .ClusterID and .ClusterName
+	RoleNameTemplate string `json:"roleNameTemplate"`
+	// IamPath is the path of the roles and policies created for accesses, defaults to /
+	IamPath string `json:"iamPath"`
+	// PermissionsBoundaryArn is the permissions boundary of the roles created for accesses
+	PermissionsBoundaryArn string `json:"permissionsBoundaryArn"`
+	// MaxSessionDuration is the maximum session duration of the roles created for accesses, between
+	// 1h and 12h, e.g. 2h
+	MaxSessionDuration string `json:"maxSessionDuration"`
+	// ManagedPolicyArns is a comma separated list of policies attached to the roles created for
+	// accesses, next to the policy of the access
+	ManagedPolicyArns string `json:"managedPolicyArns"`
+	// RoleScope is whether service accounts get the role of each access, access (default), or a role
+	// of their own shared by their accesses, serviceAccount
+	RoleScope string `json:"roleScope"`
+	// ServiceAccountRoleNameTemplate is the template of the names of the roles of service accounts,
+	// with the fields of RoleNameTemplate for the service account
+	ServiceAccountRoleNameTemplate string `json:"serviceAccountRoleNameTemplate"`
+
+	// KmsKeyScope manages a customer managed KMS key per namespace or per secret, encrypting the
+	// secrets without a KmsKeyArn in their provider spec. Secrets use the AWS managed key when empty.
+	KmsKeyScope string `json:"kmsKeyScope"`
+	// KmsKeyAliasPrefix prefixes the aliases of the managed keys, defaults to alias/secretsbeam/
+	KmsKeyAliasPrefix string `json:"kmsKeyAliasPrefix"`
+
+	// AdoptUntagged takes over the existing resources without ownership tags, which are otherwise
+	// left untouched
+	AdoptUntagged string `json:"adoptUntagged"`
+
+	oidcProviderID                 string
+	adoptUntagged                  bool
+	roleNameTemplate               *template.Template
+	serviceAccountRoleNameTemplate *template.Template
+	maxSessionDuration             time.Duration
+	managedPolicyArns              []string
+}
+
+func init() {
+	registry.Register(secretsv1alpha1.SecretProviderAws, func(opts registry.Options) registry.Provider {
+		return &AwsProvider{Client: opts.Client, Namespace: opts.Namespace, ClusterID: opts.ClusterID}
+	}, registry.SchemaOf(AwsProviderConfig{}))
 }
 
 // AwsStatus defines the desired state of Secret
@@ -52,16 +173,189 @@ func (p *AwsProvider) Init(config map[string]string) error {
 		return fmt.Errorf("decoding provider config: %w", err)
 	}
 
-	p.AwsProviderConfig = *providerConfig
+	if err := providerConfig.validate(); err != nil {
+		return err
+	}
+
+	cfg, err := p.loadConfig(context.Background(), *providerConfig)
+	if err != nil {
+		return err
+	}
+
+	return p.init(*providerConfig, cfg)
+}
+
+// validate checks the provider config and sets its defaults
+func (c *AwsProviderConfig) validate() error {
+	switch c.Backend {
+	case "":
+		c.Backend = BackendSecretsManager
+	case BackendSecretsManager, BackendSsm:
+	default:
+		return fmt.Errorf("unsupported backend %s", c.Backend)
+	}
+
+	switch c.AccessMode {
+	case "":
+		c.AccessMode = AccessModeIrsa
+	case AccessModeIrsa:
+	case AccessModeResourcePolicy:
+	case AccessModePodIdentity:
+		if c.ClusterName == "" {
+			return fmt.Errorf("clusterName is required by the %s access mode", AccessModePodIdentity)
+		}
+	default:
+		return fmt.Errorf("unsupported access mode %s", c.AccessMode)
+	}
+	if c.AccessMode == AccessModeResourcePolicy && c.Backend != BackendSecretsManager {
+		return fmt.Errorf("the %s access mode requires the %s backend", AccessModeResourcePolicy, BackendSecretsManager)
+	}
+
+	if c.AccessKeySecretName != "" && c.WebIdentityRoleArn != "" {
+		return fmt.Errorf("accessKeySecretName and webIdentityRoleArn are mutually exclusive")
+	}
+	if (c.WebIdentityRoleArn == "") != (c.WebIdentityTokenFile == "") {
+		return fmt.Errorf("webIdentityRoleArn and webIdentityTokenFile must be set together")
+	}
+	if c.ExternalID != "" && c.AssumeRoleArn == "" {
+		return fmt.Errorf("externalID requires assumeRoleArn")
+	}
+	if c.AccessKeyIDKey == "" {
+		c.AccessKeyIDKey = defaultAccessKeyIDKey
+	}
+	if c.SecretAccessKeyKey == "" {
+		c.SecretAccessKeyKey = defaultSecretAccessKeyKey
+	}
+	if c.RoleSessionName == "" {
+		c.RoleSessionName = defaultRoleSessionName
+	}
+
+	if c.RoleNameTemplate == "" {
+		c.RoleNameTemplate = defaultRoleNameTemplate
+	}
+	tmpl, err := parseRoleNameTemplate("roleNameTemplate", c.RoleNameTemplate)
+	if err != nil {
+		return err
+	}
+	c.roleNameTemplate = tmpl
+
+	switch c.RoleScope {
+	case "":
+		c.RoleScope = RoleScopeAccess
+	case RoleScopeAccess:
+	case RoleScopeServiceAccount:
+		if c.AccessMode == AccessModeResourcePolicy {
+			return fmt.Errorf("the %s role scope is not supported by the %s access mode", RoleScopeServiceAccount, AccessModeResourcePolicy)
+		}
+	default:
+		return fmt.Errorf("unsupported roleScope %s", c.RoleScope)
+	}
+	if c.ServiceAccountRoleNameTemplate == "" {
+		c.ServiceAccountRoleNameTemplate = defaultServiceAccountRoleNameTemplate
+	}
+	if c.serviceAccountRoleNameTemplate, err = parseRoleNameTemplate("serviceAccountRoleNameTemplate", c.ServiceAccountRoleNameTemplate); err != nil {
+		return err
+	}
+
+	if c.IamPath == "" {
+		c.IamPath = "/"
+	} else if !strings.HasPrefix(c.IamPath, "/") || !strings.HasSuffix(c.IamPath, "/") {
+		return fmt.Errorf("invalid iamPath %s, expected a path starting and ending with /", c.IamPath)
+	}
+	if c.PermissionsBoundaryArn != "" && !strings.Contains(c.PermissionsBoundaryArn, ":policy/") {
+		return fmt.Errorf("invalid permissionsBoundaryArn %s, expected the ARN of a policy", c.PermissionsBoundaryArn)
+	}
+	if c.MaxSessionDuration != "" {
+		duration, err := time.ParseDuration(c.MaxSessionDuration)
+		if err != nil || duration < time.Hour || duration > 12*time.Hour {
+			return fmt.Errorf("invalid maxSessionDuration %s, expected a duration between 1h and 12h", c.MaxSessionDuration)
+		}
+		c.maxSessionDuration = duration
+	}
+	switch c.KmsKeyScope {
+	case "", KmsKeyScopeNamespace, KmsKeyScopeSecret:
+	default:
+		return fmt.Errorf("unsupported kmsKeyScope %s", c.KmsKeyScope)
+	}
+	if c.KmsKeyAliasPrefix == "" {
+		c.KmsKeyAliasPrefix = defaultKmsKeyAliasPrefix
+	} else if !strings.HasPrefix(c.KmsKeyAliasPrefix, "alias/") || strings.HasPrefix(c.KmsKeyAliasPrefix, "alias/aws/") || !strings.HasSuffix(c.KmsKeyAliasPrefix, "/") {
+		return fmt.Errorf("invalid kmsKeyAliasPrefix %s, expected a prefix such as alias/secretsbeam/", c.KmsKeyAliasPrefix)
+	}
+
+	c.adoptUntagged = false
+	if c.AdoptUntagged != "" {
+		adopt, err := strconv.ParseBool(c.AdoptUntagged)
+		if err != nil {
+			return fmt.Errorf("parsing adoptUntagged: %w", err)
+		}
+		c.adoptUntagged = adopt
+	}
+
+	c.managedPolicyArns = nil
+	for _, policyArn := range strings.Split(c.ManagedPolicyArns, ",") {
+		if policyArn = strings.TrimSpace(policyArn); policyArn != "" {
+			c.managedPolicyArns = append(c.managedPolicyArns, policyArn)
+		}
+	}
 
-	if cfg, err := awsconfig.LoadDefaultConfig(context.Background()); err != nil {
-		return fmt.Errorf("unable to load SDK config, %v", err)
-	} else {
-		p.secretsClient = secretsmanager.NewFromConfig(cfg)
-		p.iamClient = iam.NewFromConfig(cfg)
+	return nil
+}
+
+// init validates the provider config and creates the API clients from the SDK config
+func (p *AwsProvider) init(config AwsProviderConfig, cfg aws.Config) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+	if config.Region != "" {
+		cfg.Region = config.Region
 	}
+	if cfg.Region == "" {
+		return fmt.Errorf("region is required, set it in the provider config or with AWS_REGION")
+	}
+
+	if config.Endpoint != "" {
+		if endpoint, err := url.Parse(config.Endpoint); err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+			return fmt.Errorf("invalid endpoint %s, expected an absolute URL", config.Endpoint)
+		}
+		cfg.BaseEndpoint = aws.String(config.Endpoint)
+	}
+	cfg = withRoles(cfg, config)
+
+	p.AwsProviderConfig = config
+	p.secretsClient = secretsmanager.NewFromConfig(cfg)
+	p.eksClient = eks.NewFromConfig(cfg)
+	p.iamClient = iam.NewFromConfig(cfg)
+	p.kmsClient = kms.NewFromConfig(cfg)
+	p.ssmClient = ssm.NewFromConfig(cfg)
+	p.stsClient = sts.NewFromConfig(cfg)
+	p.region = cfg.Region
 
 	p.oidcProviderID = strings.Join(strings.Split(p.OidcProviderArn, "/")[1:], "/")
 
 	return nil
 }
+
+// CheckHealth resolves the identity of the credentials of the provider with STS GetCallerIdentity,
+// which requires no permissions
+func (p *AwsProvider) CheckHealth(ctx context.Context) (*registry.Health, error) {
+	identity, err := p.stsClient.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, fmt.Errorf("getting caller identity: %w", err)
+	}
+
+	return &registry.Health{
+		Identity: aws.ToString(identity.Arn),
+		Account:  aws.ToString(identity.Account),
+		Region:   p.region,
+	}, nil
+}
+
+// Capabilities reports the features of the backend, Parameter Store deletes parameters immediately
+func (p *AwsProvider) Capabilities() registry.Capabilities {
+	if p.Backend == BackendSsm {
+		return registry.Capabilities{AccessControl: true}
+	}
+
+	return registry.AllCapabilities
+}
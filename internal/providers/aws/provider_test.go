@@ -0,0 +1,457 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/providertest"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/registry"
+)
+
+const fakeOidcProviderArn = "arn:aws:iam::111122223333:oidc-provider/oidc.eks.eu-west-1.amazonaws.com/id/EXAMPLE"
+
+func principalSubject(identifier string) secretsv1alpha1.SecretAccessSubject {
+	return secretsv1alpha1.SecretAccessSubject{
+		ProviderIdentifier: &secretsv1alpha1.SecretAccessSubjectProviderIdentifier{Identifier: identifier},
+	}
+}
+
+// trustStatements decodes the statements of the trust policy of a role
+func trustStatements(fake *fakeAWS, roleName string) []map[string]interface{} {
+	role, ok := fake.roles[roleName]
+	Expect(ok).To(BeTrue())
+
+	policy := struct {
+		Statement []map[string]interface{}
+	}{}
+	Expect(json.Unmarshal([]byte(role.trustPolicy), &policy)).To(Succeed())
+
+	return policy.Statement
+}
+
+var _ = Describe("AwsProvider", func() {
+	var (
+		ctx      context.Context
+		fake     *fakeAWS
+		provider *AwsProvider
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		fake = newFakeAWS()
+		DeferCleanup(fake.Close)
+
+		provider = &AwsProvider{}
+		Expect(provider.init(AwsProviderConfig{
+			OidcProviderArn: fakeOidcProviderArn,
+			Endpoint:        fake.server.URL,
+		}, fake.config())).To(Succeed())
+	})
+
+	Context("Init", func() {
+		It("validates the config", func() {
+			Expect((&AwsProvider{}).init(AwsProviderConfig{Backend: "dynamodb"}, fake.config())).
+				To(MatchError(ContainSubstring("unsupported backend dynamodb")))
+			Expect((&AwsProvider{}).init(AwsProviderConfig{Endpoint: "localhost:4566"}, fake.config())).
+				To(MatchError(ContainSubstring("invalid endpoint")))
+			Expect(provider.Backend).To(Equal(BackendSecretsManager))
+			Expect(provider.oidcProviderID).To(Equal("oidc.eks.eu-west-1.amazonaws.com/id/EXAMPLE"))
+		})
+
+		It("points every client at the endpoint", func() {
+			for key, value := range map[string]string{
+				"AWS_REGION":            fakeRegion,
+				"AWS_ACCESS_KEY_ID":     "AKIDFAKE",
+				"AWS_SECRET_ACCESS_KEY": "fake",
+				"AWS_CONFIG_FILE":       os.DevNull,
+			} {
+				previous, ok := os.LookupEnv(key)
+				Expect(os.Setenv(key, value)).To(Succeed())
+				DeferCleanup(func(key, previous string, ok bool) {
+					if ok {
+						os.Setenv(key, previous)
+					} else {
+						os.Unsetenv(key)
+					}
+				}, key, previous, ok)
+			}
+
+			p := &AwsProvider{}
+			Expect(p.Init(map[string]string{"endpoint": fake.server.URL, "oidcProviderArn": fakeOidcProviderArn})).To(Succeed())
+			Expect(p.Endpoint).To(Equal(fake.server.URL))
+
+			secret := providertest.NewSecret("db")
+			Expect(p.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+			Expect(p.CreateAccess(ctx, logr.Discard(), secret, providertest.NewAccess("reader"))).To(Succeed())
+			Expect(fake.operations()).To(ContainElements("CreateSecret", "CreatePolicy", "CreateRole"))
+		})
+	})
+
+	Context("health", func() {
+		It("resolves the caller identity", func() {
+			health, err := provider.CheckHealth(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*health).To(Equal(registry.Health{Identity: fakeCallerArn, Account: fakeAccountID, Region: fakeRegion}))
+			Expect(fake.operations()).To(Equal([]string{"GetCallerIdentity"}))
+		})
+
+		It("reports invalid credentials", func() {
+			fake.failOn("GetCallerIdentity", "InvalidClientTokenId")
+			_, err := provider.CheckHealth(ctx)
+			Expect(err).To(MatchError(ContainSubstring("InvalidClientTokenId")))
+		})
+
+		It("reports the capabilities of the backend", func() {
+			Expect(provider.Capabilities()).To(Equal(registry.AllCapabilities))
+
+			Expect(provider.init(AwsProviderConfig{Backend: BackendSsm, Endpoint: fake.server.URL}, fake.config())).To(Succeed())
+			Expect(provider.Capabilities()).To(Equal(registry.Capabilities{AccessControl: true}))
+		})
+	})
+
+	Context("Secrets Manager", func() {
+		It("creates, updates and describes secrets", func() {
+			secret := providertest.NewSecret("db")
+			secret.Status.SecretName = "db"
+			secret.Spec.ProviderSpec["KmsKeyArn"] = "alias/team-a"
+			Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+
+			stored := fake.secret("db")
+			Expect(stored).NotTo(BeNil())
+			Expect(stored.value).To(Equal("hunter2"))
+			Expect(stored.kmsKeyID).To(Equal("alias/team-a"))
+			Expect(secret.Status.Provider).To(HaveKeyWithValue("SecretArn", stored.arn))
+			Expect(secret.Status.Provider).To(HaveKeyWithValue("KmsKeyArn", "alias/team-a"))
+
+			created, err := provider.GetSecretLastChangedDate(ctx, logr.Discard(), secret)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*created).To(BeTemporally("==", stored.lastChanged))
+
+			Expect(provider.UpdateSecret(ctx, logr.Discard(), secret, "hunter3")).To(Succeed())
+			Expect(stored.value).To(Equal("hunter3"))
+
+			updated, err := provider.GetSecretLastChangedDate(ctx, logr.Discard(), secret)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*updated).To(BeTemporally(">", *created))
+		})
+
+		It("reports existing secrets", func() {
+			Expect(provider.CreateSecret(ctx, logr.Discard(), providertest.NewSecret("db"), "hunter2")).To(Succeed())
+			Expect(provider.CreateSecret(ctx, logr.Discard(), providertest.NewSecret("db"), "hunter2")).
+				To(MatchError(ContainSubstring("ResourceExistsException")))
+		})
+
+		It("schedules the deletion with a recovery window", func() {
+			secret := providertest.NewSecret("db")
+			secret.Status.SecretName = "db"
+			secret.Spec.RecoveryWindow = 7
+			Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+
+			Expect(provider.DeleteSecret(ctx, logr.Discard(), secret)).To(MatchError(ContainSubstring("secret scheduled for deletion")))
+			Expect(secret.Status.DeletionDate).NotTo(BeNil())
+			Expect(fake.secret("db").deletionDate).NotTo(BeNil())
+			Expect(secret.Status.DeletionDate.Time).To(BeTemporally("==", *fake.secret("db").deletionDate))
+
+			Expect(provider.UpdateSecret(ctx, logr.Discard(), secret, "hunter3")).To(MatchError(ContainSubstring("scheduled for deletion")))
+		})
+
+		It("deletes the secret without a recovery window", func() {
+			secret := providertest.NewSecret("db")
+			secret.Status.SecretName = "db"
+			Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+
+			Expect(provider.DeleteSecret(ctx, logr.Discard(), secret)).To(Succeed())
+			Expect(fake.secret("db")).To(BeNil())
+		})
+	})
+
+	Context("Parameter Store", func() {
+		BeforeEach(func() {
+			Expect(provider.init(AwsProviderConfig{Backend: BackendSsm, Endpoint: fake.server.URL}, fake.config())).To(Succeed())
+		})
+
+		It("stores secrets as SecureString parameters", func() {
+			secret := providertest.NewSecret("team-a/db")
+			Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+
+			parameter := fake.parameters["/team-a/db"]
+			Expect(parameter).NotTo(BeNil())
+			Expect(parameter.value).To(Equal("hunter2"))
+			Expect(secret.Status.Provider).To(HaveKeyWithValue("ParameterArn", parameter.arn))
+			Expect(secret.Status.Provider).To(HaveKeyWithValue("ParameterVersion", "1"))
+			Expect(secret.Status.Provider).To(HaveKeyWithValue("Tier", "Standard"))
+
+			Expect(provider.UpdateSecret(ctx, logr.Discard(), secret, "hunter3")).To(Succeed())
+			Expect(parameter.value).To(Equal("hunter3"))
+			Expect(secret.Status.Provider).To(HaveKeyWithValue("ParameterVersion", "2"))
+
+			lastChanged, err := provider.GetSecretLastChangedDate(ctx, logr.Discard(), secret)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*lastChanged).To(BeTemporally("==", parameter.lastModified))
+
+			Expect(provider.DeleteSecret(ctx, logr.Discard(), secret)).To(Succeed())
+			Expect(fake.parameters).NotTo(HaveKey("/team-a/db"))
+
+			By("ignoring parameters that are already gone")
+			Expect(provider.DeleteSecret(ctx, logr.Discard(), secret)).To(Succeed())
+		})
+
+		It("only overwrites parameters when requested", func() {
+			Expect(provider.CreateSecret(ctx, logr.Discard(), providertest.NewSecret("db"), "hunter2")).To(Succeed())
+			Expect(provider.CreateSecret(ctx, logr.Discard(), providertest.NewSecret("db"), "hunter3")).
+				To(MatchError(ContainSubstring("ParameterAlreadyExists")))
+
+			secret := providertest.NewSecret("db")
+			secret.Spec.Overwrite = true
+			Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter3")).To(Succeed())
+			Expect(fake.parameters["db"].value).To(Equal("hunter3"))
+		})
+	})
+
+	Context("access", func() {
+		var secret *secretsv1alpha1.ExternalSecret
+
+		BeforeEach(func() {
+			secret = providertest.NewSecret("db")
+			secret.Status.SecretName = "db"
+			Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+		})
+
+		It("creates a policy and a role assumable by the subjects", func() {
+			access := providertest.NewAccess("reader", providertest.ServiceAccountSubject("team-a", "api"), providertest.ServiceAccountSubject("team-b", "worker"))
+			Expect(provider.CreateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+
+			policyArn := "arn:aws:iam::111122223333:policy/secretsbeam-access-team-a-reader"
+			Expect(access.Status.Provider).To(HaveKeyWithValue("PolicyArn", policyArn))
+			Expect(access.Status.Provider).To(HaveKeyWithValue("RoleName", "secretsbeam-access-team-a-reader"))
+			Expect(access.Status.Provider).To(HaveKeyWithValue("ServiceAccountAnnotation",
+				"eks.amazonaws.com/role-arn=arn:aws:iam::111122223333:role/secretsbeam-access-team-a-reader"))
+			Expect(access.Status.Subjects).To(Equal(access.Spec.AccessSubjects))
+
+			policy := fake.policies[policyArn]
+			Expect(policy).NotTo(BeNil())
+			expected, err := getSecretAccessPolicy(secret)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policy.defaultVersion().document).To(MatchJSON(expected))
+			Expect(fake.roles["secretsbeam-access-team-a-reader"].attachedPolicies).To(HaveKey(policyArn))
+
+			Expect(trustStatements(fake, "secretsbeam-access-team-a-reader")).To(ConsistOf(map[string]interface{}{
+				"Effect":    "Allow",
+				"Principal": map[string]interface{}{"Federated": fakeOidcProviderArn},
+				"Action":    "sts:AssumeRoleWithWebIdentity",
+				"Condition": map[string]interface{}{
+					"StringEquals": map[string]interface{}{
+						"oidc.eks.eu-west-1.amazonaws.com/id/EXAMPLE:sub": []interface{}{
+							"system:serviceaccount:team-a:api",
+							"system:serviceaccount:team-b:worker",
+						},
+					},
+				},
+			}))
+		})
+
+		It("trusts principals of other accounts", func() {
+			access := providertest.NewAccess("reader",
+				principalSubject("arn:aws:iam::444455556666:role/ci"),
+				principalSubject("arn:aws:iam::444455556666:user/deploy"),
+				principalSubject("arn:aws:iam::777788889999:role/batch"),
+			)
+			Expect(provider.CreateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+
+			statements := trustStatements(fake, "secretsbeam-access-team-a-reader")
+			Expect(statements).To(HaveLen(2))
+			Expect(statements[0]).To(Equal(map[string]interface{}{
+				"Effect":    "Allow",
+				"Principal": map[string]interface{}{"AWS": "arn:aws:iam::444455556666:root"},
+				"Action":    "sts:AssumeRole",
+				"Condition": map[string]interface{}{
+					"ArnLike": map[string]interface{}{
+						"aws:PrincipalArn": []interface{}{"arn:aws:iam::444455556666:role/ci", "arn:aws:iam::444455556666:user/deploy"},
+					},
+				},
+			}))
+			Expect(statements[1]).To(HaveKeyWithValue("Principal", map[string]interface{}{"AWS": "arn:aws:iam::777788889999:root"}))
+		})
+
+		It("rejects invalid principals", func() {
+			access := providertest.NewAccess("reader", principalSubject("ci"))
+			Expect(provider.CreateAccess(ctx, logr.Discard(), secret, access)).To(MatchError(ContainSubstring("invalid provider identifier ci")))
+			Expect(fake.roles).To(BeEmpty())
+		})
+
+		It("waits for the ARN of the secret", func() {
+			delete(secret.Status.Provider, "SecretArn")
+
+			access := providertest.NewAccess("reader", providertest.ServiceAccountSubject("team-a", "api"))
+			Expect(provider.CreateAccess(ctx, logr.Discard(), secret, access)).To(MatchError(ContainSubstring("the ARN of secret db is not known yet")))
+			Expect(fake.policies).To(BeEmpty())
+
+			secret.Status.Provider["SecretArn"] = "arn"
+			Expect(provider.CreateAccess(ctx, logr.Discard(), secret, access)).To(MatchError(ContainSubstring("the ARN of secret db is not known yet")))
+		})
+
+		It("updates the existing policy and role when created again", func() {
+			access := providertest.NewAccess("reader", providertest.ServiceAccountSubject("team-a", "api"))
+			Expect(provider.CreateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+
+			access.Spec.AccessSubjects = append(access.Spec.AccessSubjects, providertest.ServiceAccountSubject("team-a", "worker"))
+			Expect(provider.CreateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+
+			Expect(fake.policyVersions(access.Status.Provider["PolicyArn"])).To(Equal([]string{"v1", "v2"}))
+			Expect(fake.policies[access.Status.Provider["PolicyArn"]].defaultVersion().id).To(Equal("v2"))
+			Expect(trustStatements(fake, "secretsbeam-access-team-a-reader")[0]["Condition"]).To(HaveKeyWithValue("StringEquals", HaveKeyWithValue(
+				"oidc.eks.eu-west-1.amazonaws.com/id/EXAMPLE:sub", HaveLen(2))))
+			Expect(fake.operations()).To(ContainElement("UpdateAssumeRolePolicy"))
+		})
+
+		It("reports failures to create the role", func() {
+			fake.failOn("CreateRole", "ServiceFailure")
+
+			access := providertest.NewAccess("reader", providertest.ServiceAccountSubject("team-a", "api"))
+			Expect(provider.CreateAccess(ctx, logr.Discard(), secret, access)).To(MatchError(ContainSubstring("failed to create role")))
+			Expect(access.Status.Provider).To(HaveKey("PolicyArn"))
+			Expect(access.Status.Provider).NotTo(HaveKey("RoleName"))
+
+			By("reusing the policy once the role can be created")
+			fake.failOn("CreateRole", "")
+			Expect(provider.CreateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+			Expect(fake.policies).To(HaveLen(1))
+			Expect(fake.roles["secretsbeam-access-team-a-reader"].attachedPolicies).To(HaveKey(access.Status.Provider["PolicyArn"]))
+		})
+
+		Context("updates", func() {
+			var access *secretsv1alpha1.ExternalSecretAccess
+
+			BeforeEach(func() {
+				access = providertest.NewAccess("reader", providertest.ServiceAccountSubject("team-a", "api"))
+				Expect(provider.CreateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+			})
+
+			It("sets a new default policy version and updates the trust policy", func() {
+				secret.Status.Provider["SecretArn"] = "arn:aws:secretsmanager:eu-west-1:111122223333:secret:renamed-AbCdEf"
+				access.Spec.AccessSubjects = []secretsv1alpha1.SecretAccessSubject{providertest.ServiceAccountSubject("team-a", "worker")}
+				Expect(provider.UpdateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+
+				policy := fake.policies[access.Status.Provider["PolicyArn"]]
+				Expect(policy.defaultVersion().id).To(Equal("v2"))
+				Expect(policy.defaultVersion().document).To(ContainSubstring("secret:renamed-??????"))
+				Expect(trustStatements(fake, "secretsbeam-access-team-a-reader")[0]["Condition"]).To(Equal(map[string]interface{}{
+					"StringEquals": map[string]interface{}{
+						"oidc.eks.eu-west-1.amazonaws.com/id/EXAMPLE:sub": []interface{}{"system:serviceaccount:team-a:worker"},
+					},
+				}))
+				Expect(access.Status.Subjects).To(Equal(access.Spec.AccessSubjects))
+			})
+
+			It("stays under the policy version limit", func() {
+				for i := 0; i < 2*iamPolicyVersionLimit; i++ {
+					Expect(provider.UpdateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+				}
+
+				// only the previous version is kept next to the default one
+				Expect(fake.policyVersions(access.Status.Provider["PolicyArn"])).To(Equal([]string{"v10", "v11"}))
+				Expect(fake.policies[access.Status.Provider["PolicyArn"]].defaultVersion().id).To(Equal("v11"))
+			})
+
+			It("uses the role of the status", func() {
+				fake.roles["renamed"] = fake.roles["secretsbeam-access-team-a-reader"]
+				delete(fake.roles, "secretsbeam-access-team-a-reader")
+				access.Status.Provider["RoleName"] = "renamed"
+
+				Expect(provider.UpdateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+			})
+
+			It("reports missing policies", func() {
+				access.Status.Provider["PolicyArn"] = "arn:aws:iam::111122223333:policy/missing"
+				Expect(provider.UpdateAccess(ctx, logr.Discard(), secret, access)).To(MatchError(ContainSubstring("NoSuchEntity")))
+			})
+		})
+
+		Context("deletion", func() {
+			var access *secretsv1alpha1.ExternalSecretAccess
+
+			BeforeEach(func() {
+				access = providertest.NewAccess("reader", providertest.ServiceAccountSubject("team-a", "api"))
+				Expect(provider.CreateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+				Expect(provider.UpdateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+				Expect(provider.UpdateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+			})
+
+			It("detaches and deletes the policy and the role", func() {
+				Expect(provider.DeleteAccess(ctx, logr.Discard(), access)).To(Succeed())
+				Expect(fake.policies).To(BeEmpty())
+				Expect(fake.roles).To(BeEmpty())
+			})
+
+			It("ignores resources that are already gone", func() {
+				Expect(provider.DeleteAccess(ctx, logr.Discard(), access)).To(Succeed())
+				Expect(provider.DeleteAccess(ctx, logr.Discard(), access)).To(Succeed())
+			})
+
+			It("does nothing for accesses that were never created", func() {
+				operations := len(fake.operations())
+				Expect(provider.DeleteAccess(ctx, logr.Discard(), providertest.NewAccess("pending"))).To(Succeed())
+				Expect(fake.operations()).To(HaveLen(operations))
+			})
+
+			It("reports other failures", func() {
+				fake.failOn("DeleteRole", "ServiceFailure")
+				Expect(provider.DeleteAccess(ctx, logr.Discard(), access)).To(MatchError(ContainSubstring("failed to delete role secretsbeam-access-team-a-reader")))
+				Expect(fake.policies).To(BeEmpty())
+
+				By("finishing the deletion on retry")
+				fake.failOn("DeleteRole", "")
+				Expect(provider.DeleteAccess(ctx, logr.Discard(), access)).To(Succeed())
+				Expect(fake.roles).To(BeEmpty())
+			})
+		})
+	})
+
+	Context("deleteNOldestPolicyVersions", func() {
+		var policyArn string
+
+		BeforeEach(func() {
+			access := providertest.NewAccess("reader", providertest.ServiceAccountSubject("team-a", "api"))
+			secret := providertest.NewSecret("db")
+			secret.Status.Provider["SecretArn"] = "arn:aws:secretsmanager:eu-west-1:111122223333:secret:db-AbCdEf"
+			Expect(provider.CreateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+			policyArn = access.Status.Provider["PolicyArn"]
+
+			// v1 to v4 are non default versions, oldest first, and v5 is the default
+			policy := fake.policies[policyArn]
+			for i := 2; i <= iamPolicyVersionLimit; i++ {
+				policy.versions = append(policy.versions, &fakePolicyVersion{id: fmt.Sprintf("v%d", i), document: "{}", created: fake.now()})
+			}
+			policy.versions[0].isDefault = false
+			policy.versions[len(policy.versions)-1].isDefault = true
+		})
+
+		It("deletes the oldest non default versions", func() {
+			Expect(provider.deleteNOldestPolicyVersions(ctx, policyArn, 2)).To(Succeed())
+			Expect(fake.policyVersions(policyArn)).To(Equal([]string{"v3", "v4", "v5"}))
+		})
+
+		It("deletes every non default version without a limit", func() {
+			Expect(provider.deleteNOldestPolicyVersions(ctx, policyArn, 0)).To(Succeed())
+			Expect(fake.policyVersions(policyArn)).To(Equal([]string{"v5"}))
+		})
+
+		It("never deletes the default version", func() {
+			Expect(provider.deleteNOldestPolicyVersions(ctx, policyArn, 10)).To(Succeed())
+			Expect(fake.policyVersions(policyArn)).To(Equal([]string{"v5"}))
+			Expect(fake.operations()).NotTo(ContainElement("DeletePolicy"))
+		})
+
+		It("reports missing policies", func() {
+			err := provider.deleteNOldestPolicyVersions(ctx, "arn:aws:iam::111122223333:policy/missing", 1)
+			Expect(isNoSuchEntity(err)).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,240 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	smtypes "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/go-logr/logr"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+)
+
+// replicationStatusPrefix prefixes the entries of the status holding the replication status of each
+// replica region
+const replicationStatusPrefix = "ReplicationStatus."
+
+var regionPattern = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-[0-9]+$`)
+
+// replicaRegions parses the ReplicaRegions entry of the provider spec of a secret, a comma separated
+// list of regions each optionally followed by the KMS key of its replica, e.g.
+// us-east-1,us-west-2=alias/team-a. Replicas without a key use the AWS managed key of their region.
+func (p *AwsProvider) replicaRegions(secret *secretsv1alpha1.ExternalSecret) (map[string]string, error) {
+	replicas := make(map[string]string)
+	val, ok := secret.Spec.ProviderSpec["ReplicaRegions"]
+	if !ok {
+		return replicas, nil
+	}
+	if p.Backend != BackendSecretsManager {
+		return nil, fmt.Errorf("ReplicaRegions is only supported by the %s backend", BackendSecretsManager)
+	}
+
+	for _, entry := range strings.Split(val, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		region, keyID, _ := strings.Cut(entry, "=")
+		region = strings.TrimSpace(region)
+		if !regionPattern.MatchString(region) {
+			return nil, fmt.Errorf("invalid replica region %s", region)
+		}
+		if region == p.region {
+			return nil, fmt.Errorf("replica region %s is the region of the secret", region)
+		}
+		if _, ok := replicas[region]; ok {
+			return nil, fmt.Errorf("replica region %s is listed twice", region)
+		}
+		replicas[region] = strings.TrimSpace(keyID)
+	}
+
+	return replicas, nil
+}
+
+// recordedReplicas returns the replicas recorded in the ReplicaRegions entry of the status, the
+// replicas created by the provider
+func recordedReplicas(status map[string]string) map[string]string {
+	replicas := make(map[string]string)
+	if val := status["ReplicaRegions"]; val != "" {
+		for _, entry := range strings.Split(val, ",") {
+			region, keyID, _ := strings.Cut(entry, "=")
+			replicas[region] = keyID
+		}
+	}
+
+	return replicas
+}
+
+// recordReplication sets the replicas in the ReplicaRegions entry of the status, sorted by region,
+// and the status of each replica in the ReplicationStatus.<region> entries
+func recordReplication(status map[string]string, replicas map[string]string, replication []smtypes.ReplicationStatusType) {
+	for entry := range status {
+		if strings.HasPrefix(entry, replicationStatusPrefix) {
+			delete(status, entry)
+		}
+	}
+	for _, replica := range replication {
+		value := string(replica.Status)
+		if message := aws.ToString(replica.StatusMessage); message != "" && replica.Status == smtypes.StatusTypeFailed {
+			value += ": " + message
+		}
+		status[replicationStatusPrefix+aws.ToString(replica.Region)] = value
+	}
+
+	if len(replicas) == 0 {
+		delete(status, "ReplicaRegions")
+		return
+	}
+	entries := make([]string, 0, len(replicas))
+	for _, region := range sortedKeys(replicas) {
+		if keyID := replicas[region]; keyID != "" {
+			entries = append(entries, region+"="+keyID)
+		} else {
+			entries = append(entries, region)
+		}
+	}
+	status["ReplicaRegions"] = strings.Join(entries, ",")
+}
+
+// replicaRegionTypes returns the replicas of the regions, in the order of the regions
+func replicaRegionTypes(replicas map[string]string, regions []string) []smtypes.ReplicaRegionType {
+	res := make([]smtypes.ReplicaRegionType, 0, len(regions))
+	for _, region := range regions {
+		replica := smtypes.ReplicaRegionType{Region: aws.String(region)}
+		if keyID := replicas[region]; keyID != "" {
+			replica.KmsKeyId = aws.String(keyID)
+		}
+		res = append(res, replica)
+	}
+
+	return res
+}
+
+// syncReplicas replicates the secret to the regions missing from its replicas and removes the replicas
+// of the regions removed from its spec. The key of a replica cannot be changed, replicas whose key
+// changed are replaced. Replicas created outside of the provider are left alone, also in the regions
+// of the spec, and are not recorded.
+func (p *AwsProvider) syncReplicas(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, id string) error {
+	desired, err := p.replicaRegions(secret)
+	if err != nil {
+		return err
+	}
+	recorded := recordedReplicas(secret.Status.Provider)
+	if len(desired) == 0 && len(recorded) == 0 {
+		return nil
+	}
+
+	result, err := p.secretsClient.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{
+		SecretId: aws.String(id),
+	})
+	if err != nil {
+		return fmt.Errorf("describing secret %s: %w", id, err)
+	}
+	current := make(map[string]bool, len(result.ReplicationStatus))
+	for _, replica := range result.ReplicationStatus {
+		current[aws.ToString(replica.Region)] = true
+	}
+	replication := result.ReplicationStatus
+
+	remove := make([]string, 0)
+	for _, region := range sortedKeys(recorded) {
+		if keyID, ok := desired[region]; current[region] && (!ok || keyID != recorded[region]) {
+			remove = append(remove, region)
+		}
+	}
+	if len(remove) > 0 {
+		removed, err := p.secretsClient.RemoveRegionsFromReplication(ctx, &secretsmanager.RemoveRegionsFromReplicationInput{
+			SecretId:             aws.String(id),
+			RemoveReplicaRegions: remove,
+		})
+		if err != nil {
+			return fmt.Errorf("removing replica regions %s of secret %s: %w", strings.Join(remove, ","), id, err)
+		}
+		reqLogger.Info(fmt.Sprintf("Removed replica regions %s of secret %s", strings.Join(remove, ","), id))
+
+		for _, region := range remove {
+			delete(current, region)
+		}
+		replication = removed.ReplicationStatus
+	}
+
+	add := make([]string, 0)
+	for _, region := range sortedKeys(desired) {
+		if !current[region] {
+			add = append(add, region)
+		}
+	}
+	if len(add) > 0 {
+		added, err := p.secretsClient.ReplicateSecretToRegions(ctx, &secretsmanager.ReplicateSecretToRegionsInput{
+			SecretId:          aws.String(id),
+			AddReplicaRegions: replicaRegionTypes(desired, add),
+		})
+		if err != nil {
+			return fmt.Errorf("replicating secret %s to %s: %w", id, strings.Join(add, ","), err)
+		}
+		reqLogger.Info(fmt.Sprintf("Replicated secret %s to %s", id, strings.Join(add, ",")))
+
+		replication = added.ReplicationStatus
+	}
+
+	// only the replicas created by the provider are recorded, and later removed
+	owned := make(map[string]string)
+	for _, region := range add {
+		owned[region] = desired[region]
+	}
+	for region := range recorded {
+		if keyID, ok := desired[region]; ok {
+			owned[region] = keyID
+		}
+	}
+	recordReplication(secret.Status.Provider, owned, replication)
+
+	return nil
+}
+
+// removeReplicas removes the replicas created by the provider, a secret with replicas cannot be deleted
+func (p *AwsProvider) removeReplicas(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret) error {
+	regions := sortedKeys(recordedReplicas(secret.Status.Provider))
+	if len(regions) == 0 {
+		return nil
+	}
+
+	if _, err := p.secretsClient.RemoveRegionsFromReplication(ctx, &secretsmanager.RemoveRegionsFromReplicationInput{
+		SecretId:             aws.String(secret.Status.SecretName),
+		RemoveReplicaRegions: regions,
+	}); err != nil {
+		var notFoundErr *smtypes.ResourceNotFoundException
+		if !errors.As(err, &notFoundErr) {
+			return fmt.Errorf("removing replica regions %s of secret %s: %w", strings.Join(regions, ","), secret.Status.SecretName, err)
+		}
+	}
+	reqLogger.Info(fmt.Sprintf("Removed replica regions %s of secret %s", strings.Join(regions, ","), secret.Status.SecretName))
+
+	recordReplication(secret.Status.Provider, nil, nil)
+
+	return nil
+}
+
+// replicaArns returns the ARNs of the replicas of a secret, which share the name of the secret in
+// their region
+func replicaArns(secret *secretsv1alpha1.ExternalSecret, secretArn string) []string {
+	primary, err := arn.Parse(secretArn)
+	if err != nil {
+		return nil
+	}
+
+	res := make([]string, 0)
+	for _, region := range sortedKeys(recordedReplicas(secret.Status.Provider)) {
+		replica := primary
+		replica.Region = region
+		res = append(res, replica.String())
+	}
+
+	return res
+}
@@ -0,0 +1,169 @@
+package aws
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/providertest"
+)
+
+var _ = Describe("AwsProvider replication", func() {
+	var (
+		ctx      context.Context
+		fake     *fakeAWS
+		provider *AwsProvider
+		secret   *secretsv1alpha1.ExternalSecret
+	)
+
+	const replicaKeyArn = "arn:aws:kms:us-west-2:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab"
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		fake = newFakeAWS()
+		DeferCleanup(fake.Close)
+
+		provider = &AwsProvider{ClusterID: "cluster-a"}
+		Expect(provider.init(AwsProviderConfig{Endpoint: fake.server.URL}, fake.config())).To(Succeed())
+
+		secret = providertest.NewSecret("db")
+		secret.ObjectMeta = metav1.ObjectMeta{Namespace: "team-a", Name: "db"}
+		secret.Status.SecretName = "db"
+	})
+
+	It("validates the replica regions", func() {
+		for value, message := range map[string]string{
+			"us-east-1,moon":          "invalid replica region moon",
+			"us-east-1," + fakeRegion: "replica region " + fakeRegion + " is the region of the secret",
+			"us-east-1,us-east-1=x":   "replica region us-east-1 is listed twice",
+		} {
+			secret.Spec.ProviderSpec["ReplicaRegions"] = value
+			Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(MatchError(message), value)
+		}
+		Expect(fake.secret("db")).To(BeNil())
+	})
+
+	It("replicates new secrets to the regions", func() {
+		secret.Spec.ProviderSpec["ReplicaRegions"] = "us-west-2=" + replicaKeyArn + ", us-east-1"
+		Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+
+		Expect(fake.replicasOf("db")).To(Equal(map[string]string{"us-east-1": "", "us-west-2": replicaKeyArn}))
+		Expect(secret.Status.Provider).To(HaveKeyWithValue("ReplicaRegions", "us-east-1,us-west-2="+replicaKeyArn))
+		Expect(secret.Status.Provider).To(HaveKeyWithValue("ReplicationStatus.us-east-1", "InSync"))
+		Expect(secret.Status.Provider).To(HaveKeyWithValue("ReplicationStatus.us-west-2", "InSync"))
+	})
+
+	It("reports the replicas that failed", func() {
+		fake.failReplication("us-east-1", "Access denied to the KMS key")
+		secret.Spec.ProviderSpec["ReplicaRegions"] = "us-east-1"
+		Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+
+		Expect(secret.Status.Provider).To(HaveKeyWithValue("ReplicationStatus.us-east-1", "Failed: Access denied to the KMS key"))
+	})
+
+	It("adds and removes the regions on update", func() {
+		secret.Spec.ProviderSpec["ReplicaRegions"] = "us-east-1,us-west-2"
+		Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+
+		secret.Spec.ProviderSpec["ReplicaRegions"] = "us-west-2,ap-southeast-2"
+		Expect(provider.UpdateSecret(ctx, logr.Discard(), secret, "hunter3")).To(Succeed())
+
+		Expect(fake.replicasOf("db")).To(Equal(map[string]string{"ap-southeast-2": "", "us-west-2": ""}))
+		Expect(secret.Status.Provider).To(HaveKeyWithValue("ReplicaRegions", "ap-southeast-2,us-west-2"))
+		Expect(secret.Status.Provider).To(HaveKey("ReplicationStatus.ap-southeast-2"))
+		Expect(secret.Status.Provider).NotTo(HaveKey("ReplicationStatus.us-east-1"))
+
+		delete(secret.Spec.ProviderSpec, "ReplicaRegions")
+		Expect(provider.UpdateSecret(ctx, logr.Discard(), secret, "hunter3")).To(Succeed())
+
+		Expect(fake.replicasOf("db")).To(BeEmpty())
+		Expect(secret.Status.Provider).NotTo(HaveKey("ReplicaRegions"))
+		Expect(secret.Status.Provider).NotTo(HaveKey("ReplicationStatus.us-west-2"))
+	})
+
+	It("replaces the replicas whose key changed", func() {
+		secret.Spec.ProviderSpec["ReplicaRegions"] = "us-west-2"
+		Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+
+		secret.Spec.ProviderSpec["ReplicaRegions"] = "us-west-2=" + replicaKeyArn
+		Expect(provider.UpdateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+
+		Expect(fake.replicasOf("db")).To(Equal(map[string]string{"us-west-2": replicaKeyArn}))
+		Expect(secret.Status.Provider).To(HaveKeyWithValue("ReplicaRegions", "us-west-2="+replicaKeyArn))
+	})
+
+	It("leaves the replicas created outside of the provider alone", func() {
+		Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+		fake.mu.Lock()
+		fake.replicate(fake.secrets["db"], "us-east-1", "")
+		fake.mu.Unlock()
+
+		Expect(provider.UpdateSecret(ctx, logr.Discard(), secret, "hunter3")).To(Succeed())
+		secret.Spec.ProviderSpec["ReplicaRegions"] = "us-west-2"
+		Expect(provider.UpdateSecret(ctx, logr.Discard(), secret, "hunter3")).To(Succeed())
+
+		Expect(fake.replicasOf("db")).To(Equal(map[string]string{"us-east-1": "", "us-west-2": ""}))
+		Expect(secret.Status.Provider).To(HaveKeyWithValue("ReplicaRegions", "us-west-2"))
+	})
+
+	It("does not record the replicas of the regions of the spec created outside of the provider", func() {
+		Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+		fake.mu.Lock()
+		fake.replicate(fake.secrets["db"], "us-east-1", "")
+		fake.mu.Unlock()
+
+		secret.Spec.ProviderSpec["ReplicaRegions"] = "us-east-1,us-west-2"
+		Expect(provider.UpdateSecret(ctx, logr.Discard(), secret, "hunter3")).To(Succeed())
+		Expect(secret.Status.Provider).To(HaveKeyWithValue("ReplicaRegions", "us-west-2"))
+
+		delete(secret.Spec.ProviderSpec, "ReplicaRegions")
+		Expect(provider.UpdateSecret(ctx, logr.Discard(), secret, "hunter3")).To(Succeed())
+		Expect(fake.replicasOf("db")).To(Equal(map[string]string{"us-east-1": ""}))
+		Expect(secret.Status.Provider).NotTo(HaveKey("ReplicaRegions"))
+	})
+
+	It("grants the accesses the reading of the replicas", func() {
+		secret.Spec.ProviderSpec["ReplicaRegions"] = "us-east-1,us-west-2=" + replicaKeyArn
+		Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+
+		secretArn := secret.Status.Provider["SecretArn"]
+		prefix := secretArn[:len(secretArn)-6]
+		Expect(policyStatements(secret)).To(ConsistOf(
+			map[string]interface{}{
+				"Effect": "Allow",
+				"Action": []interface{}{"secretsmanager:GetSecretValue"},
+				"Resource": []interface{}{
+					prefix + "??????",
+					"arn:aws:secretsmanager:us-east-1:111122223333:secret:db-??????",
+					"arn:aws:secretsmanager:us-west-2:111122223333:secret:db-??????",
+				},
+			},
+			map[string]interface{}{
+				"Effect":   "Allow",
+				"Action":   []interface{}{"kms:Decrypt"},
+				"Resource": replicaKeyArn,
+			},
+		))
+	})
+
+	It("removes the replicas before deleting the secret", func() {
+		secret.Spec.ProviderSpec["ReplicaRegions"] = "us-east-1"
+		Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+
+		Expect(provider.DeleteSecret(ctx, logr.Discard(), secret)).To(Succeed())
+		Expect(fake.replicasOf("db")).To(BeEmpty())
+		Expect(secret.Status.Provider).NotTo(HaveKey("ReplicaRegions"))
+	})
+
+	It("is not supported by the Parameter Store backend", func() {
+		Expect(provider.init(AwsProviderConfig{Endpoint: fake.server.URL, Backend: BackendSsm}, fake.config())).To(Succeed())
+		secret.Spec.ProviderSpec["ReplicaRegions"] = "us-east-1"
+
+		Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).
+			To(MatchError("ReplicaRegions is only supported by the secretsmanager backend"))
+	})
+})
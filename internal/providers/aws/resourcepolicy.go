@@ -0,0 +1,212 @@
+package aws
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	smtypes "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/go-logr/logr"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+)
+
+// resourcePolicySidPrefix prefixes the ids of the statements of the accesses in the resource policies
+// of secrets, statements without it are left as they are
+const resourcePolicySidPrefix = "SecretsBeam"
+
+// resourcePolicySid returns the id of the statement of an access, a hash of the cluster, namespace and
+// name of the access as ids only allow alphanumeric characters
+func (p *AwsProvider) resourcePolicySid(access *secretsv1alpha1.ExternalSecretAccess) string {
+	sum := sha256.Sum256([]byte(p.ClusterID + "/" + access.Namespace + "/" + access.Name))
+	return resourcePolicySidPrefix + hex.EncodeToString(sum[:])[:16]
+}
+
+// resourcePolicyPrincipals returns the principals of the subjects of an access, sorted so the policy
+// only changes with the subjects. Service accounts have no principal of their own.
+func resourcePolicyPrincipals(access *secretsv1alpha1.ExternalSecretAccess) ([]string, error) {
+	principals := make([]string, 0)
+	for _, subject := range access.Spec.AccessSubjects {
+		if subject.ServiceAccount != nil {
+			return nil, fmt.Errorf("service account %s/%s cannot be granted in the %s access mode, only provider identifiers can",
+				subject.ServiceAccount.Namespace, subject.ServiceAccount.Name, AccessModeResourcePolicy)
+		} else if subject.ProviderIdentifier != nil {
+			if _, err := arn.Parse(subject.ProviderIdentifier.Identifier); err != nil {
+				return nil, fmt.Errorf("invalid provider identifier %s: %w", subject.ProviderIdentifier.Identifier, err)
+			}
+			principals = append(principals, subject.ProviderIdentifier.Identifier)
+		}
+	}
+	sort.Strings(principals)
+
+	return principals, nil
+}
+
+// grantResourcePolicy grants the subjects of an access the reading of the secret with a statement of
+// the resource policy of the secret, next to the statements of the other accesses of the secret. The
+// roles and associations left by the other access modes are removed.
+func (p *AwsProvider) grantResourcePolicy(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, access *secretsv1alpha1.ExternalSecretAccess) error {
+	principals, err := resourcePolicyPrincipals(access)
+	if err != nil {
+		return err
+	}
+	secretArn := secret.Status.Provider["SecretArn"]
+	if secretArn == "" {
+		return fmt.Errorf("secret %s has no ARN yet", secret.Status.SecretName)
+	}
+
+	// the statement follows the secret when it is created again under a new ARN
+	if recorded := access.Status.Provider["ResourcePolicySecretArn"]; recorded != "" && recorded != secretArn {
+		if err := p.revokeResourcePolicy(ctx, reqLogger, access); err != nil {
+			return err
+		}
+	}
+
+	if len(principals) == 0 {
+		if err := p.revokeResourcePolicy(ctx, reqLogger, access); err != nil {
+			return err
+		}
+	} else {
+		sid := p.resourcePolicySid(access)
+		if err := p.editResourcePolicy(ctx, secretArn, sid, map[string]interface{}{
+			"Sid":    sid,
+			"Effect": "Allow",
+			"Principal": map[string]interface{}{
+				"AWS": principals,
+			},
+			"Action":   "secretsmanager:GetSecretValue",
+			"Resource": "*",
+		}); err != nil {
+			return err
+		}
+		reqLogger.Info(fmt.Sprintf("Granted %d principals in the resource policy of secret %s", len(principals), secretArn))
+
+		access.Status.Provider["ResourcePolicySecretArn"] = secretArn
+		access.Status.Provider["ResourcePolicySid"] = sid
+	}
+
+	if err := p.deleteRole(ctx, reqLogger, access); err != nil {
+		return err
+	}
+
+	access.Status.Subjects = access.Spec.AccessSubjects
+
+	return nil
+}
+
+// revokeResourcePolicy removes the statement of an access from the resource policy of its secret,
+// recorded in the ResourcePolicySecretArn entry of the status
+func (p *AwsProvider) revokeResourcePolicy(ctx context.Context, reqLogger logr.Logger, access *secretsv1alpha1.ExternalSecretAccess) error {
+	secretArn := access.Status.Provider["ResourcePolicySecretArn"]
+	if secretArn == "" {
+		return nil
+	}
+
+	if err := p.editResourcePolicy(ctx, secretArn, access.Status.Provider["ResourcePolicySid"], nil); err != nil {
+		return err
+	}
+	reqLogger.Info(fmt.Sprintf("Removed statement %s from the resource policy of secret %s", access.Status.Provider["ResourcePolicySid"], secretArn))
+
+	delete(access.Status.Provider, "ResourcePolicySecretArn")
+	delete(access.Status.Provider, "ResourcePolicySid")
+
+	return nil
+}
+
+// editResourcePolicy replaces the statement of the id in the resource policy of a secret, or removes
+// it when the statement is nil. The policy is deleted once it has no statements left. Edits are
+// serialized so that accesses of the same secret do not overwrite each other.
+func (p *AwsProvider) editResourcePolicy(ctx context.Context, secretArn, sid string, statement map[string]interface{}) error {
+	p.resourcePolicyMu.Lock()
+	defer p.resourcePolicyMu.Unlock()
+
+	result, err := p.secretsClient.GetResourcePolicy(ctx, &secretsmanager.GetResourcePolicyInput{
+		SecretId: aws.String(secretArn),
+	})
+	if err != nil {
+		var notFoundErr *smtypes.ResourceNotFoundException
+		if errors.As(err, &notFoundErr) && statement == nil {
+			return nil
+		}
+		return fmt.Errorf("getting the resource policy of secret %s: %w", secretArn, err)
+	}
+
+	statements, err := documentStatements(aws.ToString(result.ResourcePolicy))
+	if err != nil {
+		return fmt.Errorf("parsing the resource policy of secret %s: %w", secretArn, err)
+	}
+	kept := make([]interface{}, 0, len(statements)+1)
+	for _, current := range statements {
+		if id, _ := current["Sid"].(string); id != sid {
+			kept = append(kept, current)
+		}
+	}
+	if statement != nil {
+		kept = append(kept, statement)
+	}
+
+	if len(kept) == 0 {
+		if result.ResourcePolicy == nil {
+			return nil
+		}
+		if _, err := p.secretsClient.DeleteResourcePolicy(ctx, &secretsmanager.DeleteResourcePolicyInput{
+			SecretId: aws.String(secretArn),
+		}); err != nil {
+			return fmt.Errorf("deleting the resource policy of secret %s: %w", secretArn, err)
+		}
+		return nil
+	}
+
+	policy, err := json.Marshal(map[string]interface{}{
+		"Version":   "2012-10-17",
+		"Statement": kept,
+	})
+	if err != nil {
+		return fmt.Errorf("marshalling the resource policy of secret %s: %w", secretArn, err)
+	}
+	if _, err := p.secretsClient.PutResourcePolicy(ctx, &secretsmanager.PutResourcePolicyInput{
+		SecretId:          aws.String(secretArn),
+		ResourcePolicy:    aws.String(string(policy)),
+		BlockPublicPolicy: aws.Bool(true),
+	}); err != nil {
+		return fmt.Errorf("putting the resource policy of secret %s: %w", secretArn, err)
+	}
+
+	return nil
+}
+
+// documentStatements returns the statements of a policy document, which holds either a list of
+// statements or a single one
+func documentStatements(document string) ([]map[string]interface{}, error) {
+	if document == "" {
+		return nil, nil
+	}
+
+	policy := struct {
+		Statement json.RawMessage
+	}{}
+	if err := json.Unmarshal([]byte(document), &policy); err != nil {
+		return nil, err
+	}
+	if len(policy.Statement) == 0 {
+		return nil, nil
+	}
+
+	statements := make([]map[string]interface{}, 0)
+	if err := json.Unmarshal(policy.Statement, &statements); err == nil {
+		return statements, nil
+	}
+	statement := make(map[string]interface{})
+	if err := json.Unmarshal(policy.Statement, &statement); err != nil {
+		return nil, err
+	}
+
+	return []map[string]interface{}{statement}, nil
+}
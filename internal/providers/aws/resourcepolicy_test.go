@@ -0,0 +1,168 @@
+package aws
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/providertest"
+)
+
+var _ = Describe("AwsProvider resource policies", func() {
+	var (
+		ctx      context.Context
+		fake     *fakeAWS
+		provider *AwsProvider
+		secret   *secretsv1alpha1.ExternalSecret
+	)
+
+	const (
+		ciArn     = "arn:aws:iam::444455556666:role/ci"
+		deployArn = "arn:aws:iam::444455556666:role/deploy"
+		auditArn  = "arn:aws:iam::777788889999:root"
+	)
+
+	// foreignStatement is a statement of the resource policy managed outside of the provider
+	foreignStatement := map[string]interface{}{
+		"Sid":       "Audit",
+		"Effect":    "Allow",
+		"Principal": map[string]interface{}{"AWS": auditArn},
+		"Action":    "secretsmanager:DescribeSecret",
+		"Resource":  "*",
+	}
+
+	newProvider := func(accessMode string) *AwsProvider {
+		provider := &AwsProvider{ClusterID: "cluster-a"}
+		Expect(provider.init(AwsProviderConfig{
+			AccessMode:      accessMode,
+			Endpoint:        fake.server.URL,
+			OidcProviderArn: fakeOidcProviderArn,
+		}, fake.config())).To(Succeed())
+
+		return provider
+	}
+
+	statementOf := func(access *secretsv1alpha1.ExternalSecretAccess, principals ...interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"Sid":       provider.resourcePolicySid(access),
+			"Effect":    "Allow",
+			"Principal": map[string]interface{}{"AWS": principals},
+			"Action":    "secretsmanager:GetSecretValue",
+			"Resource":  "*",
+		}
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		fake = newFakeAWS()
+		DeferCleanup(fake.Close)
+
+		provider = newProvider(AccessModeResourcePolicy)
+		secret = providertest.NewSecret("db")
+		secret.Status.SecretName = "db"
+		Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+	})
+
+	It("validates the config", func() {
+		Expect((&AwsProvider{}).init(AwsProviderConfig{AccessMode: AccessModeResourcePolicy, Backend: BackendSsm}, fake.config())).
+			To(MatchError(ContainSubstring("the resourcePolicy access mode requires the secretsmanager backend")))
+	})
+
+	It("grants the principals in the resource policy of the secret without a role", func() {
+		access := providertest.NewAccess("reader", principalSubject(deployArn), principalSubject(ciArn))
+		Expect(provider.CreateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+
+		Expect(fake.resourcePolicyOf("db")).To(ConsistOf(statementOf(access, ciArn, deployArn)))
+		Expect(access.Status.Provider).To(Equal(map[string]string{
+			"ResourcePolicySecretArn": secret.Status.Provider["SecretArn"],
+			"ResourcePolicySid":       provider.resourcePolicySid(access),
+		}))
+		Expect(fake.roles).To(BeEmpty())
+		Expect(fake.policies).To(BeEmpty())
+	})
+
+	It("merges the statements of the accesses of the secret", func() {
+		fake.secret("db").policy = `{"Version":"2012-10-17","Statement":{"Sid":"Audit","Effect":"Allow","Principal":{"AWS":"` + auditArn + `"},"Action":"secretsmanager:DescribeSecret","Resource":"*"}}`
+
+		reader := providertest.NewAccess("reader", principalSubject(ciArn))
+		Expect(provider.CreateAccess(ctx, logr.Discard(), secret, reader)).To(Succeed())
+		deployer := providertest.NewAccess("deployer", principalSubject(deployArn))
+		Expect(provider.CreateAccess(ctx, logr.Discard(), secret, deployer)).To(Succeed())
+
+		Expect(fake.resourcePolicyOf("db")).To(ConsistOf(
+			foreignStatement,
+			statementOf(reader, ciArn),
+			statementOf(deployer, deployArn),
+		))
+
+		reader.Spec.AccessSubjects = append(reader.Spec.AccessSubjects, principalSubject(deployArn))
+		Expect(provider.UpdateAccess(ctx, logr.Discard(), secret, reader)).To(Succeed())
+		Expect(fake.resourcePolicyOf("db")).To(ConsistOf(
+			foreignStatement,
+			statementOf(reader, ciArn, deployArn),
+			statementOf(deployer, deployArn),
+		))
+
+		Expect(provider.DeleteAccess(ctx, logr.Discard(), reader)).To(Succeed())
+		Expect(provider.DeleteAccess(ctx, logr.Discard(), deployer)).To(Succeed())
+		Expect(fake.resourcePolicyOf("db")).To(ConsistOf(foreignStatement), "statements of others are kept")
+	})
+
+	It("deletes the resource policy with the last statement", func() {
+		access := providertest.NewAccess("reader", principalSubject(ciArn))
+		Expect(provider.CreateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+
+		Expect(provider.DeleteAccess(ctx, logr.Discard(), access)).To(Succeed())
+		Expect(fake.secret("db").policy).To(BeEmpty())
+		Expect(access.Status.Provider).To(BeEmpty())
+	})
+
+	It("ignores secrets deleted before their accesses", func() {
+		access := providertest.NewAccess("reader", principalSubject(ciArn))
+		Expect(provider.CreateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+		delete(fake.secrets, "db")
+
+		Expect(provider.DeleteAccess(ctx, logr.Discard(), access)).To(Succeed())
+	})
+
+	It("moves the statement to a secret created again", func() {
+		access := providertest.NewAccess("reader", principalSubject(ciArn))
+		Expect(provider.CreateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+
+		delete(fake.secrets, "db")
+		Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+		Expect(provider.UpdateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+
+		Expect(fake.resourcePolicyOf("db")).To(ConsistOf(statementOf(access, ciArn)))
+		Expect(access.Status.Provider).To(HaveKeyWithValue("ResourcePolicySecretArn", secret.Status.Provider["SecretArn"]))
+	})
+
+	It("rejects service account subjects", func() {
+		access := providertest.NewAccess("reader", providertest.ServiceAccountSubject("team-a", "api"))
+		Expect(provider.CreateAccess(ctx, logr.Discard(), secret, access)).
+			To(MatchError(ContainSubstring("service account team-a/api cannot be granted in the resourcePolicy access mode")))
+	})
+
+	It("switches the accesses between roles and resource policies", func() {
+		irsa := newProvider(AccessModeIrsa)
+		access := providertest.NewAccess("reader", principalSubject(ciArn))
+		Expect(irsa.CreateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+		Expect(fake.role("secretsbeam-access-team-a-reader")).NotTo(BeNil())
+
+		Expect(provider.UpdateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+		Expect(fake.role("secretsbeam-access-team-a-reader")).To(BeNil())
+		Expect(fake.policies).To(BeEmpty())
+		Expect(fake.resourcePolicyOf("db")).To(ConsistOf(statementOf(access, ciArn)))
+		Expect(access.Status.Provider).NotTo(HaveKey("RoleArn"))
+		Expect(access.Status.Provider).NotTo(HaveKey("ServiceAccountAnnotation"))
+
+		Expect(irsa.UpdateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+		Expect(fake.role("secretsbeam-access-team-a-reader")).NotTo(BeNil())
+		Expect(fake.secret("db").policy).To(BeEmpty())
+		Expect(access.Status.Provider).NotTo(HaveKey("ResourcePolicySid"))
+		Expect(access.Status.Provider).To(HaveKey("RoleArn"))
+	})
+})
@@ -12,7 +12,21 @@ import (
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// defaultSecretsManagerKey is the AWS managed key of Secrets Manager
+const defaultSecretsManagerKey = "alias/aws/secretsmanager"
+
 func (p *AwsProvider) DeleteSecret(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret) error {
+	if p.Backend == BackendSsm {
+		return p.deleteParameter(ctx, reqLogger, secret)
+	}
+
+	if err := p.claimSecret(ctx, secret.Status.SecretName, secret, nil, nil); err != nil {
+		return err
+	}
+	if err := p.removeReplicas(ctx, reqLogger, secret); err != nil {
+		return err
+	}
+
 	reqLogger.Info("Successfully finalized Secret")
 
 	input := &secretsmanager.DeleteSecretInput{
@@ -28,6 +42,10 @@ func (p *AwsProvider) DeleteSecret(ctx context.Context, reqLogger logr.Logger, s
 		return fmt.Errorf("failed to delete secret: %v", err)
 	}
 
+	if err := p.deleteSecretKey(ctx, reqLogger, secret); err != nil {
+		return err
+	}
+
 	if secret.Spec.RecoveryWindow > 0 {
 		t := v1.NewTime(*result.DeletionDate)
 		secret.Status.DeletionDate = &t
@@ -38,14 +56,31 @@ func (p *AwsProvider) DeleteSecret(ctx context.Context, reqLogger logr.Logger, s
 }
 
 func (p *AwsProvider) CreateSecret(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, value string) error {
+	if p.Backend == BackendSsm {
+		return p.createParameter(ctx, reqLogger, secret, value)
+	}
+
 	input := &secretsmanager.CreateSecretInput{
 		Name:         secret.Spec.ExternalName,
 		SecretString: aws.String(value),
 	}
+	tags := p.tagsOf(secret, secret.Spec.Tags)
+	input.Tags = secretsManagerTags(tags, sortedKeys(tags))
 
-	if val, ok := secret.Spec.ProviderSpec["KmsKeyArn"]; ok {
-		input.KmsKeyId = aws.String(val)
-		secret.Status.Provider["KmsKeyArn"] = val
+	key, err := p.keyOf(ctx, reqLogger, secret)
+	if err != nil {
+		return err
+	}
+	if key.id != "" {
+		input.KmsKeyId = aws.String(key.id)
+	}
+
+	replicas, err := p.replicaRegions(secret)
+	if err != nil {
+		return err
+	}
+	if len(replicas) > 0 {
+		input.AddReplicaRegions = replicaRegionTypes(replicas, sortedKeys(replicas))
 	}
 
 	result, err := p.secretsClient.CreateSecret(ctx, input)
@@ -55,11 +90,18 @@ func (p *AwsProvider) CreateSecret(ctx context.Context, reqLogger logr.Logger, s
 	}
 
 	secret.Status.Provider["SecretArn"] = aws.ToString(result.ARN)
+	key.record(secret.Status.Provider)
+	recordReplication(secret.Status.Provider, replicas, result.ReplicationStatus)
+	recordTags(secret.Status.Provider, secret.Spec.Tags)
 
 	return nil
 }
 
 func (p *AwsProvider) GetSecretLastChangedDate(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret) (*time.Time, error) {
+	if p.Backend == BackendSsm {
+		return p.getParameterLastChangedDate(ctx, secret)
+	}
+
 	input := &secretsmanager.DescribeSecretInput{
 		SecretId: aws.String(secret.Status.SecretName),
 	}
@@ -73,17 +115,43 @@ func (p *AwsProvider) GetSecretLastChangedDate(ctx context.Context, reqLogger lo
 }
 
 func (p *AwsProvider) UpdateSecret(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, value string) error {
+	if p.Backend == BackendSsm {
+		return p.updateParameter(ctx, reqLogger, secret, value)
+	}
+
+	if err := p.claimSecret(ctx, aws.ToString(secret.Spec.ExternalName), secret, p.tagsOf(secret, secret.Spec.Tags), removedTags(secret.Status.Provider, secret.Spec.Tags)); err != nil {
+		return err
+	}
+	recordTags(secret.Status.Provider, secret.Spec.Tags)
+
 	updateInput := &secretsmanager.UpdateSecretInput{
 		SecretId:     secret.Spec.ExternalName,
 		SecretString: aws.String(value),
 	}
 
+	// the new version is encrypted with the new key, previous versions keep the key they were written with
+	key, err := p.keyOf(ctx, reqLogger, secret)
+	if err != nil {
+		return err
+	}
+	changed := key.id != secret.Status.Provider["KmsKeyArn"]
+	if changed {
+		updateInput.KmsKeyId = aws.String(key.id)
+		if key.id == "" {
+			updateInput.KmsKeyId = aws.String(defaultSecretsManagerKey)
+		}
+	}
+
 	result, err := p.secretsClient.UpdateSecret(ctx, updateInput)
 	if err != nil {
 		return fmt.Errorf("failed to update secret: %v", err)
 	}
 
 	secret.Status.Provider["SecretArn"] = aws.ToString(result.ARN)
+	if changed {
+		reqLogger.Info(fmt.Sprintf("Encrypted secret %s with KMS key %s", aws.ToString(updateInput.SecretId), aws.ToString(updateInput.KmsKeyId)))
+	}
+	key.record(secret.Status.Provider)
 
-	return nil
+	return p.syncReplicas(ctx, reqLogger, secret, aws.ToString(secret.Spec.ExternalName))
 }
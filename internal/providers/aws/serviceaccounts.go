@@ -0,0 +1,363 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+)
+
+// serviceAccountAnnotationPrefix prefixes the entries of the status holding the annotation of a single
+// service account subject
+const serviceAccountAnnotationPrefix = "ServiceAccountAnnotation."
+
+// serviceAccountRoles returns the roles of the service accounts granted by an access, recorded in the
+// ServiceAccountRoles entry of its status by namespace/name of their service account
+func serviceAccountRoles(access *secretsv1alpha1.ExternalSecretAccess) map[string]string {
+	roles := make(map[string]string)
+	for _, entry := range strings.Split(access.Status.Provider["ServiceAccountRoles"], ",") {
+		if serviceAccount, roleArn, ok := strings.Cut(entry, "="); ok {
+			roles[serviceAccount] = roleArn
+		}
+	}
+
+	return roles
+}
+
+// setServiceAccountRoles records the roles in the status of the access, with the name of the inline
+// policy of the access in the roles. In the irsa mode, the annotation of each service account with its
+// role is recorded in a ServiceAccountAnnotation.<namespace>/<name> entry.
+func (p *AwsProvider) setServiceAccountRoles(access *secretsv1alpha1.ExternalSecretAccess, policyName string, roles map[string]string) {
+	for entry := range access.Status.Provider {
+		if strings.HasPrefix(entry, serviceAccountAnnotationPrefix) {
+			delete(access.Status.Provider, entry)
+		}
+	}
+	if p.AccessMode != AccessModePodIdentity {
+		for serviceAccount, roleArn := range roles {
+			access.Status.Provider[serviceAccountAnnotationPrefix+serviceAccount] = fmt.Sprintf("eks.amazonaws.com/role-arn=%s", roleArn)
+		}
+	}
+
+	if len(roles) == 0 {
+		delete(access.Status.Provider, "ServiceAccountRoles")
+		delete(access.Status.Provider, "RolePolicyName")
+		return
+	}
+
+	entries := make([]string, 0, len(roles))
+	for _, serviceAccount := range sortedKeys(roles) {
+		entries = append(entries, serviceAccount+"="+roles[serviceAccount])
+	}
+	access.Status.Provider["ServiceAccountRoles"] = strings.Join(entries, ",")
+	access.Status.Provider["RolePolicyName"] = policyName
+}
+
+// serviceAccountOwner is the owner of the role of a service account, the role outlives the accesses
+// sharing it
+func serviceAccountOwner(serviceAccount string) metav1.Object {
+	namespace, name, _ := strings.Cut(serviceAccount, "/")
+	return &metav1.ObjectMeta{Namespace: namespace, Name: name}
+}
+
+// roleNameOf returns the name of a role from its ARN, which holds the path of the role
+func roleNameOf(roleArn string) string {
+	return roleArn[strings.LastIndex(roleArn, "/")+1:]
+}
+
+// grantServiceAccountRoles adds the policy of an access to the roles of its service account subjects as
+// an inline policy, the roles are created for the first access granting their service account. Its
+// provider identifier subjects keep assuming the role of the access. The inline policies of the
+// service accounts removed from the access are deleted, with the roles left without inline policies.
+func (p *AwsProvider) grantServiceAccountRoles(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, access *secretsv1alpha1.ExternalSecretAccess) error {
+	principals := make([]secretsv1alpha1.SecretAccessSubject, 0)
+	desired := make(map[string]*secretsv1alpha1.SecretAccessSubjectServiceAccount)
+	for _, subject := range access.Spec.AccessSubjects {
+		if subject.ServiceAccount != nil {
+			desired[subject.ServiceAccount.Namespace+"/"+subject.ServiceAccount.Name] = subject.ServiceAccount
+		} else if subject.ProviderIdentifier != nil {
+			principals = append(principals, subject)
+		}
+	}
+
+	if len(principals) > 0 {
+		// the role of the copy only trusts the provider identifiers, the resources it records are kept
+		// in the status of the access even when the role fails to be created
+		scoped := access.DeepCopy()
+		scoped.Spec.AccessSubjects = principals
+		err := p.createRole(ctx, reqLogger, secret, scoped)
+		access.Status.Provider = scoped.Status.Provider
+		if err != nil {
+			return err
+		}
+		delete(access.Status.Provider, "ServiceAccountAnnotation")
+	} else {
+		if err := p.deleteRole(ctx, reqLogger, access); err != nil {
+			return err
+		}
+		if err := p.revokeResourcePolicy(ctx, reqLogger, access); err != nil {
+			return err
+		}
+	}
+
+	policyDocumentJSON, err := getSecretAccessPolicy(secret)
+	if err != nil {
+		return fmt.Errorf("failed to build policy document: %w", err)
+	}
+	policyName := access.Status.Provider["RolePolicyName"]
+	if policyName == "" {
+		if policyName, err = p.resourceName(access); err != nil {
+			return err
+		}
+	}
+
+	p.serviceAccountRolesMu.Lock()
+	defer p.serviceAccountRolesMu.Unlock()
+
+	roles := serviceAccountRoles(access)
+	defer func() {
+		p.setServiceAccountRoles(access, policyName, roles)
+	}()
+
+	serviceAccounts := make([]string, 0, len(desired))
+	for serviceAccount := range desired {
+		serviceAccounts = append(serviceAccounts, serviceAccount)
+	}
+	sort.Strings(serviceAccounts)
+
+	for _, serviceAccount := range serviceAccounts {
+		// roles keep their name when the template changes
+		roleName := roleNameOf(roles[serviceAccount])
+		if roles[serviceAccount] == "" {
+			if roleName, err = p.serviceAccountRoleName(desired[serviceAccount]); err != nil {
+				return err
+			}
+		}
+
+		roleArn, err := p.ensureServiceAccountRole(ctx, reqLogger, desired[serviceAccount], roleName)
+		if err != nil {
+			return err
+		}
+
+		if _, err := p.iamClient.PutRolePolicy(ctx, &iam.PutRolePolicyInput{
+			RoleName:       aws.String(roleName),
+			PolicyName:     aws.String(policyName),
+			PolicyDocument: aws.String(policyDocumentJSON),
+		}); err != nil {
+			return fmt.Errorf("putting policy %s in role %s: %w", policyName, roleName, err)
+		}
+		if _, ok := roles[serviceAccount]; !ok {
+			reqLogger.Info(fmt.Sprintf("Added policy %s to role %s of service account %s", policyName, roleName, serviceAccount))
+		}
+		roles[serviceAccount] = roleArn
+	}
+
+	for _, serviceAccount := range sortedKeys(roles) {
+		if desired[serviceAccount] != nil {
+			continue
+		}
+
+		if err := p.releaseServiceAccountRole(ctx, reqLogger, serviceAccount, roles[serviceAccount], policyName); err != nil {
+			return err
+		}
+		delete(roles, serviceAccount)
+	}
+
+	access.Status.Subjects = access.Spec.AccessSubjects
+
+	return nil
+}
+
+// revokeServiceAccountRoles removes the policy of an access from the roles of its service accounts,
+// left by the serviceAccount role scope
+func (p *AwsProvider) revokeServiceAccountRoles(ctx context.Context, reqLogger logr.Logger, access *secretsv1alpha1.ExternalSecretAccess) error {
+	roles := serviceAccountRoles(access)
+	if len(roles) == 0 {
+		return nil
+	}
+
+	p.serviceAccountRolesMu.Lock()
+	defer p.serviceAccountRolesMu.Unlock()
+
+	policyName := access.Status.Provider["RolePolicyName"]
+	defer func() {
+		p.setServiceAccountRoles(access, policyName, roles)
+	}()
+
+	for _, serviceAccount := range sortedKeys(roles) {
+		if err := p.releaseServiceAccountRole(ctx, reqLogger, serviceAccount, roles[serviceAccount], policyName); err != nil {
+			return err
+		}
+		delete(roles, serviceAccount)
+	}
+
+	return nil
+}
+
+// ensureServiceAccountRole creates the role of a service account, trusted by the service account
+// alone, or applies the settings of the provider to the existing role. It returns the ARN of the role.
+func (p *AwsProvider) ensureServiceAccountRole(ctx context.Context, reqLogger logr.Logger, serviceAccount *secretsv1alpha1.SecretAccessSubjectServiceAccount, roleName string) (string, error) {
+	owner := serviceAccountOwner(serviceAccount.Namespace + "/" + serviceAccount.Name)
+	tags := p.tagsOf(owner, nil)
+
+	assumeRolePolicyDocumentJSON, err := p.getAssumePolicyDocument(&secretsv1alpha1.ExternalSecretAccess{
+		Spec: secretsv1alpha1.ExternalSecretAccessSpec{
+			AccessSubjects: []secretsv1alpha1.SecretAccessSubject{{ServiceAccount: serviceAccount}},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("marshalling assume role policy document: %w", err)
+	}
+
+	var roleArn string
+	if role, err := p.iamClient.GetRole(ctx, &iam.GetRoleInput{
+		RoleName: aws.String(roleName),
+	}); err == nil {
+		if err := p.claimRole(ctx, roleName, owner, tags, nil); err != nil {
+			return "", err
+		}
+		if _, err := p.iamClient.UpdateAssumeRolePolicy(ctx, &iam.UpdateAssumeRolePolicyInput{
+			RoleName:       aws.String(roleName),
+			PolicyDocument: aws.String(assumeRolePolicyDocumentJSON),
+		}); err != nil {
+			return "", fmt.Errorf("updating role %s: %w", roleName, err)
+		}
+		if err := p.configureRole(ctx, roleName); err != nil {
+			return "", err
+		}
+		roleArn = aws.ToString(role.Role.Arn)
+	} else if isNoSuchEntity(err) {
+		created, err := p.iamClient.CreateRole(ctx, p.createRoleInput(roleName, assumeRolePolicyDocumentJSON, tags))
+		if err != nil {
+			return "", fmt.Errorf("creating role %s: %w", roleName, err)
+		}
+		roleArn = aws.ToString(created.Role.Arn)
+		reqLogger.Info(fmt.Sprintf("Created role %s of service account %s/%s", roleArn, serviceAccount.Namespace, serviceAccount.Name))
+	} else {
+		return "", fmt.Errorf("getting role %s: %w", roleName, err)
+	}
+
+	for _, policyArn := range p.managedPolicyArns {
+		if _, err := p.iamClient.AttachRolePolicy(ctx, &iam.AttachRolePolicyInput{
+			RoleName:  aws.String(roleName),
+			PolicyArn: aws.String(policyArn),
+		}); err != nil {
+			return "", fmt.Errorf("attaching managed policy %s to role %s: %w", policyArn, roleName, err)
+		}
+	}
+
+	if p.AccessMode == AccessModePodIdentity {
+		if _, err := p.associate(ctx, serviceAccount, roleArn, tags); err != nil {
+			return "", fmt.Errorf("associating %s/%s with role %s: %w", serviceAccount.Namespace, serviceAccount.Name, roleArn, err)
+		}
+	}
+
+	return roleArn, nil
+}
+
+// releaseServiceAccountRole deletes the inline policy of an access from the role of a service account.
+// The role is deleted with its last inline policy, once no access grants the service account.
+func (p *AwsProvider) releaseServiceAccountRole(ctx context.Context, reqLogger logr.Logger, serviceAccount, roleArn, policyName string) error {
+	roleName := roleNameOf(roleArn)
+	owner := serviceAccountOwner(serviceAccount)
+	if err := p.claimRole(ctx, roleName, owner, nil, nil); err != nil {
+		return err
+	}
+
+	if _, err := p.iamClient.DeleteRolePolicy(ctx, &iam.DeleteRolePolicyInput{
+		RoleName:   aws.String(roleName),
+		PolicyName: aws.String(policyName),
+	}); err != nil && !isNoSuchEntity(err) {
+		return fmt.Errorf("deleting policy %s from role %s: %w", policyName, roleName, err)
+	}
+	reqLogger.Info(fmt.Sprintf("Removed policy %s from role %s of service account %s", policyName, roleName, serviceAccount))
+
+	policies, err := p.iamClient.ListRolePolicies(ctx, &iam.ListRolePoliciesInput{
+		RoleName: aws.String(roleName),
+	})
+	if err != nil {
+		if isNoSuchEntity(err) {
+			return nil
+		}
+		return fmt.Errorf("listing the policies of role %s: %w", roleName, err)
+	}
+	if len(policies.PolicyNames) > 0 {
+		return nil
+	}
+
+	if err := p.dissociate(ctx, reqLogger, owner, roleArn); err != nil {
+		return err
+	}
+
+	attached, err := p.iamClient.ListAttachedRolePolicies(ctx, &iam.ListAttachedRolePoliciesInput{
+		RoleName: aws.String(roleName),
+	})
+	if err != nil {
+		return fmt.Errorf("listing the managed policies of role %s: %w", roleName, err)
+	}
+	for _, policy := range attached.AttachedPolicies {
+		if _, err := p.iamClient.DetachRolePolicy(ctx, &iam.DetachRolePolicyInput{
+			RoleName:  aws.String(roleName),
+			PolicyArn: policy.PolicyArn,
+		}); err != nil && !isNoSuchEntity(err) {
+			return fmt.Errorf("detaching managed policy %s from role %s: %w", aws.ToString(policy.PolicyArn), roleName, err)
+		}
+	}
+
+	if _, err := p.iamClient.DeleteRole(ctx, &iam.DeleteRoleInput{
+		RoleName: aws.String(roleName),
+	}); err != nil && !isNoSuchEntity(err) {
+		return fmt.Errorf("failed to delete role %s: %w", roleName, err)
+	}
+	reqLogger.Info(fmt.Sprintf("Deleted role %s of service account %s", roleName, serviceAccount))
+
+	return nil
+}
+
+// dissociate deletes the pod identity associations of the service account with the role, when the
+// provider has a cluster
+func (p *AwsProvider) dissociate(ctx context.Context, reqLogger logr.Logger, serviceAccount metav1.Object, roleArn string) error {
+	if p.ClusterName == "" {
+		return nil
+	}
+
+	existing, err := p.eksClient.ListPodIdentityAssociations(ctx, &eks.ListPodIdentityAssociationsInput{
+		ClusterName:    aws.String(p.ClusterName),
+		Namespace:      aws.String(serviceAccount.GetNamespace()),
+		ServiceAccount: aws.String(serviceAccount.GetName()),
+	})
+	if err != nil {
+		return fmt.Errorf("listing the associations of %s/%s: %w", serviceAccount.GetNamespace(), serviceAccount.GetName(), err)
+	}
+
+	for _, summary := range existing.Associations {
+		association, err := p.eksClient.DescribePodIdentityAssociation(ctx, &eks.DescribePodIdentityAssociationInput{
+			ClusterName:   aws.String(p.ClusterName),
+			AssociationId: summary.AssociationId,
+		})
+		if err != nil {
+			return fmt.Errorf("describing association %s: %w", aws.ToString(summary.AssociationId), err)
+		}
+		if aws.ToString(association.Association.RoleArn) != roleArn {
+			continue
+		}
+
+		if _, err := p.eksClient.DeletePodIdentityAssociation(ctx, &eks.DeletePodIdentityAssociationInput{
+			ClusterName:   aws.String(p.ClusterName),
+			AssociationId: summary.AssociationId,
+		}); err != nil && !isResourceNotFound(err) {
+			return fmt.Errorf("deleting pod identity association %s: %w", aws.ToString(summary.AssociationId), err)
+		}
+		reqLogger.Info(fmt.Sprintf("Deleted pod identity association %s of %s/%s", aws.ToString(summary.AssociationId), serviceAccount.GetNamespace(), serviceAccount.GetName()))
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package aws
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/providertest"
+)
+
+var _ = Describe("AwsProvider service account roles", func() {
+	var (
+		ctx      context.Context
+		fake     *fakeAWS
+		provider *AwsProvider
+		db       *secretsv1alpha1.ExternalSecret
+		api      *secretsv1alpha1.ExternalSecret
+	)
+
+	const (
+		apiRole    = "secretsbeam-sa-team-a-api"
+		apiRoleArn = "arn:aws:iam::111122223333:role/" + apiRole
+		ciArn      = "arn:aws:iam::444455556666:role/ci"
+	)
+
+	newProvider := func(config AwsProviderConfig) *AwsProvider {
+		config.Endpoint = fake.server.URL
+		config.OidcProviderArn = fakeOidcProviderArn
+		provider := &AwsProvider{ClusterID: "cluster-a"}
+		Expect(provider.init(config, fake.config())).To(Succeed())
+
+		return provider
+	}
+
+	newSharedSecret := func(name string) *secretsv1alpha1.ExternalSecret {
+		secret := providertest.NewSecret(name)
+		secret.Status.SecretName = name
+		Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+
+		return secret
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		fake = newFakeAWS()
+		DeferCleanup(fake.Close)
+
+		provider = newProvider(AwsProviderConfig{RoleScope: RoleScopeServiceAccount})
+		db = newSharedSecret("db")
+		api = newSharedSecret("api")
+	})
+
+	It("validates the config", func() {
+		for _, invalid := range []struct {
+			config  AwsProviderConfig
+			message string
+		}{
+			{AwsProviderConfig{RoleScope: "namespace"}, "unsupported roleScope namespace"},
+			{AwsProviderConfig{RoleScope: RoleScopeServiceAccount, AccessMode: AccessModeResourcePolicy}, "the serviceAccount role scope is not supported by the resourcePolicy access mode"},
+			{AwsProviderConfig{ServiceAccountRoleNameTemplate: "{{ .Team }}"}, "invalid serviceAccountRoleNameTemplate"},
+		} {
+			Expect((&AwsProvider{}).init(invalid.config, fake.config())).To(MatchError(ContainSubstring(invalid.message)), invalid.message)
+		}
+	})
+
+	It("shares the role of a service account between its accesses", func() {
+		dbReader := providertest.NewAccess("db-reader", providertest.ServiceAccountSubject("team-a", "api"))
+		Expect(provider.CreateAccess(ctx, logr.Discard(), db, dbReader)).To(Succeed())
+		apiReader := providertest.NewAccess("api-reader", providertest.ServiceAccountSubject("team-a", "api"))
+		Expect(provider.CreateAccess(ctx, logr.Discard(), api, apiReader)).To(Succeed())
+
+		Expect(fake.roles).To(HaveLen(1))
+		Expect(fake.policies).To(BeEmpty())
+		role := fake.role(apiRole)
+		Expect(role).NotTo(BeNil())
+		Expect(role.inlinePolicies).To(HaveLen(2))
+		Expect(role.inlinePolicies).To(HaveKey("secretsbeam-access-team-a-db-reader"))
+		Expect(role.inlinePolicies).To(HaveKey("secretsbeam-access-team-a-api-reader"))
+		Expect(role.tags).To(HaveKeyWithValue(TagNamespace, "team-a"))
+		Expect(role.tags).To(HaveKeyWithValue(TagName, "api"))
+
+		statements := trustStatements(fake, apiRole)
+		Expect(statements).To(HaveLen(1))
+		Expect(statements[0]).To(HaveKeyWithValue("Condition", map[string]interface{}{
+			"StringEquals": map[string]interface{}{
+				"oidc.eks.eu-west-1.amazonaws.com/id/EXAMPLE:sub": []interface{}{"system:serviceaccount:team-a:api"},
+			},
+		}))
+
+		for _, access := range []*secretsv1alpha1.ExternalSecretAccess{dbReader, apiReader} {
+			Expect(access.Status.Provider).To(Equal(map[string]string{
+				"ServiceAccountRoles":                 "team-a/api=" + apiRoleArn,
+				"RolePolicyName":                      "secretsbeam-access-team-a-" + access.Name,
+				"ServiceAccountAnnotation.team-a/api": "eks.amazonaws.com/role-arn=" + apiRoleArn,
+			}))
+		}
+	})
+
+	It("deletes the role with the last access of the service account", func() {
+		fake.addPolicy("arn:aws:iam::aws:policy/ReadOnlyAccess")
+		provider = newProvider(AwsProviderConfig{RoleScope: RoleScopeServiceAccount, ManagedPolicyArns: "arn:aws:iam::aws:policy/ReadOnlyAccess"})
+		dbReader := providertest.NewAccess("db-reader", providertest.ServiceAccountSubject("team-a", "api"))
+		Expect(provider.CreateAccess(ctx, logr.Discard(), db, dbReader)).To(Succeed())
+		apiReader := providertest.NewAccess("api-reader", providertest.ServiceAccountSubject("team-a", "api"))
+		Expect(provider.CreateAccess(ctx, logr.Discard(), api, apiReader)).To(Succeed())
+		Expect(fake.role(apiRole).policies()).To(ConsistOf("arn:aws:iam::aws:policy/ReadOnlyAccess"))
+
+		Expect(provider.DeleteAccess(ctx, logr.Discard(), dbReader)).To(Succeed())
+		Expect(fake.role(apiRole).inlinePolicies).To(HaveLen(1))
+		Expect(fake.role(apiRole).inlinePolicies).To(HaveKey("secretsbeam-access-team-a-api-reader"))
+
+		Expect(provider.DeleteAccess(ctx, logr.Discard(), apiReader)).To(Succeed())
+		Expect(fake.role(apiRole)).To(BeNil())
+		Expect(apiReader.Status.Provider).To(BeEmpty())
+	})
+
+	It("removes the policy from the service accounts removed from the access", func() {
+		access := providertest.NewAccess("reader", providertest.ServiceAccountSubject("team-a", "api"), providertest.ServiceAccountSubject("team-a", "worker"))
+		Expect(provider.CreateAccess(ctx, logr.Discard(), db, access)).To(Succeed())
+		other := providertest.NewAccess("api-reader", providertest.ServiceAccountSubject("team-a", "api"))
+		Expect(provider.CreateAccess(ctx, logr.Discard(), api, other)).To(Succeed())
+
+		access.Spec.AccessSubjects = []secretsv1alpha1.SecretAccessSubject{providertest.ServiceAccountSubject("team-a", "worker")}
+		Expect(provider.UpdateAccess(ctx, logr.Discard(), db, access)).To(Succeed())
+
+		Expect(fake.role(apiRole).inlinePolicies).NotTo(HaveKey("secretsbeam-access-team-a-reader"))
+		Expect(fake.role("secretsbeam-sa-team-a-worker").inlinePolicies).To(HaveKey("secretsbeam-access-team-a-reader"))
+		Expect(access.Status.Provider).To(HaveKeyWithValue("ServiceAccountRoles", "team-a/worker=arn:aws:iam::111122223333:role/secretsbeam-sa-team-a-worker"))
+		Expect(access.Status.Provider).To(HaveKeyWithValue("ServiceAccountAnnotation.team-a/worker", "eks.amazonaws.com/role-arn=arn:aws:iam::111122223333:role/secretsbeam-sa-team-a-worker"))
+		Expect(access.Status.Provider).NotTo(HaveKey("ServiceAccountAnnotation.team-a/api"))
+	})
+
+	It("keeps the role of the access for the provider identifiers", func() {
+		access := providertest.NewAccess("reader", providertest.ServiceAccountSubject("team-a", "api"), principalSubject(ciArn))
+		Expect(provider.CreateAccess(ctx, logr.Discard(), db, access)).To(Succeed())
+
+		Expect(fake.role("secretsbeam-access-team-a-reader")).NotTo(BeNil())
+		statements := trustStatements(fake, "secretsbeam-access-team-a-reader")
+		Expect(statements).To(HaveLen(1))
+		Expect(statements[0]).To(HaveKeyWithValue("Action", "sts:AssumeRole"))
+		Expect(access.Status.Provider).To(HaveKey("RoleArn"))
+		Expect(access.Status.Provider).NotTo(HaveKey("ServiceAccountAnnotation"))
+		Expect(access.Status.Provider).To(HaveKeyWithValue("ServiceAccountRoles", "team-a/api="+apiRoleArn))
+		Expect(access.Status.Subjects).To(Equal(access.Spec.AccessSubjects))
+	})
+
+	It("associates the roles of the service accounts in the podIdentity mode", func() {
+		provider = newProvider(AwsProviderConfig{RoleScope: RoleScopeServiceAccount, AccessMode: AccessModePodIdentity, ClusterName: fakeClusterName})
+		dbReader := providertest.NewAccess("db-reader", providertest.ServiceAccountSubject("team-a", "api"))
+		Expect(provider.CreateAccess(ctx, logr.Discard(), db, dbReader)).To(Succeed())
+		apiReader := providertest.NewAccess("api-reader", providertest.ServiceAccountSubject("team-a", "api"))
+		Expect(provider.CreateAccess(ctx, logr.Discard(), api, apiReader)).To(Succeed())
+
+		Expect(fake.podIdentityAssociations(fakeClusterName)).To(Equal([]string{"team-a/api=" + apiRoleArn}))
+		Expect(trustStatements(fake, apiRole)).To(ConsistOf(HaveKeyWithValue("Principal", map[string]interface{}{"Service": podIdentityPrincipal})))
+		Expect(dbReader.Status.Provider).NotTo(HaveKey("ServiceAccountAnnotation.team-a/api"))
+
+		Expect(provider.DeleteAccess(ctx, logr.Discard(), dbReader)).To(Succeed())
+		Expect(fake.podIdentityAssociations(fakeClusterName)).To(HaveLen(1))
+		Expect(provider.DeleteAccess(ctx, logr.Discard(), apiReader)).To(Succeed())
+		Expect(fake.podIdentityAssociations(fakeClusterName)).To(BeEmpty())
+	})
+
+	It("switches the accesses between the role scopes", func() {
+		perAccess := newProvider(AwsProviderConfig{})
+		access := providertest.NewAccess("reader", providertest.ServiceAccountSubject("team-a", "api"))
+		Expect(perAccess.CreateAccess(ctx, logr.Discard(), db, access)).To(Succeed())
+		Expect(fake.role("secretsbeam-access-team-a-reader")).NotTo(BeNil())
+
+		Expect(provider.UpdateAccess(ctx, logr.Discard(), db, access)).To(Succeed())
+		Expect(fake.role("secretsbeam-access-team-a-reader")).To(BeNil())
+		Expect(fake.policies).To(BeEmpty())
+		Expect(fake.role(apiRole)).NotTo(BeNil())
+		Expect(access.Status.Provider).NotTo(HaveKey("ServiceAccountAnnotation"))
+
+		Expect(perAccess.UpdateAccess(ctx, logr.Discard(), db, access)).To(Succeed())
+		Expect(fake.role(apiRole)).To(BeNil())
+		Expect(fake.role("secretsbeam-access-team-a-reader")).NotTo(BeNil())
+		Expect(access.Status.Provider).NotTo(HaveKey("ServiceAccountRoles"))
+		Expect(access.Status.Provider).NotTo(HaveKey("ServiceAccountAnnotation.team-a/api"))
+		Expect(access.Status.Provider).To(HaveKeyWithValue("ServiceAccountAnnotation", "eks.amazonaws.com/role-arn=arn:aws:iam::111122223333:role/secretsbeam-access-team-a-reader"))
+	})
+})
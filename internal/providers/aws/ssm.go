@@ -0,0 +1,204 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/go-logr/logr"
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+)
+
+// parameterName returns the Parameter Store name of a secret. Names containing a slash are
+// hierarchical and must be fully qualified, so a leading slash is added when missing.
+func parameterName(secret *secretsv1alpha1.ExternalSecret) string {
+	if val, ok := secret.Status.Provider["ParameterName"]; ok && val != "" {
+		return val
+	}
+
+	name := secret.Status.SecretName
+	if secret.Spec.ExternalName != nil {
+		name = *secret.Spec.ExternalName
+	}
+
+	if strings.Contains(name, "/") && !strings.HasPrefix(name, "/") {
+		name = "/" + name
+	}
+
+	return name
+}
+
+func parameterTier(secret *secretsv1alpha1.ExternalSecret) (types.ParameterTier, error) {
+	val, ok := secret.Spec.ProviderSpec["Tier"]
+	if !ok || val == "" {
+		return types.ParameterTierStandard, nil
+	}
+
+	for _, tier := range types.ParameterTierStandard.Values() {
+		if string(tier) == val {
+			return tier, nil
+		}
+	}
+
+	return "", fmt.Errorf("unsupported parameter tier %s", val)
+}
+
+func (p *AwsProvider) deleteParameter(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret) error {
+	if secret.Spec.RecoveryWindow > 0 {
+		reqLogger.Info("Parameter Store does not support recovery windows, deleting parameter immediately")
+	}
+
+	name := parameterName(secret)
+	if err := p.claimParameter(ctx, name, secret, nil, nil); err != nil {
+		return err
+	}
+
+	if _, err := p.ssmClient.DeleteParameter(ctx, &ssm.DeleteParameterInput{
+		Name: aws.String(name),
+	}); err != nil {
+		var notFoundErr *types.ParameterNotFound
+		if !errors.As(err, &notFoundErr) {
+			return fmt.Errorf("failed to delete parameter: %w", err)
+		}
+
+		reqLogger.Info(fmt.Sprintf("Parameter %s not found, ignoring", name))
+	}
+
+	return p.deleteSecretKey(ctx, reqLogger, secret)
+}
+
+func (p *AwsProvider) createParameter(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, value string) error {
+	tier, err := parameterTier(secret)
+	if err != nil {
+		return err
+	}
+	if _, err := p.replicaRegions(secret); err != nil {
+		return err
+	}
+
+	input := &ssm.PutParameterInput{
+		Name:      aws.String(parameterName(secret)),
+		Value:     aws.String(value),
+		Type:      types.ParameterTypeSecureString,
+		Tier:      tier,
+		Overwrite: aws.Bool(secret.Spec.Overwrite),
+	}
+
+	key, err := p.keyOf(ctx, reqLogger, secret)
+	if err != nil {
+		return err
+	}
+	if key.id != "" {
+		input.KeyId = aws.String(key.id)
+	}
+
+	// an overwritten parameter must be owned by the secret, a new one is tagged when created
+	tags := p.tagsOf(secret, secret.Spec.Tags)
+	if secret.Spec.Overwrite {
+		if err := p.claimParameter(ctx, aws.ToString(input.Name), secret, nil, nil); err != nil {
+			return err
+		}
+	} else {
+		input.Tags = ssmTags(tags, sortedKeys(tags))
+	}
+
+	if err := p.putParameter(ctx, secret, input); err != nil {
+		reqLogger.Error(err, "Failed to create Parameter")
+		return err
+	}
+	key.record(secret.Status.Provider)
+
+	// the parameter was verified before being written, tags cannot be passed when overwriting
+	if secret.Spec.Overwrite {
+		if _, err := p.ssmClient.AddTagsToResource(ctx, &ssm.AddTagsToResourceInput{
+			ResourceType: types.ResourceTypeForTaggingParameter,
+			ResourceId:   input.Name,
+			Tags:         ssmTags(tags, sortedKeys(tags)),
+		}); err != nil {
+			return fmt.Errorf("tagging parameter %s: %w", aws.ToString(input.Name), err)
+		}
+	}
+	recordTags(secret.Status.Provider, secret.Spec.Tags)
+
+	return nil
+}
+
+func (p *AwsProvider) getParameterLastChangedDate(ctx context.Context, secret *secretsv1alpha1.ExternalSecret) (*time.Time, error) {
+	result, err := p.ssmClient.GetParameter(ctx, &ssm.GetParameterInput{
+		Name: aws.String(parameterName(secret)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe parameter: %w", err)
+	}
+
+	return result.Parameter.LastModifiedDate, nil
+}
+
+func (p *AwsProvider) updateParameter(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, value string) error {
+	tier, err := parameterTier(secret)
+	if err != nil {
+		return err
+	}
+	if _, err := p.replicaRegions(secret); err != nil {
+		return err
+	}
+
+	input := &ssm.PutParameterInput{
+		Name:      aws.String(parameterName(secret)),
+		Value:     aws.String(value),
+		Type:      types.ParameterTypeSecureString,
+		Tier:      tier,
+		Overwrite: aws.Bool(true),
+	}
+	// SecureString parameters without a key are encrypted with the AWS managed key of Parameter Store
+	key, err := p.keyOf(ctx, reqLogger, secret)
+	if err != nil {
+		return err
+	}
+	if key.id != "" {
+		input.KeyId = aws.String(key.id)
+	}
+
+	if err := p.claimParameter(ctx, aws.ToString(input.Name), secret, p.tagsOf(secret, secret.Spec.Tags), removedTags(secret.Status.Provider, secret.Spec.Tags)); err != nil {
+		return err
+	}
+	recordTags(secret.Status.Provider, secret.Spec.Tags)
+
+	if err := p.putParameter(ctx, secret, input); err != nil {
+		return fmt.Errorf("failed to update parameter: %w", err)
+	}
+	if key.id != secret.Status.Provider["KmsKeyArn"] {
+		reqLogger.Info(fmt.Sprintf("Encrypted parameter %s with KMS key %s", aws.ToString(input.Name), aws.ToString(input.KeyId)))
+	}
+	key.record(secret.Status.Provider)
+
+	return nil
+}
+
+// putParameter writes the parameter and records its ARN, which PutParameter does not return
+func (p *AwsProvider) putParameter(ctx context.Context, secret *secretsv1alpha1.ExternalSecret, input *ssm.PutParameterInput) error {
+	result, err := p.ssmClient.PutParameter(ctx, input)
+	if err != nil {
+		return err
+	}
+
+	parameter, err := p.ssmClient.GetParameter(ctx, &ssm.GetParameterInput{
+		Name: input.Name,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to describe parameter: %w", err)
+	}
+
+	secret.Status.Provider["ParameterName"] = aws.ToString(input.Name)
+	secret.Status.Provider["ParameterArn"] = aws.ToString(parameter.Parameter.ARN)
+	secret.Status.Provider["ParameterVersion"] = strconv.FormatInt(result.Version, 10)
+	secret.Status.Provider["Tier"] = string(result.Tier)
+
+	return nil
+}
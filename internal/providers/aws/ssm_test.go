@@ -0,0 +1,88 @@
+package aws
+
+import (
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/providertest"
+)
+
+func policyStatements(secret *secretsv1alpha1.ExternalSecret) []map[string]interface{} {
+	document, err := getSecretAccessPolicy(secret)
+	Expect(err).NotTo(HaveOccurred())
+
+	policy := struct {
+		Statement []map[string]interface{}
+	}{}
+	Expect(json.Unmarshal([]byte(document), &policy)).To(Succeed())
+
+	return policy.Statement
+}
+
+var _ = Describe("Parameter Store backend", func() {
+	It("fully qualifies hierarchical parameter names", func() {
+		Expect(parameterName(providertest.NewSecret("db-password"))).To(Equal("db-password"))
+		Expect(parameterName(providertest.NewSecret("team-a/db/password"))).To(Equal("/team-a/db/password"))
+		Expect(parameterName(providertest.NewSecret("/team-a/db/password"))).To(Equal("/team-a/db/password"))
+
+		secret := providertest.NewSecret("renamed")
+		secret.Status.Provider["ParameterName"] = "/team-a/original"
+		Expect(parameterName(secret)).To(Equal("/team-a/original"))
+	})
+
+	It("validates parameter tiers", func() {
+		secret := providertest.NewSecret("db")
+		Expect(parameterTier(secret)).To(Equal(types.ParameterTierStandard))
+
+		secret.Spec.ProviderSpec["Tier"] = "Advanced"
+		Expect(parameterTier(secret)).To(Equal(types.ParameterTierAdvanced))
+
+		secret.Spec.ProviderSpec["Tier"] = "Premium"
+		_, err := parameterTier(secret)
+		Expect(err).To(MatchError(ContainSubstring("unsupported parameter tier")))
+	})
+
+	Context("access policy", func() {
+		It("grants GetSecretValue on Secrets Manager secrets", func() {
+			secret := providertest.NewSecret("db")
+			secret.Status.Provider["SecretArn"] = "arn:aws:secretsmanager:eu-west-1:111122223333:secret:db-AbCdEf"
+
+			Expect(policyStatements(secret)).To(ConsistOf(map[string]interface{}{
+				"Effect":   "Allow",
+				"Action":   []interface{}{"secretsmanager:GetSecretValue"},
+				"Resource": "arn:aws:secretsmanager:eu-west-1:111122223333:secret:db-??????",
+			}))
+		})
+
+		It("grants GetParameter on parameters", func() {
+			secret := providertest.NewSecret("/team-a/db")
+			secret.Status.Provider["ParameterArn"] = "arn:aws:ssm:eu-west-1:111122223333:parameter/team-a/db"
+
+			Expect(policyStatements(secret)).To(ConsistOf(map[string]interface{}{
+				"Effect":   "Allow",
+				"Action":   []interface{}{"ssm:GetParameter"},
+				"Resource": "arn:aws:ssm:eu-west-1:111122223333:parameter/team-a/db",
+			}))
+		})
+
+		It("grants decrypt on the customer managed key of parameters", func() {
+			secret := providertest.NewSecret("/team-a/db")
+			secret.Status.Provider["ParameterArn"] = "arn:aws:ssm:eu-west-1:111122223333:parameter/team-a/db"
+			secret.Status.Provider["KmsKeyArn"] = "arn:aws:kms:eu-west-1:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab"
+
+			Expect(policyStatements(secret)).To(ContainElement(map[string]interface{}{
+				"Effect":   "Allow",
+				"Action":   []interface{}{"kms:Decrypt"},
+				"Resource": "arn:aws:kms:eu-west-1:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab",
+			}))
+
+			By("skipping aliases, which cannot be used as a resource")
+			secret.Status.Provider["KmsKeyArn"] = "alias/team-a"
+			Expect(policyStatements(secret)).To(HaveLen(1))
+		})
+	})
+})
@@ -0,0 +1,14 @@
+package aws
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestAwsProvider(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "AWS Provider Suite")
+}
@@ -0,0 +1,395 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	smtypes "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/tiagoposse/secretsbeam-operator/internal/version"
+)
+
+// Ownership tags set on every resource created by the provider
+const (
+	TagClusterID       = "secretsbeam.orbitops.dev/cluster-id"
+	TagNamespace       = "secretsbeam.orbitops.dev/namespace"
+	TagName            = "secretsbeam.orbitops.dev/name"
+	TagUID             = "secretsbeam.orbitops.dev/uid"
+	TagOperatorVersion = "secretsbeam.orbitops.dev/operator-version"
+)
+
+// reservedTagPrefix is the prefix of the ownership tags, never removed from the resources
+const reservedTagPrefix = "secretsbeam.orbitops.dev/"
+
+// OwnershipError is returned when a resource to update or delete belongs to another cluster or object,
+// or has no ownership tags
+type OwnershipError struct {
+	Resource  string
+	ClusterID string
+	Namespace string
+	Name      string
+}
+
+func (e *OwnershipError) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("%s exists without ownership tags, set adoptUntagged to take it over", e.Resource)
+	}
+	return fmt.Sprintf("%s is owned by %s/%s of cluster %s", e.Resource, e.Namespace, e.Name, e.ClusterID)
+}
+
+// tagsOf returns the tags of the resources created for the object, its own tags and the ownership tags
+func (p *AwsProvider) tagsOf(obj metav1.Object, tags map[string]string) map[string]string {
+	res := make(map[string]string, len(tags)+5)
+	for key, value := range tags {
+		res[key] = value
+	}
+	if p.ClusterID != "" {
+		res[TagClusterID] = p.ClusterID
+	}
+	res[TagNamespace] = obj.GetNamespace()
+	res[TagName] = obj.GetName()
+	if obj.GetUID() != "" {
+		res[TagUID] = string(obj.GetUID())
+	}
+	res[TagOperatorVersion] = version.Version
+
+	return res
+}
+
+// verifyOwner checks the ownership tags of an existing resource. The owner is the cluster, namespace
+// and name of the object, its UID is only informational so that objects restored from a backup keep
+// their resources. Resources without ownership tags are only adopted with adoptUntagged, e.g. those
+// created before tagging.
+func (p *AwsProvider) verifyOwner(resource string, tags map[string]string, obj metav1.Object) error {
+	if _, ok := tags[TagName]; !ok {
+		if p.adoptUntagged {
+			return nil
+		}
+		return &OwnershipError{Resource: resource}
+	}
+
+	if tags[TagClusterID] != p.ClusterID || tags[TagNamespace] != obj.GetNamespace() || tags[TagName] != obj.GetName() {
+		return &OwnershipError{
+			Resource:  resource,
+			ClusterID: tags[TagClusterID],
+			Namespace: tags[TagNamespace],
+			Name:      tags[TagName],
+		}
+	}
+
+	return nil
+}
+
+// missingTags returns the tags that are not set to their value in current, sorted by key
+func missingTags(current, tags map[string]string) []string {
+	missing := make([]string, 0)
+	for key, value := range tags {
+		if existing, ok := current[key]; !ok || existing != value {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+
+	return missing
+}
+
+// recordTags records the keys of the tags of the spec of an object in the Tags entry of its status,
+// so that the tags later removed from the spec are removed from its resources
+func recordTags(status map[string]string, tags map[string]string) {
+	if len(tags) == 0 {
+		delete(status, "Tags")
+		return
+	}
+	status["Tags"] = strings.Join(sortedKeys(tags), ",")
+}
+
+// removedTags returns the keys of the tags recorded in the status that are no longer in the spec,
+// sorted by key. The ownership tags are never removed.
+func removedTags(status map[string]string, tags map[string]string) []string {
+	removed := make([]string, 0)
+	if status["Tags"] == "" {
+		return removed
+	}
+	for _, key := range strings.Split(status["Tags"], ",") {
+		if _, ok := tags[key]; !ok && !strings.HasPrefix(key, reservedTagPrefix) {
+			removed = append(removed, key)
+		}
+	}
+
+	return removed
+}
+
+// setTags returns the keys still set in current
+func setTags(current map[string]string, keys []string) []string {
+	set := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if _, ok := current[key]; ok {
+			set = append(set, key)
+		}
+	}
+
+	return set
+}
+
+func secretsManagerTags(tags map[string]string, keys []string) []smtypes.Tag {
+	res := make([]smtypes.Tag, 0, len(keys))
+	for _, key := range keys {
+		res = append(res, smtypes.Tag{Key: aws.String(key), Value: aws.String(tags[key])})
+	}
+
+	return res
+}
+
+func ssmTags(tags map[string]string, keys []string) []ssmtypes.Tag {
+	res := make([]ssmtypes.Tag, 0, len(keys))
+	for _, key := range keys {
+		res = append(res, ssmtypes.Tag{Key: aws.String(key), Value: aws.String(tags[key])})
+	}
+
+	return res
+}
+
+func iamTags(tags map[string]string, keys []string) []iamtypes.Tag {
+	res := make([]iamtypes.Tag, 0, len(keys))
+	for _, key := range keys {
+		res = append(res, iamtypes.Tag{Key: aws.String(key), Value: aws.String(tags[key])})
+	}
+
+	return res
+}
+
+func kmsTags(tags map[string]string, keys []string) []kmstypes.Tag {
+	res := make([]kmstypes.Tag, 0, len(keys))
+	for _, key := range keys {
+		res = append(res, kmstypes.Tag{TagKey: aws.String(key), TagValue: aws.String(tags[key])})
+	}
+
+	return res
+}
+
+// sortedKeys returns the keys of the tags, sorted so requests only change with the tags
+func sortedKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// claimSecret verifies the ownership of a Secrets Manager secret, sets its missing tags and removes
+// the removed ones. Missing secrets are left to the caller.
+func (p *AwsProvider) claimSecret(ctx context.Context, id string, obj metav1.Object, tags map[string]string, removed []string) error {
+	result, err := p.secretsClient.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{
+		SecretId: aws.String(id),
+	})
+	if err != nil {
+		var notFoundErr *smtypes.ResourceNotFoundException
+		if errors.As(err, &notFoundErr) {
+			return nil
+		}
+		return fmt.Errorf("describing secret %s: %w", id, err)
+	}
+
+	current := make(map[string]string, len(result.Tags))
+	for _, tag := range result.Tags {
+		current[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	if err := p.verifyOwner("secret "+id, current, obj); err != nil {
+		return err
+	}
+
+	if missing := missingTags(current, tags); len(missing) > 0 {
+		if _, err := p.secretsClient.TagResource(ctx, &secretsmanager.TagResourceInput{
+			SecretId: aws.String(id),
+			Tags:     secretsManagerTags(tags, missing),
+		}); err != nil {
+			return fmt.Errorf("tagging secret %s: %w", id, err)
+		}
+	}
+	if untag := setTags(current, removed); len(untag) > 0 {
+		if _, err := p.secretsClient.UntagResource(ctx, &secretsmanager.UntagResourceInput{
+			SecretId: aws.String(id),
+			TagKeys:  untag,
+		}); err != nil {
+			return fmt.Errorf("untagging secret %s: %w", id, err)
+		}
+	}
+
+	return nil
+}
+
+// claimParameter verifies the ownership of a parameter, sets its missing tags and removes the removed
+// ones, tags cannot be passed to PutParameter when overwriting. Missing parameters are left to the
+// caller.
+func (p *AwsProvider) claimParameter(ctx context.Context, name string, obj metav1.Object, tags map[string]string, removed []string) error {
+	result, err := p.ssmClient.ListTagsForResource(ctx, &ssm.ListTagsForResourceInput{
+		ResourceType: ssmtypes.ResourceTypeForTaggingParameter,
+		ResourceId:   aws.String(name),
+	})
+	if err != nil {
+		var notFoundErr *ssmtypes.InvalidResourceId
+		if errors.As(err, &notFoundErr) {
+			return nil
+		}
+		return fmt.Errorf("listing tags of parameter %s: %w", name, err)
+	}
+
+	current := make(map[string]string, len(result.TagList))
+	for _, tag := range result.TagList {
+		current[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	if err := p.verifyOwner("parameter "+name, current, obj); err != nil {
+		return err
+	}
+
+	if missing := missingTags(current, tags); len(missing) > 0 {
+		if _, err := p.ssmClient.AddTagsToResource(ctx, &ssm.AddTagsToResourceInput{
+			ResourceType: ssmtypes.ResourceTypeForTaggingParameter,
+			ResourceId:   aws.String(name),
+			Tags:         ssmTags(tags, missing),
+		}); err != nil {
+			return fmt.Errorf("tagging parameter %s: %w", name, err)
+		}
+	}
+	if untag := setTags(current, removed); len(untag) > 0 {
+		if _, err := p.ssmClient.RemoveTagsFromResource(ctx, &ssm.RemoveTagsFromResourceInput{
+			ResourceType: ssmtypes.ResourceTypeForTaggingParameter,
+			ResourceId:   aws.String(name),
+			TagKeys:      untag,
+		}); err != nil {
+			return fmt.Errorf("untagging parameter %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// claimPolicy verifies the ownership of an IAM policy, sets its missing tags and removes the removed
+// ones. Missing policies are left to the caller.
+func (p *AwsProvider) claimPolicy(ctx context.Context, policyArn string, obj metav1.Object, tags map[string]string, removed []string) error {
+	result, err := p.iamClient.ListPolicyTags(ctx, &iam.ListPolicyTagsInput{
+		PolicyArn: aws.String(policyArn),
+	})
+	if err != nil {
+		if isNoSuchEntity(err) {
+			return nil
+		}
+		return fmt.Errorf("listing tags of policy %s: %w", policyArn, err)
+	}
+
+	current := make(map[string]string, len(result.Tags))
+	for _, tag := range result.Tags {
+		current[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	if err := p.verifyOwner("policy "+policyArn, current, obj); err != nil {
+		return err
+	}
+
+	if missing := missingTags(current, tags); len(missing) > 0 {
+		if _, err := p.iamClient.TagPolicy(ctx, &iam.TagPolicyInput{
+			PolicyArn: aws.String(policyArn),
+			Tags:      iamTags(tags, missing),
+		}); err != nil {
+			return fmt.Errorf("tagging policy %s: %w", policyArn, err)
+		}
+	}
+	if untag := setTags(current, removed); len(untag) > 0 {
+		if _, err := p.iamClient.UntagPolicy(ctx, &iam.UntagPolicyInput{
+			PolicyArn: aws.String(policyArn),
+			TagKeys:   untag,
+		}); err != nil {
+			return fmt.Errorf("untagging policy %s: %w", policyArn, err)
+		}
+	}
+
+	return nil
+}
+
+// claimRole verifies the ownership of an IAM role, sets its missing tags and removes the removed ones.
+// Missing roles are left to the caller.
+func (p *AwsProvider) claimRole(ctx context.Context, roleName string, obj metav1.Object, tags map[string]string, removed []string) error {
+	result, err := p.iamClient.ListRoleTags(ctx, &iam.ListRoleTagsInput{
+		RoleName: aws.String(roleName),
+	})
+	if err != nil {
+		if isNoSuchEntity(err) {
+			return nil
+		}
+		return fmt.Errorf("listing tags of role %s: %w", roleName, err)
+	}
+
+	current := make(map[string]string, len(result.Tags))
+	for _, tag := range result.Tags {
+		current[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	if err := p.verifyOwner("role "+roleName, current, obj); err != nil {
+		return err
+	}
+
+	if missing := missingTags(current, tags); len(missing) > 0 {
+		if _, err := p.iamClient.TagRole(ctx, &iam.TagRoleInput{
+			RoleName: aws.String(roleName),
+			Tags:     iamTags(tags, missing),
+		}); err != nil {
+			return fmt.Errorf("tagging role %s: %w", roleName, err)
+		}
+	}
+	if untag := setTags(current, removed); len(untag) > 0 {
+		if _, err := p.iamClient.UntagRole(ctx, &iam.UntagRoleInput{
+			RoleName: aws.String(roleName),
+			TagKeys:  untag,
+		}); err != nil {
+			return fmt.Errorf("untagging role %s: %w", roleName, err)
+		}
+	}
+
+	return nil
+}
+
+// claimKey verifies the ownership of a KMS key and sets its missing tags. Missing keys are left to
+// the caller.
+func (p *AwsProvider) claimKey(ctx context.Context, keyID string, obj metav1.Object, tags map[string]string) error {
+	result, err := p.kmsClient.ListResourceTags(ctx, &kms.ListResourceTagsInput{
+		KeyId: aws.String(keyID),
+	})
+	if err != nil {
+		var notFoundErr *kmstypes.NotFoundException
+		if errors.As(err, &notFoundErr) {
+			return nil
+		}
+		return fmt.Errorf("listing tags of KMS key %s: %w", keyID, err)
+	}
+
+	current := make(map[string]string, len(result.Tags))
+	for _, tag := range result.Tags {
+		current[aws.ToString(tag.TagKey)] = aws.ToString(tag.TagValue)
+	}
+	if err := p.verifyOwner("KMS key "+keyID, current, obj); err != nil {
+		return err
+	}
+
+	if missing := missingTags(current, tags); len(missing) > 0 {
+		if _, err := p.kmsClient.TagResource(ctx, &kms.TagResourceInput{
+			KeyId: aws.String(keyID),
+			Tags:  kmsTags(tags, missing),
+		}); err != nil {
+			return fmt.Errorf("tagging KMS key %s: %w", keyID, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,239 @@
+package aws
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/providertest"
+	"github.com/tiagoposse/secretsbeam-operator/internal/version"
+)
+
+var _ = Describe("AwsProvider ownership tags", func() {
+	var (
+		ctx      context.Context
+		fake     *fakeAWS
+		provider *AwsProvider
+		other    *AwsProvider
+	)
+
+	newOwnedSecret := func(name string) *secretsv1alpha1.ExternalSecret {
+		secret := providertest.NewSecret(name)
+		secret.ObjectMeta = metav1.ObjectMeta{Namespace: "team-a", Name: name, UID: types.UID("uid-" + name)}
+		secret.Status.SecretName = name
+		secret.Spec.Tags = map[string]string{"team": "a"}
+		return secret
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		fake = newFakeAWS()
+		DeferCleanup(fake.Close)
+
+		provider = &AwsProvider{ClusterID: "cluster-a"}
+		Expect(provider.init(AwsProviderConfig{OidcProviderArn: fakeOidcProviderArn, Endpoint: fake.server.URL}, fake.config())).To(Succeed())
+		other = &AwsProvider{ClusterID: "cluster-b"}
+		Expect(other.init(AwsProviderConfig{OidcProviderArn: fakeOidcProviderArn, Endpoint: fake.server.URL}, fake.config())).To(Succeed())
+	})
+
+	Context("Secrets Manager", func() {
+		It("tags secrets with their owner and their tags", func() {
+			secret := newOwnedSecret("db")
+			Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+			Expect(fake.tagsOf("db")).To(Equal(map[string]string{
+				"team":                                      "a",
+				"secretsbeam.orbitops.dev/cluster-id":       "cluster-a",
+				"secretsbeam.orbitops.dev/namespace":        "team-a",
+				"secretsbeam.orbitops.dev/name":             "db",
+				"secretsbeam.orbitops.dev/uid":              "uid-db",
+				"secretsbeam.orbitops.dev/operator-version": version.Version,
+			}))
+
+			By("adding the tags of the secret on updates")
+			secret.Spec.Tags["cost-center"] = "42"
+			secret.UID = "uid-restored"
+			Expect(provider.UpdateSecret(ctx, logr.Discard(), secret, "hunter3")).To(Succeed())
+			Expect(fake.tagsOf("db")).To(HaveKeyWithValue("cost-center", "42"))
+			Expect(fake.tagsOf("db")).To(HaveKeyWithValue(TagUID, "uid-restored"))
+
+			By("removing the tags removed from the secret on updates")
+			fake.secrets["db"].tags["backup"] = "daily"
+			delete(secret.Spec.Tags, "team")
+			Expect(provider.UpdateSecret(ctx, logr.Discard(), secret, "hunter4")).To(Succeed())
+			Expect(fake.tagsOf("db")).NotTo(HaveKey("team"))
+			Expect(fake.tagsOf("db")).To(HaveKeyWithValue("cost-center", "42"))
+			Expect(fake.tagsOf("db")).To(HaveKeyWithValue("backup", "daily"), "tags set outside of the operator are kept")
+			Expect(fake.tagsOf("db")).To(HaveKeyWithValue(TagName, "db"))
+		})
+
+		It("leaves the secrets of other clusters alone", func() {
+			Expect(other.CreateSecret(ctx, logr.Discard(), newOwnedSecret("db"), "hunter2")).To(Succeed())
+
+			secret := newOwnedSecret("db")
+			err := provider.UpdateSecret(ctx, logr.Discard(), secret, "hunter3")
+			Expect(err).To(MatchError("secret db is owned by team-a/db of cluster cluster-b"))
+			Expect(err).To(BeAssignableToTypeOf(&OwnershipError{}))
+			Expect(fake.secrets["db"].value).To(Equal("hunter2"))
+
+			Expect(provider.DeleteSecret(ctx, logr.Discard(), secret)).To(BeAssignableToTypeOf(&OwnershipError{}))
+			Expect(fake.secrets).To(HaveKey("db"))
+		})
+
+		It("leaves the secrets of other objects alone", func() {
+			Expect(provider.CreateSecret(ctx, logr.Discard(), newOwnedSecret("db"), "hunter2")).To(Succeed())
+
+			secret := newOwnedSecret("db")
+			secret.Namespace = "team-b"
+			Expect(provider.UpdateSecret(ctx, logr.Discard(), secret, "hunter3")).To(MatchError("secret db is owned by team-a/db of cluster cluster-a"))
+		})
+
+		It("only adopts untagged secrets with adoptUntagged", func() {
+			Expect(provider.CreateSecret(ctx, logr.Discard(), providertest.NewSecret("db"), "hunter2")).To(Succeed())
+			fake.secrets["db"].tags = nil
+
+			secret := newOwnedSecret("db")
+			err := provider.UpdateSecret(ctx, logr.Discard(), secret, "hunter3")
+			Expect(err).To(MatchError("secret db exists without ownership tags, set adoptUntagged to take it over"))
+			Expect(err).To(BeAssignableToTypeOf(&OwnershipError{}))
+			Expect(provider.DeleteSecret(ctx, logr.Discard(), secret)).To(BeAssignableToTypeOf(&OwnershipError{}))
+			Expect(fake.secrets["db"].value).To(Equal("hunter2"))
+
+			Expect(provider.init(AwsProviderConfig{AdoptUntagged: "true", Endpoint: fake.server.URL}, fake.config())).To(Succeed())
+			Expect(provider.UpdateSecret(ctx, logr.Discard(), secret, "hunter3")).To(Succeed())
+			Expect(fake.tagsOf("db")).To(HaveKeyWithValue(TagClusterID, "cluster-a"))
+			Expect(fake.secrets["db"].value).To(Equal("hunter3"))
+		})
+
+		It("never removes the ownership tags", func() {
+			secret := newOwnedSecret("db")
+			Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+
+			secret.Status.Provider["Tags"] = "team," + TagClusterID
+			Expect(provider.UpdateSecret(ctx, logr.Discard(), secret, "hunter3")).To(Succeed())
+			Expect(fake.tagsOf("db")).To(HaveKeyWithValue(TagClusterID, "cluster-a"))
+			Expect(secret.Status.Provider).To(HaveKeyWithValue("Tags", "team"))
+		})
+	})
+
+	Context("Parameter Store", func() {
+		BeforeEach(func() {
+			Expect(provider.init(AwsProviderConfig{Backend: BackendSsm, Endpoint: fake.server.URL}, fake.config())).To(Succeed())
+			Expect(other.init(AwsProviderConfig{Backend: BackendSsm, Endpoint: fake.server.URL}, fake.config())).To(Succeed())
+		})
+
+		It("tags parameters with their owner", func() {
+			secret := newOwnedSecret("db")
+			Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+			Expect(fake.tagsOf("db")).To(HaveKeyWithValue(TagClusterID, "cluster-a"))
+			Expect(fake.tagsOf("db")).To(HaveKeyWithValue("team", "a"))
+
+			By("removing the tags removed from the secret on updates")
+			delete(secret.Spec.Tags, "team")
+			Expect(provider.UpdateSecret(ctx, logr.Discard(), secret, "hunter3")).To(Succeed())
+			Expect(fake.tagsOf("db")).NotTo(HaveKey("team"))
+			Expect(fake.tagsOf("db")).To(HaveKeyWithValue(TagClusterID, "cluster-a"))
+
+			By("tagging overwritten parameters after writing them")
+			overwritten := newOwnedSecret("api")
+			overwritten.Spec.Overwrite = true
+			Expect(provider.CreateSecret(ctx, logr.Discard(), overwritten, "hunter2")).To(Succeed())
+			Expect(fake.tagsOf("api")).To(HaveKeyWithValue(TagName, "api"))
+		})
+
+		It("leaves the parameters of other clusters alone", func() {
+			Expect(other.CreateSecret(ctx, logr.Discard(), newOwnedSecret("db"), "hunter2")).To(Succeed())
+
+			secret := newOwnedSecret("db")
+			secret.Spec.Overwrite = true
+			Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter3")).To(MatchError("parameter db is owned by team-a/db of cluster cluster-b"))
+			Expect(provider.UpdateSecret(ctx, logr.Discard(), secret, "hunter3")).To(BeAssignableToTypeOf(&OwnershipError{}))
+			Expect(provider.DeleteSecret(ctx, logr.Discard(), secret)).To(BeAssignableToTypeOf(&OwnershipError{}))
+			Expect(fake.parameters["db"].value).To(Equal("hunter2"))
+		})
+	})
+
+	Context("IAM", func() {
+		var secret *secretsv1alpha1.ExternalSecret
+
+		BeforeEach(func() {
+			secret = newOwnedSecret("db")
+			Expect(provider.CreateSecret(ctx, logr.Discard(), secret, "hunter2")).To(Succeed())
+		})
+
+		It("tags policies and roles with their owner", func() {
+			access := providertest.NewAccess("reader", providertest.ServiceAccountSubject("team-a", "api"))
+			access.UID = "uid-reader"
+			access.Spec.Tags = map[string]string{"team": "a"}
+			Expect(provider.CreateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+
+			for _, id := range []string{access.Status.Provider["PolicyArn"], access.Status.Provider["RoleName"]} {
+				Expect(fake.tagsOf(id)).To(Equal(map[string]string{
+					"team":                                      "a",
+					"secretsbeam.orbitops.dev/cluster-id":       "cluster-a",
+					"secretsbeam.orbitops.dev/namespace":        "team-a",
+					"secretsbeam.orbitops.dev/name":             "reader",
+					"secretsbeam.orbitops.dev/uid":              "uid-reader",
+					"secretsbeam.orbitops.dev/operator-version": version.Version,
+				}), id)
+			}
+
+			By("adding the tags of the access on updates")
+			access.Spec.Tags["cost-center"] = "42"
+			Expect(provider.UpdateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+			Expect(fake.tagsOf(access.Status.Provider["PolicyArn"])).To(HaveKeyWithValue("cost-center", "42"))
+			Expect(fake.tagsOf(access.Status.Provider["RoleName"])).To(HaveKeyWithValue("cost-center", "42"))
+
+			By("removing the tags removed from the access on updates")
+			delete(access.Spec.Tags, "team")
+			Expect(provider.UpdateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+			for _, id := range []string{access.Status.Provider["PolicyArn"], access.Status.Provider["RoleName"]} {
+				Expect(fake.tagsOf(id)).NotTo(HaveKey("team"), id)
+				Expect(fake.tagsOf(id)).To(HaveKeyWithValue("cost-center", "42"), id)
+				Expect(fake.tagsOf(id)).To(HaveKeyWithValue(TagName, "reader"), id)
+			}
+		})
+
+		It("leaves the policies and roles of other clusters alone", func() {
+			Expect(other.CreateAccess(ctx, logr.Discard(), secret, providertest.NewAccess("reader", providertest.ServiceAccountSubject("team-a", "api")))).To(Succeed())
+
+			access := providertest.NewAccess("reader", providertest.ServiceAccountSubject("team-b", "api"))
+			access.Status.Provider["PolicyArn"] = "arn:aws:iam::111122223333:policy/secretsbeam-access-team-a-reader"
+			access.Status.Provider["RoleName"] = "secretsbeam-access-team-a-reader"
+			Expect(provider.UpdateAccess(ctx, logr.Discard(), secret, access)).To(MatchError(
+				"policy arn:aws:iam::111122223333:policy/secretsbeam-access-team-a-reader is owned by team-a/reader of cluster cluster-b"))
+			Expect(provider.DeleteAccess(ctx, logr.Discard(), access)).To(BeAssignableToTypeOf(&OwnershipError{}))
+			Expect(fake.policies).To(HaveLen(1))
+			Expect(fake.roles).To(HaveLen(1))
+			Expect(fake.policyVersions(access.Status.Provider["PolicyArn"])).To(Equal([]string{"v1"}))
+		})
+
+		It("leaves untagged policies and roles alone", func() {
+			access := providertest.NewAccess("reader", providertest.ServiceAccountSubject("team-a", "api"))
+			Expect(provider.CreateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+			fake.roles[access.Status.Provider["RoleName"]].tags = nil
+
+			access.Spec.AccessSubjects = append(access.Spec.AccessSubjects, providertest.ServiceAccountSubject("team-a", "worker"))
+			Expect(provider.UpdateAccess(ctx, logr.Discard(), secret, access)).To(MatchError(
+				"role secretsbeam-access-team-a-reader exists without ownership tags, set adoptUntagged to take it over"))
+			Expect(trustStatements(fake, access.Status.Provider["RoleName"])).To(HaveLen(1))
+			Expect(provider.DeleteAccess(ctx, logr.Discard(), access)).To(BeAssignableToTypeOf(&OwnershipError{}))
+			Expect(fake.roles).To(HaveKey(access.Status.Provider["RoleName"]))
+
+			fake.policies[access.Status.Provider["PolicyArn"]].tags = nil
+			Expect(provider.UpdateAccess(ctx, logr.Discard(), secret, access)).To(MatchError(ContainSubstring("exists without ownership tags")))
+		})
+
+		It("tags pod identity associations", func() {
+			Expect(provider.init(AwsProviderConfig{AccessMode: AccessModePodIdentity, ClusterName: fakeClusterName, Endpoint: fake.server.URL}, fake.config())).To(Succeed())
+
+			access := providertest.NewAccess("reader", providertest.ServiceAccountSubject("team-a", "api"))
+			Expect(provider.CreateAccess(ctx, logr.Discard(), secret, access)).To(Succeed())
+			Expect(fake.associations[podIdentityAssociations(access)["team-a/api"]].tags).To(HaveKeyWithValue(TagClusterID, "cluster-a"))
+		})
+	})
+})
@@ -0,0 +1,247 @@
+package azure
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/google/uuid"
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+)
+
+const (
+	// keyVaultSecretsUserRole is the id of the built-in Key Vault Secrets User role definition
+	keyVaultSecretsUserRole = "4633458b-17de-408a-b874-0445c86b69e6"
+	tokenExchangeAudience   = "api://AzureADTokenExchange"
+	// managed identities accept at most 20 federated identity credentials
+	maxFederatedCredentials = 20
+	maxResourceNameLength   = 120
+)
+
+var invalidResourceNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+type userAssignedIdentity struct {
+	ID         string            `json:"id,omitempty"`
+	Location   string            `json:"location"`
+	Tags       map[string]string `json:"tags,omitempty"`
+	Properties *struct {
+		ClientID    string `json:"clientId"`
+		PrincipalID string `json:"principalId"`
+	} `json:"properties,omitempty"`
+}
+
+type federatedCredentialProperties struct {
+	Issuer    string   `json:"issuer"`
+	Subject   string   `json:"subject"`
+	Audiences []string `json:"audiences"`
+}
+
+type federatedCredential struct {
+	Properties federatedCredentialProperties `json:"properties"`
+}
+
+type roleAssignmentProperties struct {
+	RoleDefinitionID string `json:"roleDefinitionId"`
+	PrincipalID      string `json:"principalId"`
+	PrincipalType    string `json:"principalType,omitempty"`
+}
+
+type roleAssignment struct {
+	Properties roleAssignmentProperties `json:"properties"`
+}
+
+// resourceName builds a valid Azure resource name from parts, hashing them when they are too long
+func resourceName(parts ...string) string {
+	name := strings.Trim(invalidResourceNameChars.ReplaceAllString(strings.Join(parts, "-"), "-"), "-_")
+	if len(name) <= maxResourceNameLength {
+		return name
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(parts, "/")))
+	hash := hex.EncodeToString(sum[:])[:8]
+
+	return fmt.Sprintf("%s-%s", strings.TrimRight(name[:maxResourceNameLength-len(hash)-1], "-_"), hash)
+}
+
+func identityName(access *secretsv1alpha1.ExternalSecretAccess) string {
+	return resourceName("sb", access.Namespace, access.Name)
+}
+
+func splitStatus(access *secretsv1alpha1.ExternalSecretAccess, key string) []string {
+	val, ok := access.Status.Provider[key]
+	if !ok || val == "" {
+		return nil
+	}
+
+	return strings.Split(val, ",")
+}
+
+func (p *AzureProvider) identityPath(name string) string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.ManagedIdentity/userAssignedIdentities/%s", p.SubscriptionID, p.ResourceGroup, name)
+}
+
+// roleAssignmentID returns a stable role assignment id for a principal on a scope so that
+// reconciling the same access does not create duplicate assignments
+func roleAssignmentID(scope, principalID string) string {
+	name := uuid.NewSHA1(uuid.NameSpaceURL, []byte(fmt.Sprintf("%s|%s|%s", scope, principalID, keyVaultSecretsUserRole)))
+
+	return fmt.Sprintf("%s/providers/Microsoft.Authorization/roleAssignments/%s", scope, name.String())
+}
+
+func (p *AzureProvider) CreateAccess(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, access *secretsv1alpha1.ExternalSecretAccess) error {
+	return p.applyAccess(ctx, reqLogger, secret, access)
+}
+
+func (p *AzureProvider) UpdateAccess(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, access *secretsv1alpha1.ExternalSecretAccess) error {
+	return p.applyAccess(ctx, reqLogger, secret, access)
+}
+
+func (p *AzureProvider) DeleteAccess(ctx context.Context, reqLogger logr.Logger, access *secretsv1alpha1.ExternalSecretAccess) error {
+	for _, assignment := range splitStatus(access, "RoleAssignments") {
+		if err := p.roleAssignmentClient.do(ctx, http.MethodDelete, assignment, nil, nil); err != nil && !isNotFound(err) {
+			return fmt.Errorf("failed to delete role assignment %s: %w", assignment, err)
+		}
+		reqLogger.Info(fmt.Sprintf("Deleted role assignment %s", assignment))
+	}
+
+	// federated identity credentials are deleted along with the identity
+	if name, ok := access.Status.Provider["IdentityName"]; ok {
+		if err := p.identityClient.do(ctx, http.MethodDelete, p.identityPath(name), nil, nil); err != nil {
+			if !isNotFound(err) {
+				return fmt.Errorf("failed to delete managed identity %s: %w", name, err)
+			}
+
+			reqLogger.Info(fmt.Sprintf("Managed identity %s not found, ignoring", name))
+		} else {
+			reqLogger.Info(fmt.Sprintf("Deleted managed identity %s", name))
+		}
+	}
+
+	return nil
+}
+
+// applyAccess makes sure the managed identity of the access exists, federates it with every
+// service account subject and grants it and the provider identifiers read access on the secret
+func (p *AzureProvider) applyAccess(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, access *secretsv1alpha1.ExternalSecretAccess) error {
+	if p.Location == "" {
+		return fmt.Errorf("location is required to create managed identities")
+	}
+
+	name := identityName(access)
+	identity := &userAssignedIdentity{}
+	if err := p.identityClient.do(ctx, http.MethodPut, p.identityPath(name), &userAssignedIdentity{
+		Location: p.Location,
+		Tags:     map[string]string{managedByTag: "secretsbeam-operator"},
+	}, identity); err != nil {
+		return fmt.Errorf("failed to create managed identity: %w", err)
+	}
+	if identity.Properties == nil {
+		return fmt.Errorf("managed identity %s has no client id", name)
+	}
+	reqLogger.Info(fmt.Sprintf("Ensured managed identity %s", name))
+
+	access.Status.Provider["IdentityName"] = name
+	access.Status.Provider["ClientId"] = identity.Properties.ClientID
+	access.Status.Provider["PrincipalId"] = identity.Properties.PrincipalID
+	access.Status.Provider["ServiceAccountAnnotation"] = fmt.Sprintf("azure.workload.identity/client-id=%s", identity.Properties.ClientID)
+
+	if err := p.applyFederatedCredentials(ctx, reqLogger, access, name); err != nil {
+		return err
+	}
+
+	return p.applyRoleAssignments(ctx, reqLogger, secret, access, identity.Properties.PrincipalID)
+}
+
+func (p *AzureProvider) applyFederatedCredentials(ctx context.Context, reqLogger logr.Logger, access *secretsv1alpha1.ExternalSecretAccess, identity string) error {
+	credentials := make([]string, 0)
+	for _, subject := range access.Spec.AccessSubjects {
+		if subject.ServiceAccount == nil {
+			continue
+		}
+		if p.OIDCIssuer == "" {
+			return fmt.Errorf("oidcIssuer is required to grant access to service accounts")
+		}
+
+		credential := resourceName(subject.ServiceAccount.Namespace, subject.ServiceAccount.Name)
+		if contains(credentials, credential) {
+			continue
+		}
+		credentials = append(credentials, credential)
+		if len(credentials) > maxFederatedCredentials {
+			return fmt.Errorf("an access supports at most %d service accounts", maxFederatedCredentials)
+		}
+
+		if err := p.identityClient.do(ctx, http.MethodPut, fmt.Sprintf("%s/federatedIdentityCredentials/%s", p.identityPath(identity), credential), &federatedCredential{
+			Properties: federatedCredentialProperties{
+				Issuer:    p.OIDCIssuer,
+				Subject:   fmt.Sprintf("system:serviceaccount:%s:%s", subject.ServiceAccount.Namespace, subject.ServiceAccount.Name),
+				Audiences: []string{tokenExchangeAudience},
+			},
+		}, nil); err != nil {
+			return fmt.Errorf("failed to create federated credential %s: %w", credential, err)
+		}
+		reqLogger.Info(fmt.Sprintf("Ensured federated credential %s", credential))
+	}
+
+	for _, credential := range splitStatus(access, "FederatedCredentials") {
+		if !contains(credentials, credential) {
+			if err := p.identityClient.do(ctx, http.MethodDelete, fmt.Sprintf("%s/federatedIdentityCredentials/%s", p.identityPath(identity), credential), nil, nil); err != nil && !isNotFound(err) {
+				return fmt.Errorf("failed to delete federated credential %s: %w", credential, err)
+			}
+			reqLogger.Info(fmt.Sprintf("Deleted federated credential %s", credential))
+		}
+	}
+	access.Status.Provider["FederatedCredentials"] = strings.Join(credentials, ",")
+
+	return nil
+}
+
+func (p *AzureProvider) applyRoleAssignments(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, access *secretsv1alpha1.ExternalSecretAccess, principalID string) error {
+	scope := fmt.Sprintf("%s/secrets/%s", p.vaultResourceID(), secretName(secret))
+	roleDefinition := fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Authorization/roleDefinitions/%s", p.SubscriptionID, keyVaultSecretsUserRole)
+
+	// principalType is only known for the managed identity, setting it avoids failures while it replicates
+	principals := []roleAssignmentProperties{{RoleDefinitionID: roleDefinition, PrincipalID: principalID, PrincipalType: "ServicePrincipal"}}
+	for _, subject := range access.Spec.AccessSubjects {
+		if subject.ProviderIdentifier != nil {
+			principals = append(principals, roleAssignmentProperties{RoleDefinitionID: roleDefinition, PrincipalID: subject.ProviderIdentifier.Identifier})
+		}
+	}
+
+	assignments := make([]string, 0, len(principals))
+	for _, principal := range principals {
+		assignment := roleAssignmentID(scope, principal.PrincipalID)
+		if err := p.roleAssignmentClient.do(ctx, http.MethodPut, assignment, &roleAssignment{Properties: principal}, nil); err != nil && !isConflict(err) {
+			return fmt.Errorf("failed to assign role to %s: %w", principal.PrincipalID, err)
+		}
+		reqLogger.Info(fmt.Sprintf("Granted Key Vault Secrets User on %s to %s", scope, principal.PrincipalID))
+		assignments = append(assignments, assignment)
+	}
+
+	for _, assignment := range splitStatus(access, "RoleAssignments") {
+		if !contains(assignments, assignment) {
+			if err := p.roleAssignmentClient.do(ctx, http.MethodDelete, assignment, nil, nil); err != nil && !isNotFound(err) {
+				return fmt.Errorf("failed to delete role assignment %s: %w", assignment, err)
+			}
+			reqLogger.Info(fmt.Sprintf("Deleted role assignment %s", assignment))
+		}
+	}
+	access.Status.Provider["RoleAssignments"] = strings.Join(assignments, ",")
+
+	return nil
+}
+
+func contains(list []string, val string) bool {
+	for _, v := range list {
+		if v == val {
+			return true
+		}
+	}
+
+	return false
+}
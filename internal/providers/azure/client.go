@@ -0,0 +1,156 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// apiError is the error body returned by Key Vault and Azure Resource Manager
+type apiError struct {
+	StatusCode int    `json:"-"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s (%d): %s", e.Code, e.StatusCode, e.Message)
+}
+
+func isNotFound(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+func isConflict(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict
+}
+
+// restClient is a minimal JSON client for the Azure REST APIs used by the provider
+type restClient struct {
+	httpClient *http.Client
+	endpoint   string
+	apiVersion string
+}
+
+func (c *restClient) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("marshalling request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	u := fmt.Sprintf("%s%s?api-version=%s", strings.TrimSuffix(c.endpoint, "/"), path, c.apiVersion)
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading response: %w", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		errBody := struct {
+			Error *apiError `json:"error"`
+		}{}
+		if err := json.Unmarshal(data, &errBody); err != nil || errBody.Error == nil {
+			return &apiError{StatusCode: resp.StatusCode, Code: resp.Status, Message: string(data)}
+		}
+
+		errBody.Error.StatusCode = resp.StatusCode
+		return errBody.Error
+	}
+
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("unmarshalling response: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// tokenSource gets Microsoft Entra ID tokens for a single scope with the client credentials flow,
+// authenticating either with a client secret or with a federated token file as set up by
+// Azure Workload Identity. The federated token is read on every request since it is rotated.
+type tokenSource struct {
+	httpClient         *http.Client
+	authorityHost      string
+	tenantID           string
+	clientID           string
+	clientSecret       string
+	federatedTokenFile string
+	scope              string
+}
+
+func (s *tokenSource) Token() (*oauth2.Token, error) {
+	form := url.Values{
+		"grant_type": {"client_credentials"},
+		"client_id":  {s.clientID},
+		"scope":      {s.scope},
+	}
+
+	if s.federatedTokenFile != "" {
+		assertion, err := os.ReadFile(s.federatedTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading federated token: %w", err)
+		}
+
+		form.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+		form.Set("client_assertion", strings.TrimSpace(string(assertion)))
+	} else {
+		form.Set("client_secret", s.clientSecret)
+	}
+
+	u := fmt.Sprintf("%s/%s/oauth2/v2.0/token", strings.TrimSuffix(s.authorityHost, "/"), s.tenantID)
+	resp, err := s.httpClient.PostForm(u, form)
+	if err != nil {
+		return nil, fmt.Errorf("requesting token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	result := struct {
+		AccessToken      string `json:"access_token"`
+		TokenType        string `json:"token_type"`
+		ExpiresIn        int64  `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("requesting token: %s: %s", result.Error, result.ErrorDescription)
+	}
+
+	return &oauth2.Token{
+		AccessToken: result.AccessToken,
+		TokenType:   result.TokenType,
+		Expiry:      time.Now().Add(time.Duration(result.ExpiresIn) * time.Second),
+	}, nil
+}
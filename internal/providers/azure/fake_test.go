@@ -0,0 +1,265 @@
+package azure
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+type fakeKeyVaultSecret struct {
+	values      []string
+	contentType string
+	tags        map[string]string
+	updated     time.Time
+}
+
+type fakeIdentity struct {
+	clientID    string
+	principalID string
+	location    string
+	credentials map[string]federatedCredentialProperties
+}
+
+// fakeAzureAPI is an in-memory stand-in for the Key Vault, Azure Resource Manager and
+// Microsoft Entra ID token endpoints used by the provider
+type fakeAzureAPI struct {
+	mu             sync.Mutex
+	secrets        map[string]*fakeKeyVaultSecret
+	deletedSecrets map[string]*fakeKeyVaultSecret
+	identities     map[string]*fakeIdentity
+	assignments    map[string]roleAssignmentProperties
+	tokenRequests  []map[string]string
+	// denied makes every Key Vault and Resource Manager request fail with Forbidden
+	denied bool
+	server *httptest.Server
+}
+
+func newFakeAzureAPI() *fakeAzureAPI {
+	f := &fakeAzureAPI{
+		secrets:        make(map[string]*fakeKeyVaultSecret),
+		deletedSecrets: make(map[string]*fakeKeyVaultSecret),
+		identities:     make(map[string]*fakeIdentity),
+		assignments:    make(map[string]roleAssignmentProperties),
+	}
+	f.server = httptest.NewServer(http.HandlerFunc(f.handle))
+
+	return f
+}
+
+func (f *fakeAzureAPI) Close() {
+	f.server.Close()
+}
+
+func azureError(w http.ResponseWriter, code int, errCode string) {
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]*apiError{
+		"error": {Code: errCode, Message: errCode},
+	})
+}
+
+func azureJSON(w http.ResponseWriter, body interface{}) {
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func (f *fakeAzureAPI) handle(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if strings.HasSuffix(r.URL.Path, "/oauth2/v2.0/token") {
+		f.handleToken(w, r)
+		return
+	}
+
+	if r.URL.Query().Get("api-version") == "" {
+		azureError(w, http.StatusBadRequest, "MissingApiVersionParameter")
+		return
+	}
+
+	if f.denied {
+		azureError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case parts[0] == "secrets" && len(parts) == 1:
+		azureJSON(w, map[string]interface{}{"value": []interface{}{}})
+	case strings.Contains(r.URL.Path, "/providers/Microsoft.Authorization/roleAssignments/"):
+		f.handleRoleAssignment(w, r)
+	case strings.Contains(r.URL.Path, "/providers/Microsoft.ManagedIdentity/userAssignedIdentities/"):
+		f.handleIdentity(w, r, parts)
+	case parts[0] == "secrets" && len(parts) == 2:
+		f.handleSecret(w, r, parts[1])
+	case parts[0] == "deletedsecrets":
+		f.handleDeletedSecret(w, r, parts[1:])
+	default:
+		azureError(w, http.StatusNotFound, "NotFound")
+	}
+}
+
+func (f *fakeAzureAPI) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		azureError(w, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	request := map[string]string{}
+	for k := range r.PostForm {
+		request[k] = r.PostForm.Get(k)
+	}
+	f.tokenRequests = append(f.tokenRequests, request)
+
+	azureJSON(w, map[string]interface{}{
+		"access_token": "fake-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+	})
+}
+
+func (f *fakeAzureAPI) secretBundle(name string, secret *fakeKeyVaultSecret) *secretBundle {
+	return &secretBundle{
+		ID:          fmt.Sprintf("%s/secrets/%s/%d", f.server.URL, name, len(secret.values)),
+		ContentType: secret.contentType,
+		Tags:        secret.tags,
+		Attributes:  &secretAttributes{Updated: secret.updated.Unix()},
+	}
+}
+
+func (f *fakeAzureAPI) handleSecret(w http.ResponseWriter, r *http.Request, name string) {
+	secret, ok := f.secrets[name]
+
+	switch r.Method {
+	case http.MethodGet:
+		if !ok {
+			azureError(w, http.StatusNotFound, "SecretNotFound")
+			return
+		}
+		azureJSON(w, f.secretBundle(name, secret))
+	case http.MethodPut:
+		if _, deleted := f.deletedSecrets[name]; deleted {
+			azureError(w, http.StatusConflict, "ObjectIsDeletedButRecoverable")
+			return
+		}
+
+		body := &secretBundle{}
+		_ = json.NewDecoder(r.Body).Decode(body)
+		if !ok {
+			secret = &fakeKeyVaultSecret{}
+			f.secrets[name] = secret
+		}
+		secret.values = append(secret.values, body.Value)
+		secret.contentType = body.ContentType
+		secret.tags = body.Tags
+		secret.updated = time.Now()
+		azureJSON(w, f.secretBundle(name, secret))
+	case http.MethodDelete:
+		if !ok {
+			azureError(w, http.StatusNotFound, "SecretNotFound")
+			return
+		}
+		delete(f.secrets, name)
+		f.deletedSecrets[name] = secret
+		azureJSON(w, &deletedSecretBundle{
+			secretBundle:       *f.secretBundle(name, secret),
+			RecoveryID:         fmt.Sprintf("%s/deletedsecrets/%s", f.server.URL, name),
+			ScheduledPurgeDate: time.Now().Add(90 * 24 * time.Hour).Unix(),
+		})
+	}
+}
+
+func (f *fakeAzureAPI) handleDeletedSecret(w http.ResponseWriter, r *http.Request, parts []string) {
+	secret, ok := f.deletedSecrets[parts[0]]
+	if !ok {
+		azureError(w, http.StatusNotFound, "SecretNotFound")
+		return
+	}
+
+	if len(parts) == 2 && parts[1] == "recover" && r.Method == http.MethodPost {
+		f.secrets[parts[0]] = secret
+	}
+	delete(f.deletedSecrets, parts[0])
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (f *fakeAzureAPI) handleIdentity(w http.ResponseWriter, r *http.Request, parts []string) {
+	// subscriptions/<sub>/resourceGroups/<rg>/providers/Microsoft.ManagedIdentity/userAssignedIdentities/<name>[/federatedIdentityCredentials/<name>]
+	name := parts[7]
+	identity, ok := f.identities[name]
+
+	if len(parts) == 10 {
+		if !ok {
+			azureError(w, http.StatusNotFound, "ResourceNotFound")
+			return
+		}
+
+		if r.Method == http.MethodDelete {
+			if _, ok := identity.credentials[parts[9]]; !ok {
+				azureError(w, http.StatusNotFound, "ResourceNotFound")
+				return
+			}
+			delete(identity.credentials, parts[9])
+			return
+		}
+
+		body := &federatedCredential{}
+		_ = json.NewDecoder(r.Body).Decode(body)
+		identity.credentials[parts[9]] = body.Properties
+		azureJSON(w, body)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		body := &userAssignedIdentity{}
+		_ = json.NewDecoder(r.Body).Decode(body)
+		if !ok {
+			identity = &fakeIdentity{
+				clientID:    fmt.Sprintf("client-%s", name),
+				principalID: fmt.Sprintf("principal-%s", name),
+				credentials: make(map[string]federatedCredentialProperties),
+			}
+			f.identities[name] = identity
+		}
+		identity.location = body.Location
+
+		azureJSON(w, map[string]interface{}{
+			"id":         r.URL.Path,
+			"location":   identity.location,
+			"properties": map[string]string{"clientId": identity.clientID, "principalId": identity.principalID},
+		})
+	case http.MethodDelete:
+		if !ok {
+			azureError(w, http.StatusNotFound, "ResourceNotFound")
+			return
+		}
+		delete(f.identities, name)
+	}
+}
+
+func (f *fakeAzureAPI) handleRoleAssignment(w http.ResponseWriter, r *http.Request) {
+	_, ok := f.assignments[r.URL.Path]
+
+	switch r.Method {
+	case http.MethodPut:
+		if ok {
+			azureError(w, http.StatusConflict, "RoleAssignmentExists")
+			return
+		}
+
+		body := &roleAssignment{}
+		_ = json.NewDecoder(r.Body).Decode(body)
+		f.assignments[r.URL.Path] = body.Properties
+		w.WriteHeader(http.StatusCreated)
+		azureJSON(w, body)
+	case http.MethodDelete:
+		if !ok {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		delete(f.assignments, r.URL.Path)
+	}
+}
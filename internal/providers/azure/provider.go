@@ -0,0 +1,157 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"golang.org/x/oauth2"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/registry"
+)
+
+const (
+	keyVaultAPIVersion        = "7.4"
+	managementAPIVersion      = "2023-01-31"
+	roleAssignmentAPIVersion  = "2022-04-01"
+	defaultAuthorityHost      = "https://login.microsoftonline.com"
+	defaultManagementEndpoint = "https://management.azure.com"
+	keyVaultScope             = "https://vault.azure.net/.default"
+	managementScope           = "https://management.azure.com/.default"
+)
+
+// AzureProvider stores secrets in Azure Key Vault and grants access to them through
+// user-assigned managed identities federated with Kubernetes service accounts
+type AzureProvider struct {
+	AzureProviderConfig
+
+	vaultClient          *restClient
+	identityClient       *restClient
+	roleAssignmentClient *restClient
+}
+
+type AzureProviderConfig struct {
+	// VaultName is the name of the Key Vault, it must use the Azure RBAC permission model
+	VaultName string `json:"vaultName"`
+	// VaultURL overrides the Key Vault URL, defaults to https://<VaultName>.vault.azure.net
+	VaultURL string `json:"vaultURL"`
+	// VaultResourceGroup is the resource group of the Key Vault, defaults to ResourceGroup
+	VaultResourceGroup string `json:"vaultResourceGroup"`
+	SubscriptionID     string `json:"subscriptionID"`
+	// ResourceGroup and Location are where the managed identities of accesses are created
+	ResourceGroup string `json:"resourceGroup"`
+	Location      string `json:"location"`
+	// OIDCIssuer is the service account issuer of the cluster trusted by the federated credentials
+	OIDCIssuer string `json:"oidcIssuer"`
+	// TenantID and ClientID default to AZURE_TENANT_ID and AZURE_CLIENT_ID. The operator
+	// authenticates with AZURE_FEDERATED_TOKEN_FILE when set, otherwise with AZURE_CLIENT_SECRET.
+	TenantID           string `json:"tenantID"`
+	ClientID           string `json:"clientID"`
+	AuthorityHost      string `json:"authorityHost"`
+	ManagementEndpoint string `json:"managementEndpoint"`
+}
+
+func init() {
+	registry.Register(secretsv1alpha1.SecretProviderAzure, func(_ registry.Options) registry.Provider {
+		return &AzureProvider{}
+	}, registry.SchemaOf(AzureProviderConfig{}, "vaultName", "subscriptionID", "resourceGroup"))
+}
+
+func (p *AzureProvider) Init(config map[string]string) error {
+	providerConfig := &AzureProviderConfig{}
+	if err := mapstructure.Decode(config, providerConfig); err != nil {
+		return fmt.Errorf("decoding provider config: %w", err)
+	}
+
+	if providerConfig.TenantID == "" {
+		providerConfig.TenantID = os.Getenv("AZURE_TENANT_ID")
+	}
+	if providerConfig.ClientID == "" {
+		providerConfig.ClientID = os.Getenv("AZURE_CLIENT_ID")
+	}
+	if providerConfig.AuthorityHost == "" {
+		providerConfig.AuthorityHost = os.Getenv("AZURE_AUTHORITY_HOST")
+	}
+	if providerConfig.AuthorityHost == "" {
+		providerConfig.AuthorityHost = defaultAuthorityHost
+	}
+
+	credentials := tokenSource{
+		httpClient:         &http.Client{Timeout: 30 * time.Second},
+		authorityHost:      providerConfig.AuthorityHost,
+		tenantID:           providerConfig.TenantID,
+		clientID:           providerConfig.ClientID,
+		clientSecret:       os.Getenv("AZURE_CLIENT_SECRET"),
+		federatedTokenFile: os.Getenv("AZURE_FEDERATED_TOKEN_FILE"),
+	}
+	if credentials.tenantID == "" || credentials.clientID == "" {
+		return fmt.Errorf("unable to load azure credentials, tenantID and clientID are required")
+	}
+	if credentials.clientSecret == "" && credentials.federatedTokenFile == "" {
+		return fmt.Errorf("unable to load azure credentials, either AZURE_FEDERATED_TOKEN_FILE or AZURE_CLIENT_SECRET must be set")
+	}
+
+	return p.init(*providerConfig, authorizedClient(credentials, keyVaultScope), authorizedClient(credentials, managementScope))
+}
+
+func authorizedClient(credentials tokenSource, scope string) *http.Client {
+	credentials.scope = scope
+
+	return &http.Client{
+		Transport: &oauth2.Transport{Source: oauth2.ReuseTokenSource(nil, &credentials)},
+		Timeout:   30 * time.Second,
+	}
+}
+
+func (p *AzureProvider) init(config AzureProviderConfig, vaultHTTPClient, managementHTTPClient *http.Client) error {
+	if config.VaultName == "" {
+		return fmt.Errorf("vaultName is required")
+	}
+	if config.SubscriptionID == "" {
+		return fmt.Errorf("subscriptionID is required")
+	}
+	if config.ResourceGroup == "" {
+		return fmt.Errorf("resourceGroup is required")
+	}
+	if config.VaultURL == "" {
+		config.VaultURL = fmt.Sprintf("https://%s.vault.azure.net", config.VaultName)
+	}
+	if config.VaultResourceGroup == "" {
+		config.VaultResourceGroup = config.ResourceGroup
+	}
+	if config.ManagementEndpoint == "" {
+		config.ManagementEndpoint = defaultManagementEndpoint
+	}
+
+	p.AzureProviderConfig = config
+	p.vaultClient = &restClient{httpClient: vaultHTTPClient, endpoint: config.VaultURL, apiVersion: keyVaultAPIVersion}
+	p.identityClient = &restClient{httpClient: managementHTTPClient, endpoint: config.ManagementEndpoint, apiVersion: managementAPIVersion}
+	p.roleAssignmentClient = &restClient{httpClient: managementHTTPClient, endpoint: config.ManagementEndpoint, apiVersion: roleAssignmentAPIVersion}
+
+	return nil
+}
+
+// vaultResourceID is the Azure Resource Manager id of the Key Vault, used as the scope of role assignments
+func (p *AzureProvider) vaultResourceID() string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.KeyVault/vaults/%s", p.SubscriptionID, p.VaultResourceGroup, p.VaultName)
+}
+
+// CheckHealth lists the secrets of the Key Vault, which validates the credentials, the
+// connectivity to the vault and the permissions of the operator on it
+func (p *AzureProvider) CheckHealth(ctx context.Context) (*registry.Health, error) {
+	if err := p.vaultClient.do(ctx, http.MethodGet, "/secrets", nil, nil); err != nil {
+		return nil, fmt.Errorf("listing secrets: %w", err)
+	}
+
+	return &registry.Health{Identity: p.ClientID, Account: p.SubscriptionID, Region: p.Location}, nil
+}
+
+// Capabilities reports the features of Key Vault, deleted secrets are recoverable for the
+// retention period of the vault and secrets have no native rotation
+func (p *AzureProvider) Capabilities() registry.Capabilities {
+	return registry.Capabilities{RecoveryWindow: true, AccessControl: true}
+}
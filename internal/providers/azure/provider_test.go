@@ -0,0 +1,297 @@
+package azure
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/providertest"
+)
+
+const vaultID = "/subscriptions/sub/resourceGroups/vaults/providers/Microsoft.KeyVault/vaults/kv"
+
+func principalSubject(id string) secretsv1alpha1.SecretAccessSubject {
+	return secretsv1alpha1.SecretAccessSubject{
+		ProviderIdentifier: &secretsv1alpha1.SecretAccessSubjectProviderIdentifier{Identifier: id},
+	}
+}
+
+var _ = Describe("AzureProvider", func() {
+	var (
+		ctx      context.Context
+		fake     *fakeAzureAPI
+		provider *AzureProvider
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		fake = newFakeAzureAPI()
+
+		provider = &AzureProvider{}
+		Expect(provider.init(AzureProviderConfig{
+			VaultName:          "kv",
+			VaultURL:           fake.server.URL,
+			VaultResourceGroup: "vaults",
+			SubscriptionID:     "sub",
+			ResourceGroup:      "identities",
+			Location:           "westeurope",
+			OIDCIssuer:         "https://oidc.example.com/cluster",
+			ManagementEndpoint: fake.server.URL,
+		}, fake.server.Client(), fake.server.Client())).To(Succeed())
+	})
+
+	AfterEach(func() {
+		fake.Close()
+	})
+
+	Context("health", func() {
+		It("lists the secrets of the vault", func() {
+			health, err := provider.CheckHealth(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(health.Account).To(Equal("sub"))
+			Expect(health.Region).To(Equal("westeurope"))
+		})
+
+		It("reports missing permissions", func() {
+			fake.denied = true
+			_, err := provider.CheckHealth(ctx)
+			Expect(err).To(MatchError(ContainSubstring("Forbidden")))
+		})
+	})
+
+	Context("configuration", func() {
+		It("requires the vault, subscription and resource group", func() {
+			Expect((&AzureProvider{}).init(AzureProviderConfig{SubscriptionID: "sub", ResourceGroup: "rg"}, nil, nil)).NotTo(Succeed())
+			Expect((&AzureProvider{}).init(AzureProviderConfig{VaultName: "kv", ResourceGroup: "rg"}, nil, nil)).NotTo(Succeed())
+			Expect((&AzureProvider{}).init(AzureProviderConfig{VaultName: "kv", SubscriptionID: "sub"}, nil, nil)).NotTo(Succeed())
+
+			p := &AzureProvider{}
+			Expect(p.init(AzureProviderConfig{VaultName: "kv", SubscriptionID: "sub", ResourceGroup: "rg"}, nil, nil)).To(Succeed())
+			Expect(p.VaultURL).To(Equal("https://kv.vault.azure.net"))
+			Expect(p.VaultResourceGroup).To(Equal("rg"))
+		})
+
+		It("requires credentials", func() {
+			GinkgoT().Setenv("AZURE_TENANT_ID", "tenant")
+			GinkgoT().Setenv("AZURE_CLIENT_ID", "client")
+			GinkgoT().Setenv("AZURE_CLIENT_SECRET", "")
+			GinkgoT().Setenv("AZURE_FEDERATED_TOKEN_FILE", "")
+
+			Expect((&AzureProvider{}).Init(map[string]string{"vaultName": "kv", "subscriptionID": "sub", "resourceGroup": "rg"})).
+				To(MatchError(ContainSubstring("AZURE_FEDERATED_TOKEN_FILE")))
+		})
+
+		It("exchanges the federated token for an access token, reading it on every request", func() {
+			tokenFile := filepath.Join(GinkgoT().TempDir(), "token")
+			Expect(os.WriteFile(tokenFile, []byte("first-jwt"), 0o600)).To(Succeed())
+
+			source := &tokenSource{
+				httpClient:         fake.server.Client(),
+				authorityHost:      fake.server.URL,
+				tenantID:           "tenant",
+				clientID:           "client",
+				federatedTokenFile: tokenFile,
+				scope:              keyVaultScope,
+			}
+			token, err := source.Token()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(token.AccessToken).To(Equal("fake-token"))
+			Expect(token.Expiry).To(BeTemporally("~", time.Now().Add(time.Hour), time.Minute))
+
+			Expect(os.WriteFile(tokenFile, []byte("second-jwt"), 0o600)).To(Succeed())
+			_, err = source.Token()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fake.tokenRequests).To(HaveLen(2))
+			Expect(fake.tokenRequests[0]).To(HaveKeyWithValue("client_assertion", "first-jwt"))
+			Expect(fake.tokenRequests[1]).To(HaveKeyWithValue("client_assertion", "second-jwt"))
+			Expect(fake.tokenRequests[1]).To(HaveKeyWithValue("scope", keyVaultScope))
+			Expect(fake.tokenRequests[1]).NotTo(HaveKey("client_secret"))
+		})
+	})
+
+	Context("secrets", func() {
+		It("creates secrets with a valid Key Vault name", func() {
+			secret := providertest.NewSecret("team-a/db_password")
+			secret.Spec.ProviderSpec["ContentType"] = "text/plain"
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, secret, "hunter2")).To(Succeed())
+
+			Expect(fake.secrets).To(HaveKey("team-a-db-password"))
+			Expect(fake.secrets["team-a-db-password"].values).To(Equal([]string{"hunter2"}))
+			Expect(fake.secrets["team-a-db-password"].contentType).To(Equal("text/plain"))
+			Expect(fake.secrets["team-a-db-password"].tags).To(HaveKeyWithValue(managedByTag, "secretsbeam-operator"))
+			Expect(secret.Status.Provider).To(Equal(map[string]string{
+				"VaultURL":   fake.server.URL,
+				"SecretName": "team-a-db-password",
+				"SecretId":   fake.server.URL + "/secrets/team-a-db-password/1",
+			}))
+		})
+
+		It("only overwrites existing secrets when asked to", func() {
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, providertest.NewSecret("db"), "first")).To(Succeed())
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, providertest.NewSecret("db"), "second")).To(MatchError(ContainSubstring("already exists")))
+
+			secret := providertest.NewSecret("db")
+			secret.Spec.Overwrite = true
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, secret, "second")).To(Succeed())
+			Expect(fake.secrets["db"].values).To(Equal([]string{"first", "second"}))
+		})
+
+		It("recovers soft-deleted secrets only when overwriting", func() {
+			fake.deletedSecrets["db"] = &fakeKeyVaultSecret{values: []string{"old"}}
+
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, providertest.NewSecret("db"), "new")).To(MatchError(ContainSubstring("deleted but recoverable")))
+
+			secret := providertest.NewSecret("db")
+			secret.Spec.Overwrite = true
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, secret, "new")).To(Succeed())
+			Expect(fake.deletedSecrets).To(BeEmpty())
+			Expect(fake.secrets["db"].values).To(Equal([]string{"old", "new"}))
+		})
+
+		It("writes a new version on update and reports when it changed", func() {
+			secret := providertest.NewSecret("db")
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, secret, "first")).To(Succeed())
+			Expect(provider.UpdateSecret(ctx, GinkgoLogr, secret, "second")).To(Succeed())
+			Expect(secret.Status.Provider["SecretId"]).To(HaveSuffix("/secrets/db/2"))
+
+			changed, err := provider.GetSecretLastChangedDate(ctx, GinkgoLogr, secret)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changed.Unix()).To(Equal(fake.secrets["db"].updated.Unix()))
+		})
+
+		It("deletes and purges secrets without a recovery window", func() {
+			secret := providertest.NewSecret("db")
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, secret, "value")).To(Succeed())
+			Expect(provider.DeleteSecret(ctx, GinkgoLogr, secret)).To(Succeed())
+			Expect(fake.secrets).To(BeEmpty())
+			Expect(fake.deletedSecrets).To(BeEmpty())
+
+			By("ignoring secrets that are already gone")
+			Expect(provider.DeleteSecret(ctx, GinkgoLogr, secret)).To(Succeed())
+		})
+
+		It("soft deletes secrets with a recovery window until the vault purges them", func() {
+			secret := providertest.NewSecret("db")
+			secret.Spec.RecoveryWindow = 7
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, secret, "value")).To(Succeed())
+
+			Expect(provider.DeleteSecret(ctx, GinkgoLogr, secret)).To(MatchError(ContainSubstring("scheduled for deletion")))
+			Expect(fake.secrets).To(BeEmpty())
+			Expect(fake.deletedSecrets).To(HaveKey("db"))
+			Expect(secret.Status.DeletionDate).NotTo(BeNil())
+			Expect(secret.Status.DeletionDate.Time).To(BeTemporally("~", time.Now().Add(90*24*time.Hour), time.Minute))
+
+			By("waiting while the secret is recoverable")
+			Expect(provider.DeleteSecret(ctx, GinkgoLogr, secret)).NotTo(Succeed())
+			Expect(fake.deletedSecrets).To(HaveKey("db"))
+
+			By("finishing once the purge date is over")
+			past := metav1.NewTime(time.Now().Add(-time.Minute))
+			secret.Status.DeletionDate = &past
+			Expect(provider.DeleteSecret(ctx, GinkgoLogr, secret)).To(Succeed())
+		})
+	})
+
+	Context("access", func() {
+		var (
+			secret *secretsv1alpha1.ExternalSecret
+			access *secretsv1alpha1.ExternalSecretAccess
+		)
+
+		BeforeEach(func() {
+			secret = providertest.NewSecret("db")
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, secret, "value")).To(Succeed())
+
+			access = &secretsv1alpha1.ExternalSecretAccess{
+				ObjectMeta: metav1.ObjectMeta{Name: "reader", Namespace: "team-a"},
+				Spec: secretsv1alpha1.ExternalSecretAccessSpec{
+					SecretName: "db",
+					AccessSubjects: []secretsv1alpha1.SecretAccessSubject{
+						providertest.ServiceAccountSubject("team-a", "api"),
+						providertest.ServiceAccountSubject("team-b", "worker"),
+						principalSubject("group-object-id"),
+					},
+				},
+				Status: secretsv1alpha1.ExternalSecretAccessStatus{
+					Provider: map[string]string{},
+				},
+			}
+			Expect(provider.CreateAccess(ctx, GinkgoLogr, secret, access)).To(Succeed())
+		})
+
+		It("creates a managed identity federated with the service accounts", func() {
+			Expect(fake.identities).To(HaveKey("sb-team-a-reader"))
+			identity := fake.identities["sb-team-a-reader"]
+			Expect(identity.location).To(Equal("westeurope"))
+			Expect(identity.credentials).To(Equal(map[string]federatedCredentialProperties{
+				"team-a-api": {
+					Issuer:    "https://oidc.example.com/cluster",
+					Subject:   "system:serviceaccount:team-a:api",
+					Audiences: []string{tokenExchangeAudience},
+				},
+				"team-b-worker": {
+					Issuer:    "https://oidc.example.com/cluster",
+					Subject:   "system:serviceaccount:team-b:worker",
+					Audiences: []string{tokenExchangeAudience},
+				},
+			}))
+
+			Expect(access.Status.Provider).To(HaveKeyWithValue("IdentityName", "sb-team-a-reader"))
+			Expect(access.Status.Provider).To(HaveKeyWithValue("ClientId", "client-sb-team-a-reader"))
+			Expect(access.Status.Provider).To(HaveKeyWithValue("PrincipalId", "principal-sb-team-a-reader"))
+			Expect(access.Status.Provider).To(HaveKeyWithValue("ServiceAccountAnnotation", "azure.workload.identity/client-id=client-sb-team-a-reader"))
+			Expect(access.Status.Provider).To(HaveKeyWithValue("FederatedCredentials", "team-a-api,team-b-worker"))
+		})
+
+		It("assigns Key Vault Secrets User on the secret to the identity and principals", func() {
+			Expect(fake.assignments).To(HaveLen(2))
+			principals := make([]string, 0)
+			for id, assignment := range fake.assignments {
+				Expect(id).To(HavePrefix(vaultID + "/secrets/db/providers/Microsoft.Authorization/roleAssignments/"))
+				Expect(assignment.RoleDefinitionID).To(Equal("/subscriptions/sub/providers/Microsoft.Authorization/roleDefinitions/" + keyVaultSecretsUserRole))
+				principals = append(principals, assignment.PrincipalID)
+			}
+			Expect(principals).To(ConsistOf("principal-sb-team-a-reader", "group-object-id"))
+
+			By("reusing the same assignments when reconciling again")
+			Expect(provider.UpdateAccess(ctx, GinkgoLogr, secret, access)).To(Succeed())
+			Expect(fake.assignments).To(HaveLen(2))
+		})
+
+		It("removes credentials and role assignments of dropped subjects on update", func() {
+			access.Spec.AccessSubjects = []secretsv1alpha1.SecretAccessSubject{
+				providertest.ServiceAccountSubject("team-a", "api"),
+			}
+			Expect(provider.UpdateAccess(ctx, GinkgoLogr, secret, access)).To(Succeed())
+
+			Expect(fake.identities["sb-team-a-reader"].credentials).To(HaveLen(1))
+			Expect(fake.identities["sb-team-a-reader"].credentials).To(HaveKey("team-a-api"))
+			Expect(fake.assignments).To(HaveLen(1))
+			for _, assignment := range fake.assignments {
+				Expect(assignment.PrincipalID).To(Equal("principal-sb-team-a-reader"))
+			}
+			Expect(access.Status.Provider).To(HaveKeyWithValue("FederatedCredentials", "team-a-api"))
+		})
+
+		It("requires the cluster issuer for service account subjects", func() {
+			provider.OIDCIssuer = ""
+			Expect(provider.UpdateAccess(ctx, GinkgoLogr, secret, access)).To(MatchError(ContainSubstring("oidcIssuer")))
+		})
+
+		It("removes the role assignments and the identity on delete", func() {
+			Expect(provider.DeleteAccess(ctx, GinkgoLogr, access)).To(Succeed())
+			Expect(fake.assignments).To(BeEmpty())
+			Expect(fake.identities).To(BeEmpty())
+
+			By("ignoring resources that are already gone")
+			Expect(provider.DeleteAccess(ctx, GinkgoLogr, access)).To(Succeed())
+		})
+	})
+})
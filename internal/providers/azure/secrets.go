@@ -0,0 +1,183 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const managedByTag = "managed-by"
+
+var invalidSecretNameChars = regexp.MustCompile(`[^a-zA-Z0-9-]+`)
+
+type secretAttributes struct {
+	Enabled *bool `json:"enabled,omitempty"`
+	Created int64 `json:"created,omitempty"`
+	Updated int64 `json:"updated,omitempty"`
+}
+
+type secretBundle struct {
+	ID          string            `json:"id,omitempty"`
+	Value       string            `json:"value,omitempty"`
+	ContentType string            `json:"contentType,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Attributes  *secretAttributes `json:"attributes,omitempty"`
+}
+
+type deletedSecretBundle struct {
+	secretBundle
+	RecoveryID         string `json:"recoveryId"`
+	ScheduledPurgeDate int64  `json:"scheduledPurgeDate"`
+}
+
+// toSecretName converts an external name into a valid Key Vault secret name
+func toSecretName(name string) string {
+	return strings.Trim(invalidSecretNameChars.ReplaceAllString(name, "-"), "-")
+}
+
+func secretName(secret *secretsv1alpha1.ExternalSecret) string {
+	if val, ok := secret.Status.Provider["SecretName"]; ok && val != "" {
+		return val
+	}
+
+	name := secret.Status.SecretName
+	if secret.Spec.ExternalName != nil {
+		name = *secret.Spec.ExternalName
+	}
+
+	return toSecretName(name)
+}
+
+// DeleteSecret purges secrets without a recovery window. Otherwise the secret is soft-deleted and
+// kept recoverable for the retention period of the vault, which Key Vault sets per vault.
+func (p *AzureProvider) DeleteSecret(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret) error {
+	name := secretName(secret)
+
+	if secret.Spec.RecoveryWindow > 0 && secret.Status.DeletionDate != nil {
+		if time.Now().Before(secret.Status.DeletionDate.Time) {
+			return fmt.Errorf("secret scheduled for deletion in %s", secret.Status.DeletionDate.Time.String())
+		}
+
+		return nil
+	}
+
+	deleted := &deletedSecretBundle{}
+	found := true
+	if err := p.vaultClient.do(ctx, http.MethodDelete, fmt.Sprintf("/secrets/%s", name), nil, deleted); err != nil {
+		if !isNotFound(err) {
+			return fmt.Errorf("failed to delete secret: %w", err)
+		}
+
+		reqLogger.Info(fmt.Sprintf("Secret %s not found, ignoring", name))
+		found = false
+	}
+
+	if secret.Spec.RecoveryWindow > 0 {
+		if !found {
+			return nil
+		}
+
+		reqLogger.Info(fmt.Sprintf("Key Vault applies the retention period of the vault instead of the %d days recovery window", secret.Spec.RecoveryWindow))
+		t := v1.NewTime(time.Unix(deleted.ScheduledPurgeDate, 0))
+		secret.Status.DeletionDate = &t
+		return fmt.Errorf("secret scheduled for deletion in %s", t.Time.String())
+	}
+
+	if err := p.vaultClient.do(ctx, http.MethodDelete, fmt.Sprintf("/deletedsecrets/%s", name), nil, nil); err != nil {
+		// the deletion completes asynchronously, retry until the deleted secret can be purged
+		if found && (isNotFound(err) || isConflict(err)) {
+			return fmt.Errorf("waiting for secret %s to be deleted before purging it", name)
+		}
+		if !isNotFound(err) {
+			return fmt.Errorf("failed to purge secret: %w", err)
+		}
+	}
+	reqLogger.Info(fmt.Sprintf("Purged secret %s", name))
+
+	return nil
+}
+
+func (p *AzureProvider) CreateSecret(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, value string) error {
+	name := secretName(secret)
+
+	if !secret.Spec.Overwrite {
+		err := p.vaultClient.do(ctx, http.MethodGet, fmt.Sprintf("/secrets/%s", name), nil, nil)
+		if err == nil {
+			return fmt.Errorf("secret %s already exists and overwrite is not set", name)
+		}
+		if !isNotFound(err) {
+			return fmt.Errorf("failed to describe secret: %w", err)
+		}
+	}
+
+	result, err := p.setSecret(ctx, secret, name, value)
+	if err != nil && isConflict(err) {
+		// a soft-deleted secret with the same name blocks the creation until it is recovered or purged
+		if !secret.Spec.Overwrite {
+			return fmt.Errorf("secret %s is deleted but recoverable and overwrite is not set", name)
+		}
+
+		reqLogger.Info(fmt.Sprintf("Recovering deleted secret %s to overwrite it", name))
+		if err := p.vaultClient.do(ctx, http.MethodPost, fmt.Sprintf("/deletedsecrets/%s/recover", name), nil, nil); err != nil {
+			return fmt.Errorf("failed to recover secret: %w", err)
+		}
+
+		result, err = p.setSecret(ctx, secret, name, value)
+	}
+	if err != nil {
+		reqLogger.Error(err, "Failed to create Secret")
+		return err
+	}
+
+	secret.Status.Provider["VaultURL"] = p.VaultURL
+	secret.Status.Provider["SecretName"] = name
+	secret.Status.Provider["SecretId"] = result.ID
+
+	return nil
+}
+
+func (p *AzureProvider) GetSecretLastChangedDate(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret) (*time.Time, error) {
+	result := &secretBundle{}
+	if err := p.vaultClient.do(ctx, http.MethodGet, fmt.Sprintf("/secrets/%s", secretName(secret)), nil, result); err != nil {
+		return nil, fmt.Errorf("failed to describe secret: %w", err)
+	}
+	if result.Attributes == nil {
+		return nil, fmt.Errorf("secret %s has no attributes", secretName(secret))
+	}
+
+	t := time.Unix(result.Attributes.Updated, 0)
+	return &t, nil
+}
+
+func (p *AzureProvider) UpdateSecret(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, value string) error {
+	name := secretName(secret)
+
+	result, err := p.setSecret(ctx, secret, name, value)
+	if err != nil {
+		return fmt.Errorf("failed to update secret: %w", err)
+	}
+
+	secret.Status.Provider["SecretName"] = name
+	secret.Status.Provider["SecretId"] = result.ID
+
+	return nil
+}
+
+// setSecret writes a new version of the secret
+func (p *AzureProvider) setSecret(ctx context.Context, secret *secretsv1alpha1.ExternalSecret, name, value string) (*secretBundle, error) {
+	result := &secretBundle{}
+	err := p.vaultClient.do(ctx, http.MethodPut, fmt.Sprintf("/secrets/%s", name), &secretBundle{
+		Value:       value,
+		ContentType: secret.Spec.ProviderSpec["ContentType"],
+		Tags:        map[string]string{managedByTag: "secretsbeam-operator"},
+	}, result)
+
+	return result, err
+}
@@ -0,0 +1,14 @@
+package azure
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestAzureProvider(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Azure Provider Suite")
+}
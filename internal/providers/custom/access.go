@@ -0,0 +1,49 @@
+package custom
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/registry"
+)
+
+func toAccess(access *secretsv1alpha1.ExternalSecretAccess) Access {
+	return Access{
+		Namespace: access.Namespace,
+		Name:      access.Name,
+		Subjects:  access.Spec.AccessSubjects,
+		Provider:  access.Status.Provider,
+	}
+}
+
+func (p *CustomProvider) CreateAccess(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, access *secretsv1alpha1.ExternalSecretAccess) error {
+	result, err := p.call(ctx, PathCreateAccess, &AccessRequest{Secret: toSecret(secret), Access: toAccess(access)})
+	if err != nil {
+		return fmt.Errorf("failed to create access: %w", err)
+	}
+
+	registry.MergeProviderStatus(&access.Status.Provider, result.Provider)
+
+	return nil
+}
+
+func (p *CustomProvider) UpdateAccess(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, access *secretsv1alpha1.ExternalSecretAccess) error {
+	result, err := p.call(ctx, PathUpdateAccess, &AccessRequest{Secret: toSecret(secret), Access: toAccess(access)})
+	if err != nil {
+		return fmt.Errorf("failed to update access: %w", err)
+	}
+
+	registry.MergeProviderStatus(&access.Status.Provider, result.Provider)
+
+	return nil
+}
+
+func (p *CustomProvider) DeleteAccess(ctx context.Context, reqLogger logr.Logger, access *secretsv1alpha1.ExternalSecretAccess) error {
+	if _, err := p.call(ctx, PathDeleteAccess, &AccessRequest{Access: toAccess(access)}); err != nil {
+		return fmt.Errorf("failed to delete access: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package custom
+
+import (
+	"time"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+)
+
+// ProtocolVersion is the version of the custom provider protocol spoken by this client.
+// It is sent in the ProtocolVersionHeader of every request and used as the path prefix.
+// See docs/providers/custom.md for the full specification.
+const (
+	ProtocolVersion       = "v1"
+	ProtocolVersionHeader = "X-Secretsbeam-Protocol-Version"
+)
+
+// Paths of the protocol operations, relative to the provider url
+const (
+	PathCreateSecret             = "/" + ProtocolVersion + "/secrets/create"
+	PathUpdateSecret             = "/" + ProtocolVersion + "/secrets/update"
+	PathDeleteSecret             = "/" + ProtocolVersion + "/secrets/delete"
+	PathGetSecretLastChangedDate = "/" + ProtocolVersion + "/secrets/last-changed"
+	PathCreateAccess             = "/" + ProtocolVersion + "/access/create"
+	PathUpdateAccess             = "/" + ProtocolVersion + "/access/update"
+	PathDeleteAccess             = "/" + ProtocolVersion + "/access/delete"
+)
+
+// Secret is the representation of an ExternalSecret sent to the provider
+type Secret struct {
+	Namespace      string            `json:"namespace"`
+	Name           string            `json:"name"`
+	ExternalName   string            `json:"externalName"`
+	RecoveryWindow int64             `json:"recoveryWindow,omitempty"`
+	ProviderSpec   map[string]string `json:"providerSpec,omitempty"`
+	// Provider is the provider state previously returned for this secret
+	Provider map[string]string `json:"provider,omitempty"`
+}
+
+// Access is the representation of an ExternalSecretAccess sent to the provider
+type Access struct {
+	Namespace string                                `json:"namespace"`
+	Name      string                                `json:"name"`
+	Subjects  []secretsv1alpha1.SecretAccessSubject `json:"subjects,omitempty"`
+	// Provider is the provider state previously returned for this access
+	Provider map[string]string `json:"provider,omitempty"`
+}
+
+// SecretRequest is the body of the secret operations. Value is only set on create and update.
+type SecretRequest struct {
+	Secret Secret  `json:"secret"`
+	Value  *string `json:"value,omitempty"`
+}
+
+// AccessRequest is the body of the access operations. Secret is not set on delete.
+type AccessRequest struct {
+	Secret *Secret `json:"secret,omitempty"`
+	Access Access  `json:"access"`
+}
+
+// Response is the body of a successful operation
+type Response struct {
+	// Provider entries are merged into the provider state of the object, empty values remove the entry
+	Provider map[string]string `json:"provider,omitempty"`
+	// DeletionDate is set by delete secret when the secret is scheduled for deletion
+	DeletionDate *time.Time `json:"deletionDate,omitempty"`
+	// LastChangedDate is set by last-changed
+	LastChangedDate *time.Time `json:"lastChangedDate,omitempty"`
+}
+
+// ErrorResponse is the body of a failed operation
+type ErrorResponse struct {
+	Message string `json:"message"`
+}
@@ -0,0 +1,213 @@
+package custom
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/registry"
+)
+
+const (
+	defaultTimeout        = 30 * time.Second
+	defaultTokenSecretKey = "token"
+	defaultCASecretKey    = "ca.crt"
+)
+
+// CustomProvider delegates every operation to an external service speaking the custom provider protocol
+type CustomProvider struct {
+	CustomProviderConfig
+
+	// Client and Namespace are used to read the token and CA secrets referenced by the config
+	Client    client.Client
+	Namespace string
+
+	httpClient *http.Client
+	// capabilities are parsed from the Capabilities of the config
+	capabilities registry.Capabilities
+}
+
+type CustomProviderConfig struct {
+	Url string `json:"url"`
+	// Timeout is a duration string applied to every request, defaults to 30s
+	Timeout string `json:"timeout"`
+	// TokenSecretName references a Secret in the provider namespace holding a bearer token
+	TokenSecretName string `json:"tokenSecretName"`
+	TokenSecretKey  string `json:"tokenSecretKey"`
+	// CASecretName references a Secret in the provider namespace holding the CA bundle of the service
+	CASecretName       string `json:"caSecretName"`
+	CASecretKey        string `json:"caSecretKey"`
+	InsecureSkipVerify string `json:"insecureSkipVerify"`
+	// Capabilities is a comma separated list of the capabilities of the service, it supports none
+	// when it is empty
+	Capabilities string `json:"capabilities"`
+}
+
+func init() {
+	registry.Register(secretsv1alpha1.SecretProviderCustom, func(opts registry.Options) registry.Provider {
+		return &CustomProvider{Client: opts.Client, Namespace: opts.Namespace}
+	}, registry.SchemaOf(CustomProviderConfig{}, "url"))
+}
+
+func (p *CustomProvider) Init(config map[string]string) error {
+	providerConfig := &CustomProviderConfig{}
+	if err := mapstructure.Decode(config, providerConfig); err != nil {
+		return fmt.Errorf("decoding provider config: %w", err)
+	}
+
+	if providerConfig.Url == "" {
+		return fmt.Errorf("url is required")
+	}
+	if providerConfig.TokenSecretKey == "" {
+		providerConfig.TokenSecretKey = defaultTokenSecretKey
+	}
+	if providerConfig.CASecretKey == "" {
+		providerConfig.CASecretKey = defaultCASecretKey
+	}
+
+	timeout := defaultTimeout
+	if providerConfig.Timeout != "" {
+		val, err := time.ParseDuration(providerConfig.Timeout)
+		if err != nil {
+			return fmt.Errorf("parsing timeout: %w", err)
+		}
+		timeout = val
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if providerConfig.InsecureSkipVerify != "" {
+		val, err := strconv.ParseBool(providerConfig.InsecureSkipVerify)
+		if err != nil {
+			return fmt.Errorf("parsing insecureSkipVerify: %w", err)
+		}
+		tlsConfig.InsecureSkipVerify = val
+	}
+
+	capabilities := make([]string, 0)
+	for _, name := range strings.Split(providerConfig.Capabilities, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			capabilities = append(capabilities, name)
+		}
+	}
+	var err error
+	if p.capabilities, err = registry.ParseCapabilities(capabilities); err != nil {
+		return fmt.Errorf("parsing capabilities: %w", err)
+	}
+
+	p.CustomProviderConfig = *providerConfig
+
+	if p.CASecretName != "" {
+		ca, err := p.readSecretKey(context.Background(), p.CASecretName, p.CASecretKey)
+		if err != nil {
+			return fmt.Errorf("reading CA bundle: %w", err)
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return fmt.Errorf("no certificates found in CA bundle %s/%s", p.Namespace, p.CASecretName)
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	p.httpClient = &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+
+	return nil
+}
+
+// Capabilities reports the capabilities listed in the config
+func (p *CustomProvider) Capabilities() registry.Capabilities {
+	return p.capabilities
+}
+
+func (p *CustomProvider) readSecretKey(ctx context.Context, name, key string) ([]byte, error) {
+	if p.Client == nil {
+		return nil, fmt.Errorf("no kubernetes client configured to read secret %s", name)
+	}
+
+	secret := &corev1.Secret{}
+	if err := p.Client.Get(ctx, types.NamespacedName{Namespace: p.Namespace, Name: name}, secret); err != nil {
+		return nil, fmt.Errorf("getting secret %s/%s: %w", p.Namespace, name, err)
+	}
+
+	val, ok := secret.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("key %s not found in secret %s/%s", key, p.Namespace, name)
+	}
+
+	return val, nil
+}
+
+// call sends a protocol request to the provider service and decodes its response
+func (p *CustomProvider) call(ctx context.Context, path string, in interface{}) (*Response, error) {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(p.Url, "/")+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(ProtocolVersionHeader, ProtocolVersion)
+
+	// the token is read on every call so that rotations are picked up without restarting
+	if p.TokenSecretName != "" {
+		token, err := p.readSecretKey(ctx, p.TokenSecretName, p.TokenSecretKey)
+		if err != nil {
+			return nil, fmt.Errorf("reading token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("calling %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if version := resp.Header.Get(ProtocolVersionHeader); version != "" && version != ProtocolVersion {
+		return nil, fmt.Errorf("provider speaks protocol %s, expected %s", version, ProtocolVersion)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		errResp := &ErrorResponse{}
+		if err := json.Unmarshal(body, errResp); err != nil || errResp.Message == "" {
+			errResp.Message = strings.TrimSpace(string(body))
+		}
+
+		return nil, fmt.Errorf("%s failed with status %d: %s", path, resp.StatusCode, errResp.Message)
+	}
+
+	result := &Response{}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, result); err != nil {
+			return nil, fmt.Errorf("unmarshalling response: %w", err)
+		}
+	}
+
+	return result, nil
+}
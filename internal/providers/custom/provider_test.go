@@ -0,0 +1,273 @@
+package custom
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/providertest"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/registry"
+)
+
+// recordedCall is a request received by the test provider service
+type recordedCall struct {
+	Path    string
+	Header  http.Header
+	Secret  *SecretRequest
+	Access  *AccessRequest
+	Version string
+}
+
+type testService struct {
+	mu       sync.Mutex
+	calls    []recordedCall
+	response func(path string) (int, interface{})
+	server   *httptest.Server
+}
+
+func newTestService() *testService {
+	s := &testService{
+		response: func(string) (int, interface{}) { return http.StatusOK, &Response{} },
+	}
+	s.server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		call := recordedCall{Path: r.URL.Path, Header: r.Header.Clone(), Version: r.Header.Get(ProtocolVersionHeader)}
+		switch r.URL.Path {
+		case PathCreateAccess, PathUpdateAccess, PathDeleteAccess:
+			call.Access = &AccessRequest{}
+			_ = json.NewDecoder(r.Body).Decode(call.Access)
+		default:
+			call.Secret = &SecretRequest{}
+			_ = json.NewDecoder(r.Body).Decode(call.Secret)
+		}
+		s.calls = append(s.calls, call)
+
+		code, body := s.response(r.URL.Path)
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(body)
+	}))
+
+	return s
+}
+
+func (s *testService) lastCall() recordedCall {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.calls[len(s.calls)-1]
+}
+
+func (s *testService) caBundle() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.server.Certificate().Raw})
+}
+
+// newSecret returns a secret of the fixtures with a recovery window and a path
+func newSecret(name string) *secretsv1alpha1.ExternalSecret {
+	secret := providertest.NewSecret(name)
+	secret.Spec.RecoveryWindow = 7
+	secret.Spec.ProviderSpec["Path"] = "kv/team-a"
+
+	return secret
+}
+
+var _ = Describe("CustomProvider", func() {
+	var (
+		ctx      context.Context
+		service  *testService
+		cli      client.Client
+		provider *CustomProvider
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		service = newTestService()
+		cli = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "bridge", Namespace: "operator"},
+				Data: map[string][]byte{
+					"token":  []byte("t0ken\n"),
+					"ca.crt": service.caBundle(),
+				},
+			},
+		).Build()
+
+		provider = &CustomProvider{Client: cli, Namespace: "operator"}
+		Expect(provider.Init(map[string]string{
+			"url":             service.server.URL,
+			"timeout":         "2s",
+			"tokenSecretName": "bridge",
+			"caSecretName":    "bridge",
+		})).To(Succeed())
+	})
+
+	AfterEach(func() {
+		service.server.Close()
+	})
+
+	Context("configuration", func() {
+		It("requires a url", func() {
+			Expect((&CustomProvider{}).Init(map[string]string{})).NotTo(Succeed())
+		})
+
+		It("rejects invalid timeouts", func() {
+			Expect((&CustomProvider{}).Init(map[string]string{"url": "https://x", "timeout": "soon"})).NotTo(Succeed())
+		})
+
+		It("reports the capabilities of the config", func() {
+			Expect(registry.CapabilitiesOf(provider)).To(Equal(registry.Capabilities{}), "services support nothing unless they are listed")
+
+			p := &CustomProvider{}
+			Expect(p.Init(map[string]string{"url": "https://x", "capabilities": "AccessControl, RecoveryWindow"})).To(Succeed())
+			Expect(registry.CapabilitiesOf(p)).To(Equal(registry.AllCapabilities))
+
+			Expect(p.Init(map[string]string{"url": "https://x", "capabilities": "Rotation"})).
+				To(MatchError(`parsing capabilities: unknown capability "Rotation"`))
+		})
+
+		It("fails when the referenced CA secret is missing", func() {
+			p := &CustomProvider{Client: cli, Namespace: "operator"}
+			Expect(p.Init(map[string]string{"url": service.server.URL, "caSecretName": "missing"})).NotTo(Succeed())
+		})
+
+		It("does not trust the service without its CA", func() {
+			p := &CustomProvider{}
+			Expect(p.Init(map[string]string{"url": service.server.URL})).To(Succeed())
+			Expect(p.CreateSecret(ctx, GinkgoLogr, newSecret("db"), "value")).NotTo(Succeed())
+		})
+
+		It("times out slow services", func() {
+			slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(500 * time.Millisecond)
+			}))
+			defer slow.Close()
+
+			p := &CustomProvider{}
+			Expect(p.Init(map[string]string{"url": slow.URL, "timeout": "50ms"})).To(Succeed())
+			Expect(p.CreateSecret(ctx, GinkgoLogr, newSecret("db"), "value")).To(MatchError(ContainSubstring("Timeout")))
+		})
+	})
+
+	Context("secrets", func() {
+		It("sends versioned, authenticated requests and stores the returned state", func() {
+			service.response = func(string) (int, interface{}) {
+				return http.StatusOK, &Response{Provider: map[string]string{"Id": "42"}}
+			}
+
+			secret := newSecret("db")
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, secret, "s3cr3t")).To(Succeed())
+
+			call := service.lastCall()
+			Expect(call.Path).To(Equal("/v1/secrets/create"))
+			Expect(call.Version).To(Equal("v1"))
+			Expect(call.Header.Get("Authorization")).To(Equal("Bearer t0ken"))
+			Expect(*call.Secret.Value).To(Equal("s3cr3t"))
+			Expect(call.Secret.Secret).To(Equal(Secret{
+				Namespace:      "team-a",
+				Name:           "db",
+				ExternalName:   "db",
+				RecoveryWindow: 7,
+				ProviderSpec:   map[string]string{"Path": "kv/team-a"},
+			}))
+			Expect(secret.Status.Provider).To(Equal(map[string]string{"Id": "42"}))
+		})
+
+		It("sends back the provider state and merges updates", func() {
+			secret := newSecret("db")
+			secret.Status.Provider = map[string]string{"Id": "42", "Stale": "yes"}
+			service.response = func(string) (int, interface{}) {
+				return http.StatusOK, &Response{Provider: map[string]string{"Version": "2", "Stale": ""}}
+			}
+
+			Expect(provider.UpdateSecret(ctx, GinkgoLogr, secret, "n3w")).To(Succeed())
+			Expect(service.lastCall().Secret.Secret.Provider).To(Equal(map[string]string{"Id": "42", "Stale": "yes"}))
+			Expect(secret.Status.Provider).To(Equal(map[string]string{"Id": "42", "Version": "2"}))
+		})
+
+		It("reports deletion and last changed dates", func() {
+			when := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+			service.response = func(path string) (int, interface{}) {
+				return http.StatusOK, &Response{DeletionDate: &when, LastChangedDate: &when}
+			}
+
+			secret := newSecret("db")
+			changed, err := provider.GetSecretLastChangedDate(ctx, GinkgoLogr, secret)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changed.Equal(when)).To(BeTrue())
+			Expect(service.lastCall().Secret.Value).To(BeNil())
+
+			Expect(provider.DeleteSecret(ctx, GinkgoLogr, secret)).To(Succeed())
+			Expect(service.lastCall().Path).To(Equal("/v1/secrets/delete"))
+			Expect(secret.Status.DeletionDate.Time.Equal(when)).To(BeTrue())
+		})
+
+		It("surfaces error messages from the service", func() {
+			service.response = func(string) (int, interface{}) {
+				return http.StatusConflict, &ErrorResponse{Message: "secret already exists"}
+			}
+
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, newSecret("db"), "value")).
+				To(MatchError(ContainSubstring("secret already exists")))
+		})
+
+		It("refuses responses from another protocol version", func() {
+			other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set(ProtocolVersionHeader, "v2")
+				_, _ = w.Write([]byte("{}"))
+			}))
+			defer other.Close()
+
+			p := &CustomProvider{}
+			Expect(p.Init(map[string]string{"url": other.URL})).To(Succeed())
+			Expect(p.UpdateSecret(ctx, GinkgoLogr, newSecret("db"), "value")).To(MatchError(ContainSubstring("protocol v2")))
+		})
+	})
+
+	Context("access", func() {
+		It("sends the secret and the subjects and stores the returned state", func() {
+			service.response = func(string) (int, interface{}) {
+				return http.StatusOK, &Response{Provider: map[string]string{"ServiceAccountAnnotation": "bridge.io/role=reader"}}
+			}
+
+			secret := newSecret("db")
+			access := &secretsv1alpha1.ExternalSecretAccess{
+				ObjectMeta: metav1.ObjectMeta{Name: "reader", Namespace: "team-a"},
+				Spec: secretsv1alpha1.ExternalSecretAccessSpec{
+					SecretName: "db",
+					AccessSubjects: []secretsv1alpha1.SecretAccessSubject{
+						{ServiceAccount: &secretsv1alpha1.SecretAccessSubjectServiceAccount{Namespace: "team-a", Name: "api"}},
+					},
+				},
+			}
+
+			Expect(provider.CreateAccess(ctx, GinkgoLogr, secret, access)).To(Succeed())
+			call := service.lastCall()
+			Expect(call.Path).To(Equal("/v1/access/create"))
+			Expect(call.Access.Secret.Name).To(Equal("db"))
+			Expect(call.Access.Access.Subjects).To(Equal(access.Spec.AccessSubjects))
+			Expect(access.Status.Provider).To(HaveKeyWithValue("ServiceAccountAnnotation", "bridge.io/role=reader"))
+
+			Expect(provider.UpdateAccess(ctx, GinkgoLogr, secret, access)).To(Succeed())
+			Expect(service.lastCall().Path).To(Equal("/v1/access/update"))
+			Expect(service.lastCall().Access.Access.Provider).To(HaveKey("ServiceAccountAnnotation"))
+
+			Expect(provider.DeleteAccess(ctx, GinkgoLogr, access)).To(Succeed())
+			Expect(service.lastCall().Path).To(Equal("/v1/access/delete"))
+			Expect(service.lastCall().Access.Secret).To(BeNil())
+		})
+	})
+})
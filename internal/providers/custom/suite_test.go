@@ -0,0 +1,14 @@
+package custom
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestCustomProvider(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Custom Provider Suite")
+}
@@ -0,0 +1,50 @@
+package execplugin
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/registry"
+	execv1 "github.com/tiagoposse/secretsbeam-operator/pkg/plugin/exec/v1"
+)
+
+func toAccess(access *secretsv1alpha1.ExternalSecretAccess) *execv1.Access {
+	return &execv1.Access{
+		Namespace: access.Namespace,
+		Name:      access.Name,
+		Subjects:  access.Spec.AccessSubjects,
+		Provider:  access.Status.Provider,
+	}
+}
+
+func (p *ExecPluginProvider) CreateAccess(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, access *secretsv1alpha1.ExternalSecretAccess) error {
+	result, err := p.call(ctx, execv1.OperationCreateAccess, &execv1.Request{Secret: toSecret(secret), Access: toAccess(access)})
+	if err != nil {
+		return fmt.Errorf("failed to create access: %w", err)
+	}
+
+	registry.MergeProviderStatus(&access.Status.Provider, result.Provider)
+
+	return nil
+}
+
+func (p *ExecPluginProvider) UpdateAccess(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, access *secretsv1alpha1.ExternalSecretAccess) error {
+	result, err := p.call(ctx, execv1.OperationUpdateAccess, &execv1.Request{Secret: toSecret(secret), Access: toAccess(access)})
+	if err != nil {
+		return fmt.Errorf("failed to update access: %w", err)
+	}
+
+	registry.MergeProviderStatus(&access.Status.Provider, result.Provider)
+
+	return nil
+}
+
+func (p *ExecPluginProvider) DeleteAccess(ctx context.Context, reqLogger logr.Logger, access *secretsv1alpha1.ExternalSecretAccess) error {
+	if _, err := p.call(ctx, execv1.OperationDeleteAccess, &execv1.Request{Access: toAccess(access)}); err != nil {
+		return fmt.Errorf("failed to delete access: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package execplugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	execv1 "github.com/tiagoposse/secretsbeam-operator/pkg/plugin/exec/v1"
+)
+
+// storedSecret is the state kept by the reference helper for every secret
+type storedSecret struct {
+	Value       string    `json:"value"`
+	Version     int       `json:"version"`
+	LastChanged time.Time `json:"lastChanged"`
+}
+
+// runHelper implements the protocol on top of files in the stateDir of the config. The failWith and
+// sleep config entries make every operation fail or hang, lastRequest records the request received
+// and capabilities lists the capabilities reported on init.
+func runHelper(operation string) int {
+	req := &execv1.Request{}
+	if err := json.NewDecoder(os.Stdin).Decode(req); err != nil {
+		fmt.Fprintf(os.Stderr, "decoding request: %s\n", err)
+		return 1
+	}
+
+	if val := req.Config["sleep"]; val != "" {
+		d, _ := time.ParseDuration(val)
+		time.Sleep(d)
+	}
+	if val := req.Config["failWith"]; val != "" {
+		fmt.Fprintln(os.Stderr, val)
+		return 2
+	}
+
+	stateDir := req.Config["stateDir"]
+	if path := req.Config["lastRequest"]; path != "" {
+		data, _ := json.Marshal(map[string]interface{}{"operation": operation, "request": req})
+		_ = os.WriteFile(path, data, 0600)
+	}
+
+	resp, err := handle(stateDir, operation, req)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if err := json.NewEncoder(os.Stdout).Encode(resp); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+func handle(stateDir, operation string, req *execv1.Request) (*execv1.Response, error) {
+	switch operation {
+	case execv1.OperationInit:
+		if stateDir == "" {
+			return nil, fmt.Errorf("stateDir is required")
+		}
+		return &execv1.Response{Capabilities: strings.Fields(req.Config["capabilities"])}, nil
+	case execv1.OperationCreateSecret, execv1.OperationUpdateSecret:
+		if req.Secret == nil || req.Value == nil {
+			return nil, fmt.Errorf("secret and value are required")
+		}
+
+		stored, _ := readSecret(stateDir, req.Secret.ExternalName)
+		if stored == nil {
+			stored = &storedSecret{}
+		}
+		stored.Value = *req.Value
+		stored.Version++
+		stored.LastChanged = time.Now().UTC()
+		if err := writeSecret(stateDir, req.Secret.ExternalName, stored); err != nil {
+			return nil, err
+		}
+
+		return &execv1.Response{Provider: map[string]string{"Version": fmt.Sprint(stored.Version)}}, nil
+	case execv1.OperationGetSecretLastChangedDate:
+		stored, err := readSecret(stateDir, req.Secret.ExternalName)
+		if err != nil {
+			return nil, err
+		}
+
+		return &execv1.Response{LastChangedDate: &stored.LastChanged}, nil
+	case execv1.OperationDeleteSecret:
+		if req.Secret.RecoveryWindow > 0 {
+			deletion := time.Now().Add(time.Duration(req.Secret.RecoveryWindow) * 24 * time.Hour).UTC()
+			return &execv1.Response{DeletionDate: &deletion}, nil
+		}
+
+		if err := os.Remove(filepath.Join(stateDir, req.Secret.ExternalName)); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		return &execv1.Response{}, nil
+	case execv1.OperationCreateAccess, execv1.OperationUpdateAccess:
+		if req.Access == nil || req.Secret == nil {
+			return nil, fmt.Errorf("secret and access are required")
+		}
+		return &execv1.Response{Provider: map[string]string{"Subjects": fmt.Sprint(len(req.Access.Subjects))}}, nil
+	case execv1.OperationDeleteAccess:
+		if req.Access == nil {
+			return nil, fmt.Errorf("access is required")
+		}
+		return &execv1.Response{}, nil
+	}
+
+	return nil, fmt.Errorf("unknown operation %s", operation)
+}
+
+func readSecret(stateDir, name string) (*storedSecret, error) {
+	data, err := os.ReadFile(filepath.Join(stateDir, name))
+	if err != nil {
+		return nil, fmt.Errorf("secret %s not found", name)
+	}
+
+	stored := &storedSecret{}
+	return stored, json.Unmarshal(data, stored)
+}
+
+func writeSecret(stateDir, name string, stored *storedSecret) error {
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(stateDir, name), data, 0600)
+}
@@ -0,0 +1,111 @@
+package execplugin
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	execv1 "github.com/tiagoposse/secretsbeam-operator/pkg/plugin/exec/v1"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/registry"
+)
+
+const defaultTimeout = 30 * time.Second
+
+// ExecPluginProvider delegates every operation to a helper binary shipped in the operator image,
+// invoked once per operation with JSON on stdin and stdout
+type ExecPluginProvider struct {
+	ExecPluginProviderConfig
+
+	config  map[string]string
+	command *execv1.Command
+	// capabilities are the capabilities the helper reported on init
+	capabilities registry.Capabilities
+}
+
+type ExecPluginProviderConfig struct {
+	// Command is the absolute path of the helper binary
+	Command string `json:"command"`
+	// Args are whitespace separated arguments passed to the helper before the operation
+	Args string `json:"args"`
+	// Timeout is a duration string applied to every invocation, defaults to 30s
+	Timeout string `json:"timeout"`
+}
+
+func init() {
+	registry.Register(secretsv1alpha1.SecretProviderExec, func(_ registry.Options) registry.Provider {
+		return &ExecPluginProvider{}
+	}, func() registry.ConfigSchema {
+		// the whole config is passed to the plugin
+		schema := registry.SchemaOf(ExecPluginProviderConfig{}, "command")
+		schema.AdditionalKeys = true
+		return schema
+	}())
+}
+
+func (p *ExecPluginProvider) Init(config map[string]string) error {
+	providerConfig := &ExecPluginProviderConfig{}
+	if err := mapstructure.Decode(config, providerConfig); err != nil {
+		return fmt.Errorf("decoding provider config: %w", err)
+	}
+
+	if providerConfig.Command == "" {
+		return fmt.Errorf("command is required")
+	}
+	if !filepath.IsAbs(providerConfig.Command) {
+		return fmt.Errorf("command must be an absolute path, got %s", providerConfig.Command)
+	}
+
+	info, err := os.Stat(providerConfig.Command)
+	if err != nil {
+		return fmt.Errorf("finding helper: %w", err)
+	}
+	if info.IsDir() || info.Mode().Perm()&0111 == 0 {
+		return fmt.Errorf("helper %s is not executable", providerConfig.Command)
+	}
+
+	timeout := defaultTimeout
+	if providerConfig.Timeout != "" {
+		val, err := time.ParseDuration(providerConfig.Timeout)
+		if err != nil {
+			return fmt.Errorf("parsing timeout: %w", err)
+		}
+		timeout = val
+	}
+
+	p.ExecPluginProviderConfig = *providerConfig
+	p.config = config
+	p.command = &execv1.Command{
+		Path:    providerConfig.Command,
+		Args:    strings.Fields(providerConfig.Args),
+		Timeout: timeout,
+	}
+
+	// init lets the helper validate the config before any object is reconciled
+	resp, err := p.call(context.Background(), execv1.OperationInit, &execv1.Request{})
+	if err != nil {
+		return fmt.Errorf("initializing helper: %w", err)
+	}
+	if p.capabilities, err = registry.ParseCapabilities(resp.Capabilities); err != nil {
+		return fmt.Errorf("helper reported %w", err)
+	}
+
+	return nil
+}
+
+// Capabilities reports the capabilities the helper reported on init
+func (p *ExecPluginProvider) Capabilities() registry.Capabilities {
+	return p.capabilities
+}
+
+// call invokes the helper for an operation, the provider config is sent along with every request
+func (p *ExecPluginProvider) call(ctx context.Context, operation string, req *execv1.Request) (*execv1.Response, error) {
+	req.Config = p.config
+
+	return p.command.Run(ctx, operation, req)
+}
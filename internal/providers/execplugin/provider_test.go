@@ -0,0 +1,176 @@
+package execplugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/providertest"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/registry"
+	execv1 "github.com/tiagoposse/secretsbeam-operator/pkg/plugin/exec/v1"
+)
+
+// newSecret returns a secret of the fixtures with a provider spec and no provider status
+func newSecret(name string) *secretsv1alpha1.ExternalSecret {
+	secret := providertest.NewSecret(name)
+	secret.Spec.ProviderSpec["Tier"] = "gold"
+	secret.Status.Provider = nil
+
+	return secret
+}
+
+// recordedRequest is the last request written by the reference helper
+type recordedRequest struct {
+	Operation string         `json:"operation"`
+	Request   execv1.Request `json:"request"`
+}
+
+var _ = Describe("ExecPluginProvider", func() {
+	var (
+		ctx         context.Context
+		config      map[string]string
+		lastRequest string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		dir := GinkgoT().TempDir()
+		lastRequest = filepath.Join(dir, "last-request.json")
+		config = map[string]string{
+			"command":     helperPath,
+			"args":        "-test.run=^$",
+			"stateDir":    dir,
+			"lastRequest": lastRequest,
+		}
+	})
+
+	readLastRequest := func() recordedRequest {
+		data, err := os.ReadFile(lastRequest)
+		Expect(err).NotTo(HaveOccurred())
+
+		recorded := recordedRequest{}
+		Expect(json.Unmarshal(data, &recorded)).To(Succeed())
+		return recorded
+	}
+
+	Context("Init", func() {
+		It("validates the command", func() {
+			Expect((&ExecPluginProvider{}).Init(map[string]string{})).To(MatchError("command is required"))
+			Expect((&ExecPluginProvider{}).Init(map[string]string{"command": "helper"})).To(MatchError(ContainSubstring("absolute path")))
+			Expect((&ExecPluginProvider{}).Init(map[string]string{"command": "/does/not/exist"})).To(MatchError(ContainSubstring("finding helper")))
+			Expect((&ExecPluginProvider{}).Init(map[string]string{"command": GinkgoT().TempDir()})).To(MatchError(ContainSubstring("not executable")))
+		})
+
+		It("uses the capabilities reported by the helper", func() {
+			provider := &ExecPluginProvider{}
+			Expect(provider.Init(config)).To(Succeed())
+			Expect(registry.CapabilitiesOf(provider)).To(Equal(registry.Capabilities{}), "helpers support nothing unless they report it")
+
+			config["capabilities"] = "RecoveryWindow AccessControl"
+			Expect(provider.Init(config)).To(Succeed())
+			Expect(registry.CapabilitiesOf(provider)).To(Equal(registry.AllCapabilities))
+
+			config["capabilities"] = "Rotation"
+			Expect(provider.Init(config)).To(MatchError(`helper reported unknown capability "Rotation"`))
+		})
+
+		It("lets the helper validate the config", func() {
+			delete(config, "stateDir")
+			Expect((&ExecPluginProvider{}).Init(config)).To(MatchError(ContainSubstring("stateDir is required")))
+		})
+	})
+
+	Context("operations", func() {
+		var provider *ExecPluginProvider
+
+		BeforeEach(func() {
+			provider = &ExecPluginProvider{}
+			Expect(provider.Init(config)).To(Succeed())
+		})
+
+		It("sends the config and secret and merges the returned provider state", func() {
+			secret := newSecret("db")
+			secret.Spec.Overwrite = true
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, secret, "hunter2")).To(Succeed())
+
+			recorded := readLastRequest()
+			Expect(recorded.Operation).To(Equal(execv1.OperationCreateSecret))
+			Expect(recorded.Request.Config).To(Equal(config))
+			Expect(*recorded.Request.Value).To(Equal("hunter2"))
+			Expect(recorded.Request.Secret.Namespace).To(Equal("team-a"))
+			Expect(recorded.Request.Secret.ExternalName).To(Equal("db"))
+			Expect(recorded.Request.Secret.Overwrite).To(BeTrue())
+			Expect(recorded.Request.Secret.ProviderSpec).To(Equal(map[string]string{"Tier": "gold"}))
+			Expect(secret.Status.Provider).To(Equal(map[string]string{"Version": "1"}))
+
+			Expect(provider.UpdateSecret(ctx, GinkgoLogr, secret, "hunter3")).To(Succeed())
+			Expect(secret.Status.Provider).To(Equal(map[string]string{"Version": "2"}))
+
+			changed, err := provider.GetSecretLastChangedDate(ctx, GinkgoLogr, secret)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changed).NotTo(BeNil())
+		})
+
+		It("records the deletion date returned by the helper", func() {
+			secret := newSecret("db")
+			secret.Spec.RecoveryWindow = 7
+			Expect(provider.DeleteSecret(ctx, GinkgoLogr, secret)).To(Succeed())
+			Expect(secret.Status.DeletionDate).NotTo(BeNil())
+		})
+
+		It("sends access subjects and no secret on delete", func() {
+			access := &secretsv1alpha1.ExternalSecretAccess{
+				ObjectMeta: metav1.ObjectMeta{Name: "reader", Namespace: "team-a"},
+				Spec: secretsv1alpha1.ExternalSecretAccessSpec{
+					AccessSubjects: []secretsv1alpha1.SecretAccessSubject{
+						{ServiceAccount: &secretsv1alpha1.SecretAccessSubjectServiceAccount{Namespace: "team-b", Name: "api"}},
+					},
+				},
+			}
+			Expect(provider.CreateAccess(ctx, GinkgoLogr, newSecret("db"), access)).To(Succeed())
+			Expect(access.Status.Provider).To(Equal(map[string]string{"Subjects": "1"}))
+			Expect(readLastRequest().Request.Access.Subjects[0].ServiceAccount.Name).To(Equal("api"))
+
+			Expect(provider.DeleteAccess(ctx, GinkgoLogr, access)).To(Succeed())
+			recorded := readLastRequest()
+			Expect(recorded.Operation).To(Equal(execv1.OperationDeleteAccess))
+			Expect(recorded.Request.Secret).To(BeNil())
+		})
+
+		It("surfaces the stderr of failed invocations", func() {
+			provider.config["failWith"] = "backend unavailable"
+
+			err := provider.UpdateSecret(ctx, GinkgoLogr, newSecret("db"), "value")
+			Expect(err).To(MatchError(ContainSubstring("update-secret exited with status 2: backend unavailable")))
+
+			var execErr *execv1.Error
+			Expect(errors.As(err, &execErr)).To(BeTrue())
+			Expect(execErr.ExitCode).To(Equal(2))
+		})
+
+		It("keeps the end of long error output", func() {
+			provider.config["failWith"] = strings.Repeat("a", 10000) + " the actual error"
+
+			err := provider.DeleteAccess(ctx, GinkgoLogr, &secretsv1alpha1.ExternalSecretAccess{})
+			Expect(err).To(MatchError(HaveSuffix("the actual error")))
+			Expect(len(err.Error())).To(BeNumerically("<", 5000))
+		})
+
+		It("kills helpers exceeding the timeout", func() {
+			config["timeout"] = "200ms"
+			Expect(provider.Init(config)).To(Succeed())
+			provider.config["sleep"] = "5s"
+
+			err := provider.CreateSecret(ctx, GinkgoLogr, newSecret("db"), "value")
+			Expect(err).To(MatchError(ContainSubstring("create-secret timed out")))
+		})
+	})
+})
@@ -0,0 +1,77 @@
+package execplugin
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/registry"
+	execv1 "github.com/tiagoposse/secretsbeam-operator/pkg/plugin/exec/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func toSecret(secret *secretsv1alpha1.ExternalSecret) *execv1.Secret {
+	externalName := secret.Status.SecretName
+	if secret.Spec.ExternalName != nil {
+		externalName = *secret.Spec.ExternalName
+	}
+
+	return &execv1.Secret{
+		Namespace:      secret.Namespace,
+		Name:           secret.Name,
+		ExternalName:   externalName,
+		RecoveryWindow: secret.Spec.RecoveryWindow,
+		Overwrite:      secret.Spec.Overwrite,
+		ProviderSpec:   secret.Spec.ProviderSpec,
+		Provider:       secret.Status.Provider,
+	}
+}
+
+func (p *ExecPluginProvider) DeleteSecret(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret) error {
+	result, err := p.call(ctx, execv1.OperationDeleteSecret, &execv1.Request{Secret: toSecret(secret)})
+	if err != nil {
+		return fmt.Errorf("failed to delete secret: %w", err)
+	}
+
+	if result.DeletionDate != nil {
+		t := v1.NewTime(*result.DeletionDate)
+		secret.Status.DeletionDate = &t
+		reqLogger.Info(fmt.Sprintf("Secret scheduled for deletion in %s", t.Time.String()))
+	}
+
+	return nil
+}
+
+func (p *ExecPluginProvider) CreateSecret(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, value string) error {
+	result, err := p.call(ctx, execv1.OperationCreateSecret, &execv1.Request{Secret: toSecret(secret), Value: &value})
+	if err != nil {
+		reqLogger.Error(err, "Failed to create Secret")
+		return err
+	}
+
+	registry.MergeProviderStatus(&secret.Status.Provider, result.Provider)
+
+	return nil
+}
+
+func (p *ExecPluginProvider) GetSecretLastChangedDate(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret) (*time.Time, error) {
+	result, err := p.call(ctx, execv1.OperationGetSecretLastChangedDate, &execv1.Request{Secret: toSecret(secret)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe secret: %w", err)
+	}
+
+	return result.LastChangedDate, nil
+}
+
+func (p *ExecPluginProvider) UpdateSecret(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, value string) error {
+	result, err := p.call(ctx, execv1.OperationUpdateSecret, &execv1.Request{Secret: toSecret(secret), Value: &value})
+	if err != nil {
+		return fmt.Errorf("failed to update secret: %w", err)
+	}
+
+	registry.MergeProviderStatus(&secret.Status.Provider, result.Provider)
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package execplugin
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/tiagoposse/secretsbeam-operator/pkg/plugin/exec/conformance"
+	execv1 "github.com/tiagoposse/secretsbeam-operator/pkg/plugin/exec/v1"
+)
+
+// helperEnv makes the test binary behave as the reference helper, see helper_test.go
+const helperEnv = "EXECPLUGIN_TEST_HELPER"
+
+// helperPath is the path of the test binary, used as the helper under test
+var helperPath string
+
+func TestMain(m *testing.M) {
+	if os.Getenv(helperEnv) == "1" {
+		os.Exit(runHelper(os.Args[len(os.Args)-1]))
+	}
+
+	path, err := os.Executable()
+	if err != nil {
+		panic(err)
+	}
+	helperPath = path
+	os.Setenv(helperEnv, "1")
+
+	os.Exit(m.Run())
+}
+
+func TestExecPluginProvider(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Exec Plugin Provider Suite")
+}
+
+func TestConformance(t *testing.T) {
+	conformance.Run(t, conformance.Options{
+		Command: execv1.Command{Path: helperPath, Args: []string{"-test.run=^$"}},
+		Config:  map[string]string{"stateDir": t.TempDir()},
+	})
+}
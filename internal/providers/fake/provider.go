@@ -0,0 +1,363 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/registry"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Methods of the Provider interface, used to inspect calls and inject errors
+const (
+	MethodInit                     = "Init"
+	MethodCreateSecret             = "CreateSecret"
+	MethodUpdateSecret             = "UpdateSecret"
+	MethodDeleteSecret             = "DeleteSecret"
+	MethodGetSecretLastChangedDate = "GetSecretLastChangedDate"
+	MethodCreateAccess             = "CreateAccess"
+	MethodUpdateAccess             = "UpdateAccess"
+	MethodDeleteAccess             = "DeleteAccess"
+)
+
+// Call is an operation received by the fake
+type Call struct {
+	Method string
+	// Object is the namespace/name of the ExternalSecret, or of the ExternalSecretAccess for access methods
+	Object string
+	// Value is the value passed to create and update secret
+	Value string
+}
+
+// Secret is a secret stored by the fake
+type Secret struct {
+	Value        string
+	Version      int
+	LastChanged  time.Time
+	DeletionDate *time.Time
+}
+
+// FakeProvider is an in-memory provider recording the calls it receives, for tests.
+// Errors can be injected per method with FailOn. The zero value is ready to use.
+type FakeProvider struct {
+	mu       sync.Mutex
+	config   map[string]string
+	secrets  map[string]*Secret
+	accesses map[string][]secretsv1alpha1.SecretAccessSubject
+	calls    []Call
+	failures map[string]error
+	// annotations are the service account annotations set on accesses, by service account
+	annotations map[string]string
+}
+
+// Capabilities reports every capability, the fake keeps deleted secrets and grants accesses
+func (p *FakeProvider) Capabilities() registry.Capabilities {
+	return registry.AllCapabilities
+}
+
+func (p *FakeProvider) Init(config map[string]string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.record(Call{Method: MethodInit}); err != nil {
+		return err
+	}
+	p.config = config
+
+	return nil
+}
+
+// record stores a call and returns the error injected for its method, it must be called with the lock held
+func (p *FakeProvider) record(call Call) error {
+	p.calls = append(p.calls, call)
+	if p.secrets == nil {
+		p.secrets = make(map[string]*Secret)
+		p.accesses = make(map[string][]secretsv1alpha1.SecretAccessSubject)
+	}
+
+	return p.failures[call.Method]
+}
+
+// FailOn makes every call of a method return err, a nil err clears the failure
+func (p *FakeProvider) FailOn(method string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.failures == nil {
+		p.failures = make(map[string]error)
+	}
+
+	if err == nil {
+		delete(p.failures, method)
+	} else {
+		p.failures[method] = err
+	}
+}
+
+// Calls returns the calls received so far, optionally only those of the given methods
+func (p *FakeProvider) Calls(methods ...string) []Call {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	calls := make([]Call, 0, len(p.calls))
+	for _, call := range p.calls {
+		if len(methods) == 0 {
+			calls = append(calls, call)
+			continue
+		}
+
+		for _, method := range methods {
+			if call.Method == method {
+				calls = append(calls, call)
+				break
+			}
+		}
+	}
+
+	return calls
+}
+
+// Config returns the config received by Init
+func (p *FakeProvider) Config() map[string]string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.config
+}
+
+// Secret returns a copy of a stored secret by external name
+func (p *FakeProvider) Secret(name string) (Secret, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	secret, ok := p.secrets[name]
+	if !ok {
+		return Secret{}, false
+	}
+
+	return *secret, true
+}
+
+// Access returns the subjects granted by an access, by namespace/name
+func (p *FakeProvider) Access(name string) ([]secretsv1alpha1.SecretAccessSubject, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	subjects, ok := p.accesses[name]
+	return subjects, ok
+}
+
+// SetServiceAccountAnnotation makes access operations set the annotation of a service account,
+// <key>=<value>, in the provider status of accesses. An empty service account sets the annotation of
+// all service accounts, an empty annotation clears it.
+func (p *FakeProvider) SetServiceAccountAnnotation(serviceAccount, annotation string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.annotations == nil {
+		p.annotations = make(map[string]string)
+	}
+
+	if annotation == "" {
+		delete(p.annotations, serviceAccount)
+	} else {
+		p.annotations[serviceAccount] = annotation
+	}
+}
+
+// Reset forgets every secret, access, call, injected failure and annotation
+func (p *FakeProvider) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.secrets = nil
+	p.accesses = nil
+	p.calls = nil
+	p.failures = nil
+	p.annotations = nil
+}
+
+func objectName(namespace, name string) string {
+	return fmt.Sprintf("%s/%s", namespace, name)
+}
+
+func externalName(secret *secretsv1alpha1.ExternalSecret) string {
+	if secret.Spec.ExternalName != nil {
+		return *secret.Spec.ExternalName
+	}
+
+	return secret.Status.SecretName
+}
+
+func (p *FakeProvider) DeleteSecret(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.record(Call{Method: MethodDeleteSecret, Object: objectName(secret.Namespace, secret.Name)}); err != nil {
+		return err
+	}
+
+	name := externalName(secret)
+	stored, ok := p.secrets[name]
+	if !ok {
+		return nil
+	}
+
+	if secret.Spec.RecoveryWindow > 0 {
+		if secret.Status.DeletionDate == nil {
+			deletion := time.Now().Add(time.Duration(secret.Spec.RecoveryWindow) * 24 * time.Hour)
+			stored.DeletionDate = &deletion
+
+			t := v1.NewTime(deletion)
+			secret.Status.DeletionDate = &t
+		}
+
+		if time.Now().Before(secret.Status.DeletionDate.Time) {
+			return fmt.Errorf("secret scheduled for deletion in %s", secret.Status.DeletionDate.Time.String())
+		}
+	}
+
+	delete(p.secrets, name)
+
+	return nil
+}
+
+func (p *FakeProvider) CreateSecret(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, value string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.record(Call{Method: MethodCreateSecret, Object: objectName(secret.Namespace, secret.Name), Value: value}); err != nil {
+		return err
+	}
+
+	name := externalName(secret)
+	stored, ok := p.secrets[name]
+	if ok && !secret.Spec.Overwrite {
+		return fmt.Errorf("secret %s already exists and overwrite is not set", name)
+	}
+	if !ok {
+		stored = &Secret{}
+		p.secrets[name] = stored
+	}
+
+	stored.Value = value
+	stored.Version++
+	stored.LastChanged = time.Now()
+	stored.DeletionDate = nil
+
+	if secret.Status.Provider == nil {
+		secret.Status.Provider = make(map[string]string)
+	}
+	secret.Status.Provider["Version"] = strconv.Itoa(stored.Version)
+
+	return nil
+}
+
+func (p *FakeProvider) UpdateSecret(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, value string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.record(Call{Method: MethodUpdateSecret, Object: objectName(secret.Namespace, secret.Name), Value: value}); err != nil {
+		return err
+	}
+
+	name := externalName(secret)
+	stored, ok := p.secrets[name]
+	if !ok {
+		return fmt.Errorf("secret %s not found", name)
+	}
+
+	stored.Value = value
+	stored.Version++
+	stored.LastChanged = time.Now()
+
+	if secret.Status.Provider == nil {
+		secret.Status.Provider = make(map[string]string)
+	}
+	secret.Status.Provider["Version"] = strconv.Itoa(stored.Version)
+
+	return nil
+}
+
+func (p *FakeProvider) GetSecretLastChangedDate(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret) (*time.Time, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.record(Call{Method: MethodGetSecretLastChangedDate, Object: objectName(secret.Namespace, secret.Name)}); err != nil {
+		return nil, err
+	}
+
+	stored, ok := p.secrets[externalName(secret)]
+	if !ok {
+		return nil, fmt.Errorf("secret %s not found", externalName(secret))
+	}
+
+	t := stored.LastChanged
+	return &t, nil
+}
+
+func (p *FakeProvider) CreateAccess(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, access *secretsv1alpha1.ExternalSecretAccess) error {
+	return p.applyAccess(MethodCreateAccess, secret, access)
+}
+
+func (p *FakeProvider) UpdateAccess(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, access *secretsv1alpha1.ExternalSecretAccess) error {
+	return p.applyAccess(MethodUpdateAccess, secret, access)
+}
+
+func (p *FakeProvider) applyAccess(method string, secret *secretsv1alpha1.ExternalSecret, access *secretsv1alpha1.ExternalSecretAccess) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	name := objectName(access.Namespace, access.Name)
+	if err := p.record(Call{Method: method, Object: name}); err != nil {
+		return err
+	}
+
+	if _, ok := p.secrets[externalName(secret)]; !ok {
+		return fmt.Errorf("secret %s not found", externalName(secret))
+	}
+
+	p.accesses[name] = append([]secretsv1alpha1.SecretAccessSubject{}, access.Spec.AccessSubjects...)
+
+	if access.Status.Provider == nil {
+		access.Status.Provider = make(map[string]string)
+	}
+	access.Status.Provider["Secret"] = externalName(secret)
+	// the version of the secret stands for the provider state the grants of accesses depend on
+	access.Status.Provider["SecretVersion"] = secret.Status.Provider["Version"]
+
+	for entry := range access.Status.Provider {
+		if strings.HasPrefix(entry, "ServiceAccountAnnotation") {
+			delete(access.Status.Provider, entry)
+		}
+	}
+	for serviceAccount, annotation := range p.annotations {
+		if serviceAccount == "" {
+			access.Status.Provider["ServiceAccountAnnotation"] = annotation
+		} else {
+			access.Status.Provider["ServiceAccountAnnotation."+serviceAccount] = annotation
+		}
+	}
+
+	return nil
+}
+
+func (p *FakeProvider) DeleteAccess(ctx context.Context, reqLogger logr.Logger, access *secretsv1alpha1.ExternalSecretAccess) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	name := objectName(access.Namespace, access.Name)
+	if err := p.record(Call{Method: MethodDeleteAccess, Object: name}); err != nil {
+		return err
+	}
+
+	delete(p.accesses, name)
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+)
+
+func aclKey(access *secretsv1alpha1.ExternalSecretAccess) string {
+	return fmt.Sprintf("%s/%s", access.Namespace, access.Name)
+}
+
+func (p *FileProvider) CreateAccess(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, access *secretsv1alpha1.ExternalSecretAccess) error {
+	return p.applyAccess(ctx, reqLogger, secret, access)
+}
+
+func (p *FileProvider) UpdateAccess(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, access *secretsv1alpha1.ExternalSecretAccess) error {
+	return p.applyAccess(ctx, reqLogger, secret, access)
+}
+
+// applyAccess replaces the ACL entry of the access in the secret file with its service account subjects
+func (p *FileProvider) applyAccess(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, access *secretsv1alpha1.ExternalSecretAccess) error {
+	subjects := make([]serviceAccount, 0, len(access.Spec.AccessSubjects))
+	names := make([]string, 0, len(access.Spec.AccessSubjects))
+	for _, subject := range access.Spec.AccessSubjects {
+		if subject.ServiceAccount == nil {
+			return fmt.Errorf("the file provider only supports service account subjects")
+		}
+
+		subjects = append(subjects, serviceAccount{Namespace: subject.ServiceAccount.Namespace, Name: subject.ServiceAccount.Name})
+		names = append(names, fmt.Sprintf("%s/%s", subject.ServiceAccount.Namespace, subject.ServiceAccount.Name))
+	}
+	sort.Strings(names)
+
+	p.store.mu.Lock()
+	defer p.store.mu.Unlock()
+
+	name := secretName(secret)
+	doc, err := p.store.load(name)
+	if err != nil {
+		return fmt.Errorf("failed to grant access: %w", err)
+	}
+
+	// the access may have moved to another secret
+	if previous := access.Status.Provider["Name"]; previous != "" && previous != name {
+		if err := p.revoke(previous, aclKey(access)); err != nil {
+			return err
+		}
+	}
+
+	if doc.ACL == nil {
+		doc.ACL = make(map[string][]serviceAccount)
+	}
+	doc.ACL[aclKey(access)] = subjects
+	if err := p.store.save(doc); err != nil {
+		return fmt.Errorf("failed to grant access: %w", err)
+	}
+
+	if access.Status.Provider == nil {
+		access.Status.Provider = make(map[string]string)
+	}
+	access.Status.Provider["Name"] = name
+	access.Status.Provider["Subjects"] = strings.Join(names, ",")
+
+	return nil
+}
+
+func (p *FileProvider) DeleteAccess(ctx context.Context, reqLogger logr.Logger, access *secretsv1alpha1.ExternalSecretAccess) error {
+	name, ok := access.Status.Provider["Name"]
+	if !ok || name == "" {
+		reqLogger.Info("Access was never granted, ignoring")
+		return nil
+	}
+
+	p.store.mu.Lock()
+	defer p.store.mu.Unlock()
+
+	return p.revoke(name, aclKey(access))
+}
+
+// revoke removes an ACL entry from a secret file, a missing secret has nothing left to revoke
+func (p *FileProvider) revoke(name, key string) error {
+	doc, err := p.store.load(name)
+	if err != nil {
+		if errors.Is(err, errNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to revoke access: %w", err)
+	}
+
+	if _, ok := doc.ACL[key]; !ok {
+		return nil
+	}
+
+	delete(doc.ACL, key)
+	if err := p.store.save(doc); err != nil {
+		return fmt.Errorf("failed to revoke access: %w", err)
+	}
+
+	return nil
+}
+
+// ReadSecret returns the current value of a secret when the service account is granted by its ACL,
+// so that tests and local tooling can check accesses end to end without a cloud account
+func (p *FileProvider) ReadSecret(name, namespace, serviceAccountName string) (string, error) {
+	p.store.mu.Lock()
+	defer p.store.mu.Unlock()
+
+	doc, err := p.store.load(strings.Trim(name, "/"))
+	if err != nil {
+		return "", err
+	}
+	if doc.DeletionDate != nil {
+		return "", fmt.Errorf("secret %s is scheduled for deletion", name)
+	}
+	if !doc.allowed(namespace, serviceAccountName) {
+		return "", fmt.Errorf("service account %s/%s is not allowed to read secret %s", namespace, serviceAccountName, name)
+	}
+
+	return doc.current().Value, nil
+}
@@ -0,0 +1,160 @@
+package file
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/registry"
+)
+
+const (
+	defaultKeySecretKey = "key"
+	defaultMaxVersions  = 10
+	keySize             = 32
+)
+
+// FileProvider stores secrets AES-256-GCM encrypted in a local directory, usually a PVC, and models
+// access as an ACL of service accounts kept in the secret file. It is meant for development and CI.
+type FileProvider struct {
+	FileProviderConfig
+
+	// Client and Namespace are used to read the key secret referenced by the config
+	Client    client.Client
+	Namespace string
+
+	store *store
+}
+
+type FileProviderConfig struct {
+	// Path is the directory the secrets are stored in
+	Path string `json:"path"`
+	// KeySecretName references a Secret in the provider namespace holding the base64 encoded 32 byte key
+	KeySecretName string `json:"keySecretName"`
+	KeySecretKey  string `json:"keySecretKey"`
+	// KeyFile is read instead of the secret when set, e.g. a mounted secret
+	KeyFile string `json:"keyFile"`
+	// MaxVersions is the number of versions kept per secret, defaults to 10
+	MaxVersions string `json:"maxVersions"`
+}
+
+func init() {
+	registry.Register(secretsv1alpha1.SecretProviderFile, func(opts registry.Options) registry.Provider {
+		return &FileProvider{Client: opts.Client, Namespace: opts.Namespace}
+	}, registry.SchemaOf(FileProviderConfig{}, "path"))
+}
+
+func (p *FileProvider) Init(config map[string]string) error {
+	providerConfig := &FileProviderConfig{}
+	if err := mapstructure.Decode(config, providerConfig); err != nil {
+		return fmt.Errorf("decoding provider config: %w", err)
+	}
+
+	if providerConfig.Path == "" {
+		return fmt.Errorf("path is required")
+	}
+	if providerConfig.KeySecretName == "" && providerConfig.KeyFile == "" {
+		return fmt.Errorf("one of keySecretName or keyFile is required")
+	}
+	if providerConfig.KeySecretKey == "" {
+		providerConfig.KeySecretKey = defaultKeySecretKey
+	}
+
+	maxVersions := defaultMaxVersions
+	if providerConfig.MaxVersions != "" {
+		val, err := strconv.Atoi(providerConfig.MaxVersions)
+		if err != nil || val < 1 {
+			return fmt.Errorf("maxVersions must be a positive integer, got %s", providerConfig.MaxVersions)
+		}
+		maxVersions = val
+	}
+
+	p.FileProviderConfig = *providerConfig
+
+	key, err := p.readKey(context.Background())
+	if err != nil {
+		return err
+	}
+
+	return p.init(key, maxVersions)
+}
+
+func (p *FileProvider) init(key []byte, maxVersions int) error {
+	if err := os.MkdirAll(p.Path, 0o700); err != nil {
+		return fmt.Errorf("creating %s: %w", p.Path, err)
+	}
+
+	s, err := newStore(p.Path, key, maxVersions)
+	if err != nil {
+		return err
+	}
+	p.store = s
+
+	return nil
+}
+
+// CheckHealth checks that the directory of the secrets is still writable, e.g. that its volume is mounted
+func (p *FileProvider) CheckHealth(_ context.Context) (*registry.Health, error) {
+	probe, err := os.CreateTemp(p.Path, ".health-*")
+	if err != nil {
+		return nil, fmt.Errorf("writing to %s: %w", p.Path, err)
+	}
+	_ = probe.Close()
+	if err := os.Remove(probe.Name()); err != nil {
+		return nil, fmt.Errorf("removing %s: %w", probe.Name(), err)
+	}
+
+	return &registry.Health{}, nil
+}
+
+// Capabilities reports the features of the file backend, which has no native rotation
+func (p *FileProvider) Capabilities() registry.Capabilities {
+	return registry.Capabilities{RecoveryWindow: true, AccessControl: true}
+}
+
+// readKey returns the key from the key file or secret, either base64 encoded or raw
+func (p *FileProvider) readKey(ctx context.Context) ([]byte, error) {
+	var data []byte
+	if p.KeyFile != "" {
+		val, err := os.ReadFile(p.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading key file: %w", err)
+		}
+		data = val
+	} else {
+		if p.Client == nil {
+			return nil, fmt.Errorf("no kubernetes client configured to read secret %s", p.KeySecretName)
+		}
+
+		secret := &corev1.Secret{}
+		if err := p.Client.Get(ctx, types.NamespacedName{Namespace: p.Namespace, Name: p.KeySecretName}, secret); err != nil {
+			return nil, fmt.Errorf("getting secret %s/%s: %w", p.Namespace, p.KeySecretName, err)
+		}
+
+		val, ok := secret.Data[p.KeySecretKey]
+		if !ok {
+			return nil, fmt.Errorf("key %s not found in secret %s/%s", p.KeySecretKey, p.Namespace, p.KeySecretName)
+		}
+		data = val
+	}
+
+	if len(data) == keySize {
+		return data, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != keySize {
+		return nil, fmt.Errorf("key must be %d bytes, raw or base64 encoded", keySize)
+	}
+
+	return key, nil
+}
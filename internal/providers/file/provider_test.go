@@ -0,0 +1,219 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/providertest"
+)
+
+var testKey = bytes.Repeat([]byte{7}, keySize)
+
+var _ = Describe("FileProvider", func() {
+	var (
+		ctx      context.Context
+		dir      string
+		provider *FileProvider
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		dir = GinkgoT().TempDir()
+
+		provider = &FileProvider{FileProviderConfig: FileProviderConfig{Path: dir}}
+		Expect(provider.init(testKey, 3)).To(Succeed())
+	})
+
+	Context("Init", func() {
+		It("reads a base64 encoded key from a secret", func() {
+			cli := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "file-key", Namespace: "secretsbeam"},
+				Data:       map[string][]byte{"key": []byte(base64.StdEncoding.EncodeToString(testKey) + "\n")},
+			}).Build()
+
+			p := &FileProvider{Client: cli, Namespace: "secretsbeam"}
+			Expect(p.Init(map[string]string{"path": dir, "keySecretName": "file-key"})).To(Succeed())
+
+			// secrets written with the same key can be read
+			secret := providertest.NewSecret("db")
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, secret, "hunter2")).To(Succeed())
+			_, err := p.GetSecretLastChangedDate(ctx, GinkgoLogr, secret)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("validates the config and key", func() {
+			keyFile := filepath.Join(dir, "key")
+			Expect(os.WriteFile(keyFile, []byte("c2hvcnQ="), 0o600)).To(Succeed())
+
+			Expect((&FileProvider{}).Init(map[string]string{})).To(MatchError("path is required"))
+			Expect((&FileProvider{}).Init(map[string]string{"path": dir})).To(MatchError(ContainSubstring("keySecretName or keyFile")))
+			Expect((&FileProvider{}).Init(map[string]string{"path": dir, "keyFile": keyFile})).To(MatchError(ContainSubstring("must be 32 bytes")))
+			Expect((&FileProvider{}).Init(map[string]string{"path": dir, "keyFile": keyFile, "maxVersions": "0"})).To(MatchError(ContainSubstring("maxVersions")))
+		})
+	})
+
+	Context("health", func() {
+		It("checks that the directory is writable", func() {
+			_, err := provider.CheckHealth(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.ReadDir(dir)).To(BeEmpty())
+
+			Expect(os.RemoveAll(dir)).To(Succeed())
+			_, err = provider.CheckHealth(ctx)
+			Expect(err).To(MatchError(ContainSubstring("writing to " + dir)))
+		})
+	})
+
+	Context("secrets", func() {
+		It("stores encrypted versions", func() {
+			secret := providertest.NewSecret("team-a/db")
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, secret, "hunter2")).To(Succeed())
+			Expect(secret.Status.Provider).To(Equal(map[string]string{"Name": "team-a/db", "Version": "1"}))
+
+			data, err := os.ReadFile(filepath.Join(dir, "team-a", "db.secret"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).NotTo(ContainSubstring("hunter2"))
+
+			for i := 0; i < 4; i++ {
+				Expect(provider.UpdateSecret(ctx, GinkgoLogr, secret, "hunter3")).To(Succeed())
+			}
+			Expect(secret.Status.Provider["Version"]).To(Equal("5"))
+
+			doc, err := provider.store.load("team-a/db")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(doc.Versions).To(HaveLen(3))
+			Expect(doc.Versions[0].Version).To(Equal(3))
+			Expect(doc.current().Value).To(Equal("hunter3"))
+
+			changed, err := provider.GetSecretLastChangedDate(ctx, GinkgoLogr, secret)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*changed).To(Equal(doc.current().Created))
+		})
+
+		It("rejects files that were tampered with or moved", func() {
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, providertest.NewSecret("db"), "hunter2")).To(Succeed())
+			data, err := os.ReadFile(filepath.Join(dir, "db.secret"))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.WriteFile(filepath.Join(dir, "other.secret"), data, 0o600)).To(Succeed())
+			_, err = provider.GetSecretLastChangedDate(ctx, GinkgoLogr, providertest.NewSecret("other"))
+			Expect(err).To(MatchError(ContainSubstring("decrypting")))
+
+			other := &FileProvider{FileProviderConfig: FileProviderConfig{Path: dir}}
+			Expect(other.init(bytes.Repeat([]byte{8}, keySize), 3)).To(Succeed())
+			_, err = other.GetSecretLastChangedDate(ctx, GinkgoLogr, providertest.NewSecret("db"))
+			Expect(err).To(MatchError(ContainSubstring("decrypting")))
+		})
+
+		It("rejects names escaping the directory", func() {
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, providertest.NewSecret("../db"), "hunter2")).To(MatchError(ContainSubstring("invalid secret name")))
+		})
+
+		It("only overwrites existing secrets when overwrite is set", func() {
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, providertest.NewSecret("db"), "hunter2")).To(Succeed())
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, providertest.NewSecret("db"), "hunter3")).To(MatchError(ContainSubstring("already exists")))
+
+			secret := providertest.NewSecret("db")
+			secret.Spec.Overwrite = true
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, secret, "hunter3")).To(Succeed())
+			Expect(secret.Status.Provider["Version"]).To(Equal("2"))
+		})
+
+		It("keeps secrets during the recovery window", func() {
+			secret := providertest.NewSecret("db")
+			secret.Spec.RecoveryWindow = 7
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, secret, "hunter2")).To(Succeed())
+
+			Expect(provider.DeleteSecret(ctx, GinkgoLogr, secret)).To(MatchError(ContainSubstring("scheduled for deletion")))
+			Expect(secret.Status.DeletionDate).NotTo(BeNil())
+			Expect(filepath.Join(dir, "db.secret")).To(BeAnExistingFile())
+
+			// a new secret with overwrite restores it
+			restored := providertest.NewSecret("db")
+			restored.Spec.Overwrite = true
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, restored, "hunter3")).To(Succeed())
+			doc, err := provider.store.load("db")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(doc.DeletionDate).To(BeNil())
+
+			past := metav1.NewTime(time.Now().Add(-time.Minute))
+			secret.Status.DeletionDate = &past
+			Expect(provider.DeleteSecret(ctx, GinkgoLogr, secret)).To(Succeed())
+			Expect(filepath.Join(dir, "db.secret")).NotTo(BeAnExistingFile())
+		})
+
+		It("ignores deleting missing secrets", func() {
+			secret := providertest.NewSecret("missing")
+			secret.Spec.RecoveryWindow = 7
+			Expect(provider.DeleteSecret(ctx, GinkgoLogr, secret)).To(Succeed())
+			Expect(provider.DeleteSecret(ctx, GinkgoLogr, providertest.NewSecret("missing"))).To(Succeed())
+		})
+	})
+
+	Context("access", func() {
+		var secret *secretsv1alpha1.ExternalSecret
+
+		BeforeEach(func() {
+			secret = providertest.NewSecret("db")
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, secret, "hunter2")).To(Succeed())
+		})
+
+		It("grants and revokes service accounts", func() {
+			_, err := provider.ReadSecret("db", "team-b", "api")
+			Expect(err).To(MatchError(ContainSubstring("not allowed")))
+
+			access := providertest.NewAccess("reader", providertest.ServiceAccountSubject("team-b", "api"), providertest.ServiceAccountSubject("team-a", "worker"))
+			Expect(provider.CreateAccess(ctx, GinkgoLogr, secret, access)).To(Succeed())
+			Expect(access.Status.Provider).To(Equal(map[string]string{"Name": "db", "Subjects": "team-a/worker,team-b/api"}))
+
+			val, err := provider.ReadSecret("db", "team-b", "api")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(val).To(Equal("hunter2"))
+
+			access.Spec.AccessSubjects = access.Spec.AccessSubjects[1:]
+			Expect(provider.UpdateAccess(ctx, GinkgoLogr, secret, access)).To(Succeed())
+			_, err = provider.ReadSecret("db", "team-b", "api")
+			Expect(err).To(HaveOccurred())
+			_, err = provider.ReadSecret("db", "team-a", "worker")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(provider.DeleteAccess(ctx, GinkgoLogr, access)).To(Succeed())
+			_, err = provider.ReadSecret("db", "team-a", "worker")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("moves the ACL entry when the access points to another secret", func() {
+			access := providertest.NewAccess("reader", providertest.ServiceAccountSubject("team-b", "api"))
+			Expect(provider.CreateAccess(ctx, GinkgoLogr, secret, access)).To(Succeed())
+
+			other := providertest.NewSecret("cache")
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, other, "hunter3")).To(Succeed())
+			Expect(provider.UpdateAccess(ctx, GinkgoLogr, other, access)).To(Succeed())
+
+			_, err := provider.ReadSecret("db", "team-b", "api")
+			Expect(err).To(HaveOccurred())
+			val, err := provider.ReadSecret("cache", "team-b", "api")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(val).To(Equal("hunter3"))
+		})
+
+		It("rejects provider identifiers", func() {
+			access := providertest.NewAccess("reader", secretsv1alpha1.SecretAccessSubject{
+				ProviderIdentifier: &secretsv1alpha1.SecretAccessSubjectProviderIdentifier{Identifier: "group:ops"},
+			})
+			Expect(provider.CreateAccess(ctx, GinkgoLogr, secret, access)).To(MatchError(ContainSubstring("only supports service account subjects")))
+		})
+	})
+})
@@ -0,0 +1,139 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func secretName(secret *secretsv1alpha1.ExternalSecret) string {
+	if val, ok := secret.Status.Provider["Name"]; ok && val != "" {
+		return val
+	}
+
+	name := secret.Status.SecretName
+	if secret.Spec.ExternalName != nil {
+		name = *secret.Spec.ExternalName
+	}
+
+	return strings.Trim(name, "/")
+}
+
+func (p *FileProvider) DeleteSecret(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret) error {
+	p.store.mu.Lock()
+	defer p.store.mu.Unlock()
+
+	name := secretName(secret)
+
+	if secret.Spec.RecoveryWindow > 0 {
+		if secret.Status.DeletionDate == nil {
+			doc, err := p.store.load(name)
+			if err != nil {
+				if !errors.Is(err, errNotFound) {
+					return fmt.Errorf("failed to delete secret: %w", err)
+				}
+
+				reqLogger.Info(fmt.Sprintf("Secret %s not found, ignoring", name))
+				return nil
+			}
+
+			deletion := time.Now().Add(time.Duration(secret.Spec.RecoveryWindow) * 24 * time.Hour).UTC()
+			doc.DeletionDate = &deletion
+			if err := p.store.save(doc); err != nil {
+				return fmt.Errorf("failed to delete secret: %w", err)
+			}
+
+			t := v1.NewTime(deletion)
+			secret.Status.DeletionDate = &t
+		}
+
+		// keep the file recoverable until the recovery window is over
+		if time.Now().Before(secret.Status.DeletionDate.Time) {
+			return fmt.Errorf("secret scheduled for deletion in %s", secret.Status.DeletionDate.Time.String())
+		}
+	}
+
+	if err := p.store.remove(name); err != nil {
+		return fmt.Errorf("failed to delete secret: %w", err)
+	}
+
+	return nil
+}
+
+func (p *FileProvider) CreateSecret(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, value string) error {
+	p.store.mu.Lock()
+	defer p.store.mu.Unlock()
+
+	name := secretName(secret)
+
+	doc, err := p.store.load(name)
+	switch {
+	case errors.Is(err, errNotFound):
+		doc = &document{Name: name}
+	case err != nil:
+		reqLogger.Error(err, "Failed to create Secret")
+		return err
+	case doc.DeletionDate != nil && !secret.Spec.Overwrite:
+		return fmt.Errorf("secret %s is scheduled for deletion in %s and overwrite is not set", name, doc.DeletionDate.String())
+	case doc.DeletionDate == nil && !secret.Spec.Overwrite:
+		return fmt.Errorf("secret %s already exists and overwrite is not set", name)
+	}
+
+	doc.DeletionDate = nil
+	version := doc.addVersion(value, p.store.maxVersions)
+	if err := p.store.save(doc); err != nil {
+		reqLogger.Error(err, "Failed to create Secret")
+		return err
+	}
+
+	secret.Status.Provider["Name"] = name
+	secret.Status.Provider["Version"] = strconv.Itoa(version)
+
+	return nil
+}
+
+func (p *FileProvider) GetSecretLastChangedDate(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret) (*time.Time, error) {
+	p.store.mu.Lock()
+	defer p.store.mu.Unlock()
+
+	doc, err := p.store.load(secretName(secret))
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe secret: %w", err)
+	}
+
+	current := doc.current()
+	if current == nil {
+		return nil, nil
+	}
+
+	return &current.Created, nil
+}
+
+func (p *FileProvider) UpdateSecret(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, value string) error {
+	p.store.mu.Lock()
+	defer p.store.mu.Unlock()
+
+	name := secretName(secret)
+
+	doc, err := p.store.load(name)
+	if err != nil {
+		return fmt.Errorf("failed to update secret: %w", err)
+	}
+
+	version := doc.addVersion(value, p.store.maxVersions)
+	if err := p.store.save(doc); err != nil {
+		return fmt.Errorf("failed to update secret: %w", err)
+	}
+
+	secret.Status.Provider["Name"] = name
+	secret.Status.Provider["Version"] = strconv.Itoa(version)
+
+	return nil
+}
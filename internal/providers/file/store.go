@@ -0,0 +1,205 @@
+package file
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileVersion prefixes every secret file so that the format can evolve
+const (
+	fileVersion   = byte(1)
+	fileExtension = ".secret"
+)
+
+var errNotFound = errors.New("secret not found")
+
+// document is the content of a secret file
+type document struct {
+	Name     string    `json:"name"`
+	Versions []version `json:"versions"`
+	// DeletionDate is set when the secret is scheduled for deletion
+	DeletionDate *time.Time `json:"deletionDate,omitempty"`
+	// ACL lists the service accounts allowed to read the secret, by access namespace/name
+	ACL map[string][]serviceAccount `json:"acl,omitempty"`
+}
+
+type version struct {
+	Version int       `json:"version"`
+	Value   string    `json:"value"`
+	Created time.Time `json:"created"`
+}
+
+type serviceAccount struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+func (d *document) current() *version {
+	if len(d.Versions) == 0 {
+		return nil
+	}
+
+	return &d.Versions[len(d.Versions)-1]
+}
+
+// addVersion appends a value, dropping the oldest versions above max
+func (d *document) addVersion(value string, max int) int {
+	next := 1
+	if current := d.current(); current != nil {
+		next = current.Version + 1
+	}
+
+	d.Versions = append(d.Versions, version{Version: next, Value: value, Created: time.Now().UTC()})
+	if len(d.Versions) > max {
+		d.Versions = d.Versions[len(d.Versions)-max:]
+	}
+
+	return next
+}
+
+// allowed reports whether a service account is granted by any access of the ACL
+func (d *document) allowed(namespace, name string) bool {
+	for _, subjects := range d.ACL {
+		for _, subject := range subjects {
+			if subject.Namespace == namespace && subject.Name == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// store reads and writes encrypted secret files under a directory
+type store struct {
+	mu          sync.Mutex
+	dir         string
+	aead        cipher.AEAD
+	maxVersions int
+}
+
+func newStore(dir string, key []byte, maxVersions int) (*store, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher: %w", err)
+	}
+
+	return &store{dir: dir, aead: aead, maxVersions: maxVersions}, nil
+}
+
+// path returns the file of a secret, names containing / are stored in sub directories
+func (s *store) path(name string) (string, error) {
+	name = strings.Trim(name, "/")
+	if name == "" {
+		return "", fmt.Errorf("secret name is empty")
+	}
+
+	for _, part := range strings.Split(name, "/") {
+		if part == "" || part == "." || part == ".." {
+			return "", fmt.Errorf("invalid secret name %s", name)
+		}
+	}
+
+	return filepath.Join(s.dir, filepath.FromSlash(name)+fileExtension), nil
+}
+
+// load decrypts the document of a secret, the name is authenticated so files cannot be swapped
+func (s *store) load(name string) (*document, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errNotFound
+		}
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	nonceSize := s.aead.NonceSize()
+	if len(data) < 1+nonceSize || data[0] != fileVersion {
+		return nil, fmt.Errorf("%s is not a secret file", path)
+	}
+
+	plaintext, err := s.aead.Open(nil, data[1:1+nonceSize], data[1+nonceSize:], []byte(strings.Trim(name, "/")))
+	if err != nil {
+		return nil, fmt.Errorf("decrypting %s: %w", path, err)
+	}
+
+	doc := &document{}
+	if err := json.Unmarshal(plaintext, doc); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
+	}
+
+	return doc, nil
+}
+
+// save encrypts and atomically replaces the file of a secret
+func (s *store) save(doc *document) error {
+	path, err := s.path(doc.Name)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("encoding secret: %w", err)
+	}
+
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("generating nonce: %w", err)
+	}
+
+	data := append([]byte{fileVersion}, nonce...)
+	data = s.aead.Seal(data, nonce, plaintext, []byte(strings.Trim(doc.Name, "/")))
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("creating directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *store) remove(name string) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,14 @@
+package file
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestFileProvider(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "File Provider Suite")
+}
@@ -0,0 +1,150 @@
+package gcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// apiError is the error body returned by Google REST APIs
+type apiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s (%d): %s", e.Status, e.Code, e.Message)
+}
+
+func isNotFound(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound
+}
+
+func isAlreadyExists(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusConflict
+}
+
+// restClient is a minimal JSON client for the Google REST APIs used by the provider
+type restClient struct {
+	httpClient *http.Client
+	endpoint   string
+}
+
+func (c *restClient) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("marshalling request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.endpoint, "/")+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading response: %w", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		errBody := struct {
+			Error *apiError `json:"error"`
+		}{}
+		if err := json.Unmarshal(data, &errBody); err != nil || errBody.Error == nil {
+			return &apiError{Code: resp.StatusCode, Status: resp.Status, Message: string(data)}
+		}
+
+		return errBody.Error
+	}
+
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("unmarshalling response: %w", err)
+		}
+	}
+
+	return nil
+}
+
+type iamBinding struct {
+	Role    string   `json:"role"`
+	Members []string `json:"members"`
+}
+
+type iamPolicy struct {
+	Version  int          `json:"version,omitempty"`
+	Etag     string       `json:"etag,omitempty"`
+	Bindings []iamBinding `json:"bindings,omitempty"`
+}
+
+// setMembers replaces the members of role that are part of managed with desired,
+// leaving any members added outside of the operator untouched
+func (p *iamPolicy) setMembers(role string, managed, desired []string) {
+	remove := make(map[string]bool, len(managed))
+	for _, m := range managed {
+		remove[m] = true
+	}
+
+	bindings := make([]iamBinding, 0, len(p.Bindings)+1)
+	found := false
+	for _, b := range p.Bindings {
+		if b.Role != role {
+			bindings = append(bindings, b)
+			continue
+		}
+
+		found = true
+		members := make([]string, 0, len(b.Members)+len(desired))
+		for _, m := range b.Members {
+			if !remove[m] {
+				members = append(members, m)
+			}
+		}
+		for _, m := range desired {
+			if !contains(members, m) {
+				members = append(members, m)
+			}
+		}
+
+		if len(members) > 0 {
+			bindings = append(bindings, iamBinding{Role: role, Members: members})
+		}
+	}
+
+	if !found && len(desired) > 0 {
+		bindings = append(bindings, iamBinding{Role: role, Members: desired})
+	}
+
+	p.Bindings = bindings
+}
+
+func contains(list []string, val string) bool {
+	for _, v := range list {
+		if v == val {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,198 @@
+package gcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+type fakeSecret struct {
+	secret   gcpSecret
+	versions []secretVersion
+	values   []string
+	policy   iamPolicy
+}
+
+type fakeServiceAccount struct {
+	account serviceAccount
+	policy  iamPolicy
+}
+
+// fakeGoogleAPI is an in-memory stand-in for the Secret Manager and IAM REST APIs
+type fakeGoogleAPI struct {
+	mu              sync.Mutex
+	secrets         map[string]*fakeSecret
+	serviceAccounts map[string]*fakeServiceAccount
+	// denied makes every request fail with PERMISSION_DENIED
+	denied bool
+	server *httptest.Server
+}
+
+func newFakeGoogleAPI() *fakeGoogleAPI {
+	f := &fakeGoogleAPI{
+		secrets:         make(map[string]*fakeSecret),
+		serviceAccounts: make(map[string]*fakeServiceAccount),
+	}
+	f.server = httptest.NewServer(http.HandlerFunc(f.handle))
+
+	return f
+}
+
+func (f *fakeGoogleAPI) Close() {
+	f.server.Close()
+}
+
+func writeError(w http.ResponseWriter, code int, status, message string) {
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]*apiError{
+		"error": {Code: code, Status: status, Message: message},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, body interface{}) {
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func (f *fakeGoogleAPI) handle(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.denied {
+		writeError(w, http.StatusForbidden, "PERMISSION_DENIED", "permission denied")
+		return
+	}
+
+	resource, verb, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v1/"), ":")
+	parts := strings.Split(resource, "/")
+	if len(parts) < 3 || parts[0] != "projects" {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "unknown path")
+		return
+	}
+
+	switch parts[2] {
+	case "secrets":
+		f.handleSecrets(w, r, parts, verb)
+	case "serviceAccounts":
+		f.handleServiceAccounts(w, r, parts, verb)
+	default:
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "unknown collection")
+	}
+}
+
+func (f *fakeGoogleAPI) handleSecrets(w http.ResponseWriter, r *http.Request, parts []string, verb string) {
+	if len(parts) == 3 && r.Method == http.MethodPost {
+		name := fmt.Sprintf("projects/%s/secrets/%s", parts[1], r.URL.Query().Get("secretId"))
+		if _, ok := f.secrets[name]; ok {
+			writeError(w, http.StatusConflict, "ALREADY_EXISTS", "secret already exists")
+			return
+		}
+
+		s := gcpSecret{}
+		_ = json.NewDecoder(r.Body).Decode(&s)
+		s.Name = name
+		f.secrets[name] = &fakeSecret{secret: s}
+		writeJSON(w, s)
+		return
+	}
+	if len(parts) == 3 && r.Method == http.MethodGet {
+		secrets := make([]gcpSecret, 0)
+		for _, s := range f.secrets {
+			secrets = append(secrets, s.secret)
+		}
+		writeJSON(w, map[string][]gcpSecret{"secrets": secrets})
+		return
+	}
+
+	name := strings.Join(parts[:4], "/")
+	secret, ok := f.secrets[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "secret not found")
+		return
+	}
+
+	switch {
+	case verb == "addVersion":
+		req := addVersionRequest{}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		version := secretVersion{
+			Name:       fmt.Sprintf("%s/versions/%d", name, len(secret.versions)+1),
+			CreateTime: time.Now().UTC().Format(time.RFC3339Nano),
+			State:      "ENABLED",
+		}
+		secret.versions = append(secret.versions, version)
+		secret.values = append(secret.values, req.Payload.Data)
+		writeJSON(w, version)
+	case verb == "getIamPolicy":
+		writeJSON(w, secret.policy)
+	case verb == "setIamPolicy":
+		req := setIamPolicyRequest{}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		secret.policy = *req.Policy
+		writeJSON(w, secret.policy)
+	case len(parts) == 6 && parts[5] == "latest":
+		if len(secret.versions) == 0 {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "no versions")
+			return
+		}
+		writeJSON(w, secret.versions[len(secret.versions)-1])
+	case r.Method == http.MethodDelete:
+		delete(f.secrets, name)
+		writeJSON(w, struct{}{})
+	default:
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "unsupported secret operation")
+	}
+}
+
+func (f *fakeGoogleAPI) handleServiceAccounts(w http.ResponseWriter, r *http.Request, parts []string, verb string) {
+	if len(parts) == 3 && r.Method == http.MethodPost {
+		req := createServiceAccountRequest{}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		email := fmt.Sprintf("%s@%s.iam.gserviceaccount.com", req.AccountID, parts[1])
+		if _, ok := f.serviceAccounts[email]; ok {
+			writeError(w, http.StatusConflict, "ALREADY_EXISTS", "service account already exists")
+			return
+		}
+
+		sa := req.ServiceAccount
+		sa.Email = email
+		sa.Name = fmt.Sprintf("projects/%s/serviceAccounts/%s", parts[1], email)
+		f.serviceAccounts[email] = &fakeServiceAccount{account: sa}
+		writeJSON(w, sa)
+		return
+	}
+
+	sa, ok := f.serviceAccounts[parts[3]]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "service account not found")
+		return
+	}
+
+	switch {
+	case verb == "getIamPolicy":
+		writeJSON(w, sa.policy)
+	case verb == "setIamPolicy":
+		req := setIamPolicyRequest{}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		sa.policy = *req.Policy
+		writeJSON(w, sa.policy)
+	case r.Method == http.MethodDelete:
+		delete(f.serviceAccounts, parts[3])
+		writeJSON(w, struct{}{})
+	default:
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "unsupported service account operation")
+	}
+}
+
+func (f *fakeGoogleAPI) members(policy iamPolicy, role string) []string {
+	for _, b := range policy.Bindings {
+		if b.Role == role {
+			return b.Members
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,189 @@
+package gcp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/go-logr/logr"
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+)
+
+const (
+	secretAccessorRole       = "roles/secretmanager.secretAccessor"
+	workloadIdentityUserRole = "roles/iam.workloadIdentityUser"
+	// service account ids must be between 6 and 30 characters
+	maxServiceAccountIDLength = 30
+)
+
+var invalidServiceAccountIDChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+type serviceAccount struct {
+	Name        string `json:"name,omitempty"`
+	Email       string `json:"email,omitempty"`
+	DisplayName string `json:"displayName,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+type createServiceAccountRequest struct {
+	AccountID      string         `json:"accountId"`
+	ServiceAccount serviceAccount `json:"serviceAccount"`
+}
+
+type setIamPolicyRequest struct {
+	Policy *iamPolicy `json:"policy"`
+}
+
+// serviceAccountID builds a valid GCP service account id for an access, hashing
+// the namespace and name when they do not fit in the 30 character limit
+func serviceAccountID(namespace, name string) string {
+	id := strings.Trim(invalidServiceAccountIDChars.ReplaceAllString(strings.ToLower(fmt.Sprintf("sb-%s-%s", namespace, name)), "-"), "-")
+	if len(id) <= maxServiceAccountIDLength {
+		return id
+	}
+
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s/%s", namespace, name)))
+	hash := hex.EncodeToString(sum[:])[:8]
+
+	return fmt.Sprintf("%s-%s", strings.TrimRight(id[:maxServiceAccountIDLength-len(hash)-1], "-"), hash)
+}
+
+func (p *GcpProvider) CreateAccess(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, access *secretsv1alpha1.ExternalSecretAccess) error {
+	if _, ok := access.Status.Provider["ServiceAccountEmail"]; !ok {
+		accountID := serviceAccountID(access.Namespace, access.Name)
+
+		result := &serviceAccount{}
+		if err := p.iamClient.do(ctx, http.MethodPost, fmt.Sprintf("/v1/projects/%s/serviceAccounts", p.ProjectID), &createServiceAccountRequest{
+			AccountID: accountID,
+			ServiceAccount: serviceAccount{
+				DisplayName: fmt.Sprintf("secretsbeam %s/%s", access.Namespace, access.Name),
+				Description: "Managed by secretsbeam-operator",
+			},
+		}, result); err != nil {
+			if !isAlreadyExists(err) {
+				return fmt.Errorf("failed to create service account: %w", err)
+			}
+
+			result.Email = fmt.Sprintf("%s@%s.iam.gserviceaccount.com", accountID, p.ProjectID)
+		}
+
+		reqLogger.Info(fmt.Sprintf("Created Service Account: %s", result.Email))
+		access.Status.Provider["ServiceAccountEmail"] = result.Email
+		access.Status.Provider["ServiceAccountAnnotation"] = fmt.Sprintf("iam.gke.io/gcp-service-account=%s", result.Email)
+	}
+
+	return p.applyAccess(ctx, reqLogger, secret, access)
+}
+
+func (p *GcpProvider) UpdateAccess(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, access *secretsv1alpha1.ExternalSecretAccess) error {
+	if _, ok := access.Status.Provider["ServiceAccountEmail"]; !ok {
+		return fmt.Errorf("access has no service account, it must be recreated")
+	}
+
+	return p.applyAccess(ctx, reqLogger, secret, access)
+}
+
+func (p *GcpProvider) DeleteAccess(ctx context.Context, reqLogger logr.Logger, access *secretsv1alpha1.ExternalSecretAccess) error {
+	if secretName, ok := access.Status.Provider["SecretName"]; ok {
+		if err := p.setSecretMembers(ctx, secretName, managedMembers(access), nil); err != nil {
+			if !isNotFound(err) {
+				return fmt.Errorf("failed to remove bindings from %s: %w", secretName, err)
+			}
+
+			reqLogger.Info(fmt.Sprintf("Secret %s not found, ignoring", secretName))
+		}
+	}
+
+	if email, ok := access.Status.Provider["ServiceAccountEmail"]; ok {
+		if err := p.iamClient.do(ctx, http.MethodDelete, fmt.Sprintf("/v1/projects/%s/serviceAccounts/%s", p.ProjectID, email), nil, nil); err != nil {
+			if !isNotFound(err) {
+				return fmt.Errorf("failed to delete service account %s: %w", email, err)
+			}
+
+			reqLogger.Info(fmt.Sprintf("Service Account %s not found, ignoring", email))
+		} else {
+			reqLogger.Info(fmt.Sprintf("Deleted Service Account %s", email))
+		}
+	}
+
+	return nil
+}
+
+// applyAccess grants the access service account and provider identifiers read access on the secret
+// and lets the kubernetes service account subjects impersonate the access service account
+func (p *GcpProvider) applyAccess(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, access *secretsv1alpha1.ExternalSecretAccess) error {
+	email := access.Status.Provider["ServiceAccountEmail"]
+	secretName := p.secretResource(secret)
+
+	secretMembers := []string{fmt.Sprintf("serviceAccount:%s", email)}
+	workloadMembers := make([]string, 0)
+	for _, subject := range access.Spec.AccessSubjects {
+		if subject.ServiceAccount != nil {
+			workloadMembers = append(workloadMembers, fmt.Sprintf("serviceAccount:%s[%s/%s]", p.WorkloadIdentityPool, subject.ServiceAccount.Namespace, subject.ServiceAccount.Name))
+		} else if subject.ProviderIdentifier != nil {
+			secretMembers = append(secretMembers, subject.ProviderIdentifier.Identifier)
+		}
+	}
+
+	// the target secret changed, clean up the bindings on the previous one
+	if previous, ok := access.Status.Provider["SecretName"]; ok && previous != secretName {
+		if err := p.setSecretMembers(ctx, previous, managedMembers(access), nil); err != nil && !isNotFound(err) {
+			return fmt.Errorf("failed to remove bindings from %s: %w", previous, err)
+		}
+	}
+
+	if err := p.setSecretMembers(ctx, secretName, managedMembers(access), secretMembers); err != nil {
+		return fmt.Errorf("failed to update secret policy: %w", err)
+	}
+	reqLogger.Info(fmt.Sprintf("Granted %s on %s", secretAccessorRole, secretName))
+
+	access.Status.Provider["SecretName"] = secretName
+	access.Status.Provider["Members"] = strings.Join(secretMembers, ",")
+
+	saResource := fmt.Sprintf("/v1/projects/%s/serviceAccounts/%s", p.ProjectID, email)
+	policy := &iamPolicy{}
+	if err := p.iamClient.do(ctx, http.MethodPost, saResource+":getIamPolicy", struct{}{}, policy); err != nil {
+		return fmt.Errorf("failed to get service account policy: %w", err)
+	}
+
+	// the service account belongs to this access, so the workload identity binding is fully owned
+	var current []string
+	for _, b := range policy.Bindings {
+		if b.Role == workloadIdentityUserRole {
+			current = b.Members
+		}
+	}
+	policy.setMembers(workloadIdentityUserRole, current, workloadMembers)
+
+	if err := p.iamClient.do(ctx, http.MethodPost, saResource+":setIamPolicy", &setIamPolicyRequest{Policy: policy}, nil); err != nil {
+		return fmt.Errorf("failed to update service account policy: %w", err)
+	}
+	reqLogger.Info(fmt.Sprintf("Granted %s on %s", workloadIdentityUserRole, email))
+
+	return nil
+}
+
+func (p *GcpProvider) setSecretMembers(ctx context.Context, secretName string, managed, desired []string) error {
+	policy := &iamPolicy{}
+	if err := p.secretsClient.do(ctx, http.MethodGet, fmt.Sprintf("/v1/%s:getIamPolicy", secretName), nil, policy); err != nil {
+		return err
+	}
+
+	policy.setMembers(secretAccessorRole, managed, desired)
+
+	return p.secretsClient.do(ctx, http.MethodPost, fmt.Sprintf("/v1/%s:setIamPolicy", secretName), &setIamPolicyRequest{Policy: policy}, nil)
+}
+
+// managedMembers returns the members previously bound on the secret by this access
+func managedMembers(access *secretsv1alpha1.ExternalSecretAccess) []string {
+	val, ok := access.Status.Provider["Members"]
+	if !ok || val == "" {
+		return nil
+	}
+
+	return strings.Split(val, ",")
+}
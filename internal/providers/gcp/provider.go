@@ -0,0 +1,93 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/mitchellh/mapstructure"
+	"golang.org/x/oauth2/google"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/registry"
+)
+
+const (
+	defaultSecretManagerEndpoint = "https://secretmanager.googleapis.com"
+	defaultIamEndpoint           = "https://iam.googleapis.com"
+	cloudPlatformScope           = "https://www.googleapis.com/auth/cloud-platform"
+)
+
+// GcpProvider stores secrets in GCP Secret Manager and grants access to them
+// through GCP service accounts bound to Kubernetes service accounts with Workload Identity
+type GcpProvider struct {
+	GcpProviderConfig
+
+	secretsClient *restClient
+	iamClient     *restClient
+}
+
+type GcpProviderConfig struct {
+	ProjectID string `json:"projectID"`
+	// WorkloadIdentityPool defaults to <ProjectID>.svc.id.goog
+	WorkloadIdentityPool string `json:"workloadIdentityPool"`
+	// SecretManagerEndpoint and IamEndpoint override the Google API endpoints, e.g. for emulators
+	SecretManagerEndpoint string `json:"secretManagerEndpoint"`
+	IamEndpoint           string `json:"iamEndpoint"`
+}
+
+func init() {
+	registry.Register(secretsv1alpha1.SecretProviderGcp, func(_ registry.Options) registry.Provider {
+		return &GcpProvider{}
+	}, registry.SchemaOf(GcpProviderConfig{}, "projectID"))
+}
+
+func (p *GcpProvider) Init(config map[string]string) error {
+	providerConfig := &GcpProviderConfig{}
+	if err := mapstructure.Decode(config, providerConfig); err != nil {
+		return fmt.Errorf("decoding provider config: %w", err)
+	}
+
+	httpClient, err := google.DefaultClient(context.Background(), cloudPlatformScope)
+	if err != nil {
+		return fmt.Errorf("unable to load google credentials, %v", err)
+	}
+
+	return p.init(*providerConfig, httpClient)
+}
+
+func (p *GcpProvider) init(config GcpProviderConfig, httpClient *http.Client) error {
+	if config.ProjectID == "" {
+		return fmt.Errorf("projectID is required")
+	}
+	if config.WorkloadIdentityPool == "" {
+		config.WorkloadIdentityPool = fmt.Sprintf("%s.svc.id.goog", config.ProjectID)
+	}
+	if config.SecretManagerEndpoint == "" {
+		config.SecretManagerEndpoint = defaultSecretManagerEndpoint
+	}
+	if config.IamEndpoint == "" {
+		config.IamEndpoint = defaultIamEndpoint
+	}
+
+	p.GcpProviderConfig = config
+	p.secretsClient = &restClient{httpClient: httpClient, endpoint: config.SecretManagerEndpoint}
+	p.iamClient = &restClient{httpClient: httpClient, endpoint: config.IamEndpoint}
+
+	return nil
+}
+
+// CheckHealth lists a single secret of the project, which validates the credentials, the
+// connectivity to Secret Manager and the read permissions of the operator
+func (p *GcpProvider) CheckHealth(ctx context.Context) (*registry.Health, error) {
+	if err := p.secretsClient.do(ctx, http.MethodGet, fmt.Sprintf("/v1/projects/%s/secrets?pageSize=1", p.ProjectID), nil, nil); err != nil {
+		return nil, fmt.Errorf("listing secrets: %w", err)
+	}
+
+	return &registry.Health{Account: p.ProjectID}, nil
+}
+
+// Capabilities reports the features of Secret Manager, which deletes secrets immediately
+func (p *GcpProvider) Capabilities() registry.Capabilities {
+	return registry.Capabilities{AccessControl: true}
+}
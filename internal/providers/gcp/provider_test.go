@@ -0,0 +1,192 @@
+package gcp
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/providertest"
+)
+
+var _ = Describe("GcpProvider", func() {
+	var (
+		ctx      context.Context
+		fake     *fakeGoogleAPI
+		provider *GcpProvider
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		fake = newFakeGoogleAPI()
+		provider = &GcpProvider{}
+		Expect(provider.init(GcpProviderConfig{
+			ProjectID:             "test-project",
+			SecretManagerEndpoint: fake.server.URL,
+			IamEndpoint:           fake.server.URL,
+		}, fake.server.Client())).To(Succeed())
+	})
+
+	AfterEach(func() {
+		fake.Close()
+	})
+
+	It("defaults the workload identity pool to the project pool", func() {
+		Expect(provider.WorkloadIdentityPool).To(Equal("test-project.svc.id.goog"))
+	})
+
+	It("requires a project", func() {
+		Expect((&GcpProvider{}).init(GcpProviderConfig{}, fake.server.Client())).NotTo(Succeed())
+	})
+
+	Context("health", func() {
+		It("lists the secrets of the project", func() {
+			health, err := provider.CheckHealth(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(health.Account).To(Equal("test-project"))
+		})
+
+		It("reports missing permissions", func() {
+			fake.denied = true
+			_, err := provider.CheckHealth(ctx)
+			Expect(err).To(MatchError(ContainSubstring("PERMISSION_DENIED")))
+		})
+	})
+
+	Context("secrets", func() {
+		It("creates a secret with an initial version", func() {
+			secret := providertest.NewSecret("/team/db/password")
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, secret, "hunter2")).To(Succeed())
+
+			Expect(secret.Status.Provider["SecretName"]).To(Equal("projects/test-project/secrets/team-db-password"))
+			Expect(secret.Status.Provider["SecretVersion"]).To(Equal("projects/test-project/secrets/team-db-password/versions/1"))
+
+			stored := fake.secrets["projects/test-project/secrets/team-db-password"]
+			Expect(stored).NotTo(BeNil())
+			Expect(stored.secret.Replication.Automatic).NotTo(BeNil())
+			Expect(stored.values).To(Equal([]string{base64.StdEncoding.EncodeToString([]byte("hunter2"))}))
+		})
+
+		It("uses user managed replication and customer managed keys from the provider spec", func() {
+			located := providertest.NewSecret("located")
+			located.Spec.ProviderSpec["Locations"] = "europe-west1, us-east1"
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, located, "value")).To(Succeed())
+			Expect(fake.secrets["projects/test-project/secrets/located"].secret.Replication.UserManaged.Replicas).To(Equal([]replica{
+				{Location: "europe-west1"}, {Location: "us-east1"},
+			}))
+
+			encrypted := providertest.NewSecret("encrypted")
+			encrypted.Spec.ProviderSpec["KmsKeyName"] = "projects/test-project/locations/global/keyRings/r/cryptoKeys/k"
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, encrypted, "value")).To(Succeed())
+			Expect(encrypted.Status.Provider["KmsKeyName"]).To(Equal("projects/test-project/locations/global/keyRings/r/cryptoKeys/k"))
+			Expect(fake.secrets["projects/test-project/secrets/encrypted"].secret.Replication.Automatic.CustomerManagedEncryption.KmsKeyName).
+				To(Equal("projects/test-project/locations/global/keyRings/r/cryptoKeys/k"))
+
+			invalid := providertest.NewSecret("invalid")
+			invalid.Spec.ProviderSpec["Locations"] = "europe-west1"
+			invalid.Spec.ProviderSpec["KmsKeyName"] = "key"
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, invalid, "value")).NotTo(Succeed())
+		})
+
+		It("only overwrites existing secrets when asked to", func() {
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, providertest.NewSecret("existing"), "first")).To(Succeed())
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, providertest.NewSecret("existing"), "second")).NotTo(Succeed())
+
+			secret := providertest.NewSecret("existing")
+			secret.Spec.Overwrite = true
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, secret, "second")).To(Succeed())
+			Expect(fake.secrets["projects/test-project/secrets/existing"].versions).To(HaveLen(2))
+		})
+
+		It("adds a version on update and reports its creation date", func() {
+			secret := providertest.NewSecret("rotated")
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, secret, "first")).To(Succeed())
+			Expect(provider.UpdateSecret(ctx, GinkgoLogr, secret, "second")).To(Succeed())
+
+			stored := fake.secrets["projects/test-project/secrets/rotated"]
+			Expect(stored.versions).To(HaveLen(2))
+			Expect(secret.Status.Provider["SecretVersion"]).To(Equal(stored.versions[1].Name))
+
+			changed, err := provider.GetSecretLastChangedDate(ctx, GinkgoLogr, secret)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changed.UTC().Format(time.RFC3339Nano)).To(Equal(stored.versions[1].CreateTime))
+		})
+
+		It("deletes secrets and ignores secrets that are already gone", func() {
+			secret := providertest.NewSecret("deleted")
+			secret.Spec.RecoveryWindow = 7
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, secret, "value")).To(Succeed())
+			Expect(provider.DeleteSecret(ctx, GinkgoLogr, secret)).To(Succeed())
+			Expect(fake.secrets).NotTo(HaveKey("projects/test-project/secrets/deleted"))
+
+			Expect(provider.DeleteSecret(ctx, GinkgoLogr, secret)).To(Succeed())
+		})
+	})
+
+	Context("access", func() {
+		var secret *secretsv1alpha1.ExternalSecret
+
+		BeforeEach(func() {
+			secret = providertest.NewSecret("shared")
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, secret, "value")).To(Succeed())
+		})
+
+		It("creates a service account bound to the secret and to the workload identity subjects", func() {
+			access := providertest.NewAccess("reader", providertest.ServiceAccountSubject("apps", "api"), secretsv1alpha1.SecretAccessSubject{
+				ProviderIdentifier: &secretsv1alpha1.SecretAccessSubjectProviderIdentifier{Identifier: "group:ops@example.com"},
+			})
+			Expect(provider.CreateAccess(ctx, GinkgoLogr, secret, access)).To(Succeed())
+
+			email := "sb-team-a-reader@test-project.iam.gserviceaccount.com"
+			Expect(access.Status.Provider["ServiceAccountEmail"]).To(Equal(email))
+			Expect(access.Status.Provider["ServiceAccountAnnotation"]).To(Equal("iam.gke.io/gcp-service-account=" + email))
+			Expect(access.Status.Provider["SecretName"]).To(Equal("projects/test-project/secrets/shared"))
+
+			Expect(fake.members(fake.secrets["projects/test-project/secrets/shared"].policy, secretAccessorRole)).
+				To(ConsistOf("serviceAccount:"+email, "group:ops@example.com"))
+			Expect(fake.members(fake.serviceAccounts[email].policy, workloadIdentityUserRole)).
+				To(ConsistOf("serviceAccount:test-project.svc.id.goog[apps/api]"))
+		})
+
+		It("replaces only its own members on update", func() {
+			stored := fake.secrets["projects/test-project/secrets/shared"]
+			stored.policy.Bindings = []iamBinding{{Role: secretAccessorRole, Members: []string{"user:someone@example.com"}}}
+
+			access := providertest.NewAccess("reader", providertest.ServiceAccountSubject("apps", "api"), secretsv1alpha1.SecretAccessSubject{
+				ProviderIdentifier: &secretsv1alpha1.SecretAccessSubjectProviderIdentifier{Identifier: "group:ops@example.com"},
+			})
+			Expect(provider.CreateAccess(ctx, GinkgoLogr, secret, access)).To(Succeed())
+
+			access.Spec.AccessSubjects = []secretsv1alpha1.SecretAccessSubject{providertest.ServiceAccountSubject("apps", "worker")}
+			Expect(provider.UpdateAccess(ctx, GinkgoLogr, secret, access)).To(Succeed())
+
+			email := access.Status.Provider["ServiceAccountEmail"]
+			Expect(fake.members(stored.policy, secretAccessorRole)).To(ConsistOf("user:someone@example.com", "serviceAccount:"+email))
+			Expect(fake.members(fake.serviceAccounts[email].policy, workloadIdentityUserRole)).
+				To(ConsistOf("serviceAccount:test-project.svc.id.goog[apps/worker]"))
+		})
+
+		It("removes the bindings and the service account on delete", func() {
+			access := providertest.NewAccess("reader", providertest.ServiceAccountSubject("apps", "api"))
+			Expect(provider.CreateAccess(ctx, GinkgoLogr, secret, access)).To(Succeed())
+			Expect(provider.DeleteAccess(ctx, GinkgoLogr, access)).To(Succeed())
+
+			Expect(fake.serviceAccounts).To(BeEmpty())
+			Expect(fake.secrets["projects/test-project/secrets/shared"].policy.Bindings).To(BeEmpty())
+
+			By("ignoring resources that are already gone")
+			Expect(provider.DeleteAccess(ctx, GinkgoLogr, access)).To(Succeed())
+		})
+
+		It("fits long names in the service account id limit", func() {
+			id := serviceAccountID("a-very-long-namespace-name", "and-an-even-longer-access-name")
+			Expect(len(id)).To(BeNumerically("<=", maxServiceAccountIDLength))
+			Expect(strings.HasPrefix(id, "sb-a-very-long")).To(BeTrue())
+			Expect(id).NotTo(Equal(serviceAccountID("a-very-long-namespace-name", "and-an-even-longer-access-other")))
+		})
+	})
+})
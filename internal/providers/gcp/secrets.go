@@ -0,0 +1,179 @@
+package gcp
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+)
+
+var invalidSecretIDChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+type customerManagedEncryption struct {
+	KmsKeyName string `json:"kmsKeyName"`
+}
+
+type automaticReplication struct {
+	CustomerManagedEncryption *customerManagedEncryption `json:"customerManagedEncryption,omitempty"`
+}
+
+type replica struct {
+	Location string `json:"location"`
+}
+
+type userManagedReplication struct {
+	Replicas []replica `json:"replicas"`
+}
+
+type replication struct {
+	Automatic   *automaticReplication   `json:"automatic,omitempty"`
+	UserManaged *userManagedReplication `json:"userManaged,omitempty"`
+}
+
+type gcpSecret struct {
+	Name        string      `json:"name,omitempty"`
+	Replication replication `json:"replication"`
+}
+
+type secretPayload struct {
+	Data string `json:"data"`
+}
+
+type addVersionRequest struct {
+	Payload secretPayload `json:"payload"`
+}
+
+type secretVersion struct {
+	Name       string `json:"name"`
+	CreateTime string `json:"createTime"`
+	State      string `json:"state"`
+}
+
+// toSecretID converts an external name into a valid Secret Manager secret id
+func toSecretID(name string) string {
+	return strings.Trim(invalidSecretIDChars.ReplaceAllString(name, "-"), "-")
+}
+
+func (p *GcpProvider) secretResource(secret *secretsv1alpha1.ExternalSecret) string {
+	if val, ok := secret.Status.Provider["SecretName"]; ok && val != "" {
+		return val
+	}
+
+	name := secret.Status.SecretName
+	if secret.Spec.ExternalName != nil {
+		name = *secret.Spec.ExternalName
+	}
+
+	return fmt.Sprintf("projects/%s/secrets/%s", p.ProjectID, toSecretID(name))
+}
+
+func (p *GcpProvider) DeleteSecret(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret) error {
+	if secret.Spec.RecoveryWindow > 0 {
+		reqLogger.Info("GCP Secret Manager does not support recovery windows, deleting secret immediately")
+	}
+
+	name := p.secretResource(secret)
+	if err := p.secretsClient.do(ctx, http.MethodDelete, "/v1/"+name, nil, nil); err != nil {
+		if isNotFound(err) {
+			reqLogger.Info(fmt.Sprintf("Secret %s not found, ignoring", name))
+			return nil
+		}
+
+		return fmt.Errorf("failed to delete secret: %w", err)
+	}
+
+	return nil
+}
+
+func (p *GcpProvider) CreateSecret(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, value string) error {
+	secretID := toSecretID(*secret.Spec.ExternalName)
+	input := &gcpSecret{}
+
+	kmsKeyName, hasKmsKey := secret.Spec.ProviderSpec["KmsKeyName"]
+	if val, ok := secret.Spec.ProviderSpec["Locations"]; ok {
+		if hasKmsKey {
+			return fmt.Errorf("KmsKeyName is only supported with automatic replication")
+		}
+
+		input.Replication.UserManaged = &userManagedReplication{}
+		for _, location := range strings.Split(val, ",") {
+			input.Replication.UserManaged.Replicas = append(input.Replication.UserManaged.Replicas, replica{
+				Location: strings.TrimSpace(location),
+			})
+		}
+	} else {
+		input.Replication.Automatic = &automaticReplication{}
+		if hasKmsKey {
+			input.Replication.Automatic.CustomerManagedEncryption = &customerManagedEncryption{KmsKeyName: kmsKeyName}
+			secret.Status.Provider["KmsKeyName"] = kmsKeyName
+		}
+	}
+
+	result := &gcpSecret{}
+	path := fmt.Sprintf("/v1/projects/%s/secrets?secretId=%s", p.ProjectID, url.QueryEscape(secretID))
+	if err := p.secretsClient.do(ctx, http.MethodPost, path, input, result); err != nil {
+		if !isAlreadyExists(err) || !secret.Spec.Overwrite {
+			reqLogger.Error(err, "Failed to create Secret")
+			return err
+		}
+
+		reqLogger.Info(fmt.Sprintf("Secret %s already exists, overwriting", secretID))
+		result.Name = fmt.Sprintf("projects/%s/secrets/%s", p.ProjectID, secretID)
+	}
+
+	version, err := p.addVersion(ctx, result.Name, value)
+	if err != nil {
+		return err
+	}
+
+	secret.Status.Provider["SecretName"] = result.Name
+	secret.Status.Provider["SecretVersion"] = version.Name
+
+	return nil
+}
+
+func (p *GcpProvider) GetSecretLastChangedDate(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret) (*time.Time, error) {
+	version := &secretVersion{}
+	if err := p.secretsClient.do(ctx, http.MethodGet, fmt.Sprintf("/v1/%s/versions/latest", p.secretResource(secret)), nil, version); err != nil {
+		return nil, fmt.Errorf("failed to describe secret: %w", err)
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, version.CreateTime)
+	if err != nil {
+		return nil, fmt.Errorf("parsing version create time: %w", err)
+	}
+
+	return &t, nil
+}
+
+func (p *GcpProvider) UpdateSecret(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, value string) error {
+	name := p.secretResource(secret)
+
+	version, err := p.addVersion(ctx, name, value)
+	if err != nil {
+		return fmt.Errorf("failed to update secret: %w", err)
+	}
+
+	secret.Status.Provider["SecretName"] = name
+	secret.Status.Provider["SecretVersion"] = version.Name
+
+	return nil
+}
+
+func (p *GcpProvider) addVersion(ctx context.Context, name, value string) (*secretVersion, error) {
+	version := &secretVersion{}
+	if err := p.secretsClient.do(ctx, http.MethodPost, fmt.Sprintf("/v1/%s:addVersion", name), &addVersionRequest{
+		Payload: secretPayload{Data: base64.StdEncoding.EncodeToString([]byte(value))},
+	}, version); err != nil {
+		return nil, fmt.Errorf("adding secret version: %w", err)
+	}
+
+	return version, nil
+}
@@ -0,0 +1,14 @@
+package gcp
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestGcpProvider(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "GCP Provider Suite")
+}
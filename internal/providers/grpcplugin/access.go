@@ -0,0 +1,72 @@
+package grpcplugin
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/registry"
+	pluginv1 "github.com/tiagoposse/secretsbeam-operator/pkg/plugin/v1"
+)
+
+func toAccess(access *secretsv1alpha1.ExternalSecretAccess) *pluginv1.Access {
+	subjects := make([]*pluginv1.AccessSubject, 0, len(access.Spec.AccessSubjects))
+	for _, subject := range access.Spec.AccessSubjects {
+		if subject.ServiceAccount != nil {
+			subjects = append(subjects, &pluginv1.AccessSubject{Subject: &pluginv1.AccessSubject_ServiceAccount{
+				ServiceAccount: &pluginv1.ServiceAccount{Namespace: subject.ServiceAccount.Namespace, Name: subject.ServiceAccount.Name},
+			}})
+		} else if subject.ProviderIdentifier != nil {
+			subjects = append(subjects, &pluginv1.AccessSubject{Subject: &pluginv1.AccessSubject_ProviderIdentifier{
+				ProviderIdentifier: subject.ProviderIdentifier.Identifier,
+			}})
+		}
+	}
+
+	return &pluginv1.Access{
+		Namespace: access.Namespace,
+		Name:      access.Name,
+		Subjects:  subjects,
+		Provider:  access.Status.Provider,
+	}
+}
+
+func (p *GrpcPluginProvider) CreateAccess(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, access *secretsv1alpha1.ExternalSecretAccess) error {
+	ctx, cancel := p.callContext(ctx)
+	defer cancel()
+
+	result, err := p.plugin.CreateAccess(ctx, &pluginv1.AccessRequest{Secret: toSecret(secret), Access: toAccess(access)})
+	if err != nil {
+		return fmt.Errorf("failed to create access: %w", err)
+	}
+
+	registry.MergeProviderStatus(&access.Status.Provider, result.Provider)
+
+	return nil
+}
+
+func (p *GrpcPluginProvider) UpdateAccess(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, access *secretsv1alpha1.ExternalSecretAccess) error {
+	ctx, cancel := p.callContext(ctx)
+	defer cancel()
+
+	result, err := p.plugin.UpdateAccess(ctx, &pluginv1.AccessRequest{Secret: toSecret(secret), Access: toAccess(access)})
+	if err != nil {
+		return fmt.Errorf("failed to update access: %w", err)
+	}
+
+	registry.MergeProviderStatus(&access.Status.Provider, result.Provider)
+
+	return nil
+}
+
+func (p *GrpcPluginProvider) DeleteAccess(ctx context.Context, reqLogger logr.Logger, access *secretsv1alpha1.ExternalSecretAccess) error {
+	ctx, cancel := p.callContext(ctx)
+	defer cancel()
+
+	if _, err := p.plugin.DeleteAccess(ctx, &pluginv1.AccessRequest{Access: toAccess(access)}); err != nil {
+		return fmt.Errorf("failed to delete access: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,231 @@
+package grpcplugin
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pluginv1 "github.com/tiagoposse/secretsbeam-operator/pkg/plugin/v1"
+)
+
+// testPlugin is an in-process plugin recording the requests it receives
+type testPlugin struct {
+	pluginv1.UnimplementedProviderServer
+
+	mu            sync.Mutex
+	version       string
+	config        map[string]string
+	capabilities  []string
+	secretReqs    []*pluginv1.SecretRequest
+	accessReqs    []*pluginv1.AccessRequest
+	provider      map[string]string
+	deletionDate  *time.Time
+	lastChanged   time.Time
+	failWith      error
+	healthServer  *health.Server
+	server        *grpc.Server
+	clientSubject string
+}
+
+func newTestPlugin() *testPlugin {
+	return &testPlugin{version: pluginv1.ProtocolVersion, provider: map[string]string{}}
+}
+
+// serve starts the plugin on the listener, with the given server options
+func (t *testPlugin) serve(listener net.Listener, opts ...grpc.ServerOption) {
+	t.server = grpc.NewServer(opts...)
+	t.healthServer = pluginv1.Register(t.server, t)
+	go func() {
+		_ = t.server.Serve(listener)
+	}()
+}
+
+func (t *testPlugin) Stop() {
+	t.server.Stop()
+}
+
+func (t *testPlugin) Handshake(ctx context.Context, req *pluginv1.HandshakeRequest) (*pluginv1.HandshakeResponse, error) {
+	if info, ok := peerTLSInfo(ctx); ok && len(info.State.PeerCertificates) > 0 {
+		t.mu.Lock()
+		t.clientSubject = info.State.PeerCertificates[0].Subject.CommonName
+		t.mu.Unlock()
+	}
+
+	return &pluginv1.HandshakeResponse{Version: t.version, Name: "test"}, nil
+}
+
+func (t *testPlugin) Init(ctx context.Context, req *pluginv1.InitRequest) (*pluginv1.InitResponse, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.config = req.Config
+	return &pluginv1.InitResponse{Capabilities: t.capabilities}, nil
+}
+
+func (t *testPlugin) secretResponse(req *pluginv1.SecretRequest) (*pluginv1.SecretResponse, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.secretReqs = append(t.secretReqs, req)
+	if t.failWith != nil {
+		return nil, t.failWith
+	}
+
+	resp := &pluginv1.SecretResponse{Provider: t.provider, LastChangedDate: timestamppb.New(t.lastChanged)}
+	if t.deletionDate != nil {
+		resp.DeletionDate = timestamppb.New(*t.deletionDate)
+	}
+
+	return resp, nil
+}
+
+func (t *testPlugin) accessResponse(req *pluginv1.AccessRequest) (*pluginv1.AccessResponse, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.accessReqs = append(t.accessReqs, req)
+	if t.failWith != nil {
+		return nil, t.failWith
+	}
+
+	return &pluginv1.AccessResponse{Provider: t.provider}, nil
+}
+
+func (t *testPlugin) CreateSecret(ctx context.Context, req *pluginv1.SecretRequest) (*pluginv1.SecretResponse, error) {
+	return t.secretResponse(req)
+}
+
+func (t *testPlugin) UpdateSecret(ctx context.Context, req *pluginv1.SecretRequest) (*pluginv1.SecretResponse, error) {
+	return t.secretResponse(req)
+}
+
+func (t *testPlugin) DeleteSecret(ctx context.Context, req *pluginv1.SecretRequest) (*pluginv1.SecretResponse, error) {
+	return t.secretResponse(req)
+}
+
+func (t *testPlugin) GetSecretLastChangedDate(ctx context.Context, req *pluginv1.SecretRequest) (*pluginv1.SecretResponse, error) {
+	return t.secretResponse(req)
+}
+
+func (t *testPlugin) CreateAccess(ctx context.Context, req *pluginv1.AccessRequest) (*pluginv1.AccessResponse, error) {
+	return t.accessResponse(req)
+}
+
+func (t *testPlugin) UpdateAccess(ctx context.Context, req *pluginv1.AccessRequest) (*pluginv1.AccessResponse, error) {
+	return t.accessResponse(req)
+}
+
+func (t *testPlugin) DeleteAccess(ctx context.Context, req *pluginv1.AccessRequest) (*pluginv1.AccessResponse, error) {
+	return t.accessResponse(req)
+}
+
+func (t *testPlugin) lastSecretRequest() *pluginv1.SecretRequest {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.secretReqs[len(t.secretReqs)-1]
+}
+
+func (t *testPlugin) lastAccessRequest() *pluginv1.AccessRequest {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.accessReqs[len(t.accessReqs)-1]
+}
+
+func peerTLSInfo(ctx context.Context) (credentials.TLSInfo, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return credentials.TLSInfo{}, false
+	}
+
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	return info, ok
+}
+
+var errPluginFailure = status.Error(codes.FailedPrecondition, "backend unavailable")
+
+// testCA issues certificates for the plugin and operator in mutual TLS tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA() *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+	cert, err := x509.ParseCertificate(der)
+	Expect(err).NotTo(HaveOccurred())
+
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a PEM encoded certificate and key for name
+func (ca *testCA) issue(name string, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	Expect(err).NotTo(HaveOccurred())
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	Expect(err).NotTo(HaveOccurred())
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// serverCredentials requires clients to present a certificate issued by the CA
+func (ca *testCA) serverCredentials(name string) credentials.TransportCredentials {
+	certPEM, keyPEM := ca.issue(name, x509.ExtKeyUsageServerAuth)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	Expect(err).NotTo(HaveOccurred())
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	return credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	})
+}
@@ -0,0 +1,254 @@
+package grpcplugin
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	pluginv1 "github.com/tiagoposse/secretsbeam-operator/pkg/plugin/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/registry"
+)
+
+const (
+	defaultTimeout = 30 * time.Second
+	caKey          = "ca.crt"
+)
+
+// GrpcPluginProvider delegates every operation to an out-of-process plugin implementing the
+// Provider service of pkg/plugin/v1, reached over a unix socket or TCP
+type GrpcPluginProvider struct {
+	GrpcPluginProviderConfig
+
+	// Client and Namespace are used to read the TLS secret referenced by the config
+	Client    client.Client
+	Namespace string
+
+	conn    *grpc.ClientConn
+	plugin  pluginv1.ProviderClient
+	health  healthpb.HealthClient
+	timeout time.Duration
+	// name is the name the plugin reported in the handshake
+	name string
+	// capabilities are the capabilities the plugin reported on init
+	capabilities registry.Capabilities
+}
+
+type GrpcPluginProviderConfig struct {
+	// Address is a gRPC target, e.g. unix:///var/run/secretsbeam/plugin.sock or dns:///plugin.ns.svc:9000
+	Address string `json:"address"`
+	// Timeout is a duration string applied to every call, defaults to 30s
+	Timeout string `json:"timeout"`
+	// TLSSecretName references a Secret in the provider namespace holding the ca.crt of the plugin and,
+	// for mutual TLS, the tls.crt and tls.key of the operator
+	TLSSecretName string `json:"tlsSecretName"`
+	// ServerName overrides the name used to verify the certificate of the plugin
+	ServerName string `json:"serverName"`
+	// Insecure allows plaintext connections to TCP addresses, unix sockets are plaintext unless TLSSecretName is set
+	Insecure string `json:"insecure"`
+}
+
+func init() {
+	registry.Register(secretsv1alpha1.SecretProviderGrpc, func(opts registry.Options) registry.Provider {
+		return &GrpcPluginProvider{Client: opts.Client, Namespace: opts.Namespace}
+	}, func() registry.ConfigSchema {
+		// the whole config is passed to the plugin
+		schema := registry.SchemaOf(GrpcPluginProviderConfig{}, "address")
+		schema.AdditionalKeys = true
+		return schema
+	}())
+}
+
+func (p *GrpcPluginProvider) Init(config map[string]string) error {
+	providerConfig := &GrpcPluginProviderConfig{}
+	if err := mapstructure.Decode(config, providerConfig); err != nil {
+		return fmt.Errorf("decoding provider config: %w", err)
+	}
+	if providerConfig.Address == "" {
+		return fmt.Errorf("address is required")
+	}
+	p.GrpcPluginProviderConfig = *providerConfig
+
+	creds, err := p.transportCredentials(context.Background())
+	if err != nil {
+		return err
+	}
+
+	return p.init(context.Background(), config, grpc.WithTransportCredentials(creds))
+}
+
+func (p *GrpcPluginProvider) transportCredentials(ctx context.Context) (credentials.TransportCredentials, error) {
+	if p.TLSSecretName == "" {
+		allowInsecure := false
+		if p.Insecure != "" {
+			val, err := strconv.ParseBool(p.Insecure)
+			if err != nil {
+				return nil, fmt.Errorf("parsing insecure: %w", err)
+			}
+			allowInsecure = val
+		}
+
+		if !allowInsecure && !strings.HasPrefix(p.Address, "unix:") {
+			return nil, fmt.Errorf("tlsSecretName is required for TCP addresses unless insecure is set")
+		}
+
+		return insecure.NewCredentials(), nil
+	}
+
+	secret, err := p.readTLSSecret(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: p.ServerName}
+	if ca, ok := secret.Data[caKey]; ok {
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in %s/%s", p.Namespace, p.TLSSecretName)
+		}
+	}
+
+	if _, ok := secret.Data[corev1.TLSCertKey]; ok {
+		// the client certificate is read on every handshake so that renewals are picked up without restarting
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			secret, err := p.readTLSSecret(context.Background())
+			if err != nil {
+				return nil, err
+			}
+
+			cert, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+			if err != nil {
+				return nil, fmt.Errorf("loading client certificate: %w", err)
+			}
+
+			return &cert, nil
+		}
+	}
+
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+func (p *GrpcPluginProvider) readTLSSecret(ctx context.Context) (*corev1.Secret, error) {
+	if p.Client == nil {
+		return nil, fmt.Errorf("no kubernetes client configured to read secret %s", p.TLSSecretName)
+	}
+
+	secret := &corev1.Secret{}
+	if err := p.Client.Get(ctx, types.NamespacedName{Namespace: p.Namespace, Name: p.TLSSecretName}, secret); err != nil {
+		return nil, fmt.Errorf("getting secret %s/%s: %w", p.Namespace, p.TLSSecretName, err)
+	}
+
+	return secret, nil
+}
+
+// init connects to the plugin, negotiates the protocol version, checks that it is serving and passes it the config
+func (p *GrpcPluginProvider) init(ctx context.Context, config map[string]string, opts ...grpc.DialOption) error {
+	p.timeout = defaultTimeout
+	if p.Timeout != "" {
+		val, err := time.ParseDuration(p.Timeout)
+		if err != nil {
+			return fmt.Errorf("parsing timeout: %w", err)
+		}
+		p.timeout = val
+	}
+
+	conn, err := grpc.DialContext(ctx, p.Address, opts...)
+	if err != nil {
+		return fmt.Errorf("connecting to plugin: %w", err)
+	}
+	p.conn = conn
+	p.plugin = pluginv1.NewProviderClient(conn)
+	p.health = healthpb.NewHealthClient(conn)
+
+	callCtx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	handshake, err := p.plugin.Handshake(callCtx, &pluginv1.HandshakeRequest{Versions: []string{pluginv1.ProtocolVersion}}, grpc.WaitForReady(true))
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("plugin handshake: %w", err)
+	}
+	if handshake.Version != pluginv1.ProtocolVersion {
+		_ = conn.Close()
+		return fmt.Errorf("plugin %s speaks protocol %s, expected %s", handshake.Name, handshake.Version, pluginv1.ProtocolVersion)
+	}
+	p.name = handshake.Name
+
+	if err := p.Check(callCtx); err != nil {
+		_ = conn.Close()
+		return err
+	}
+
+	resp, err := p.plugin.Init(callCtx, &pluginv1.InitRequest{Config: config})
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("initializing plugin: %w", err)
+	}
+	if p.capabilities, err = registry.ParseCapabilities(resp.Capabilities); err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("plugin %s reported %w", p.name, err)
+	}
+
+	return nil
+}
+
+// Capabilities reports the capabilities the plugin reported on init
+func (p *GrpcPluginProvider) Capabilities() registry.Capabilities {
+	return p.capabilities
+}
+
+// Check reports an error when the plugin is not serving. Plugins that do not implement the
+// gRPC health service are considered healthy as long as they answer.
+func (p *GrpcPluginProvider) Check(ctx context.Context) error {
+	result, err := p.health.Check(ctx, &healthpb.HealthCheckRequest{Service: pluginv1.ServiceName})
+	if err != nil {
+		if status.Code(err) == codes.Unimplemented {
+			return nil
+		}
+
+		return fmt.Errorf("checking plugin health: %w", err)
+	}
+
+	if result.Status != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("plugin is %s", result.Status.String())
+	}
+
+	return nil
+}
+
+// CheckHealth checks that the plugin is serving, the plugin is identified by its handshake name
+func (p *GrpcPluginProvider) CheckHealth(ctx context.Context) (*registry.Health, error) {
+	if err := p.Check(ctx); err != nil {
+		return nil, err
+	}
+
+	return &registry.Health{Identity: p.name}, nil
+}
+
+// Close closes the connection to the plugin
+func (p *GrpcPluginProvider) Close() error {
+	if p.conn == nil {
+		return nil
+	}
+
+	return p.conn.Close()
+}
+
+func (p *GrpcPluginProvider) callContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, p.timeout)
+}
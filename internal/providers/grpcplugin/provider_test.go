@@ -0,0 +1,228 @@
+package grpcplugin
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/providertest"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/registry"
+	pluginv1 "github.com/tiagoposse/secretsbeam-operator/pkg/plugin/v1"
+)
+
+// newSecret returns a secret of the fixtures with a provider spec and provider status
+func newSecret(name string) *secretsv1alpha1.ExternalSecret {
+	secret := providertest.NewSecret(name)
+	secret.Spec.ProviderSpec["Tier"] = "gold"
+	secret.Status.Provider["Previous"] = "state"
+
+	return secret
+}
+
+var _ = Describe("GrpcPluginProvider", func() {
+	var (
+		ctx     context.Context
+		plugin  *testPlugin
+		address string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		plugin = newTestPlugin()
+
+		socket := filepath.Join(GinkgoT().TempDir(), "plugin.sock")
+		listener, err := net.Listen("unix", socket)
+		Expect(err).NotTo(HaveOccurred())
+		plugin.serve(listener)
+		address = "unix://" + socket
+	})
+
+	AfterEach(func() {
+		plugin.Stop()
+	})
+
+	Context("connection", func() {
+		It("connects over a unix socket and passes the config to the plugin", func() {
+			provider := &GrpcPluginProvider{}
+			config := map[string]string{"address": address, "region": "eu-west-1"}
+			Expect(provider.Init(config)).To(Succeed())
+			defer provider.Close()
+
+			Expect(plugin.config).To(Equal(config))
+		})
+
+		It("requires TLS for TCP addresses unless insecure is set", func() {
+			Expect((&GrpcPluginProvider{}).Init(map[string]string{"address": "dns:///plugin.team-a.svc:9000"})).
+				To(MatchError(ContainSubstring("tlsSecretName is required")))
+			Expect((&GrpcPluginProvider{}).Init(map[string]string{})).To(MatchError(ContainSubstring("address is required")))
+		})
+
+		It("uses the capabilities reported by the plugin", func() {
+			silent := &GrpcPluginProvider{}
+			Expect(silent.Init(map[string]string{"address": address})).To(Succeed())
+			defer silent.Close()
+			Expect(registry.CapabilitiesOf(silent)).To(Equal(registry.Capabilities{}), "plugins support nothing unless they report it")
+
+			plugin.capabilities = []string{"AccessControl"}
+			provider := &GrpcPluginProvider{}
+			Expect(provider.Init(map[string]string{"address": address})).To(Succeed())
+			defer provider.Close()
+			Expect(registry.CapabilitiesOf(provider)).To(Equal(registry.Capabilities{AccessControl: true}))
+
+			plugin.capabilities = []string{"Rotation"}
+			Expect((&GrpcPluginProvider{}).Init(map[string]string{"address": address})).
+				To(MatchError(`plugin test reported unknown capability "Rotation"`))
+		})
+
+		It("rejects plugins speaking another protocol version", func() {
+			plugin.version = "v2"
+			Expect((&GrpcPluginProvider{}).Init(map[string]string{"address": address})).
+				To(MatchError(ContainSubstring("speaks protocol v2")))
+		})
+
+		It("rejects plugins that are not serving", func() {
+			plugin.healthServer.SetServingStatus(pluginv1.ServiceName, healthpb.HealthCheckResponse_NOT_SERVING)
+			Expect((&GrpcPluginProvider{}).Init(map[string]string{"address": address})).
+				To(MatchError(ContainSubstring("NOT_SERVING")))
+		})
+
+		It("times out when the plugin is unreachable", func() {
+			provider := &GrpcPluginProvider{}
+			Expect(provider.Init(map[string]string{
+				"address": "unix://" + filepath.Join(GinkgoT().TempDir(), "missing.sock"),
+				"timeout": "200ms",
+			})).To(MatchError(ContainSubstring("plugin handshake")))
+		})
+
+		It("authenticates with mutual TLS", func() {
+			ca := newTestCA()
+			certPEM, keyPEM := ca.issue("secretsbeam-operator", x509.ExtKeyUsageClientAuth)
+
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			tlsPlugin := newTestPlugin()
+			tlsPlugin.serve(listener, grpc.Creds(ca.serverCredentials("plugin.team-a.svc")))
+			defer tlsPlugin.Stop()
+
+			cli := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "plugin-tls", Namespace: "team-a"},
+				Data: map[string][]byte{
+					"ca.crt":  ca.pem,
+					"tls.crt": certPEM,
+					"tls.key": keyPEM,
+				},
+			}).Build()
+
+			provider := &GrpcPluginProvider{Client: cli, Namespace: "team-a"}
+			Expect(provider.Init(map[string]string{
+				"address":       listener.Addr().String(),
+				"tlsSecretName": "plugin-tls",
+				"serverName":    "plugin.team-a.svc",
+			})).To(Succeed())
+			defer provider.Close()
+
+			Expect(tlsPlugin.clientSubject).To(Equal("secretsbeam-operator"))
+		})
+	})
+
+	Context("operations", func() {
+		var provider *GrpcPluginProvider
+
+		BeforeEach(func() {
+			provider = &GrpcPluginProvider{}
+			Expect(provider.Init(map[string]string{"address": address})).To(Succeed())
+		})
+
+		AfterEach(func() {
+			provider.Close()
+		})
+
+		It("sends secrets and merges the returned provider state", func() {
+			plugin.provider = map[string]string{"Id": "secret-1", "Previous": ""}
+
+			secret := newSecret("db")
+			secret.Spec.Overwrite = true
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, secret, "hunter2")).To(Succeed())
+
+			req := plugin.lastSecretRequest()
+			Expect(req.GetValue()).To(Equal("hunter2"))
+			Expect(req.Secret.Namespace).To(Equal("team-a"))
+			Expect(req.Secret.ExternalName).To(Equal("db"))
+			Expect(req.Secret.Overwrite).To(BeTrue())
+			Expect(req.Secret.ProviderSpec).To(Equal(map[string]string{"Tier": "gold"}))
+			Expect(req.Secret.Provider).To(Equal(map[string]string{"Previous": "state"}))
+
+			Expect(secret.Status.Provider).To(Equal(map[string]string{"Id": "secret-1"}))
+		})
+
+		It("returns the dates reported by the plugin", func() {
+			plugin.lastChanged = time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+			changed, err := provider.GetSecretLastChangedDate(ctx, GinkgoLogr, newSecret("db"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changed.Equal(plugin.lastChanged)).To(BeTrue())
+			Expect(plugin.lastSecretRequest().Value).To(BeNil())
+
+			deletion := time.Now().Add(24 * time.Hour).UTC()
+			plugin.deletionDate = &deletion
+			secret := newSecret("db")
+			Expect(provider.DeleteSecret(ctx, GinkgoLogr, secret)).To(Succeed())
+			Expect(secret.Status.DeletionDate.Time.Equal(deletion)).To(BeTrue())
+		})
+
+		It("sends access subjects", func() {
+			plugin.provider = map[string]string{"Role": "reader"}
+
+			access := &secretsv1alpha1.ExternalSecretAccess{
+				ObjectMeta: metav1.ObjectMeta{Name: "reader", Namespace: "team-a"},
+				Spec: secretsv1alpha1.ExternalSecretAccessSpec{
+					AccessSubjects: []secretsv1alpha1.SecretAccessSubject{
+						{ServiceAccount: &secretsv1alpha1.SecretAccessSubjectServiceAccount{Namespace: "team-b", Name: "api"}},
+						{ProviderIdentifier: &secretsv1alpha1.SecretAccessSubjectProviderIdentifier{Identifier: "group:ops"}},
+					},
+				},
+			}
+			Expect(provider.CreateAccess(ctx, GinkgoLogr, newSecret("db"), access)).To(Succeed())
+
+			req := plugin.lastAccessRequest()
+			Expect(req.Secret.Name).To(Equal("db"))
+			Expect(req.Access.Subjects).To(HaveLen(2))
+			Expect(req.Access.Subjects[0].GetServiceAccount().Namespace).To(Equal("team-b"))
+			Expect(req.Access.Subjects[0].GetServiceAccount().Name).To(Equal("api"))
+			Expect(req.Access.Subjects[1].GetProviderIdentifier()).To(Equal("group:ops"))
+			Expect(access.Status.Provider).To(Equal(map[string]string{"Role": "reader"}))
+
+			Expect(provider.DeleteAccess(ctx, GinkgoLogr, access)).To(Succeed())
+			Expect(plugin.lastAccessRequest().Secret).To(BeNil())
+		})
+
+		It("surfaces plugin errors", func() {
+			plugin.failWith = errPluginFailure
+			Expect(provider.UpdateSecret(ctx, GinkgoLogr, newSecret("db"), "value")).To(MatchError(ContainSubstring("backend unavailable")))
+			Expect(provider.UpdateAccess(ctx, GinkgoLogr, newSecret("db"), &secretsv1alpha1.ExternalSecretAccess{})).To(MatchError(ContainSubstring("backend unavailable")))
+		})
+
+		It("reports the plugin health", func() {
+			Expect(provider.Check(ctx)).To(Succeed())
+			health, err := provider.CheckHealth(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(health.Identity).To(Equal("test"))
+
+			plugin.healthServer.SetServingStatus(pluginv1.ServiceName, healthpb.HealthCheckResponse_NOT_SERVING)
+			Expect(provider.Check(ctx)).To(MatchError(ContainSubstring("NOT_SERVING")))
+			_, err = provider.CheckHealth(ctx)
+			Expect(err).To(MatchError(ContainSubstring("NOT_SERVING")))
+		})
+	})
+})
@@ -0,0 +1,93 @@
+package grpcplugin
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/registry"
+	pluginv1 "github.com/tiagoposse/secretsbeam-operator/pkg/plugin/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func toSecret(secret *secretsv1alpha1.ExternalSecret) *pluginv1.Secret {
+	externalName := secret.Status.SecretName
+	if secret.Spec.ExternalName != nil {
+		externalName = *secret.Spec.ExternalName
+	}
+
+	return &pluginv1.Secret{
+		Namespace:      secret.Namespace,
+		Name:           secret.Name,
+		ExternalName:   externalName,
+		RecoveryWindow: secret.Spec.RecoveryWindow,
+		Overwrite:      secret.Spec.Overwrite,
+		ProviderSpec:   secret.Spec.ProviderSpec,
+		Provider:       secret.Status.Provider,
+	}
+}
+
+func (p *GrpcPluginProvider) DeleteSecret(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret) error {
+	ctx, cancel := p.callContext(ctx)
+	defer cancel()
+
+	result, err := p.plugin.DeleteSecret(ctx, &pluginv1.SecretRequest{Secret: toSecret(secret)})
+	if err != nil {
+		return fmt.Errorf("failed to delete secret: %w", err)
+	}
+
+	if result.DeletionDate != nil {
+		t := v1.NewTime(result.DeletionDate.AsTime())
+		secret.Status.DeletionDate = &t
+		reqLogger.Info(fmt.Sprintf("Secret scheduled for deletion in %s", t.Time.String()))
+	}
+
+	return nil
+}
+
+func (p *GrpcPluginProvider) CreateSecret(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, value string) error {
+	ctx, cancel := p.callContext(ctx)
+	defer cancel()
+
+	result, err := p.plugin.CreateSecret(ctx, &pluginv1.SecretRequest{Secret: toSecret(secret), Value: &value})
+	if err != nil {
+		reqLogger.Error(err, "Failed to create Secret")
+		return err
+	}
+
+	registry.MergeProviderStatus(&secret.Status.Provider, result.Provider)
+
+	return nil
+}
+
+func (p *GrpcPluginProvider) GetSecretLastChangedDate(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret) (*time.Time, error) {
+	ctx, cancel := p.callContext(ctx)
+	defer cancel()
+
+	result, err := p.plugin.GetSecretLastChangedDate(ctx, &pluginv1.SecretRequest{Secret: toSecret(secret)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe secret: %w", err)
+	}
+	if result.LastChangedDate == nil {
+		return nil, nil
+	}
+
+	t := result.LastChangedDate.AsTime()
+	return &t, nil
+}
+
+func (p *GrpcPluginProvider) UpdateSecret(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, value string) error {
+	ctx, cancel := p.callContext(ctx)
+	defer cancel()
+
+	result, err := p.plugin.UpdateSecret(ctx, &pluginv1.SecretRequest{Secret: toSecret(secret), Value: &value})
+	if err != nil {
+		return fmt.Errorf("failed to update secret: %w", err)
+	}
+
+	registry.MergeProviderStatus(&secret.Status.Provider, result.Provider)
+
+	return nil
+}
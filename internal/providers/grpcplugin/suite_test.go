@@ -0,0 +1,14 @@
+package grpcplugin
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestGrpcPluginProvider(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "gRPC Plugin Provider Suite")
+}
@@ -0,0 +1,47 @@
+// Package providertest holds the fixtures shared by the tests of the providers.
+package providertest
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+)
+
+// Namespace is the namespace of the fixtures
+const Namespace = "team-a"
+
+// NewSecret returns an ExternalSecret of Namespace stored under its name, with an empty provider spec
+// and status
+func NewSecret(name string) *secretsv1alpha1.ExternalSecret {
+	return &secretsv1alpha1.ExternalSecret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: Namespace},
+		Spec: secretsv1alpha1.ExternalSecretSpec{
+			ExternalName: &name,
+			ProviderSpec: map[string]string{},
+		},
+		Status: secretsv1alpha1.ExternalSecretStatus{
+			Provider: map[string]string{},
+		},
+	}
+}
+
+// NewAccess returns an ExternalSecretAccess of Namespace granting the subjects, with an empty provider
+// status
+func NewAccess(name string, subjects ...secretsv1alpha1.SecretAccessSubject) *secretsv1alpha1.ExternalSecretAccess {
+	return &secretsv1alpha1.ExternalSecretAccess{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: Namespace},
+		Spec: secretsv1alpha1.ExternalSecretAccessSpec{
+			AccessSubjects: subjects,
+		},
+		Status: secretsv1alpha1.ExternalSecretAccessStatus{
+			Provider: map[string]string{},
+		},
+	}
+}
+
+// ServiceAccountSubject returns the subject of a service account
+func ServiceAccountSubject(namespace, name string) secretsv1alpha1.SecretAccessSubject {
+	return secretsv1alpha1.SecretAccessSubject{
+		ServiceAccount: &secretsv1alpha1.SecretAccessSubjectServiceAccount{Namespace: namespace, Name: name},
+	}
+}
@@ -0,0 +1,310 @@
+// Package registry holds the provider types known to the operator. Provider packages register a
+// factory and the schema of their config from an init function, and the controllers build
+// providers through New, so adding a backend only touches its own package and the list of
+// imports in package all.
+package registry
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+)
+
+// Provider is implemented by every secret backend
+type Provider interface {
+	Init(config map[string]string) error
+	DeleteSecret(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret) error
+	CreateSecret(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, value string) error
+	UpdateSecret(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, value string) error
+	CreateAccess(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, access *secretsv1alpha1.ExternalSecretAccess) error
+	UpdateAccess(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, access *secretsv1alpha1.ExternalSecretAccess) error
+	DeleteAccess(ctx context.Context, reqLogger logr.Logger, access *secretsv1alpha1.ExternalSecretAccess) error
+	GetSecretLastChangedDate(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret) (*time.Time, error)
+}
+
+// Health is what a provider reports about itself after a successful health check
+type Health struct {
+	// Identity is the principal the provider authenticates as, e.g. an IAM role ARN
+	Identity string
+	// Account is the account, project or subscription the provider works in
+	Account string
+	Region  string
+}
+
+// HealthChecker is implemented by providers able to validate their credentials and the
+// connectivity to their backend with a cheap, read-only call
+type HealthChecker interface {
+	CheckHealth(ctx context.Context) (*Health, error)
+}
+
+// Capabilities are the features of the Provider interface a backend supports. Requests for the
+// others are reported as unsupported by the controllers rather than half applied.
+type Capabilities struct {
+	// RecoveryWindow is keeping deleted secrets recoverable for ExternalSecret.Spec.RecoveryWindow days
+	RecoveryWindow bool
+	// AccessControl is granting access to a single secret, for ExternalSecretAccesses
+	AccessControl bool
+}
+
+// AllCapabilities are every feature of the Provider interface
+var AllCapabilities = Capabilities{RecoveryWindow: true, AccessControl: true}
+
+// Names of the capabilities, in the status of the providers and in the plugin protocols
+const (
+	CapabilityRecoveryWindow = "RecoveryWindow"
+	CapabilityAccessControl  = "AccessControl"
+)
+
+// Names returns the names of the supported capabilities, e.g. for the status of the provider
+func (c Capabilities) Names() []string {
+	names := make([]string, 0, 2)
+	for _, capability := range []struct {
+		name      string
+		supported bool
+	}{
+		{CapabilityRecoveryWindow, c.RecoveryWindow},
+		{CapabilityAccessControl, c.AccessControl},
+	} {
+		if capability.supported {
+			names = append(names, capability.name)
+		}
+	}
+
+	return names
+}
+
+// ParseCapabilities returns the capabilities of a list of names, e.g. reported by a plugin
+func ParseCapabilities(names []string) (Capabilities, error) {
+	capabilities := Capabilities{}
+	for _, name := range names {
+		switch name {
+		case CapabilityRecoveryWindow:
+			capabilities.RecoveryWindow = true
+		case CapabilityAccessControl:
+			capabilities.AccessControl = true
+		default:
+			return Capabilities{}, fmt.Errorf("unknown capability %q", name)
+		}
+	}
+
+	return capabilities, nil
+}
+
+// CapabilityReporter is implemented by providers to report the features of the Provider interface
+// they support. It is called once initialized, as the capabilities may depend on the config.
+type CapabilityReporter interface {
+	Capabilities() Capabilities
+}
+
+// CapabilitiesOf returns the capabilities of a provider, none when it does not report them, so that
+// the controllers never ask a backend for a feature it did not declare
+func CapabilitiesOf(provider Provider) Capabilities {
+	if reporter, ok := provider.(CapabilityReporter); ok {
+		return reporter.Capabilities()
+	}
+
+	return Capabilities{}
+}
+
+// Options are passed to factories, for providers reading objects of the cluster such as
+// secrets referenced by their config
+type Options struct {
+	Client client.Client
+	// Namespace is the namespace of the ExternalSecretProvider
+	Namespace string
+	// ClusterID identifies the cluster of the operator, e.g. in the tags of the resources created by
+	// providers, so that the resources of clusters sharing a backend are told apart
+	ClusterID string
+}
+
+// Factory returns a provider that is not yet initialized
+type Factory func(opts Options) Provider
+
+// ConfigKey is a key of the config of a provider
+type ConfigKey struct {
+	Name     string
+	Required bool
+}
+
+// ConfigSchema describes the config accepted by a provider type
+type ConfigSchema struct {
+	Keys []ConfigKey
+	// AdditionalKeys accepts keys missing from Keys, for plugins receiving the whole config
+	AdditionalKeys bool
+}
+
+// SchemaOf returns the schema of a config struct, with a key per field named after its json tag
+func SchemaOf(config interface{}, required ...string) ConfigSchema {
+	schema := ConfigSchema{}
+
+	t := reflect.TypeOf(config)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		} else if name == "" {
+			name = field.Name
+		}
+
+		schema.Keys = append(schema.Keys, ConfigKey{Name: name})
+	}
+
+	for _, name := range required {
+		found := false
+		for i := range schema.Keys {
+			if schema.Keys[i].Name == name {
+				schema.Keys[i].Required = true
+				found = true
+			}
+		}
+		if !found {
+			panic(fmt.Sprintf("registry: required key %s is not a field of %s", name, t.Name()))
+		}
+	}
+
+	return schema
+}
+
+// Validate checks that the required keys are set and, unless additional keys are accepted,
+// that every key is known. Keys are matched case insensitively, like the decoding of configs.
+func (s ConfigSchema) Validate(config map[string]string) error {
+	known := make(map[string]bool, len(s.Keys))
+	for _, key := range s.Keys {
+		known[strings.ToLower(key.Name)] = true
+
+		if !key.Required {
+			continue
+		}
+
+		found := false
+		for name, value := range config {
+			if strings.EqualFold(name, key.Name) && value != "" {
+				found = true
+			}
+		}
+		if !found {
+			return &ConfigError{Message: fmt.Sprintf("%s is required", key.Name)}
+		}
+	}
+
+	if s.AdditionalKeys {
+		return nil
+	}
+
+	unknown := make([]string, 0)
+	for name := range config {
+		if !known[strings.ToLower(name)] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return &ConfigError{Message: fmt.Sprintf("unknown keys %s", strings.Join(unknown, ", "))}
+	}
+
+	return nil
+}
+
+type registration struct {
+	factory Factory
+	schema  ConfigSchema
+}
+
+var (
+	mu            sync.RWMutex
+	registrations = make(map[string]registration)
+)
+
+// Register makes a provider type available. It panics when the type is registered twice, so it
+// is meant to be called from the init function of the provider package.
+func Register(providerType string, factory Factory, schema ConfigSchema) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if factory == nil {
+		panic("registry: nil factory for provider type " + providerType)
+	}
+	if _, ok := registrations[providerType]; ok {
+		panic("registry: provider type " + providerType + " registered twice")
+	}
+
+	registrations[providerType] = registration{factory: factory, schema: schema}
+}
+
+// Types returns the registered provider types, sorted
+func Types() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	types := make([]string, 0, len(registrations))
+	for providerType := range registrations {
+		types = append(types, providerType)
+	}
+	sort.Strings(types)
+
+	return types
+}
+
+// Schema returns the config schema of a provider type
+func Schema(providerType string) (ConfigSchema, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	r, ok := registrations[providerType]
+	return r.schema, ok
+}
+
+// New builds and initializes a provider of the given type, after validating the config against
+// the schema of the type
+func New(providerType string, opts Options, config map[string]string) (Provider, error) {
+	mu.RLock()
+	r, ok := registrations[providerType]
+	mu.RUnlock()
+
+	if !ok {
+		return nil, &UnknownTypeError{Type: providerType}
+	}
+
+	if err := r.schema.Validate(config); err != nil {
+		return nil, err
+	}
+
+	p := r.factory(opts)
+	if err := p.Init(config); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// UnknownTypeError is returned for provider types that are not registered
+type UnknownTypeError struct {
+	Type string
+}
+
+func (e *UnknownTypeError) Error() string {
+	return fmt.Sprintf("unknown provider type %q, supported types are %s", e.Type, strings.Join(Types(), ", "))
+}
+
+// ConfigError is returned for configs not matching the schema of their provider type
+type ConfigError struct {
+	Message string
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid config: " + e.Message
+}
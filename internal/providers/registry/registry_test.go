@@ -0,0 +1,153 @@
+package registry
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type testConfig struct {
+	Address string `json:"address"`
+	Timeout string `json:"timeout,omitempty"`
+	Ignored string `json:"-"`
+	Region  string
+	private string
+}
+
+// testProvider records the config it is initialized with and fails with initErr, the other methods
+// are not called by the registry
+type testProvider struct {
+	Provider
+	config  map[string]string
+	initErr error
+}
+
+func (p *testProvider) Init(config map[string]string) error {
+	p.config = config
+	return p.initErr
+}
+
+// limitedProvider reports the capabilities it is built with
+type limitedProvider struct {
+	testProvider
+	capabilities Capabilities
+}
+
+func (p *limitedProvider) Capabilities() Capabilities {
+	return p.capabilities
+}
+
+var _ = Describe("Registry", func() {
+	It("derives schemas from config structs", func() {
+		schema := SchemaOf(testConfig{}, "address")
+		Expect(schema.Keys).To(Equal([]ConfigKey{
+			{Name: "address", Required: true},
+			{Name: "timeout"},
+			{Name: "Region"},
+		}))
+
+		Expect(func() { SchemaOf(testConfig{}, "missing") }).To(Panic())
+	})
+
+	It("validates configs against schemas", func() {
+		schema := SchemaOf(testConfig{}, "address")
+		Expect(schema.Validate(map[string]string{"address": "localhost", "region": "eu-west-1"})).To(Succeed())
+		Expect(schema.Validate(map[string]string{"Address": "localhost"})).To(Succeed())
+
+		var configErr *ConfigError
+		err := schema.Validate(map[string]string{"address": ""})
+		Expect(errors.As(err, &configErr)).To(BeTrue())
+		Expect(err).To(MatchError("invalid config: address is required"))
+
+		Expect(schema.Validate(map[string]string{"address": "localhost", "tiemout": "1s", "extra": "1"})).
+			To(MatchError("invalid config: unknown keys extra, tiemout"))
+
+		schema.AdditionalKeys = true
+		Expect(schema.Validate(map[string]string{"address": "localhost", "extra": "1"})).To(Succeed())
+	})
+
+	Context("provider types", func() {
+		var built []Options
+
+		BeforeEach(func() {
+			built = nil
+		})
+
+		register := func(providerType string) {
+			Register(providerType, func(opts Options) Provider {
+				built = append(built, opts)
+				return &testProvider{}
+			}, SchemaOf(testConfig{}, "address"))
+		}
+
+		It("builds and initializes registered types", func() {
+			register("registry-test")
+			Expect(Types()).To(ContainElement("registry-test"))
+			schema, ok := Schema("registry-test")
+			Expect(ok).To(BeTrue())
+			Expect(schema.Keys).To(HaveLen(3))
+
+			p, err := New("registry-test", Options{Namespace: "operator"}, map[string]string{"address": "localhost"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(built).To(Equal([]Options{{Namespace: "operator"}}))
+			Expect(p.(*testProvider).config).To(HaveKeyWithValue("address", "localhost"))
+
+			Expect(func() { register("registry-test") }).To(Panic())
+		})
+
+		It("rejects unknown types", func() {
+			_, err := New("missing", Options{}, nil)
+
+			var unknownType *UnknownTypeError
+			Expect(errors.As(err, &unknownType)).To(BeTrue())
+			Expect(unknownType.Type).To(Equal("missing"))
+			Expect(err).To(MatchError(ContainSubstring(`unknown provider type "missing"`)))
+		})
+
+		It("does not build providers with an invalid config", func() {
+			register("registry-invalid")
+
+			_, err := New("registry-invalid", Options{}, map[string]string{})
+			Expect(err).To(MatchError(ContainSubstring("address is required")))
+			Expect(built).To(BeEmpty())
+		})
+
+		It("reports init failures", func() {
+			Register("registry-failing", func(Options) Provider {
+				return &testProvider{initErr: errors.New("unreachable")}
+			}, ConfigSchema{AdditionalKeys: true})
+
+			_, err := New("registry-failing", Options{}, map[string]string{"any": "key"})
+			Expect(err).To(MatchError("unreachable"))
+		})
+	})
+
+	It("merges the provider entries into a status", func() {
+		var status map[string]string
+		MergeProviderStatus(&status, map[string]string{"Id": "42", "Stale": ""})
+		Expect(status).To(Equal(map[string]string{"Id": "42"}))
+
+		MergeProviderStatus(&status, map[string]string{"Id": "", "Version": "2"})
+		Expect(status).To(Equal(map[string]string{"Version": "2"}))
+	})
+
+	It("reports the capabilities of providers", func() {
+		Expect(CapabilitiesOf(&testProvider{})).To(Equal(Capabilities{}), "providers not reporting them support nothing")
+		Expect(AllCapabilities.Names()).To(Equal([]string{"RecoveryWindow", "AccessControl"}))
+
+		limited := &limitedProvider{capabilities: Capabilities{AccessControl: true}}
+		Expect(CapabilitiesOf(limited)).To(Equal(Capabilities{AccessControl: true}))
+		Expect(CapabilitiesOf(limited).Names()).To(Equal([]string{"AccessControl"}))
+		Expect(Capabilities{}.Names()).To(BeEmpty())
+	})
+
+	It("parses the names of capabilities", func() {
+		Expect(ParseCapabilities(nil)).To(Equal(Capabilities{}))
+		Expect(ParseCapabilities(AllCapabilities.Names())).To(Equal(AllCapabilities))
+		Expect(ParseCapabilities([]string{"AccessControl"})).To(Equal(Capabilities{AccessControl: true}))
+
+		_, err := ParseCapabilities([]string{"AccessControl", "Rotation"})
+		Expect(err).To(MatchError(`unknown capability "Rotation"`))
+	})
+})
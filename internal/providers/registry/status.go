@@ -0,0 +1,17 @@
+package registry
+
+// MergeProviderStatus applies the provider entries returned by a remote provider to a status map,
+// creating it when needed. Empty values remove their entry.
+func MergeProviderStatus(status *map[string]string, provider map[string]string) {
+	if *status == nil {
+		*status = make(map[string]string)
+	}
+
+	for key, val := range provider {
+		if val == "" {
+			delete(*status, key)
+		} else {
+			(*status)[key] = val
+		}
+	}
+}
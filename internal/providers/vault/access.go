@@ -0,0 +1,189 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+)
+
+type groupResponse struct {
+	Data struct {
+		Policies []string `json:"policies"`
+	} `json:"data"`
+}
+
+func policyName(access *secretsv1alpha1.ExternalSecretAccess) string {
+	return fmt.Sprintf("secretsbeam-%s-%s", access.Namespace, access.Name)
+}
+
+// roleName returns the kubernetes auth role for the subjects of an access in a namespace.
+// Roles bind every listed service account in every listed namespace, so one is created per
+// subject namespace to avoid granting service accounts with the same name in other namespaces.
+func roleName(access *secretsv1alpha1.ExternalSecretAccess, namespace string) string {
+	if namespace == access.Namespace {
+		return policyName(access)
+	}
+
+	return fmt.Sprintf("%s-%s", policyName(access), namespace)
+}
+
+func (p *VaultProvider) policyDocument(path string) string {
+	return fmt.Sprintf(`path "%[1]s/data/%[2]s" {
+  capabilities = ["read"]
+}
+
+path "%[1]s/metadata/%[2]s" {
+  capabilities = ["read"]
+}
+`, p.MountPath, path)
+}
+
+func splitStatus(access *secretsv1alpha1.ExternalSecretAccess, key string) []string {
+	val, ok := access.Status.Provider[key]
+	if !ok || val == "" {
+		return nil
+	}
+
+	return strings.Split(val, ",")
+}
+
+func (p *VaultProvider) CreateAccess(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, access *secretsv1alpha1.ExternalSecretAccess) error {
+	return p.applyAccess(ctx, reqLogger, secret, access)
+}
+
+func (p *VaultProvider) UpdateAccess(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, access *secretsv1alpha1.ExternalSecretAccess) error {
+	return p.applyAccess(ctx, reqLogger, secret, access)
+}
+
+func (p *VaultProvider) DeleteAccess(ctx context.Context, reqLogger logr.Logger, access *secretsv1alpha1.ExternalSecretAccess) error {
+	policy := policyName(access)
+
+	for _, role := range splitStatus(access, "Roles") {
+		if err := p.client.do(ctx, http.MethodDelete, fmt.Sprintf("auth/%s/role/%s", p.AuthMountPath, role), nil, nil); err != nil && !isNotFound(err) {
+			return fmt.Errorf("failed to delete role %s: %w", role, err)
+		}
+		reqLogger.Info(fmt.Sprintf("Deleted role %s", role))
+	}
+
+	for _, group := range splitStatus(access, "Groups") {
+		if err := p.setGroupPolicy(ctx, group, policy, false); err != nil && !isNotFound(err) {
+			return fmt.Errorf("failed to detach policy from group %s: %w", group, err)
+		}
+	}
+
+	if err := p.client.do(ctx, http.MethodDelete, fmt.Sprintf("sys/policies/acl/%s", policy), nil, nil); err != nil && !isNotFound(err) {
+		return fmt.Errorf("failed to delete policy %s: %w", policy, err)
+	}
+	reqLogger.Info(fmt.Sprintf("Deleted policy %s", policy))
+
+	return nil
+}
+
+// applyAccess writes the read policy of the secret and binds it to a kubernetes auth role per
+// subject namespace and to the identity groups given as provider identifiers
+func (p *VaultProvider) applyAccess(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, access *secretsv1alpha1.ExternalSecretAccess) error {
+	policy := policyName(access)
+
+	if err := p.client.do(ctx, http.MethodPut, fmt.Sprintf("sys/policies/acl/%s", policy), map[string]string{
+		"policy": p.policyDocument(secretPath(secret)),
+	}, nil); err != nil {
+		return fmt.Errorf("failed to write policy: %w", err)
+	}
+	reqLogger.Info(fmt.Sprintf("Wrote policy %s", policy))
+	access.Status.Provider["Policy"] = policy
+
+	serviceAccounts := make(map[string][]string)
+	groups := make([]string, 0)
+	for _, subject := range access.Spec.AccessSubjects {
+		if subject.ServiceAccount != nil {
+			serviceAccounts[subject.ServiceAccount.Namespace] = append(serviceAccounts[subject.ServiceAccount.Namespace], subject.ServiceAccount.Name)
+		} else if subject.ProviderIdentifier != nil {
+			groups = append(groups, subject.ProviderIdentifier.Identifier)
+		}
+	}
+
+	namespaces := make([]string, 0, len(serviceAccounts))
+	for namespace := range serviceAccounts {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	roles := make([]string, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		role := roleName(access, namespace)
+		if err := p.client.do(ctx, http.MethodPost, fmt.Sprintf("auth/%s/role/%s", p.AuthMountPath, role), map[string]interface{}{
+			"bound_service_account_names":      serviceAccounts[namespace],
+			"bound_service_account_namespaces": []string{namespace},
+			"token_policies":                   []string{policy},
+			"token_ttl":                        p.RoleTokenTTL,
+		}, nil); err != nil {
+			return fmt.Errorf("failed to write role %s: %w", role, err)
+		}
+		reqLogger.Info(fmt.Sprintf("Wrote role %s", role))
+		roles = append(roles, role)
+	}
+
+	for _, role := range splitStatus(access, "Roles") {
+		if !contains(roles, role) {
+			if err := p.client.do(ctx, http.MethodDelete, fmt.Sprintf("auth/%s/role/%s", p.AuthMountPath, role), nil, nil); err != nil && !isNotFound(err) {
+				return fmt.Errorf("failed to delete role %s: %w", role, err)
+			}
+			reqLogger.Info(fmt.Sprintf("Deleted role %s", role))
+		}
+	}
+	access.Status.Provider["Roles"] = strings.Join(roles, ",")
+
+	for _, group := range groups {
+		if err := p.setGroupPolicy(ctx, group, policy, true); err != nil {
+			return fmt.Errorf("failed to attach policy to group %s: %w", group, err)
+		}
+	}
+
+	for _, group := range splitStatus(access, "Groups") {
+		if !contains(groups, group) {
+			if err := p.setGroupPolicy(ctx, group, policy, false); err != nil && !isNotFound(err) {
+				return fmt.Errorf("failed to detach policy from group %s: %w", group, err)
+			}
+		}
+	}
+	access.Status.Provider["Groups"] = strings.Join(groups, ",")
+
+	return nil
+}
+
+// setGroupPolicy adds or removes a policy from an existing identity group
+func (p *VaultProvider) setGroupPolicy(ctx context.Context, group, policy string, attach bool) error {
+	result := &groupResponse{}
+	if err := p.client.do(ctx, http.MethodGet, fmt.Sprintf("identity/group/name/%s", group), nil, result); err != nil {
+		return err
+	}
+
+	policies := make([]string, 0, len(result.Data.Policies)+1)
+	for _, existing := range result.Data.Policies {
+		if existing != policy {
+			policies = append(policies, existing)
+		}
+	}
+	if attach {
+		policies = append(policies, policy)
+	}
+
+	return p.client.do(ctx, http.MethodPost, fmt.Sprintf("identity/group/name/%s", group), map[string][]string{
+		"policies": policies,
+	}, nil)
+}
+
+func contains(list []string, val string) bool {
+	for _, v := range list {
+		if v == val {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,149 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// apiError is returned for non successful Vault responses
+type apiError struct {
+	Code   int
+	Errors []string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("vault returned %d: %s", e.Code, strings.Join(e.Errors, ", "))
+}
+
+func isNotFound(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound
+}
+
+// isCheckAndSetFailure reports whether a KV v2 write was rejected because of its cas option
+func isCheckAndSetFailure(err error) bool {
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusBadRequest {
+		return false
+	}
+
+	for _, e := range apiErr.Errors {
+		if strings.Contains(e, "check-and-set") {
+			return true
+		}
+	}
+
+	return false
+}
+
+type authResponse struct {
+	Auth struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int    `json:"lease_duration"`
+	} `json:"auth"`
+}
+
+// vaultClient is a minimal client of the Vault HTTP API that logs in with the
+// kubernetes auth method when it is not given a static token
+type vaultClient struct {
+	httpClient *http.Client
+	address    string
+	namespace  string
+
+	// login returns a new token and its ttl, it is nil when a static token is used
+	login func(ctx context.Context) (string, time.Duration, error)
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+func (c *vaultClient) getToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.login == nil || (c.token != "" && time.Now().Before(c.tokenExpiry)) {
+		return c.token, nil
+	}
+
+	token, ttl, err := c.login(ctx)
+	if err != nil {
+		return "", fmt.Errorf("logging in to vault: %w", err)
+	}
+
+	c.token = token
+	// renew a bit before the token actually expires
+	c.tokenExpiry = time.Now().Add(ttl * 9 / 10)
+
+	return c.token, nil
+}
+
+func (c *vaultClient) do(ctx context.Context, method, path string, in, out interface{}) error {
+	token, err := c.getToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	return c.doWithToken(ctx, token, method, path, in, out)
+}
+
+func (c *vaultClient) doWithToken(ctx context.Context, token, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("marshalling request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s/v1/%s", strings.TrimSuffix(c.address, "/"), strings.TrimPrefix(path, "/")), body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if c.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.namespace)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading response: %w", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		errBody := struct {
+			Errors []string `json:"errors"`
+		}{}
+		_ = json.Unmarshal(data, &errBody)
+
+		return &apiError{Code: resp.StatusCode, Errors: errBody.Errors}
+	}
+
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("unmarshalling response: %w", err)
+		}
+	}
+
+	return nil
+}
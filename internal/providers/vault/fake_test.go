@@ -0,0 +1,196 @@
+package vault
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type fakeVersion struct {
+	data    map[string]string
+	deleted bool
+}
+
+type fakeKV struct {
+	versions []*fakeVersion
+	updated  time.Time
+}
+
+// fakeVault is an in-memory stand-in for the parts of the Vault HTTP API used by the provider
+type fakeVault struct {
+	mu       sync.Mutex
+	jwt      string
+	token    string
+	mount    string
+	logins   int
+	kv       map[string]*fakeKV
+	policies map[string]string
+	roles    map[string]map[string]interface{}
+	groups   map[string][]string
+	server   *httptest.Server
+}
+
+func newFakeVault(mount, jwt string) *fakeVault {
+	f := &fakeVault{
+		jwt:      jwt,
+		token:    "s.fake-token",
+		mount:    mount,
+		kv:       make(map[string]*fakeKV),
+		policies: make(map[string]string),
+		roles:    make(map[string]map[string]interface{}),
+		groups:   make(map[string][]string),
+	}
+	f.server = httptest.NewServer(http.HandlerFunc(f.handle))
+
+	return f
+}
+
+func (f *fakeVault) Close() {
+	f.server.Close()
+}
+
+func vaultError(w http.ResponseWriter, code int, errs ...string) {
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string][]string{"errors": errs})
+}
+
+func vaultJSON(w http.ResponseWriter, body interface{}) {
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func (f *fakeVault) handle(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/v1/")
+	body := map[string]interface{}{}
+	if r.Body != nil {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+	}
+
+	if path == "auth/kubernetes/login" {
+		if body["jwt"] != f.jwt || body["role"] != "operator" {
+			vaultError(w, http.StatusForbidden, "permission denied")
+			return
+		}
+
+		f.logins++
+		vaultJSON(w, map[string]interface{}{
+			"auth": map[string]interface{}{"client_token": f.token, "lease_duration": 3600},
+		})
+		return
+	}
+
+	if r.Header.Get("X-Vault-Token") != f.token {
+		vaultError(w, http.StatusForbidden, "permission denied")
+		return
+	}
+
+	switch {
+	case path == "auth/token/lookup-self":
+		vaultJSON(w, map[string]interface{}{"data": map[string]interface{}{"display_name": "kubernetes-secretsbeam-operator"}})
+	case strings.HasPrefix(path, f.mount+"/data/"):
+		f.handleData(w, r, strings.TrimPrefix(path, f.mount+"/data/"), body)
+	case strings.HasPrefix(path, f.mount+"/metadata/"):
+		f.handleMetadata(w, r, strings.TrimPrefix(path, f.mount+"/metadata/"))
+	case strings.HasPrefix(path, f.mount+"/delete/"):
+		kv, ok := f.kv[strings.TrimPrefix(path, f.mount+"/delete/")]
+		if !ok {
+			vaultError(w, http.StatusNotFound)
+			return
+		}
+		for _, v := range body["versions"].([]interface{}) {
+			kv.versions[int(v.(float64))-1].deleted = true
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case strings.HasPrefix(path, "sys/policies/acl/"):
+		name := strings.TrimPrefix(path, "sys/policies/acl/")
+		if r.Method == http.MethodDelete {
+			delete(f.policies, name)
+		} else {
+			f.policies[name] = body["policy"].(string)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case strings.HasPrefix(path, "auth/kubernetes/role/"):
+		name := strings.TrimPrefix(path, "auth/kubernetes/role/")
+		if r.Method == http.MethodDelete {
+			delete(f.roles, name)
+		} else {
+			f.roles[name] = body
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case strings.HasPrefix(path, "identity/group/name/"):
+		name := strings.TrimPrefix(path, "identity/group/name/")
+		policies, ok := f.groups[name]
+		if !ok {
+			vaultError(w, http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodGet {
+			vaultJSON(w, map[string]interface{}{"data": map[string]interface{}{"name": name, "policies": policies}})
+			return
+		}
+		f.groups[name] = make([]string, 0)
+		for _, p := range body["policies"].([]interface{}) {
+			f.groups[name] = append(f.groups[name], p.(string))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		vaultError(w, http.StatusNotFound, "unsupported path "+path)
+	}
+}
+
+func (f *fakeVault) handleData(w http.ResponseWriter, r *http.Request, path string, body map[string]interface{}) {
+	kv, exists := f.kv[path]
+	if options, ok := body["options"].(map[string]interface{}); ok {
+		if cas, ok := options["cas"].(float64); ok && exists && int(cas) != len(kv.versions) {
+			vaultError(w, http.StatusBadRequest, "check-and-set parameter did not match the current version")
+			return
+		}
+	}
+
+	if !exists {
+		kv = &fakeKV{}
+		f.kv[path] = kv
+	}
+
+	data := map[string]string{}
+	for k, v := range body["data"].(map[string]interface{}) {
+		data[k] = v.(string)
+	}
+	kv.versions = append(kv.versions, &fakeVersion{data: data})
+	kv.updated = time.Now().UTC()
+
+	vaultJSON(w, map[string]interface{}{"data": map[string]interface{}{
+		"version":      len(kv.versions),
+		"created_time": kv.updated.Format(time.RFC3339Nano),
+	}})
+}
+
+func (f *fakeVault) handleMetadata(w http.ResponseWriter, r *http.Request, path string) {
+	kv, ok := f.kv[path]
+	if !ok {
+		vaultError(w, http.StatusNotFound)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		delete(f.kv, path)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	versions := map[string]interface{}{}
+	for i, v := range kv.versions {
+		versions[strconv.Itoa(i+1)] = map[string]interface{}{"destroyed": false, "deleted": v.deleted}
+	}
+	vaultJSON(w, map[string]interface{}{"data": map[string]interface{}{
+		"current_version": len(kv.versions),
+		"updated_time":    kv.updated.Format(time.RFC3339Nano),
+		"versions":        versions,
+	}})
+}
@@ -0,0 +1,164 @@
+package vault
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/registry"
+)
+
+const (
+	defaultMountPath     = "secret"
+	defaultAuthMountPath = "kubernetes"
+	defaultTokenPath     = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	defaultRoleTokenTTL  = "1h"
+)
+
+// VaultProvider stores secrets in a Vault KV v2 engine and grants access to them
+// through Vault policies bound to kubernetes auth roles
+type VaultProvider struct {
+	VaultProviderConfig
+
+	client *vaultClient
+}
+
+type VaultProviderConfig struct {
+	Address string `json:"address"`
+	// Namespace is the Vault enterprise namespace
+	Namespace string `json:"namespace"`
+	// MountPath is the path of the KV v2 engine, defaults to secret
+	MountPath string `json:"mountPath"`
+	// AuthMountPath is the path of the kubernetes auth method used both by the operator
+	// to log in and for the roles created for accesses, defaults to kubernetes
+	AuthMountPath string `json:"authMountPath"`
+	// Role is the kubernetes auth role the operator logs in with. When empty VAULT_TOKEN is used.
+	Role string `json:"role"`
+	// TokenPath is the service account token used to log in
+	TokenPath string `json:"tokenPath"`
+	// RoleTokenTTL is the token ttl of the roles created for accesses, defaults to 1h
+	RoleTokenTTL       string `json:"roleTokenTTL"`
+	CACertFile         string `json:"caCertFile"`
+	InsecureSkipVerify string `json:"insecureSkipVerify"`
+}
+
+func init() {
+	registry.Register(secretsv1alpha1.SecretProviderVault, func(_ registry.Options) registry.Provider {
+		return &VaultProvider{}
+	}, registry.SchemaOf(VaultProviderConfig{}, "address"))
+}
+
+func (p *VaultProvider) Init(config map[string]string) error {
+	providerConfig := &VaultProviderConfig{}
+	if err := mapstructure.Decode(config, providerConfig); err != nil {
+		return fmt.Errorf("decoding provider config: %w", err)
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if providerConfig.InsecureSkipVerify != "" {
+		val, err := strconv.ParseBool(providerConfig.InsecureSkipVerify)
+		if err != nil {
+			return fmt.Errorf("parsing insecureSkipVerify: %w", err)
+		}
+		tlsConfig.InsecureSkipVerify = val
+	}
+	if providerConfig.CACertFile != "" {
+		ca, err := os.ReadFile(providerConfig.CACertFile)
+		if err != nil {
+			return fmt.Errorf("reading CA certificate: %w", err)
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return fmt.Errorf("no certificates found in %s", providerConfig.CACertFile)
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return p.init(*providerConfig, &http.Client{Transport: transport, Timeout: 30 * time.Second})
+}
+
+func (p *VaultProvider) init(config VaultProviderConfig, httpClient *http.Client) error {
+	if config.Address == "" {
+		return fmt.Errorf("address is required")
+	}
+	if config.MountPath == "" {
+		config.MountPath = defaultMountPath
+	}
+	if config.AuthMountPath == "" {
+		config.AuthMountPath = defaultAuthMountPath
+	}
+	if config.TokenPath == "" {
+		config.TokenPath = defaultTokenPath
+	}
+	if config.RoleTokenTTL == "" {
+		config.RoleTokenTTL = defaultRoleTokenTTL
+	}
+	config.MountPath = strings.Trim(config.MountPath, "/")
+	config.AuthMountPath = strings.Trim(config.AuthMountPath, "/")
+
+	p.VaultProviderConfig = config
+	p.client = &vaultClient{
+		httpClient: httpClient,
+		address:    config.Address,
+		namespace:  config.Namespace,
+	}
+
+	if config.Role != "" {
+		p.client.login = p.kubernetesLogin
+	} else if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		p.client.token = token
+	} else {
+		return fmt.Errorf("either role or the VAULT_TOKEN environment variable must be set")
+	}
+
+	return nil
+}
+
+func (p *VaultProvider) kubernetesLogin(ctx context.Context) (string, time.Duration, error) {
+	jwt, err := os.ReadFile(p.TokenPath)
+	if err != nil {
+		return "", 0, fmt.Errorf("reading service account token: %w", err)
+	}
+
+	result := &authResponse{}
+	if err := p.client.doWithToken(ctx, "", http.MethodPost, fmt.Sprintf("auth/%s/login", p.AuthMountPath), map[string]string{
+		"jwt":  strings.TrimSpace(string(jwt)),
+		"role": p.Role,
+	}, result); err != nil {
+		return "", 0, err
+	}
+
+	return result.Auth.ClientToken, time.Duration(result.Auth.LeaseDuration) * time.Second, nil
+}
+
+// CheckHealth looks up the token of the operator, logging in first when needed, which validates
+// the credentials and the connectivity to Vault
+func (p *VaultProvider) CheckHealth(ctx context.Context) (*registry.Health, error) {
+	result := struct {
+		Data struct {
+			DisplayName string `json:"display_name"`
+		} `json:"data"`
+	}{}
+	if err := p.client.do(ctx, http.MethodGet, "auth/token/lookup-self", nil, &result); err != nil {
+		return nil, fmt.Errorf("looking up token: %w", err)
+	}
+
+	return &registry.Health{Identity: result.Data.DisplayName, Account: p.Namespace}, nil
+}
+
+// Capabilities reports the features of the KV v2 engine, which has no native rotation
+func (p *VaultProvider) Capabilities() registry.Capabilities {
+	return registry.Capabilities{RecoveryWindow: true, AccessControl: true}
+}
@@ -0,0 +1,243 @@
+package vault
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	"github.com/tiagoposse/secretsbeam-operator/internal/providers/providertest"
+)
+
+func groupSubject(name string) secretsv1alpha1.SecretAccessSubject {
+	return secretsv1alpha1.SecretAccessSubject{
+		ProviderIdentifier: &secretsv1alpha1.SecretAccessSubjectProviderIdentifier{Identifier: name},
+	}
+}
+
+var _ = Describe("VaultProvider", func() {
+	var (
+		ctx       context.Context
+		fake      *fakeVault
+		provider  *VaultProvider
+		tokenPath string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		fake = newFakeVault("kv", "sa-jwt")
+
+		tokenPath = filepath.Join(GinkgoT().TempDir(), "token")
+		Expect(os.WriteFile(tokenPath, []byte("sa-jwt\n"), 0o600)).To(Succeed())
+
+		provider = &VaultProvider{}
+		Expect(provider.init(VaultProviderConfig{
+			Address:   fake.server.URL,
+			MountPath: "/kv/",
+			Role:      "operator",
+			TokenPath: tokenPath,
+		}, fake.server.Client())).To(Succeed())
+	})
+
+	AfterEach(func() {
+		fake.Close()
+	})
+
+	Context("configuration", func() {
+		It("requires an address and credentials", func() {
+			Expect((&VaultProvider{}).init(VaultProviderConfig{}, fake.server.Client())).NotTo(Succeed())
+
+			GinkgoT().Setenv("VAULT_TOKEN", "")
+			Expect((&VaultProvider{}).init(VaultProviderConfig{Address: fake.server.URL}, fake.server.Client())).NotTo(Succeed())
+		})
+
+		It("uses VAULT_TOKEN when no role is configured", func() {
+			GinkgoT().Setenv("VAULT_TOKEN", fake.token)
+
+			p := &VaultProvider{}
+			Expect(p.init(VaultProviderConfig{Address: fake.server.URL, MountPath: "kv"}, fake.server.Client())).To(Succeed())
+			Expect(p.CreateSecret(ctx, GinkgoLogr, providertest.NewSecret("db"), "value")).To(Succeed())
+			Expect(fake.logins).To(BeZero())
+		})
+
+		It("logs in with the kubernetes auth method once and reuses the token", func() {
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, providertest.NewSecret("one"), "value")).To(Succeed())
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, providertest.NewSecret("two"), "value")).To(Succeed())
+			Expect(fake.logins).To(Equal(1))
+		})
+
+		It("fails when the login is rejected", func() {
+			Expect(os.WriteFile(tokenPath, []byte("other-jwt"), 0o600)).To(Succeed())
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, providertest.NewSecret("db"), "value")).To(MatchError(ContainSubstring("logging in to vault")))
+		})
+	})
+
+	Context("health", func() {
+		It("logs in and looks up its token", func() {
+			health, err := provider.CheckHealth(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(health.Identity).To(Equal("kubernetes-secretsbeam-operator"))
+			Expect(fake.logins).To(Equal(1))
+		})
+
+		It("reports rejected logins", func() {
+			Expect(os.WriteFile(tokenPath, []byte("other-jwt"), 0o600)).To(Succeed())
+			_, err := provider.CheckHealth(ctx)
+			Expect(err).To(MatchError(ContainSubstring("permission denied")))
+		})
+	})
+
+	Context("secrets", func() {
+		It("writes new secrets under the KV v2 mount", func() {
+			secret := providertest.NewSecret("/team-a/db")
+			secret.Spec.ProviderSpec["Key"] = "password"
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, secret, "hunter2")).To(Succeed())
+
+			Expect(secret.Status.Provider).To(Equal(map[string]string{"Mount": "kv", "Path": "team-a/db", "Version": "1"}))
+			Expect(fake.kv["team-a/db"].versions[0].data).To(Equal(map[string]string{"password": "hunter2"}))
+		})
+
+		It("only overwrites existing secrets when asked to", func() {
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, providertest.NewSecret("db"), "first")).To(Succeed())
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, providertest.NewSecret("db"), "second")).To(MatchError(ContainSubstring("already exists")))
+
+			secret := providertest.NewSecret("db")
+			secret.Spec.Overwrite = true
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, secret, "second")).To(Succeed())
+			Expect(secret.Status.Provider["Version"]).To(Equal("2"))
+		})
+
+		It("writes a new version on update and reports when it changed", func() {
+			secret := providertest.NewSecret("db")
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, secret, "first")).To(Succeed())
+			Expect(provider.UpdateSecret(ctx, GinkgoLogr, secret, "second")).To(Succeed())
+			Expect(secret.Status.Provider["Version"]).To(Equal("2"))
+			Expect(fake.kv["db"].versions[1].data).To(Equal(map[string]string{"value": "second"}))
+
+			changed, err := provider.GetSecretLastChangedDate(ctx, GinkgoLogr, secret)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changed.Equal(fake.kv["db"].updated)).To(BeTrue())
+		})
+
+		It("destroys secrets without a recovery window", func() {
+			secret := providertest.NewSecret("db")
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, secret, "value")).To(Succeed())
+			Expect(provider.DeleteSecret(ctx, GinkgoLogr, secret)).To(Succeed())
+			Expect(fake.kv).NotTo(HaveKey("db"))
+
+			By("ignoring secrets that are already gone")
+			Expect(provider.DeleteSecret(ctx, GinkgoLogr, secret)).To(Succeed())
+		})
+
+		It("soft deletes secrets and destroys them after the recovery window", func() {
+			secret := providertest.NewSecret("db")
+			secret.Spec.RecoveryWindow = 7
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, secret, "first")).To(Succeed())
+			Expect(provider.UpdateSecret(ctx, GinkgoLogr, secret, "second")).To(Succeed())
+
+			Expect(provider.DeleteSecret(ctx, GinkgoLogr, secret)).To(MatchError(ContainSubstring("scheduled for deletion")))
+			Expect(secret.Status.DeletionDate).NotTo(BeNil())
+			Expect(secret.Status.DeletionDate.Time).To(BeTemporally("~", time.Now().Add(7*24*time.Hour), time.Minute))
+			Expect(fake.kv["db"].versions[0].deleted).To(BeTrue())
+			Expect(fake.kv["db"].versions[1].deleted).To(BeTrue())
+
+			By("keeping the secret while the window is open")
+			Expect(provider.DeleteSecret(ctx, GinkgoLogr, secret)).NotTo(Succeed())
+			Expect(fake.kv).To(HaveKey("db"))
+
+			By("destroying it once the window is over")
+			past := metav1.NewTime(time.Now().Add(-time.Minute))
+			secret.Status.DeletionDate = &past
+			Expect(provider.DeleteSecret(ctx, GinkgoLogr, secret)).To(Succeed())
+			Expect(fake.kv).NotTo(HaveKey("db"))
+		})
+	})
+
+	Context("access", func() {
+		var (
+			secret *secretsv1alpha1.ExternalSecret
+			access *secretsv1alpha1.ExternalSecretAccess
+		)
+
+		BeforeEach(func() {
+			fake.groups["ops"] = []string{"default"}
+			fake.groups["auditors"] = []string{}
+
+			secret = providertest.NewSecret("db")
+			Expect(provider.CreateSecret(ctx, GinkgoLogr, secret, "value")).To(Succeed())
+
+			access = &secretsv1alpha1.ExternalSecretAccess{
+				ObjectMeta: metav1.ObjectMeta{Name: "reader", Namespace: "team-a"},
+				Spec: secretsv1alpha1.ExternalSecretAccessSpec{
+					SecretName: "db",
+					AccessSubjects: []secretsv1alpha1.SecretAccessSubject{
+						providertest.ServiceAccountSubject("team-a", "api"),
+						providertest.ServiceAccountSubject("team-a", "worker"),
+						providertest.ServiceAccountSubject("team-b", "api"),
+						groupSubject("ops"),
+					},
+				},
+				Status: secretsv1alpha1.ExternalSecretAccessStatus{
+					Provider: map[string]string{},
+				},
+			}
+			Expect(provider.CreateAccess(ctx, GinkgoLogr, secret, access)).To(Succeed())
+		})
+
+		It("creates a read policy bound to a role per subject namespace and to groups", func() {
+			Expect(fake.policies["secretsbeam-team-a-reader"]).To(ContainSubstring(`path "kv/data/db"`))
+			Expect(fake.policies["secretsbeam-team-a-reader"]).To(ContainSubstring(`capabilities = ["read"]`))
+
+			Expect(fake.roles).To(HaveLen(2))
+			Expect(fake.roles["secretsbeam-team-a-reader"]["bound_service_account_names"]).To(ConsistOf("api", "worker"))
+			Expect(fake.roles["secretsbeam-team-a-reader"]["bound_service_account_namespaces"]).To(ConsistOf("team-a"))
+			Expect(fake.roles["secretsbeam-team-a-reader"]["token_policies"]).To(ConsistOf("secretsbeam-team-a-reader"))
+			Expect(fake.roles["secretsbeam-team-a-reader-team-b"]["bound_service_account_names"]).To(ConsistOf("api"))
+			Expect(fake.roles["secretsbeam-team-a-reader-team-b"]["bound_service_account_namespaces"]).To(ConsistOf("team-b"))
+
+			Expect(fake.groups["ops"]).To(ConsistOf("default", "secretsbeam-team-a-reader"))
+
+			Expect(access.Status.Provider).To(Equal(map[string]string{
+				"Policy": "secretsbeam-team-a-reader",
+				"Roles":  "secretsbeam-team-a-reader,secretsbeam-team-a-reader-team-b",
+				"Groups": "ops",
+			}))
+		})
+
+		It("removes roles and groups that are no longer subjects on update", func() {
+			access.Spec.AccessSubjects = []secretsv1alpha1.SecretAccessSubject{
+				providertest.ServiceAccountSubject("team-a", "api"),
+				groupSubject("auditors"),
+			}
+			Expect(provider.UpdateAccess(ctx, GinkgoLogr, secret, access)).To(Succeed())
+
+			Expect(fake.roles).To(HaveKey("secretsbeam-team-a-reader"))
+			Expect(fake.roles).NotTo(HaveKey("secretsbeam-team-a-reader-team-b"))
+			Expect(fake.roles["secretsbeam-team-a-reader"]["bound_service_account_names"]).To(ConsistOf("api"))
+			Expect(fake.groups["ops"]).To(ConsistOf("default"))
+			Expect(fake.groups["auditors"]).To(ConsistOf("secretsbeam-team-a-reader"))
+		})
+
+		It("fails when a group does not exist", func() {
+			access.Spec.AccessSubjects = append(access.Spec.AccessSubjects, groupSubject("missing"))
+			Expect(provider.UpdateAccess(ctx, GinkgoLogr, secret, access)).To(MatchError(ContainSubstring("group missing")))
+		})
+
+		It("removes the policy, roles and group bindings on delete", func() {
+			Expect(provider.DeleteAccess(ctx, GinkgoLogr, access)).To(Succeed())
+
+			Expect(fake.policies).To(BeEmpty())
+			Expect(fake.roles).To(BeEmpty())
+			Expect(fake.groups["ops"]).To(ConsistOf("default"))
+
+			By("ignoring resources that are already gone")
+			delete(fake.groups, "ops")
+			Expect(provider.DeleteAccess(ctx, GinkgoLogr, access)).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,163 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const defaultSecretKey = "value"
+
+type kvWriteResponse struct {
+	Data struct {
+		Version     int    `json:"version"`
+		CreatedTime string `json:"created_time"`
+	} `json:"data"`
+}
+
+type kvMetadataResponse struct {
+	Data struct {
+		CurrentVersion int                    `json:"current_version"`
+		UpdatedTime    string                 `json:"updated_time"`
+		Versions       map[string]interface{} `json:"versions"`
+	} `json:"data"`
+}
+
+func secretPath(secret *secretsv1alpha1.ExternalSecret) string {
+	if val, ok := secret.Status.Provider["Path"]; ok && val != "" {
+		return val
+	}
+
+	name := secret.Status.SecretName
+	if secret.Spec.ExternalName != nil {
+		name = *secret.Spec.ExternalName
+	}
+
+	return strings.Trim(name, "/")
+}
+
+func secretKey(secret *secretsv1alpha1.ExternalSecret) string {
+	if val, ok := secret.Spec.ProviderSpec["Key"]; ok && val != "" {
+		return val
+	}
+
+	return defaultSecretKey
+}
+
+func (p *VaultProvider) DeleteSecret(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret) error {
+	path := secretPath(secret)
+
+	if secret.Spec.RecoveryWindow > 0 {
+		if secret.Status.DeletionDate == nil {
+			if err := p.softDelete(ctx, path); err != nil {
+				if !isNotFound(err) {
+					return fmt.Errorf("failed to delete secret: %w", err)
+				}
+
+				reqLogger.Info(fmt.Sprintf("Secret %s not found, ignoring", path))
+				return nil
+			}
+
+			t := v1.NewTime(time.Now().Add(time.Duration(secret.Spec.RecoveryWindow) * 24 * time.Hour))
+			secret.Status.DeletionDate = &t
+		}
+
+		// keep the versions recoverable until the recovery window is over
+		if time.Now().Before(secret.Status.DeletionDate.Time) {
+			return fmt.Errorf("secret scheduled for deletion in %s", secret.Status.DeletionDate.Time.String())
+		}
+	}
+
+	// deleting the metadata destroys every version of the secret
+	if err := p.client.do(ctx, http.MethodDelete, fmt.Sprintf("%s/metadata/%s", p.MountPath, path), nil, nil); err != nil && !isNotFound(err) {
+		return fmt.Errorf("failed to destroy secret: %w", err)
+	}
+
+	return nil
+}
+
+// softDelete marks every version of a secret as deleted, they can still be undeleted until destroyed
+func (p *VaultProvider) softDelete(ctx context.Context, path string) error {
+	metadata := &kvMetadataResponse{}
+	if err := p.client.do(ctx, http.MethodGet, fmt.Sprintf("%s/metadata/%s", p.MountPath, path), nil, metadata); err != nil {
+		return err
+	}
+
+	versions := make([]int, 0, len(metadata.Data.Versions))
+	for version := range metadata.Data.Versions {
+		val, err := strconv.Atoi(version)
+		if err != nil {
+			return fmt.Errorf("parsing version %s: %w", version, err)
+		}
+		versions = append(versions, val)
+	}
+
+	return p.client.do(ctx, http.MethodPost, fmt.Sprintf("%s/delete/%s", p.MountPath, path), map[string][]int{
+		"versions": versions,
+	}, nil)
+}
+
+func (p *VaultProvider) CreateSecret(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, value string) error {
+	path := secretPath(secret)
+
+	body := map[string]interface{}{
+		"data": map[string]string{secretKey(secret): value},
+	}
+	if !secret.Spec.Overwrite {
+		// only write the secret if it does not exist yet
+		body["options"] = map[string]int{"cas": 0}
+	}
+
+	result := &kvWriteResponse{}
+	if err := p.client.do(ctx, http.MethodPost, fmt.Sprintf("%s/data/%s", p.MountPath, path), body, result); err != nil {
+		if isCheckAndSetFailure(err) {
+			return fmt.Errorf("secret %s already exists and overwrite is not set", path)
+		}
+
+		reqLogger.Error(err, "Failed to create Secret")
+		return err
+	}
+
+	secret.Status.Provider["Mount"] = p.MountPath
+	secret.Status.Provider["Path"] = path
+	secret.Status.Provider["Version"] = strconv.Itoa(result.Data.Version)
+
+	return nil
+}
+
+func (p *VaultProvider) GetSecretLastChangedDate(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret) (*time.Time, error) {
+	metadata := &kvMetadataResponse{}
+	if err := p.client.do(ctx, http.MethodGet, fmt.Sprintf("%s/metadata/%s", p.MountPath, secretPath(secret)), nil, metadata); err != nil {
+		return nil, fmt.Errorf("failed to describe secret: %w", err)
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, metadata.Data.UpdatedTime)
+	if err != nil {
+		return nil, fmt.Errorf("parsing updated time: %w", err)
+	}
+
+	return &t, nil
+}
+
+func (p *VaultProvider) UpdateSecret(ctx context.Context, reqLogger logr.Logger, secret *secretsv1alpha1.ExternalSecret, value string) error {
+	path := secretPath(secret)
+
+	result := &kvWriteResponse{}
+	if err := p.client.do(ctx, http.MethodPost, fmt.Sprintf("%s/data/%s", p.MountPath, path), map[string]interface{}{
+		"data": map[string]string{secretKey(secret): value},
+	}, result); err != nil {
+		return fmt.Errorf("failed to update secret: %w", err)
+	}
+
+	secret.Status.Provider["Path"] = path
+	secret.Status.Provider["Version"] = strconv.Itoa(result.Data.Version)
+
+	return nil
+}
@@ -0,0 +1,6 @@
+// Package version holds the version of the operator, set when building with
+// -ldflags "-X github.com/tiagoposse/secretsbeam-operator/internal/version.Version=<version>"
+package version
+
+// Version is the version of the operator, dev for builds without ldflags
+var Version = "dev"
@@ -0,0 +1,230 @@
+// Package conformance checks that a helper binary implements the exec plugin protocol. Helper authors
+// can call Run from their own tests, or run the tests of this package against their binary:
+//
+//	go test ./pkg/plugin/exec/conformance -args -helper=/path/to/helper -config=key=value,key=value
+package conformance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+	execv1 "github.com/tiagoposse/secretsbeam-operator/pkg/plugin/exec/v1"
+)
+
+// Options configures a conformance run
+type Options struct {
+	// Command invokes the helper under test
+	Command execv1.Command
+	// Config is sent to the helper as the config of the ExternalSecretProvider
+	Config map[string]string
+	// Namespace of the objects sent to the helper, defaults to conformance
+	Namespace string
+	// SecretName is the external name of the secret created by the run, defaults to a unique name
+	SecretName string
+}
+
+// state is carried between the checks of a run, the same way the operator keeps it in the object status
+type state struct {
+	opts   Options
+	secret execv1.Secret
+	access execv1.Access
+}
+
+type check struct {
+	name string
+	run  func(ctx context.Context, s *state) error
+}
+
+// checks run in order, each one depending on the previous ones
+var checks = []check{
+	{"init accepts the config", checkInit},
+	{"unknown operations fail with a message", checkUnknownOperation},
+	{"malformed requests fail with a message", checkMalformedRequest},
+	{"create-secret", checkCreateSecret},
+	{"get-secret-last-changed", checkGetSecretLastChanged},
+	{"update-secret", checkUpdateSecret},
+	{"create-access", checkCreateAccess},
+	{"update-access", checkUpdateAccess},
+	{"delete-access", checkDeleteAccess},
+	{"delete-secret", checkDeleteSecret},
+}
+
+// Run runs every check against the helper as a subtest, stopping at the first failure
+func Run(t *testing.T, opts Options) {
+	if opts.Namespace == "" {
+		opts.Namespace = "conformance"
+	}
+	if opts.SecretName == "" {
+		opts.SecretName = fmt.Sprintf("conformance-%d", time.Now().UnixNano())
+	}
+
+	s := &state{
+		opts: opts,
+		secret: execv1.Secret{
+			Namespace:    opts.Namespace,
+			Name:         "conformance",
+			ExternalName: opts.SecretName,
+			Provider:     map[string]string{},
+		},
+		access: execv1.Access{
+			Namespace: opts.Namespace,
+			Name:      "conformance",
+			Subjects: []secretsv1alpha1.SecretAccessSubject{
+				{ServiceAccount: &secretsv1alpha1.SecretAccessSubjectServiceAccount{Namespace: opts.Namespace, Name: "conformance"}},
+			},
+			Provider: map[string]string{},
+		},
+	}
+
+	for _, c := range checks {
+		c := c
+		if !t.Run(c.name, func(t *testing.T) {
+			if err := c.run(context.Background(), s); err != nil {
+				t.Fatal(err)
+			}
+		}) {
+			return
+		}
+	}
+}
+
+func (s *state) call(ctx context.Context, operation string, req *execv1.Request) (*execv1.Response, error) {
+	req.Config = s.opts.Config
+
+	return s.opts.Command.Run(ctx, operation, req)
+}
+
+// expectFailure checks that an invocation failed with a non zero status and an error message on stderr
+func expectFailure(err error) error {
+	if err == nil {
+		return fmt.Errorf("helper exited with status 0")
+	}
+
+	var execErr *execv1.Error
+	if !errors.As(err, &execErr) {
+		return fmt.Errorf("unexpected error: %w", err)
+	}
+	if execErr.TimedOut {
+		return err
+	}
+	if execErr.Stderr == "" {
+		return fmt.Errorf("helper exited with status %d without writing to stderr", execErr.ExitCode)
+	}
+
+	return nil
+}
+
+func checkInit(ctx context.Context, s *state) error {
+	_, err := s.call(ctx, execv1.OperationInit, &execv1.Request{})
+	return err
+}
+
+func checkUnknownOperation(ctx context.Context, s *state) error {
+	data, err := json.Marshal(&execv1.Request{Config: s.opts.Config})
+	if err != nil {
+		return err
+	}
+
+	_, _, err = s.opts.Command.RunRaw(ctx, "not-an-operation", data)
+	return expectFailure(err)
+}
+
+func checkMalformedRequest(ctx context.Context, s *state) error {
+	_, _, err := s.opts.Command.RunRaw(ctx, execv1.OperationCreateSecret, []byte("{"))
+	return expectFailure(err)
+}
+
+func mergeProvider(status map[string]string, provider map[string]string) {
+	for key, val := range provider {
+		if val == "" {
+			delete(status, key)
+		} else {
+			status[key] = val
+		}
+	}
+}
+
+func checkCreateSecret(ctx context.Context, s *state) error {
+	value := "conformance-value-1"
+	result, err := s.call(ctx, execv1.OperationCreateSecret, &execv1.Request{Secret: &s.secret, Value: &value})
+	if err != nil {
+		return err
+	}
+
+	mergeProvider(s.secret.Provider, result.Provider)
+	return nil
+}
+
+func checkGetSecretLastChanged(ctx context.Context, s *state) error {
+	result, err := s.call(ctx, execv1.OperationGetSecretLastChangedDate, &execv1.Request{Secret: &s.secret})
+	if err != nil {
+		return err
+	}
+
+	if result.LastChangedDate == nil || result.LastChangedDate.IsZero() {
+		return fmt.Errorf("lastChangedDate is not set")
+	}
+	if result.LastChangedDate.After(time.Now().Add(time.Minute)) {
+		return fmt.Errorf("lastChangedDate %s is in the future", result.LastChangedDate)
+	}
+
+	return nil
+}
+
+func checkUpdateSecret(ctx context.Context, s *state) error {
+	value := "conformance-value-2"
+	result, err := s.call(ctx, execv1.OperationUpdateSecret, &execv1.Request{Secret: &s.secret, Value: &value})
+	if err != nil {
+		return err
+	}
+
+	mergeProvider(s.secret.Provider, result.Provider)
+	return nil
+}
+
+func checkCreateAccess(ctx context.Context, s *state) error {
+	result, err := s.call(ctx, execv1.OperationCreateAccess, &execv1.Request{Secret: &s.secret, Access: &s.access})
+	if err != nil {
+		return err
+	}
+
+	mergeProvider(s.access.Provider, result.Provider)
+	return nil
+}
+
+func checkUpdateAccess(ctx context.Context, s *state) error {
+	s.access.Subjects = append(s.access.Subjects, secretsv1alpha1.SecretAccessSubject{
+		ServiceAccount: &secretsv1alpha1.SecretAccessSubjectServiceAccount{Namespace: s.opts.Namespace, Name: "conformance-2"},
+	})
+
+	result, err := s.call(ctx, execv1.OperationUpdateAccess, &execv1.Request{Secret: &s.secret, Access: &s.access})
+	if err != nil {
+		return err
+	}
+
+	mergeProvider(s.access.Provider, result.Provider)
+	return nil
+}
+
+func checkDeleteAccess(ctx context.Context, s *state) error {
+	_, err := s.call(ctx, execv1.OperationDeleteAccess, &execv1.Request{Access: &s.access})
+	return err
+}
+
+func checkDeleteSecret(ctx context.Context, s *state) error {
+	result, err := s.call(ctx, execv1.OperationDeleteSecret, &execv1.Request{Secret: &s.secret})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletionDate != nil && result.DeletionDate.Before(time.Now().Add(-time.Minute)) {
+		return fmt.Errorf("deletionDate %s is in the past", result.DeletionDate)
+	}
+
+	return nil
+}
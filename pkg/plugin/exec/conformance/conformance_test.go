@@ -0,0 +1,41 @@
+package conformance
+
+import (
+	"flag"
+	"strings"
+	"testing"
+	"time"
+
+	execv1 "github.com/tiagoposse/secretsbeam-operator/pkg/plugin/exec/v1"
+)
+
+var (
+	helper     = flag.String("helper", "", "path of the helper binary to check")
+	helperArgs = flag.String("helper-args", "", "whitespace separated arguments passed to the helper before the operation")
+	config     = flag.String("config", "", "comma separated key=value pairs sent as the provider config")
+	timeout    = flag.Duration("timeout", 30*time.Second, "timeout of every invocation")
+)
+
+func TestConformance(t *testing.T) {
+	if *helper == "" {
+		t.Skip("no helper given, run with -args -helper=/path/to/helper")
+	}
+
+	providerConfig := map[string]string{}
+	for _, pair := range strings.Split(*config, ",") {
+		if pair == "" {
+			continue
+		}
+
+		key, val, ok := strings.Cut(pair, "=")
+		if !ok {
+			t.Fatalf("invalid config entry %q, expected key=value", pair)
+		}
+		providerConfig[key] = val
+	}
+
+	Run(t, Options{
+		Command: execv1.Command{Path: *helper, Args: strings.Fields(*helperArgs), Timeout: *timeout},
+		Config:  providerConfig,
+	})
+}
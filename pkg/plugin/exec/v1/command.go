@@ -0,0 +1,140 @@
+package execv1
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// maxStderr is the amount of stderr kept from a helper, the end of the output is kept as it usually holds the error
+const maxStderr = 4096
+
+// Command invokes a helper binary
+type Command struct {
+	// Path of the helper binary
+	Path string
+	// Args are passed to the helper before the operation
+	Args []string
+	// Env is appended to the environment of the operator
+	Env []string
+	// Timeout bounds every invocation, zero means no timeout besides the one of the context
+	Timeout time.Duration
+}
+
+// Error is returned when a helper exits with a non zero status or is killed
+type Error struct {
+	Operation string
+	ExitCode  int
+	// Stderr holds the end of the error output of the helper
+	Stderr   string
+	TimedOut bool
+}
+
+func (e *Error) Error() string {
+	var msg string
+	if e.TimedOut {
+		msg = fmt.Sprintf("%s timed out", e.Operation)
+	} else {
+		msg = fmt.Sprintf("%s exited with status %d", e.Operation, e.ExitCode)
+	}
+
+	if e.Stderr != "" {
+		msg += ": " + e.Stderr
+	}
+
+	return msg
+}
+
+// Run invokes the helper for an operation, writing the request to its stdin and decoding the response from its stdout
+func (c *Command) Run(ctx context.Context, operation string, req *Request) (*Response, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling request: %w", err)
+	}
+
+	out, stderr, err := c.RunRaw(ctx, operation, data)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Response{}
+	if len(bytes.TrimSpace(out)) > 0 {
+		if err := json.Unmarshal(out, result); err != nil {
+			return nil, fmt.Errorf("unmarshalling %s response: %w (stderr: %s)", operation, err, stderr)
+		}
+	}
+
+	return result, nil
+}
+
+// RunRaw invokes the helper with an arbitrary input, returning its stdout and the end of its stderr
+func (c *Command) RunRaw(ctx context.Context, operation string, input []byte) ([]byte, string, error) {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	args := append(append([]string{}, c.Args...), operation)
+	cmd := exec.CommandContext(ctx, c.Path, args...)
+	cmd.Env = append(append(os.Environ(), c.Env...), ProtocolVersionEnv+"="+ProtocolVersion)
+	// do not wait forever on the pipes if the helper left children holding them
+	cmd.WaitDelay = time.Second
+
+	stdout := &bytes.Buffer{}
+	stderr := &tailBuffer{max: maxStderr}
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err := cmd.Run()
+	if err == nil {
+		return stdout.Bytes(), stderr.String(), nil
+	}
+
+	execErr := &Error{Operation: operation, ExitCode: -1, Stderr: stderr.String()}
+	if ctx.Err() != nil {
+		execErr.TimedOut = true
+		return nil, execErr.Stderr, execErr
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return nil, execErr.Stderr, fmt.Errorf("running %s: %w", c.Path, err)
+	}
+	execErr.ExitCode = exitErr.ExitCode()
+
+	return nil, execErr.Stderr, execErr
+}
+
+// tailBuffer keeps the last max bytes written to it
+type tailBuffer struct {
+	buf       []byte
+	max       int
+	truncated bool
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.max {
+		t.buf = t.buf[len(t.buf)-t.max:]
+		t.truncated = true
+	}
+
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	out := strings.TrimSpace(string(t.buf))
+	if t.truncated {
+		out = "..." + out
+	}
+
+	return out
+}
@@ -0,0 +1,86 @@
+// Package execv1 defines version 1 of the exec plugin protocol. Exec plugins are binaries shipped
+// in the operator image, invoked once per operation with a JSON request on stdin and a JSON response
+// on stdout, in the manner of docker credential helpers. See docs/providers/exec.md for the full specification.
+package execv1
+
+import (
+	"time"
+
+	secretsv1alpha1 "github.com/tiagoposse/secretsbeam-operator/api/v1alpha1"
+)
+
+// ProtocolVersion is the version of the protocol, it is passed to helpers in the ProtocolVersionEnv variable
+const (
+	ProtocolVersion    = "v1"
+	ProtocolVersionEnv = "SECRETSBEAM_PROTOCOL_VERSION"
+)
+
+// Operations are passed to helpers as their last argument
+const (
+	OperationInit                     = "init"
+	OperationCreateSecret             = "create-secret"
+	OperationUpdateSecret             = "update-secret"
+	OperationDeleteSecret             = "delete-secret"
+	OperationGetSecretLastChangedDate = "get-secret-last-changed"
+	OperationCreateAccess             = "create-access"
+	OperationUpdateAccess             = "update-access"
+	OperationDeleteAccess             = "delete-access"
+)
+
+// Operations lists every operation of the protocol
+var Operations = []string{
+	OperationInit,
+	OperationCreateSecret,
+	OperationUpdateSecret,
+	OperationDeleteSecret,
+	OperationGetSecretLastChangedDate,
+	OperationCreateAccess,
+	OperationUpdateAccess,
+	OperationDeleteAccess,
+}
+
+// Secret is the representation of an ExternalSecret sent to the helper
+type Secret struct {
+	Namespace      string            `json:"namespace"`
+	Name           string            `json:"name"`
+	ExternalName   string            `json:"externalName"`
+	RecoveryWindow int64             `json:"recoveryWindow,omitempty"`
+	Overwrite      bool              `json:"overwrite,omitempty"`
+	ProviderSpec   map[string]string `json:"providerSpec,omitempty"`
+	// Provider is the provider state previously returned for this secret
+	Provider map[string]string `json:"provider,omitempty"`
+}
+
+// Access is the representation of an ExternalSecretAccess sent to the helper
+type Access struct {
+	Namespace string                                `json:"namespace"`
+	Name      string                                `json:"name"`
+	Subjects  []secretsv1alpha1.SecretAccessSubject `json:"subjects,omitempty"`
+	// Provider is the provider state previously returned for this access
+	Provider map[string]string `json:"provider,omitempty"`
+}
+
+// Request is written to the stdin of the helper. Helpers are not kept running between operations,
+// so the config of the ExternalSecretProvider is sent with every request.
+type Request struct {
+	Config map[string]string `json:"config,omitempty"`
+	// Secret is set on the secret operations and on create and update access
+	Secret *Secret `json:"secret,omitempty"`
+	// Access is set on the access operations
+	Access *Access `json:"access,omitempty"`
+	// Value is only set on create and update secret
+	Value *string `json:"value,omitempty"`
+}
+
+// Response is written by the helper to its stdout when it exits with status 0, an empty output is an empty response
+type Response struct {
+	// Provider entries are merged into the provider state of the object, empty values remove the entry
+	Provider map[string]string `json:"provider,omitempty"`
+	// DeletionDate is set by delete-secret when the secret is scheduled for deletion
+	DeletionDate *time.Time `json:"deletionDate,omitempty"`
+	// LastChangedDate is set by get-secret-last-changed
+	LastChangedDate *time.Time `json:"lastChangedDate,omitempty"`
+	// Capabilities are set by init to the features the helper supports, RecoveryWindow and
+	// AccessControl. The operator does not invoke the helper for the others.
+	Capabilities []string `json:"capabilities,omitempty"`
+}
@@ -0,0 +1,26 @@
+package pluginv1
+
+import (
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// ProtocolVersion is the version of the plugin protocol defined in provider.proto,
+// plugins must select it in their Handshake response
+const ProtocolVersion = "v1"
+
+// ServiceName is the name of the Provider service, the operator checks its status with the gRPC health service
+var ServiceName = Provider_ServiceDesc.ServiceName
+
+// Register registers a plugin implementation on a server along with a health service reporting it
+// as serving. The returned health server can be used by the plugin to report itself as not serving.
+func Register(s *grpc.Server, impl ProviderServer) *health.Server {
+	RegisterProviderServer(s, impl)
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(s, healthServer)
+
+	return healthServer
+}